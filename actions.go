@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// githubActions is set by the -github-actions global flag: reports are also
+// added to the job summary and threshold violations become annotations
+var githubActions bool
+
+// stepSummaryEnv names the job summary file GitHub Actions sets for each step
+const stepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// checkGitHubActions checks that the job summary can be written, so a
+// misconfigured workflow fails before anything is fetched
+func checkGitHubActions() error {
+	path := os.Getenv(stepSummaryEnv)
+	if path == "" {
+		return fmt.Errorf("-github-actions needs %s, which GitHub Actions sets for every step", stepSummaryEnv)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening the job summary: %w", err)
+	}
+	return f.Close()
+}
+
+// writeStepSummary appends the text report to the job summary, as markdown
+// under the title
+func writeStepSummary(title, report string) error {
+	f, err := os.OpenFile(os.Getenv(stepSummaryEnv), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing the job summary: %w", err)
+	}
+	if _, err := io.WriteString(f, reportMarkdown(title, report)); err != nil {
+		f.Close()
+		return fmt.Errorf("writing the job summary: %w", err)
+	}
+	return f.Close()
+}
+
+// reportMarkdown converts a text report to markdown. Markdown tables are kept
+// as they are; the text between them, and tables of the other styles, go in
+// code blocks so their alignment survives.
+func reportMarkdown(title, report string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", title)
+
+	var text []string
+	flush := func() {
+		// Blank lines around the text are left out of its code block
+		for len(text) > 0 && strings.TrimSpace(text[0]) == "" {
+			text = text[1:]
+		}
+		for len(text) > 0 && strings.TrimSpace(text[len(text)-1]) == "" {
+			text = text[:len(text)-1]
+		}
+		if len(text) > 0 {
+			fmt.Fprintf(&b, "```text\n%s\n```\n\n", strings.Join(text, "\n"))
+		}
+		text = nil
+	}
+
+	lines := strings.Split(strings.TrimRight(report, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if !isMarkdownTableStart(lines, i) {
+			text = append(text, lines[i])
+			continue
+		}
+		flush()
+		for ; i < len(lines) && strings.HasPrefix(lines[i], "|"); i++ {
+			fmt.Fprintln(&b, lines[i])
+		}
+		fmt.Fprintln(&b)
+		i--
+	}
+	flush()
+	return b.String()
+}
+
+// isMarkdownTableStart reports whether a markdown table, a header row
+// followed by a delimiter row, starts at line i
+func isMarkdownTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.HasPrefix(lines[i], "| ") && strings.HasPrefix(lines[i+1], "| -")
+}
+
+// writeAnnotation writes a workflow command that makes GitHub Actions show
+// the message as an annotation of the run. level is notice, warning or error.
+func writeAnnotation(w io.Writer, level, title, message string) {
+	fmt.Fprintf(w, "::%s title=%s::%s\n", level, escapeAnnotationProperty(title), escapeAnnotationData(message))
+}
+
+// escapeAnnotationData escapes the message of a workflow command
+func escapeAnnotationData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeAnnotationProperty escapes a property value of a workflow command
+func escapeAnnotationProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// annotateSLABreaches reports every security issue open past its SLA as an
+// error annotation
+func annotateSLABreaches(w io.Writer, report *SecurityReport) {
+	for _, si := range report.Breached {
+		writeAnnotation(w, "error", fmt.Sprintf("%s SLA breached", si.Key),
+			fmt.Sprintf("%s (%s) open %d days, %d over its %d-day SLA: %s",
+				si.Key, si.Severity, si.AgeDays, si.AgeDays-si.SLADays, si.SLADays, si.Summary))
+	}
+}
+
+// annotateFieldChanges reports the changes of the Jira fields theia reads as
+// warning annotations
+func annotateFieldChanges(w io.Writer, changes []string) {
+	for _, change := range changes {
+		writeAnnotation(w, "warning", "Jira fields changed", change)
+	}
+}
+
+// annotateMissingFields reports the Jira fields missing from the search
+// results as warning annotations
+func annotateMissingFields(w io.Writer, missing []string) {
+	for _, m := range missing {
+		writeAnnotation(w, "warning", "Jira fields missing", m)
+	}
+}
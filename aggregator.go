@@ -0,0 +1,186 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Dimension groups issues into named groups, e.g. by team or by month
+type Dimension struct {
+	Name string
+	// Group returns the group of the issue, or "" to leave it out of this dimension
+	Group func(issue Issue) string
+}
+
+// Group is the per-category analysis of the issues in one group of a dimension
+type Group struct {
+	Name          string
+	Analysis      map[string]*TicketAnalysis
+	ZeroManaCount int
+}
+
+// Aggregator accumulates issue counts and mana per category, overall and for
+// every group of each dimension the issues are added with
+type Aggregator struct {
+	Overall    map[string]*TicketAnalysis
+	dimensions map[string][]*Group
+}
+
+// NewAggregator returns an empty aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		Overall:    make(map[string]*TicketAnalysis),
+		dimensions: make(map[string][]*Group),
+	}
+}
+
+// group returns the named group of the dimension, creating it if needed
+func (a *Aggregator) group(dimension, name string) *Group {
+	for _, g := range a.dimensions[dimension] {
+		if g.Name == name {
+			return g
+		}
+	}
+	g := &Group{
+		Name:     name,
+		Analysis: make(map[string]*TicketAnalysis),
+	}
+	a.dimensions[dimension] = append(a.dimensions[dimension], g)
+	return g
+}
+
+// AddGroups creates empty groups for the dimension in the given order, so
+// they are reported even if no issue falls into them
+func (a *Aggregator) AddGroups(dimension string, names ...string) {
+	for _, name := range names {
+		a.group(dimension, name)
+	}
+}
+
+// Groups returns the groups of the dimension in the order they were created
+func (a *Aggregator) Groups(dimension string) []*Group {
+	return a.dimensions[dimension]
+}
+
+// Add records an issue of the category with the mana spent on it, in the
+// overall analysis and in its group of every given dimension
+func (a *Aggregator) Add(issue Issue, category string, mana float64, dimensions ...Dimension) {
+	addToAnalysis(a.Overall, category, mana)
+	for _, d := range dimensions {
+		name := d.Group(issue)
+		if name == "" {
+			continue
+		}
+		g := a.group(d.Name, name)
+		addToAnalysis(g.Analysis, category, mana)
+		if mana == 0 {
+			g.ZeroManaCount++
+		}
+	}
+}
+
+// ZeroManaCount returns the number of issues without mana across all categories
+func (a *Aggregator) ZeroManaCount() int {
+	var count int
+	for _, ta := range a.Overall {
+		count += ta.ZeroManaCount
+	}
+	return count
+}
+
+// Scale multiplies every count and total by factor, used to scale sampled
+// results up to the full population. Mana values are left as sampled.
+func (a *Aggregator) Scale(factor float64) {
+	scaleAnalysis(a.Overall, factor)
+	for _, groups := range a.dimensions {
+		for _, g := range groups {
+			scaleAnalysis(g.Analysis, factor)
+			g.ZeroManaCount = int(math.Round(float64(g.ZeroManaCount) * factor))
+		}
+	}
+}
+
+// addToAnalysis records one issue of the category in the analysis map
+func addToAnalysis(analysis map[string]*TicketAnalysis, category string, mana float64) {
+	ta, exists := analysis[category]
+	if !exists {
+		ta = &TicketAnalysis{
+			IssueType:  category,
+			ManaValues: make([]float64, 0),
+		}
+		analysis[category] = ta
+	}
+	ta.Count++
+	ta.TotalMana += mana
+	ta.ManaValues = append(ta.ManaValues, mana)
+	if mana == 0 {
+		ta.ZeroManaCount++
+	}
+}
+
+// teamDimension groups issues by their Team field
+var teamDimension = Dimension{
+	Name: "team",
+	Group: func(issue Issue) string {
+		if issue.Team == "" {
+			return "No Team"
+		}
+		return issue.Team
+	},
+}
+
+// orgChartDimensions group issues by the org chart group of their team and
+// by the org of that group
+func orgChartDimensions(chart []OrgGroup) (group, org Dimension) {
+	group = Dimension{
+		Name: "group",
+		Group: func(issue Issue) string {
+			if g := findOrgGroup(chart, issue.Team); g != nil {
+				return g.Name
+			}
+			return "No Group"
+		},
+	}
+	org = Dimension{
+		Name: "org",
+		Group: func(issue Issue) string {
+			if g := findOrgGroup(chart, issue.Team); g != nil {
+				return g.Org
+			}
+			return "No Org"
+		},
+	}
+	return group, org
+}
+
+// monthLabelFormat is the format of the month group names
+const monthLabelFormat = "January 2006"
+
+// monthsInRange returns the first day of every month from start to end
+func monthsInRange(start, end time.Time) []time.Time {
+	var months []time.Time
+	current := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	endMonth := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, end.Location())
+	for !current.After(endMonth) {
+		months = append(months, current)
+		current = current.AddDate(0, 1, 0)
+	}
+	return months
+}
+
+// monthDimension groups issues by resolution month, leaving out issues
+// resolved outside the months of the range
+func monthDimension(months []time.Time) Dimension {
+	return Dimension{
+		Name: "month",
+		Group: func(issue Issue) string {
+			for _, m := range months {
+				mEnd := m.AddDate(0, 1, 0)
+				if !issue.Resolved.Before(m) && issue.Resolved.Before(mEnd) {
+					return m.Format(monthLabelFormat)
+				}
+			}
+			return ""
+		},
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names of a report archive's index
+const (
+	archiveIndexJSON = "index.json"
+	archiveIndexHTML = "index.html"
+)
+
+// archiveGenerator marks the index.json files theia maintains, so other
+// files of that name are left alone
+const archiveGenerator = "theia"
+
+// archiveFormats are the formats of the files listed in an archive index,
+// by extension
+var archiveFormats = map[string]string{
+	".txt":  "text",
+	".pdf":  "pdf",
+	".json": "json",
+	".md":   "markdown",
+	".csv":  "csv",
+	".ics":  "calendar",
+}
+
+// ArchiveEntry is one report of an archive index
+type ArchiveEntry struct {
+	File    string    `json:"file"` // Path relative to the archive, with / separators
+	Title   string    `json:"title"`
+	Project string    `json:"project,omitempty"`
+	Period  string    `json:"period,omitempty"` // e.g. "2024-01-01 to 2024-03-31", "2024Q1" or an as-of date
+	Start   string    `json:"start,omitempty"`  // First day of the period, YYYY-MM-DD
+	Format  string    `json:"format"`
+	Size    int64     `json:"size"`
+	Written time.Time `json:"written"`
+}
+
+// ArchiveIndex lists the reports of an archive directory, as stored in its
+// index.json
+type ArchiveIndex struct {
+	Generator string         `json:"generator"`
+	Title     string         `json:"title"`
+	Updated   time.Time      `json:"updated"`
+	Reports   []ArchiveEntry `json:"reports"`
+}
+
+// Shapes of report titles: "PROJ-2024Q1 Mana Analysis" from close-quarter,
+// and titles ending in a range, a date or a month
+var (
+	quarterTitle = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)-(\d{4}Q[1-4])[ -](.+)$`)
+	rangeTitle   = regexp.MustCompile(`^(.*) (\d{4}-\d{2}-\d{2}) (?:to|vs) (\d{4}-\d{2}-\d{2})$`)
+	dateTitle    = regexp.MustCompile(`^(.*) (\d{4}-\d{2}-\d{2})$`)
+	monthTitle   = regexp.MustCompile(`^(.*) (\d{4}-\d{2})$`)
+	projectKeys  = regexp.MustCompile(`^[A-Z][A-Z0-9_]*(,[A-Z][A-Z0-9_]*)*$`)
+)
+
+// parseReportTitle returns the project and period of a report from its
+// title, as the report commands build them, e.g. "PROJ Mana Analysis
+// 2024-01-01 to 2024-03-31". Parts a title does not have are "".
+func parseReportTitle(title string) (project, period, start string) {
+	if m := quarterTitle.FindStringSubmatch(title); m != nil {
+		if from, _, err := parseQuarter(m[2]); err == nil {
+			start = from.Format("2006-01-02")
+		}
+		return m[1], m[2], start
+	}
+	rest := title
+	switch {
+	case rangeTitle.MatchString(title):
+		m := rangeTitle.FindStringSubmatch(title)
+		rest, period, start = m[1], m[2]+" to "+m[3], m[2]
+	case dateTitle.MatchString(title):
+		m := dateTitle.FindStringSubmatch(title)
+		rest, period, start = m[1], m[2], m[2]
+	case monthTitle.MatchString(title):
+		m := monthTitle.FindStringSubmatch(title)
+		rest, period, start = m[1], m[2], m[2]+"-01"
+	}
+	if first, _, ok := strings.Cut(rest, " "); ok && projectKeys.MatchString(first) {
+		project = first
+	}
+	return project, period, start
+}
+
+// newArchiveEntry describes the report file at path of the archive in dir
+func newArchiveEntry(dir, path, title string) (ArchiveEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ArchiveEntry{}, err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ArchiveEntry{}, err
+	}
+	e := ArchiveEntry{
+		File:    filepath.ToSlash(rel),
+		Title:   title,
+		Format:  archiveFormats[strings.ToLower(filepath.Ext(path))],
+		Size:    info.Size(),
+		Written: info.ModTime().UTC().Truncate(time.Second),
+	}
+	e.Project, e.Period, e.Start = parseReportTitle(title)
+	return e, nil
+}
+
+// reportFileTitle returns the title of a report file found in an archive:
+// that of a JSON ticket report from its project and period, else the file
+// name without its extension
+func reportFileTitle(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		return name
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return name
+	}
+	var doc struct {
+		SchemaVersion int    `json:"schema_version"`
+		Project       string `json:"project"`
+		Start         string `json:"start"`
+		End           string `json:"end"`
+	}
+	if json.Unmarshal(b, &doc) != nil || doc.SchemaVersion == 0 || doc.Project == "" {
+		return name
+	}
+	return fmt.Sprintf("%s Mana Analysis %s to %s", doc.Project, doc.Start, doc.End)
+}
+
+// loadArchiveIndex reads the index of the archive in dir. It returns nil if
+// the directory has no index theia maintains.
+func loadArchiveIndex(dir string) (*ArchiveIndex, error) {
+	b, err := os.ReadFile(filepath.Join(dir, archiveIndexJSON))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := new(ArchiveIndex)
+	if err := json.Unmarshal(b, index); err != nil || index.Generator != archiveGenerator {
+		return nil, nil
+	}
+	return index, nil
+}
+
+// findArchive returns the directory of the archive the path is in: the
+// nearest of its directories with an index theia maintains, or "" if none
+func findArchive(path string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	for {
+		index, err := loadArchiveIndex(dir)
+		if err != nil {
+			return "", err
+		}
+		if index != nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// scanArchive lists the report files of the archive in dir, keeping the
+// titles the index already has
+func scanArchive(dir string, index *ArchiveIndex) error {
+	known := make(map[string]ArchiveEntry)
+	for _, e := range index.Reports {
+		known[e.File] = e
+	}
+	index.Reports = nil
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if path != dir && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || archiveFormats[strings.ToLower(filepath.Ext(name))] == "" {
+			return nil
+		}
+		if filepath.Dir(path) == dir && (name == archiveIndexJSON || name == archiveIndexHTML) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		title := reportFileTitle(path)
+		if e, ok := known[filepath.ToSlash(rel)]; ok {
+			title = e.Title
+		}
+		e, err := newArchiveEntry(dir, path, title)
+		if err != nil {
+			return err
+		}
+		index.Reports = append(index.Reports, e)
+		return nil
+	})
+}
+
+// sortArchive orders the reports by project, those without one last, then
+// latest period first and by title
+func sortArchive(reports []ArchiveEntry) {
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if (a.Project == "") != (b.Project == "") {
+			return b.Project == ""
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Start != b.Start {
+			return a.Start > b.Start
+		}
+		return a.Title < b.Title
+	})
+}
+
+// archivePageTemplate renders index.html, one table per project
+var archivePageTemplate = template.Must(template.New("index").Funcs(template.FuncMap{"kb": archiveSize}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.3em 1em 0.3em 0; text-align: left; }
+td.size { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Index.Reports}} reports, updated {{.Index.Updated.Format "2006-01-02 15:04 MST"}}. Also available as <a href="index.json">index.json</a>.</p>
+{{range .Projects}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Period</th><th>Report</th><th>Format</th><th>Size</th><th>Written</th></tr>
+{{range .Reports}}<tr><td>{{if .Period}}{{.Period}}{{else}}-{{end}}</td><td><a href="{{.File}}">{{.Title}}</a></td><td>{{.Format}}</td><td class="size">{{kb .Size}}</td><td>{{.Written.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// archiveSize formats a file size for index.html
+func archiveSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/1024)
+}
+
+// writeArchiveIndex writes index.json and index.html of the archive in dir
+func writeArchiveIndex(dir string, index *ArchiveIndex) error {
+	index.Updated = time.Now().UTC().Truncate(time.Second)
+	indexJSON, page, err := renderArchiveIndex(index)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, archiveIndexJSON), indexJSON, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, archiveIndexHTML), page, 0o644)
+}
+
+// renderArchiveIndex sorts the reports of the index and renders its
+// index.json and index.html
+func renderArchiveIndex(index *ArchiveIndex) (indexJSON, page []byte, err error) {
+	index.Generator = archiveGenerator
+	if index.Reports == nil {
+		index.Reports = []ArchiveEntry{}
+	}
+	sortArchive(index.Reports)
+	if indexJSON, err = json.MarshalIndent(index, "", "  "); err != nil {
+		return nil, nil, err
+	}
+
+	type project struct {
+		Name    string
+		Reports []ArchiveEntry
+	}
+	var projects []project
+	for _, e := range index.Reports {
+		name := e.Project
+		if name == "" {
+			name = "Other Reports"
+		}
+		if len(projects) == 0 || projects[len(projects)-1].Name != name {
+			projects = append(projects, project{Name: name})
+		}
+		projects[len(projects)-1].Reports = append(projects[len(projects)-1].Reports, e)
+	}
+	var buf bytes.Buffer
+	err = archivePageTemplate.Execute(&buf, struct {
+		Title    string
+		Index    *ArchiveIndex
+		Projects []project
+	}{index.Title, index, projects})
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(indexJSON, '\n'), buf.Bytes(), nil
+}
+
+// buildArchiveIndex scans the archive in dir and writes its index, keeping
+// the titles of an existing index
+func buildArchiveIndex(dir, title string) (*ArchiveIndex, error) {
+	index, err := loadArchiveIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		index = &ArchiveIndex{Title: "theia reports"}
+	}
+	if title != "" {
+		index.Title = title
+	}
+	if err := scanArchive(dir, index); err != nil {
+		return nil, err
+	}
+	return index, writeArchiveIndex(dir, index)
+}
+
+// recordArchiveReport adds a report just written to the index of the
+// archive it is in, if any. The report is written either way, so failures
+// are warnings.
+func recordArchiveReport(path, title string) {
+	dir, err := findArchive(path)
+	if err == nil && dir != "" {
+		err = addArchiveReport(dir, path, title)
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not update the report archive index: %v\n", err)
+	}
+}
+
+// addArchiveReport adds or updates the report at path in the index of the
+// archive in dir
+func addArchiveReport(dir, path, title string) error {
+	index, err := loadArchiveIndex(dir)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	e, err := newArchiveEntry(dir, abs, title)
+	if err != nil {
+		return err
+	}
+	reports := index.Reports[:0]
+	for _, r := range index.Reports {
+		if r.File != e.File {
+			reports = append(reports, r)
+		}
+	}
+	index.Reports = append(reports, e)
+	return writeArchiveIndex(dir, index)
+}
+
+func runIndexCommand() {
+	// Command line flags
+	dir := flag.String("dir", "", "Directory of the report archive to index")
+	title := flag.String("title", "", "Title of index.html (default theia reports, or the title the index has)")
+	flag.Parse()
+
+	// Validate flags
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if info, err := os.Stat(*dir); err != nil || !info.IsDir() {
+		log.Fatalf("-dir %s is not a directory", *dir)
+	}
+
+	index, err := buildArchiveIndex(*dir, *title)
+	if err != nil {
+		log.Fatalf("Error indexing reports: %v", err)
+	}
+	fmt.Printf("Indexed %d reports in %s and %s\n", len(index.Reports),
+		filepath.Join(*dir, archiveIndexHTML), filepath.Join(*dir, archiveIndexJSON))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writePolicy controls the requests that change data in Jira. It is set
+// from the global flags before the subcommand runs.
+type writePolicy struct {
+	ReadOnly bool   // Refuse every write
+	AuditLog string // File every write is appended to, empty for none
+}
+
+// jiraWrites is the write policy of the Jira clients of this run
+var jiraWrites writePolicy
+
+// errReadOnly is returned for writes refused in read-only mode
+var errReadOnly = errors.New("theia is running read-only")
+
+// readRequestPaths are the POST endpoints that only read data, by path
+// suffix, such as JQL validation
+var readRequestPaths = []string{"/jql/parse", "/search"}
+
+// parseGlobalFlags parses the flags given before the subcommand and removes
+// them from os.Args. THEIA_READ_ONLY and THEIA_AUDIT_LOG set the defaults;
+// read-only mode set in the environment cannot be turned off by a flag.
+func parseGlobalFlags() error {
+	envReadOnly := false
+	if v := os.Getenv("THEIA_READ_ONLY"); v != "" {
+		var err error
+		if envReadOnly, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid THEIA_READ_ONLY %q: %w", v, err)
+		}
+	}
+
+	fs := flag.NewFlagSet("theia", flag.ContinueOnError)
+	readOnly := fs.Bool("read-only", false, "Refuse every request that would change data in Jira")
+	auditLog := fs.String("audit-log", os.Getenv("THEIA_AUDIT_LOG"), "Append every write to Jira to this file, as JSON lines")
+	fs.BoolVar(&assumeYes, "yes", false, "Answer yes to confirmation prompts, such as for date ranges over two years")
+	fs.BoolVar(&githubActions, "github-actions", false, "Add reports to the GitHub Actions job summary and annotate threshold violations")
+	localeFile := fs.String("locale-file", os.Getenv("THEIA_LOCALE_FILE"), "JSON file mapping the localized Mana Spent options and issue type names of a non-English Jira to English")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	os.Args = append(os.Args[:1], fs.Args()...)
+	if githubActions {
+		if err := checkGitHubActions(); err != nil {
+			return err
+		}
+	}
+
+	if *localeFile != "" {
+		var err error
+		if jiraLabels, err = loadLocaleFile(*localeFile); err != nil {
+			return err
+		}
+	}
+
+	jiraWrites = writePolicy{ReadOnly: *readOnly || envReadOnly, AuditLog: *auditLog}
+	return nil
+}
+
+// isJiraWrite reports whether the request changes data in Jira
+func isJiraWrite(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	case http.MethodPost:
+		for _, suffix := range readRequestPaths {
+			if strings.HasSuffix(req.URL.Path, suffix) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Time    string          `json:"time"` // RFC 3339 in UTC
+	User    string          `json:"user"`
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Blocked bool            `json:"blocked,omitempty"` // Refused in read-only mode
+}
+
+// auditLog appends entries to the audit log file
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openAuditLog opens the audit log for appending, creating it if needed
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return &auditLog{file: f}, nil
+}
+
+// write appends the entry as one line
+func (l *auditLog) write(entry auditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// writeGuard is the transport of the Jira client that enforces the write
+// policy: it refuses writes in read-only mode and records every write, done
+// or refused, in the audit log
+type writeGuard struct {
+	base     http.RoundTripper
+	policy   writePolicy
+	user     string
+	auditLog *auditLog // Nil without an audit log
+}
+
+// newWriteGuard wraps the transport in the write policy, opening its audit
+// log so a log that cannot be written fails before any request is made
+func newWriteGuard(base http.RoundTripper, policy writePolicy, user string) (*writeGuard, error) {
+	g := &writeGuard{base: base, policy: policy, user: user}
+	if policy.AuditLog != "" {
+		var err error
+		if g.auditLog, err = openAuditLog(policy.AuditLog); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func (g *writeGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isJiraWrite(req) {
+		return g.base.RoundTrip(req)
+	}
+
+	entry := auditEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		User:   g.user,
+		Method: req.Method,
+		URL:    req.URL.Redacted(),
+		Body:   requestBody(req),
+	}
+	if g.policy.ReadOnly {
+		entry.Blocked = true
+		g.audit(entry)
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w, refusing %s %s", errReadOnly, req.Method, req.URL.Path)
+	}
+
+	resp, err := g.base.RoundTrip(req)
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+	}
+	g.audit(entry)
+	return resp, err
+}
+
+// audit writes the entry to the audit log, if any. A write that was done but
+// could not be logged is reported, as it cannot be undone.
+func (g *writeGuard) audit(entry auditEntry) {
+	if g.auditLog == nil {
+		return
+	}
+	if err := g.auditLog.write(entry); err != nil {
+		fmt.Printf("Warning: could not write audit log entry for %s %s: %v\n", entry.Method, entry.URL, err)
+	}
+}
+
+// requestBody returns a copy of the request's JSON body, or nil if it has
+// none or it isn't JSON
+func requestBody(req *http.Request) json.RawMessage {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	raw, err := io.ReadAll(body)
+	if err != nil || !json.Valid(raw) {
+		return nil
+	}
+	return raw
+}
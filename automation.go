@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// AutomationExclusion is what the issues reported by automation accounts,
+// left out of the analysis, amounted to
+type AutomationExclusion struct {
+	Issues    int
+	Mana      float64
+	Reporters map[string]int // Issues per reporter, by display name
+}
+
+// add counts an excluded issue
+func (a *AutomationExclusion) add(issue Issue) {
+	a.Issues++
+	a.Mana += issue.Mana
+	name := issue.ReporterName
+	if name == "" {
+		name = issue.Reporter
+	}
+	a.Reporters[name]++
+}
+
+// parseAutomationReporters returns the reporters whose issues are left out,
+// lower-cased, from the config and the comma-separated -exclude-reporters
+func parseAutomationReporters(configured []string, list string) map[string]bool {
+	reporters := make(map[string]bool)
+	for _, r := range append(configured, strings.Split(list, ",")...) {
+		if r = strings.TrimSpace(r); r != "" {
+			reporters[strings.ToLower(r)] = true
+		}
+	}
+	return reporters
+}
+
+// withoutAutomation returns the issues no automation account reported and
+// how many were left out
+func withoutAutomation(issues []Issue, reporters map[string]bool) ([]Issue, int) {
+	if len(reporters) == 0 {
+		return issues, 0
+	}
+	opts := classifyOptions{AutomationReporters: reporters}
+	kept := make([]Issue, 0, len(issues))
+	for _, issue := range issues {
+		if !opts.isAutomation(issue) {
+			kept = append(kept, issue)
+		}
+	}
+	return kept, len(issues) - len(kept)
+}
+
+// dropAutomationIssues leaves out the issues the config's automation
+// reporters reported, for the reports that don't classify issues, and notes
+// how many to w
+func dropAutomationIssues(w io.Writer, issues []Issue, config *Config) []Issue {
+	kept, dropped := withoutAutomation(issues, parseAutomationReporters(config.AutomationReporters, ""))
+	if dropped > 0 {
+		fmt.Fprintf(w, "Automation tickets left out: %d issues\n", dropped)
+	}
+	return kept
+}
+
+// dropAutomationChildren leaves out the child tickets the config's
+// automation reporters reported from every epic, and notes how many to w
+func dropAutomationChildren(w io.Writer, children map[string][]Issue, config *Config) {
+	reporters := parseAutomationReporters(config.AutomationReporters, "")
+	total := 0
+	for epic, issues := range children {
+		kept, dropped := withoutAutomation(issues, reporters)
+		children[epic] = kept
+		total += dropped
+	}
+	if total > 0 {
+		fmt.Fprintf(w, "Automation tickets left out: %d child tickets\n", total)
+	}
+}
+
+// checkAutomationReporters checks the automationReporters of a config file
+func checkAutomationReporters(reporters []string) error {
+	for _, r := range reporters {
+		if strings.TrimSpace(r) == "" {
+			return fmt.Errorf("automationReporters must not be empty")
+		}
+	}
+	return nil
+}
+
+// writeAutomationExclusion writes how many issues automation accounts
+// reported, most issues first
+func writeAutomationExclusion(w io.Writer, a *AutomationExclusion, layout tableOptions) {
+	names := make([]string, 0, len(a.Reporters))
+	for name := range a.Reporters {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if a.Reporters[names[i]] != a.Reporters[names[j]] {
+			return a.Reporters[names[i]] > a.Reporters[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	by := ""
+	for i, name := range names {
+		if i == 0 {
+			by = ", reported by "
+		} else {
+			by += ", "
+		}
+		by += fmt.Sprintf("%s (%d)", name, a.Reporters[name])
+	}
+	fmt.Fprintf(w, "  Automation Tickets Excluded: %s issues with %s%s\n",
+		layout.Numbers.count(a.Issues), layout.Numbers.manaAmount(a.Mana), by)
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// balanceFields are the fields the load balance report reads
+var balanceFields = []string{manaFieldID, "created", "resolutiondate", teamFieldID, "reporter"}
+
+// Defaults of the load balance report: a month is imbalanced when incoming
+// mana is 25% over or under resolved mana, and three such months in a row
+// are a sustained imbalance
+const (
+	defaultImbalance = 0.25
+	defaultSustained = 3
+)
+
+// MonthBalance is the incoming and resolved mana of a team in one month
+type MonthBalance struct {
+	Month    string
+	Incoming float64
+	Resolved float64
+}
+
+// TeamBalance is the incoming and resolved mana of one team over the period
+type TeamBalance struct {
+	Team      string
+	Incoming  float64
+	Estimated float64 // Part of Incoming estimated for tickets without Mana Spent
+	Resolved  float64
+	Months    []MonthBalance // Every month of the range
+	OverRun   int            // Longest run of months with more incoming than resolved mana
+	UnderRun  int            // Longest run of months with less incoming than resolved mana
+}
+
+// BalanceReport is the data model of the balance command
+type BalanceReport struct {
+	Project      string
+	Start        string
+	End          string
+	EndInclusive bool // Set if tickets created or resolved at any time on the end date count
+	IncomingJQL  string
+	ResolvedJQL  string
+	Imbalance    float64 // Share incoming mana must be over or under resolved mana
+	Sustained    int     // Months in a row that make an imbalance sustained
+	Months       []string
+	Teams        []TeamBalance // By team name, No Team for tickets without one
+}
+
+// createdBetween is the JQL clause selecting the issues created in the
+// range, bound like resolvedBetween
+func createdBetween(start, end time.Time, inclusive bool) string {
+	return strings.ReplaceAll(resolvedBetween(start, end, inclusive), "resolutiondate", "created")
+}
+
+// monthState tells whether the incoming mana of the month is over (1) or
+// under (-1) its resolved mana by more than the imbalance, or neither (0)
+func (r *BalanceReport) monthState(m MonthBalance) int {
+	switch {
+	case m.Incoming == 0 && m.Resolved == 0:
+		return 0
+	case m.Incoming > m.Resolved*(1+r.Imbalance):
+		return 1
+	case m.Incoming < m.Resolved*(1-r.Imbalance):
+		return -1
+	}
+	return 0
+}
+
+// signal returns the sustained imbalance of the team, the longer run if it
+// has both, or "" if it has none
+func (r *BalanceReport) signal(t TeamBalance) string {
+	switch {
+	case t.OverRun >= r.Sustained && t.OverRun >= t.UnderRun:
+		return fmt.Sprintf("Falling behind (%d months)", t.OverRun)
+	case t.UnderRun >= r.Sustained:
+		return fmt.Sprintf("Spare capacity (%d months)", t.UnderRun)
+	}
+	return ""
+}
+
+// analyzeBalance sums the mana of the incoming tickets per team and creation
+// month and that of the resolved tickets per team and resolution month.
+// Incoming tickets without Mana Spent, usually still open, count with the
+// average mana of the team's resolved tickets, or of all resolved tickets
+// if the team resolved none.
+func analyzeBalance(incoming, resolved []Issue, months []time.Time, imbalance float64, sustained int) *BalanceReport {
+	report := &BalanceReport{Imbalance: imbalance, Sustained: sustained}
+	for _, m := range months {
+		report.Months = append(report.Months, m.Format(monthLabelFormat))
+	}
+	monthIndex := func(t time.Time) int {
+		for i, m := range months {
+			if !t.Before(m) && t.Before(m.AddDate(0, 1, 0)) {
+				return i
+			}
+		}
+		return -1
+	}
+	teams := make(map[string]*TeamBalance)
+	teamOf := func(issue Issue) *TeamBalance {
+		name := teamDimension.Group(issue)
+		if teams[name] == nil {
+			teams[name] = &TeamBalance{Team: name, Months: make([]MonthBalance, len(months))}
+			for i := range months {
+				teams[name].Months[i].Month = report.Months[i]
+			}
+		}
+		return teams[name]
+	}
+
+	resolvedCount := make(map[string]int)
+	var allMana float64
+	for _, issue := range resolved {
+		t := teamOf(issue)
+		t.Resolved += issue.Mana
+		resolvedCount[t.Team]++
+		allMana += issue.Mana
+		if i := monthIndex(issue.Resolved); i >= 0 {
+			t.Months[i].Resolved += issue.Mana
+		}
+	}
+	for _, issue := range incoming {
+		t := teamOf(issue)
+		mana := issue.Mana
+		if !issue.ManaSet {
+			if resolvedCount[t.Team] > 0 {
+				mana = t.Resolved / float64(resolvedCount[t.Team])
+			} else {
+				mana = safeAverage(allMana, len(resolved))
+			}
+			t.Estimated += mana
+		}
+		t.Incoming += mana
+		if i := monthIndex(issue.Created); i >= 0 {
+			t.Months[i].Incoming += mana
+		}
+	}
+
+	for _, t := range teams {
+		over, under := 0, 0
+		for _, m := range t.Months {
+			switch report.monthState(m) {
+			case 1:
+				over, under = over+1, 0
+			case -1:
+				over, under = 0, under+1
+			default:
+				over, under = 0, 0
+			}
+			t.OverRun = max(t.OverRun, over)
+			t.UnderRun = max(t.UnderRun, under)
+		}
+		report.Teams = append(report.Teams, *t)
+	}
+	sort.Slice(report.Teams, func(i, j int) bool {
+		return report.Teams[i].Team < report.Teams[j].Team
+	})
+	return report
+}
+
+// writeBalanceReport writes the incoming and resolved mana per team, the net
+// incoming mana per team and month, and the sustained imbalances
+func writeBalanceReport(w io.Writer, report *BalanceReport, layout tableOptions) {
+	fmt.Fprintf(w, "\nLoad Balance Analysis Period (experimental): %s to %s\n", report.Start, report.End)
+	fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nIncoming JQL Query:\n%s\n", report.IncomingJQL)
+	fmt.Fprintf(w, "\nResolved JQL Query:\n%s\n", report.ResolvedJQL)
+
+	table := newTextTable(
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Incoming Mana", Right: true},
+		tableColumn{Header: "Estimated", Right: true},
+		tableColumn{Header: "Resolved Mana", Right: true},
+		tableColumn{Header: "Net Incoming", Right: true},
+		tableColumn{Header: "Incoming/Resolved", Right: true},
+		tableColumn{Header: "Signal"},
+	)
+	total := TeamBalance{Team: "TOTAL"}
+	cells := func(t TeamBalance, signal string) []string {
+		ratio := "-"
+		if t.Resolved > 0 {
+			ratio = fmt.Sprintf("%.2f", t.Incoming/t.Resolved)
+		}
+		if signal == "" {
+			signal = "-"
+		}
+		return []string{t.Team,
+			layout.Numbers.mana(t.Incoming),
+			layout.Numbers.mana(t.Estimated),
+			layout.Numbers.mana(t.Resolved),
+			signedMana(layout.Numbers.mana, t.Incoming-t.Resolved),
+			ratio,
+			signal}
+	}
+	for _, t := range report.Teams {
+		table.addRow(cells(t, report.signal(t))...)
+		total.Incoming += t.Incoming
+		total.Estimated += t.Estimated
+		total.Resolved += t.Resolved
+	}
+	table.addFooter(cells(total, "")...)
+	fmt.Fprintf(w, "\nIncoming and Resolved Mana by Team:\n")
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Incoming tickets were created in the period; those without Mana Spent are estimated at the team's average resolved ticket.")
+
+	columns := []tableColumn{{Header: "Team", MaxWidth: 30}}
+	for _, m := range report.Months {
+		month, _ := time.Parse(monthLabelFormat, m)
+		columns = append(columns, tableColumn{Header: month.Format("Jan 2006"), Right: true})
+	}
+	months := newTextTable(columns...)
+	for _, t := range report.Teams {
+		row := []string{t.Team}
+		for _, m := range t.Months {
+			cell := signedMana(layout.Numbers.mana, m.Incoming-m.Resolved)
+			switch report.monthState(m) {
+			case 1:
+				cell += " >"
+			case -1:
+				cell += " <"
+			}
+			row = append(row, cell)
+		}
+		months.addRow(row...)
+	}
+	fmt.Fprintf(w, "\nNet Incoming Mana by Team and Month:\n")
+	months.write(w, layout.Style)
+	fmt.Fprintf(w, "> marks months with over %.0f%% more incoming than resolved mana, < months with over %.0f%% less.\n", report.Imbalance*100, report.Imbalance*100)
+
+	// Staffing suggestions from the sustained imbalances
+	fmt.Fprintf(w, "\nSustained Imbalances (%d or more months in a row):\n", report.Sustained)
+	found := false
+	for _, t := range report.Teams {
+		signal := report.signal(t)
+		if signal == "" {
+			continue
+		}
+		found = true
+		perMonth := (t.Incoming - t.Resolved) / float64(len(report.Months))
+		if perMonth >= 0 {
+			fmt.Fprintf(w, "  %s: %s, receives %s a month more than it resolves\n", t.Team, signal, layout.Numbers.manaAmount(perMonth))
+		} else {
+			fmt.Fprintf(w, "  %s: %s, resolves %s a month more than it receives\n", t.Team, signal, layout.Numbers.manaAmount(-perMonth))
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "  None")
+	}
+	fmt.Fprintln(w, "A starting point for staffing discussions, not a verdict: incoming mana is partly estimated, and backlogs, priorities and team changes are not taken into account.")
+}
+
+func runBalanceCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	imbalance := flag.Float64("imbalance", defaultImbalance, "Share by which a month's incoming mana must exceed or fall short of resolved mana to count as imbalanced, e.g. 0.25")
+	sustained := flag.Int("sustained", defaultSustained, "Imbalanced months in a row that make a sustained imbalance")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *imbalance <= 0 || *imbalance >= 1 {
+		log.Fatal("-imbalance must be between 0 and 1")
+	}
+	if *sustained < 1 {
+		log.Fatal("-sustained must be at least 1")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, _, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The tickets of the ticket report, and the tickets created in the
+	// period that were not discarded
+	own := config.fieldsOf(*projectKey)
+	resolvedJQL := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, *endInclusive), own) + `
+		ORDER BY created DESC`
+	incomingJQL := fmt.Sprintf(`project = "%s" AND
+		%s AND
+		(resolution is EMPTY OR resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined")) AND
+		issuetype not in (Epic, Initiative)
+		ORDER BY created DESC`,
+		*projectKey,
+		createdBetween(start, end, *endInclusive))
+
+	// Validate the queries before fetching anything
+	for _, jql := range []string{resolvedJQL, incomingJQL} {
+		if err := validateJQL(client, jql); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Every row is a team, so there is no report without the Team field
+	missing, err := findMissingFields(client, resolvedJQL, []optionalField{teamField}, own)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(missing) > 0 {
+		log.Fatalf("The balance command compares teams, but Team (%s) is not returned by Jira; check that the API user may see it and that it is on the project's screens", own.Team)
+	}
+
+	resolved, err := own.fetchIssues(client, resolvedJQL, balanceFields)
+	if err != nil {
+		log.Fatalf("Error fetching resolved tickets: %v", err)
+	}
+	incoming, err := own.fetchIssues(client, incomingJQL, balanceFields)
+	if err != nil {
+		log.Fatalf("Error fetching incoming tickets: %v", err)
+	}
+	resolved = dropAutomationIssues(os.Stdout, resolved, config)
+	incoming = dropAutomationIssues(os.Stdout, incoming, config)
+
+	report := analyzeBalance(incoming, resolved, monthsInRange(start, end), *imbalance, *sustained)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+	report.IncomingJQL = incomingJQL
+	report.ResolvedJQL = resolvedJQL
+
+	title := fmt.Sprintf("%s Load Balance Analysis %s to %s", report.Project, report.Start, report.End)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeBalanceReport(w, report, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+// watchedFieldNames are the names the watched fields have in a standard Jira
+// setup, to find them when this build's IDs don't match the instance
+var watchedFieldNames = map[string]string{
+	manaFieldID:     "Mana Spent",
+	teamFieldID:     "Team",
+	epicLinkFieldID: "Epic Link",
+}
+
+// writeDefaultConfig creates the config file from the embedded template,
+// unless it exists. created is false if it existed; it is checked then.
+func writeDefaultConfig(path string) (created bool, err error) {
+	if _, err := os.Stat(path); err == nil {
+		_, err := loadConfig(path)
+		return false, err
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	b, err := templatesFS.ReadFile("templates/config.json")
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, b, 0o644)
+}
+
+func runInitCommand() {
+	configPath := flag.String("config", "", "Config file to create (default theia/config.json in the user config directory)")
+	projectKey := flag.String("project", "", "Also check that this JIRA project can be read")
+	flag.Parse()
+
+	// Create the config directory and file
+	path := *configPath
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			log.Fatalf("No config directory: %v", err)
+		}
+	}
+	created, err := writeDefaultConfig(path)
+	if err != nil {
+		log.Fatalf("Error setting up %s: %v", path, err)
+	}
+	if created {
+		fmt.Printf("Config: created %s from the default template\n", path)
+	} else {
+		fmt.Printf("Config: %s exists and is valid, left unchanged\n", path)
+	}
+
+	// Verify the credentials
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		fmt.Println("\nSet the Jira credentials and run init again:")
+		fmt.Println(`  export JIRA_URL="https://your-domain.atlassian.net"`)
+		fmt.Println(`  export JIRA_USERNAME="your-email@domain.com"`)
+		fmt.Println(`  export JIRA_TOKEN="your-api-token"`)
+		os.Exit(1)
+	}
+	user, resp, err := client.User.GetSelf()
+	if err != nil {
+		log.Fatalf("Auth: %s failed: %s", jiraURL, describeJiraError(resp, err))
+	}
+	fmt.Printf("Auth: signed in to %s as %s\n", jiraURL, user.DisplayName)
+	if *projectKey != "" {
+		project, resp, err := client.Project.Get(*projectKey)
+		if err != nil {
+			log.Fatalf("Project: %s can't be read: %s", *projectKey, describeJiraError(resp, err))
+		}
+		fmt.Printf("Project: %s (%s) can be read\n", project.Key, project.Name)
+	}
+
+	// Check the fields theia reads, and record them for drift detection
+	snapshot, fields, err := takeFieldSnapshot(client)
+	if err != nil {
+		log.Fatalf("Fields: %v", err)
+	}
+	missing := 0
+	for _, f := range snapshot.Fields {
+		if !f.Missing {
+			fmt.Printf("Field: %s is %q", f.ID, f.Name)
+			if f.Type != "" {
+				fmt.Printf(", %s", describeFieldType(f))
+			}
+			fmt.Println()
+			continue
+		}
+		missing++
+		hint := ""
+		for _, other := range fields {
+			if other.Name == watchedFieldNames[f.ID] {
+				hint = fmt.Sprintf("; this instance has %q as %s", other.Name, other.ID)
+				break
+			}
+		}
+		fmt.Printf("Field: %s (%s) does not exist%s\n", f.ID, watchedFieldNames[f.ID], hint)
+	}
+	snapshotPath, err := fieldSnapshotPath(jiraURL)
+	if err == nil {
+		err = saveFieldSnapshot(snapshotPath, snapshot)
+	}
+	if err != nil {
+		log.Fatalf("Error saving field snapshot: %v", err)
+	}
+
+	if missing > 0 {
+		fmt.Printf("\n%d of the fields theia reads are missing, so reports would leave out their data.\n", missing)
+		os.Exit(1)
+	}
+	example := *projectKey
+	if example == "" {
+		example = "PROJ"
+	}
+	fmt.Printf("\nReady. Try: theia ticket -project %s -start \"last quarter\" -end \"last quarter\"\n", example)
+}
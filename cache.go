@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// issueCache stores raw Jira search result pages on disk, keyed by the query
+// that produced them. A nil *issueCache is a disabled cache.
+type issueCache struct {
+	dir string
+}
+
+// openIssueCache opens the cache directory under the user cache dir. It
+// returns nil, disabling the cache, if disabled is set or the directory
+// cannot be created.
+func openIssueCache(disabled bool) *issueCache {
+	dir := openCacheDir(disabled, "issues")
+	if dir == "" {
+		return nil
+	}
+	return &issueCache{dir: dir}
+}
+
+// cacheEntry is a cached search result with the validator it was fetched
+// at, which tells whether the results changed since
+type cacheEntry struct {
+	Validator string            `json:"validator"`
+	Pages     []json.RawMessage `json:"pages"`
+}
+
+// cacheKey identifies a query and the fields it requests
+func cacheKey(jql string, fields []string) string {
+	h := sha256.New()
+	h.Write([]byte(jql))
+	for _, f := range fields {
+		h.Write([]byte{0})
+		h.Write([]byte(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// load returns the cached pages for the key and their validator, if
+// present. Entries of earlier versions had no validator and are misses.
+func (c *issueCache) load(key string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	b, err := readCacheFile(c.dir, key)
+	if err != nil {
+		return nil, false
+	}
+	entry := new(cacheEntry)
+	if err := json.Unmarshal(b, entry); err != nil || entry.Validator == "" {
+		return nil, false
+	}
+	return entry, true
+}
+
+// store saves the pages for the key with their validator. Failures only
+// disable caching for this entry, so they are reported but not returned.
+func (c *issueCache) store(key, validator string, pages []json.RawMessage) {
+	if c == nil {
+		return
+	}
+
+	b, err := json.Marshal(cacheEntry{Validator: validator, Pages: pages})
+	if err != nil {
+		fmt.Printf("Warning: could not encode cache entry: %v\n", err)
+		return
+	}
+	writeCacheFile(c.dir, key, b)
+}
+
+// cacheEntryExt is the extension of the encrypted cache entries
+const cacheEntryExt = ".sealed"
+
+// readCacheFile reads and decrypts the cache entry with the name
+func readCacheFile(dir, name string) ([]byte, error) {
+	sealed, err := os.ReadFile(filepath.Join(dir, name+cacheEntryExt))
+	if err != nil {
+		return nil, err
+	}
+	c, err := loadCacheCipher()
+	if err != nil {
+		return nil, err
+	}
+	return c.open(name, sealed)
+}
+
+// writeCacheFile encrypts and writes a cache entry. It writes to a temporary
+// file first so concurrent runs never read a partial entry.
+func writeCacheFile(dir, name string, b []byte) {
+	c, err := loadCacheCipher()
+	if err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		return
+	}
+	if b, err = c.seal(name, b); err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		return
+	}
+	name += cacheEntryExt
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+	}
+}
+
+// openCacheDir creates the named directory under the user cache dir. It
+// returns "", disabling that cache, if disabled is set, the directory cannot
+// be created or there is no cache key. Entries are encrypted, as they hold
+// summaries, teams and people; plaintext entries of earlier versions are
+// deleted.
+func openCacheDir(disabled bool, name string) string {
+	if disabled {
+		return ""
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		fmt.Printf("Warning: no cache directory available, caching disabled: %v\n", err)
+		return ""
+	}
+	dir := filepath.Join(base, "theia", name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		fmt.Printf("Warning: could not create cache directory, caching disabled: %v\n", err)
+		return ""
+	}
+	if _, err := loadCacheCipher(); err != nil {
+		fmt.Printf("Warning: no cache key, caching disabled: %v\n", err)
+		return ""
+	}
+	if plaintext, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(plaintext) > 0 {
+		for _, path := range plaintext {
+			os.Remove(path)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// cacheKeyEnv holds the base64 encoded 32-byte cache key, for runs without
+// a keychain such as CI jobs
+const cacheKeyEnv = "THEIA_CACHE_KEY"
+
+// Service and account of the cache key in the OS keychain
+const (
+	keychainService = "theia"
+	keychainAccount = "cache-key"
+)
+
+// sealedMagic starts every encrypted cache entry
+var sealedMagic = []byte("theia-sealed-v1\n")
+
+// cacheCipher encrypts cache entries with AES-256-GCM. Every entry is bound
+// to its file name, so entries can't be swapped for one another.
+type cacheCipher struct {
+	aead cipher.AEAD
+}
+
+var (
+	cacheCipherOnce sync.Once
+	sharedCipher    *cacheCipher
+	sharedCipherErr error
+)
+
+// loadCacheCipher returns the cipher of the caches, loading the key once per
+// run
+func loadCacheCipher() (*cacheCipher, error) {
+	cacheCipherOnce.Do(func() {
+		key, source, err := cacheEncryptionKey()
+		if err != nil {
+			sharedCipherErr = err
+			return
+		}
+		sharedCipher, sharedCipherErr = newCacheCipher(key, source)
+	})
+	return sharedCipher, sharedCipherErr
+}
+
+// newCacheCipher creates the cipher for a 32-byte key, from source
+func newCacheCipher(key []byte, source string) (*cacheCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cache key from %s must be 32 bytes, not %d", source, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheCipher{aead: aead}, nil
+}
+
+// seal encrypts the entry stored under name
+func (c *cacheCipher) seal(name string, plain []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte{}, sealedMagic...), nonce...)
+	return c.aead.Seal(sealed, nonce, plain, []byte(name)), nil
+}
+
+// open decrypts the entry stored under name. Entries sealed with another key
+// or tampered with fail.
+func (c *cacheCipher) open(name string, sealed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, sealedMagic) {
+		return nil, errors.New("not an encrypted cache entry")
+	}
+	sealed = sealed[len(sealedMagic):]
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("truncated cache entry")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, []byte(name))
+}
+
+// cacheEncryptionKey returns the cache key and where it came from: the
+// THEIA_CACHE_KEY environment variable, the key file left by an earlier run
+// without a keychain, the OS keychain, or else a new key file. A new key is
+// generated in the keychain or key file on first use.
+func cacheEncryptionKey() ([]byte, string, error) {
+	if v := os.Getenv(cacheKeyEnv); v != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid %s: %w", cacheKeyEnv, err)
+		}
+		return key, cacheKeyEnv, nil
+	}
+
+	path, err := cacheKeyPath()
+	if err != nil {
+		return nil, "", err
+	}
+	if key, err := readKeyFile(path); err == nil {
+		return key, path, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, "", err
+	}
+
+	if secret, err := keychainLoad(); err == nil {
+		if secret != "" {
+			key, err := base64.StdEncoding.DecodeString(secret)
+			return key, "the keychain", err
+		}
+		key, err := newCacheKey()
+		if err != nil {
+			return nil, "", err
+		}
+		if err := keychainStore(base64.StdEncoding.EncodeToString(key)); err == nil {
+			return key, "the keychain", nil
+		}
+	}
+
+	key, err := newCacheKey()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, "", err
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, "", err
+	}
+	return key, path, nil
+}
+
+// cacheKeyPath returns the key file used without a keychain. It is in the
+// config directory, apart from the cache it protects, so backups or copies
+// of the cache directory can't be read.
+func cacheKeyPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no config directory for the cache key: %w", err)
+	}
+	return filepath.Join(base, "theia", "cache.key"), nil
+}
+
+// readKeyFile reads a base64 encoded key file
+func readKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// newCacheKey generates a random 32-byte key
+func newCacheKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generating the cache key: %w", err)
+	}
+	return key, nil
+}
+
+// errNoKeychain is returned where no keychain can be used
+var errNoKeychain = errors.New("no keychain available")
+
+// keychainLoad returns the secret stored in the OS keychain, "" if there is
+// none yet. The macOS keychain is used through security, and the Secret
+// Service of Linux desktops through secret-tool.
+func keychainLoad() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err != nil || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return "", errNoKeychain
+		}
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return "", errNoKeychain
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Both tools exit with an error if the item doesn't exist
+		return "", nil
+	}
+	if err != nil {
+		return "", errNoKeychain
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainStore stores the secret in the OS keychain. The secret never goes
+// on a command line, where other users could see it in the process list:
+// security reads its command from stdin in interactive mode, and secret-tool
+// reads the secret from stdin.
+func keychainStore(secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -s %s -a %s -w %s\n", keychainService, keychainAccount, secret))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=theia cache key", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return errNoKeychain
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("storing the cache key in the keychain: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	// security exits successfully in interactive mode even if the command
+	// failed, so the key is read back before it encrypts anything
+	if stored, err := keychainLoad(); err != nil || stored != secret {
+		return fmt.Errorf("storing the cache key in the keychain: the key could not be read back")
+	}
+	return nil
+}
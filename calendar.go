@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarConfig is the business calendar of the config file. Every field
+// is optional.
+type CalendarConfig struct {
+	TimeZone     string   `json:"timeZone"`     // IANA time zone, default local time
+	WorkdayStart string   `json:"workdayStart"` // HH:MM, default 09:00
+	WorkdayEnd   string   `json:"workdayEnd"`   // HH:MM, default 17:00
+	Weekend      []string `json:"weekend"`      // Day names, default Saturday and Sunday
+	Holidays     []string `json:"holidays"`     // YYYY-MM-DD
+}
+
+// businessCalendar is the working time business durations are counted in
+type businessCalendar struct {
+	loc      *time.Location
+	start    time.Duration // Start of the working day after midnight
+	end      time.Duration // End of the working day after midnight
+	weekend  map[time.Weekday]bool
+	holidays map[string]bool // YYYY-MM-DD
+}
+
+// defaultCalendarConfig is a 09:00 to 17:00 Monday to Friday working week
+var defaultCalendarConfig = CalendarConfig{
+	WorkdayStart: "09:00",
+	WorkdayEnd:   "17:00",
+	Weekend:      []string{"Saturday", "Sunday"},
+}
+
+// parseClock parses an HH:MM time of day into the duration after midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// newBusinessCalendar checks the calendar config and fills in the defaults
+// of fields it leaves out. A nil config is the default calendar.
+func newBusinessCalendar(config *CalendarConfig) (*businessCalendar, error) {
+	c := defaultCalendarConfig
+	if config != nil {
+		c.TimeZone = config.TimeZone
+		if config.WorkdayStart != "" {
+			c.WorkdayStart = config.WorkdayStart
+		}
+		if config.WorkdayEnd != "" {
+			c.WorkdayEnd = config.WorkdayEnd
+		}
+		if config.Weekend != nil {
+			c.Weekend = config.Weekend
+		}
+		c.Holidays = config.Holidays
+	}
+
+	cal := &businessCalendar{
+		loc:      time.Local,
+		weekend:  make(map[time.Weekday]bool),
+		holidays: make(map[string]bool),
+	}
+	var err error
+	if c.TimeZone != "" {
+		if cal.loc, err = time.LoadLocation(c.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid calendar time zone: %w", err)
+		}
+	}
+	if cal.start, err = parseClock(c.WorkdayStart); err != nil {
+		return nil, fmt.Errorf("invalid calendar workdayStart: %w", err)
+	}
+	if cal.end, err = parseClock(c.WorkdayEnd); err != nil {
+		return nil, fmt.Errorf("invalid calendar workdayEnd: %w", err)
+	}
+	if cal.end <= cal.start {
+		return nil, fmt.Errorf("calendar workdayEnd must be after workdayStart")
+	}
+	for _, name := range c.Weekend {
+		day, ok := parseWeekday(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid calendar weekend day %q", name)
+		}
+		cal.weekend[day] = true
+	}
+	if len(cal.weekend) == 7 {
+		return nil, fmt.Errorf("calendar weekend cannot be every day")
+	}
+	for _, h := range c.Holidays {
+		if _, err := time.Parse("2006-01-02", h); err != nil {
+			return nil, fmt.Errorf("invalid calendar holiday %q, expected YYYY-MM-DD", h)
+		}
+		cal.holidays[h] = true
+	}
+	return cal, nil
+}
+
+// parseWeekday parses an English day name, case-insensitively
+func parseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// workday returns the length of a working day
+func (c *businessCalendar) workday() time.Duration {
+	return c.end - c.start
+}
+
+// businessTime returns the working time between from and to: the time
+// within working hours, on days that are neither weekend days nor holidays
+func (c *businessCalendar) businessTime(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	from, to = from.In(c.loc), to.In(c.loc)
+
+	var total time.Duration
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, c.loc)
+	for !day.After(to) {
+		if !c.weekend[day.Weekday()] && !c.holidays[day.Format("2006-01-02")] {
+			start, end := c.at(day, c.start), c.at(day, c.end)
+			if from.After(start) {
+				start = from
+			}
+			if to.Before(end) {
+				end = to
+			}
+			if end.After(start) {
+				total += end.Sub(start)
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, c.loc)
+	}
+	return total
+}
+
+// at returns the time of day on the day. It is built from the clock time
+// rather than added to midnight, so days with a daylight saving change still
+// start and end at the configured times.
+func (c *businessCalendar) at(day time.Time, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock.Hours()), int(clock.Minutes())%60, 0, 0, c.loc)
+}
+
+// describe summarizes the calendar for report footnotes
+func (c *businessCalendar) describe() string {
+	var days []string
+	for d := time.Monday; ; d = (d + 1) % 7 {
+		if !c.weekend[d] {
+			days = append(days, d.String()[:3])
+		}
+		if d == time.Sunday {
+			break
+		}
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	zone := c.loc.String()
+	if c.loc == time.Local {
+		zone = "local time"
+	}
+	s := fmt.Sprintf("%s-%s %s, %s", clock(c.start), clock(c.end), strings.Join(days, "/"), zone)
+	if len(c.holidays) > 0 {
+		s += fmt.Sprintf(", %d holidays", len(c.holidays))
+	}
+	return s
+}
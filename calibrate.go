@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// defaultCalibrationSize is the number of tickets sampled per month
+const defaultCalibrationSize = 10
+
+// calibrationLabel is the label of the Jira tasks created by -create-task
+const calibrationLabel = "mana-calibration"
+
+// calibrationFields are the fields the calibration sample reads
+var calibrationFields = []string{"issuetype", "summary", manaFieldID, "resolutiondate", teamFieldID, "labels", "issuelinks", "assignee", "reporter"}
+
+// CalibrationStratum is one category of the month's tickets
+type CalibrationStratum struct {
+	Category string
+	Tickets  int
+	Mana     float64
+	Sampled  int
+}
+
+// CalibrationTicket is a sampled ticket whose owner verifies its Mana Spent
+type CalibrationTicket struct {
+	Issue
+	Category string
+}
+
+// CalibrationSample is the data model of the calibrate command
+type CalibrationSample struct {
+	Project string
+	Month   string // YYYY-MM
+	JQL     string
+	Seed    int64
+	Tickets int                  // Tickets of the month
+	Mana    float64              // Mana of the tickets of the month
+	Strata  []CalibrationStratum // Most mana first
+	Sample  []CalibrationTicket  // In the order of the strata, then by key
+}
+
+// calibrationSeed returns the default seed of a project's month, so reruns
+// for the same month pick the same tickets
+func calibrationSeed(projectKey, month string) int64 {
+	h := fnv.New64a()
+	io.WriteString(h, projectKey+" "+month)
+	return int64(h.Sum64() >> 1)
+}
+
+// parseMonth parses a YYYY-MM month into its first and last day
+func parseMonth(s string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", s)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", s)
+	}
+	return start, start.AddDate(0, 1, -1), nil
+}
+
+// allocateSample splits size picks between the strata: one for each stratum
+// while picks last, most mana first, then each further pick to the stratum
+// furthest below its share of the mana. Strata never get more picks than
+// tickets. Without any mana the shares follow the ticket counts.
+func allocateSample(strata []CalibrationStratum, size int) {
+	var totalMana float64
+	totalTickets := 0
+	for _, s := range strata {
+		totalMana += s.Mana
+		totalTickets += s.Tickets
+	}
+	if size > totalTickets {
+		size = totalTickets
+	}
+	picks := float64(size)
+	weight := func(s CalibrationStratum) float64 {
+		if totalMana == 0 {
+			return float64(s.Tickets) / float64(totalTickets)
+		}
+		return s.Mana / totalMana
+	}
+
+	for i := range strata {
+		if size == 0 {
+			return
+		}
+		strata[i].Sampled = 1
+		size--
+	}
+	for ; size > 0; size-- {
+		best, bestDeficit := -1, 0.0
+		for i, s := range strata {
+			if s.Sampled == s.Tickets {
+				continue
+			}
+			deficit := weight(s)*picks - float64(s.Sampled)
+			if best < 0 || deficit > bestDeficit {
+				best, bestDeficit = i, deficit
+			}
+		}
+		strata[best].Sampled++
+	}
+}
+
+// selectCalibrationSample picks size tickets at random, stratified by
+// category with the picks weighted by mana, so the categories consuming the
+// most mana get most of the verification effort while every category is
+// checked. The same issues and seed always give the same sample.
+func selectCalibrationSample(issues []Issue, opts classifyOptions, size int, seed int64) *CalibrationSample {
+	sample := &CalibrationSample{Seed: seed}
+	byCategory := make(map[string][]Issue)
+	strataIndex := make(map[string]int)
+	for _, issue := range issues {
+		if opts.excluded(issue) {
+			continue
+		}
+		category := classifyIssue(issue, opts)
+		if _, ok := strataIndex[category]; !ok {
+			strataIndex[category] = len(sample.Strata)
+			sample.Strata = append(sample.Strata, CalibrationStratum{Category: category})
+		}
+		s := &sample.Strata[strataIndex[category]]
+		s.Tickets++
+		s.Mana += issue.Mana
+		sample.Tickets++
+		sample.Mana += issue.Mana
+		byCategory[category] = append(byCategory[category], issue)
+	}
+	sort.Slice(sample.Strata, func(i, j int) bool {
+		a, b := sample.Strata[i], sample.Strata[j]
+		if a.Mana != b.Mana {
+			return a.Mana > b.Mana
+		}
+		if a.Tickets != b.Tickets {
+			return a.Tickets > b.Tickets
+		}
+		return a.Category < b.Category
+	})
+	allocateSample(sample.Strata, size)
+
+	// Tickets are sorted first so the fetch order does not change the sample
+	rng := rand.New(rand.NewSource(seed))
+	for _, s := range sample.Strata {
+		tickets := byCategory[s.Category]
+		sort.Slice(tickets, func(i, j int) bool { return tickets[i].Key < tickets[j].Key })
+		var picked []CalibrationTicket
+		for _, i := range rng.Perm(len(tickets))[:s.Sampled] {
+			picked = append(picked, CalibrationTicket{Issue: tickets[i], Category: s.Category})
+		}
+		sort.Slice(picked, func(i, j int) bool { return picked[i].Key < picked[j].Key })
+		sample.Sample = append(sample.Sample, picked...)
+	}
+	return sample
+}
+
+// ownerName returns the display name of the ticket's assignee
+func (t CalibrationTicket) ownerName() string {
+	switch {
+	case t.Owner != "":
+		return t.Owner
+	case t.Assignee != "":
+		return t.Assignee
+	}
+	return "Unassigned"
+}
+
+// writeCalibrationSample writes the strata and the checklist of sampled tickets
+func writeCalibrationSample(w io.Writer, sample *CalibrationSample, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "\nMana Calibration Sample: %s\n", sample.Month)
+	fmt.Fprintf(w, "Project: %s\n", sample.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", sample.JQL)
+
+	strata := newTextTable(
+		tableColumn{Header: "Category"},
+		tableColumn{Header: "Tickets", Right: true},
+		tableColumn{Header: "Total Mana", Right: true},
+		tableColumn{Header: "% of All Mana", Right: true},
+		tableColumn{Header: "Sampled", Right: true},
+	)
+	for _, s := range sample.Strata {
+		share := 0.0
+		if sample.Mana > 0 {
+			share = s.Mana / sample.Mana * 100
+		}
+		strata.addRow(s.Category,
+			layout.Numbers.count(s.Tickets),
+			layout.Numbers.mana(s.Mana),
+			fmt.Sprintf("%.1f%%", share),
+			layout.Numbers.count(s.Sampled))
+	}
+	strata.addFooter("ALL TICKETS",
+		layout.Numbers.count(sample.Tickets),
+		layout.Numbers.mana(sample.Mana),
+		"100.0%",
+		layout.Numbers.count(len(sample.Sample)))
+	fmt.Fprintf(w, "\nStrata:\n")
+	strata.write(w, layout.Style)
+
+	fmt.Fprintf(w, "\nChecklist (seed %d): %d tickets\n", sample.Seed, len(sample.Sample))
+	if len(sample.Sample) > 0 {
+		table := newTextTable(
+			tableColumn{Header: "Key"},
+			tableColumn{Header: "Category"},
+			tableColumn{Header: "Mana", Right: true},
+			tableColumn{Header: "Owner", MaxWidth: 30},
+			tableColumn{Header: "Link"},
+			tableColumn{Header: "Summary", MaxWidth: 60},
+		)
+		for _, t := range sample.Sample {
+			table.addRow(t.Key,
+				t.Category,
+				layout.Numbers.mana(t.Mana),
+				t.ownerName(),
+				fmt.Sprintf("%s/browse/%s", jiraURL, t.Key),
+				removeEmojis(t.Summary))
+		}
+		table.write(w, layout.Style)
+	}
+	fmt.Fprintln(w, "Every category gets a pick while the sample size allows, further picks go to the categories with the most mana. Owners check that each ticket's Mana Spent matches the work it took and correct it if not.")
+}
+
+// calibrationTaskDescription is the description of the calibration task, in
+// Jira wiki markup, mentioning the owner of every sampled ticket
+func calibrationTaskDescription(sample *CalibrationSample, jiraURL string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "These tickets resolved in %s were picked at random to check their Mana Spent. ", sample.Month)
+	b.WriteString("Owners, please check that the value matches the work each ticket took, correct it on the ticket if not, and comment here when done.\n\n")
+	for _, t := range sample.Sample {
+		owner := "Unassigned"
+		if t.Assignee != "" {
+			owner = fmt.Sprintf("[~accountid:%s]", t.Assignee)
+		}
+		fmt.Fprintf(&b, "* [%s|%s/browse/%s] %s: %s, Mana Spent %s, owner %s\n",
+			t.Key, jiraURL, t.Key, removeEmojis(t.Summary), t.Category, strconv.FormatFloat(t.Mana, 'f', -1, 64), owner)
+	}
+	fmt.Fprintf(&b, "\nSample seed %d, from %d tickets with %s mana.\n", sample.Seed, sample.Tickets, strconv.FormatFloat(sample.Mana, 'f', -1, 64))
+	return b.String()
+}
+
+// createCalibrationTask creates the Jira task asking the owners to verify the
+// sampled tickets and returns its key
+func createCalibrationTask(client *jira.Client, projectKey string, sample *CalibrationSample, jiraURL string) (string, error) {
+	task := &jira.Issue{Fields: &jira.IssueFields{
+		Project:     jira.Project{Key: projectKey},
+		Type:        jira.IssueType{Name: "Task"},
+		Summary:     fmt.Sprintf("Mana calibration %s %s: verify %d tickets", sample.Project, sample.Month, len(sample.Sample)),
+		Description: calibrationTaskDescription(sample, jiraURL),
+		Labels:      []string{calibrationLabel},
+	}}
+	created, resp, err := client.Issue.Create(task)
+	if err != nil {
+		return "", fmt.Errorf("creating calibration task: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return created.Key, nil
+}
+
+// calibrationChecklistItem is a sampled ticket in the webhook checklist
+type calibrationChecklistItem struct {
+	Key      string  `json:"key"`
+	URL      string  `json:"url"`
+	Summary  string  `json:"summary"`
+	Category string  `json:"category"`
+	Mana     float64 `json:"mana"`
+	Owner    string  `json:"owner"`
+}
+
+// calibrationChecklist renders the sample as the JSON webhook payload, with a
+// text checklist for chat webhooks and the tickets for other consumers
+func calibrationChecklist(sample *CalibrationSample, jiraURL string) ([]byte, error) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Mana calibration %s %s: please verify the Mana Spent of these tickets\n", sample.Project, sample.Month)
+	items := make([]calibrationChecklistItem, 0, len(sample.Sample))
+	for _, t := range sample.Sample {
+		item := calibrationChecklistItem{
+			Key:      t.Key,
+			URL:      fmt.Sprintf("%s/browse/%s", jiraURL, t.Key),
+			Summary:  removeEmojis(t.Summary),
+			Category: t.Category,
+			Mana:     t.Mana,
+			Owner:    t.ownerName(),
+		}
+		items = append(items, item)
+		fmt.Fprintf(&text, "- [ ] %s %s (%s, mana %s, %s) %s\n",
+			item.Key, item.Summary, item.Category, strconv.FormatFloat(item.Mana, 'f', -1, 64), item.Owner, item.URL)
+	}
+	return json.Marshal(map[string]any{
+		"text":    text.String(),
+		"project": sample.Project,
+		"month":   sample.Month,
+		"seed":    sample.Seed,
+		"tickets": items,
+	})
+}
+
+func runCalibrateCommand() {
+	// Command line flags
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	month := flag.String("month", time.Now().AddDate(0, -1, 0).Format("2006-01"), "Month of the resolved tickets sampled (YYYY-MM), default last month")
+	size := flag.Int("size", defaultCalibrationSize, "Number of tickets sampled")
+	seed := flag.Int64("seed", 0, "Random seed of the sample (default derived from the project and month)")
+	brokenWindows := flag.Bool("broken-windows", false, "Consider tickets with ux-broken-window label as separate type")
+	security := flag.Bool("security", false, "Consider tickets linked to Product Vulnerability issues as a separate type")
+	securityDepth := flag.Int("security-depth", 1, securityDepthUsage)
+	research := defineResearchFlags()
+	createTask := flag.Bool("create-task", false, "Create a Jira task asking the owners to verify the sampled tickets")
+	taskProject := flag.String("task-project", "", "Project of the -create-task task (default -project)")
+	webhookURL := flag.String("webhook-url", "", "Post the checklist as JSON to this URL")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	start, end, err := parseMonth(*month)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *size < 1 {
+		log.Fatal("-size must be at least 1")
+	}
+	if *seed == 0 {
+		*seed = calibrationSeed(*projectKey, *month)
+	}
+	if err := checkSecurityDepth(*securityDepth); err != nil {
+		log.Fatal(err)
+	}
+	if *taskProject == "" {
+		*taskProject = *projectKey
+	}
+	if *createTask && jiraWrites.ReadOnly {
+		log.Fatal("-create-task creates a Jira task, which read-only mode refuses")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	classify := newClassifyOptions(*brokenWindows, *security, config)
+	if err := research.apply(&classify); err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The tickets of the ticket report resolved at any time in the month
+	own := config.fieldsOf(*projectKey)
+	jql := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, true), own) + `
+		ORDER BY created DESC`
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := own.fetchIssues(client, jql, calibrationFields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	if err := applySecurityDepth(client, os.Stdout, issues, *securityDepth, &classify); err != nil {
+		log.Fatal(err)
+	}
+
+	sample := selectCalibrationSample(issues, classify, *size, *seed)
+	sample.Project = *projectKey
+	sample.Month = *month
+	sample.JQL = jql
+
+	title := fmt.Sprintf("%s Mana Calibration Sample %s", sample.Project, sample.Month)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeCalibrationSample(w, sample, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+	if len(sample.Sample) == 0 {
+		fmt.Printf("\nNo tickets resolved in %s, nothing to verify\n", sample.Month)
+		return
+	}
+
+	if *createTask {
+		key, err := createCalibrationTask(client, *taskProject, sample, jiraURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("\nCalibration task created: %s/browse/%s\n", jiraURL, key)
+	}
+	if *webhookURL != "" {
+		payload, err := calibrationChecklist(sample, jiraURL)
+		if err != nil {
+			log.Fatalf("Error encoding checklist: %v", err)
+		}
+		if err := postJSON(*webhookURL, payload); err != nil {
+			log.Fatalf("Error posting checklist: %v", err)
+		}
+		fmt.Println("\nChecklist posted to webhook")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// changelogFields are the fields fetched and cached with changelogs, the
+// ones every changelog-based metric reads
+var changelogFields = []string{"created", "updated", "status", teamFieldID}
+
+// changelogCache stores issues with their changelogs on disk, one file per
+// issue, valid as long as the issue's updated time is unchanged. A nil
+// *changelogCache is a disabled cache.
+type changelogCache struct {
+	dir string
+}
+
+// changelogEntry is one cached issue with its changelog
+type changelogEntry struct {
+	Updated string     `json:"updated"`
+	Issue   jira.Issue `json:"issue"`
+}
+
+// openChangelogCache opens the changelog cache under the user cache dir, or
+// returns nil if disabled is set or the directory cannot be created
+func openChangelogCache(disabled bool) *changelogCache {
+	dir := openCacheDir(disabled, "changelogs")
+	if dir == "" {
+		return nil
+	}
+	return &changelogCache{dir: dir}
+}
+
+// updatedStamp is the form of an issue's updated time the cache compares
+func updatedStamp(ji jira.Issue) string {
+	if ji.Fields == nil || time.Time(ji.Fields.Updated).IsZero() {
+		return ""
+	}
+	return time.Time(ji.Fields.Updated).UTC().Format(time.RFC3339Nano)
+}
+
+// load returns the cached issue with the key if it was cached at the updated
+// time
+func (c *changelogCache) load(key, updated string) (jira.Issue, bool) {
+	if c == nil || updated == "" {
+		return jira.Issue{}, false
+	}
+	b, err := readCacheFile(c.dir, key)
+	if err != nil {
+		return jira.Issue{}, false
+	}
+	var entry changelogEntry
+	if err := json.Unmarshal(b, &entry); err != nil || entry.Updated != updated {
+		return jira.Issue{}, false
+	}
+	return entry.Issue, true
+}
+
+// store caches the issue under its updated time
+func (c *changelogCache) store(ji jira.Issue) {
+	if c == nil || updatedStamp(ji) == "" {
+		return
+	}
+	b, err := json.Marshal(changelogEntry{Updated: updatedStamp(ji), Issue: ji})
+	if err != nil {
+		fmt.Printf("Warning: could not encode cache entry: %v\n", err)
+		return
+	}
+	writeCacheFile(c.dir, ji.Key, b)
+}
+
+// fetchChangelogs fetches the issues with their changelogs and
+// changelogFields. Only the updated times of cached issues are looked up;
+// the changelogs of issues changed since they were cached, or not cached
+// yet, are fetched and cached.
+func fetchChangelogs(client *jira.Client, cache *changelogCache, keys []string) ([]jira.Issue, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var stale []string
+	var issues []jira.Issue
+	if cache == nil {
+		stale = keys
+	} else {
+		current, err := searchByKeys(client, keys, []string{"updated"}, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, ji := range current {
+			if cached, ok := cache.load(ji.Key, updatedStamp(ji)); ok {
+				issues = append(issues, cached)
+			} else {
+				stale = append(stale, ji.Key)
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return issues, nil
+	}
+
+	fetched, err := searchByKeys(client, stale, changelogFields, "changelog")
+	if err != nil {
+		return nil, err
+	}
+	for _, ji := range fetched {
+		cache.store(ji)
+	}
+	return append(issues, fetched...), nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// defaultDoneStatuses are the statuses moving out of reopens an issue
+const defaultDoneStatuses = "Resolved,Closed,Done"
+
+// defaultChurnTop is the number of tickets listed in the churn report
+const defaultChurnTop = 20
+
+// churnFields are the fields the churn report reads
+var churnFields = []string{"issuetype", "summary", "status", manaFieldID, teamFieldID, "reporter"}
+
+// churnSorts are the -sort values of the churn report
+var churnSorts = []string{"reopens", "transitions", "reassignments", "changes"}
+
+// TicketChurn is the churn of one ticket during the period
+type TicketChurn struct {
+	Issue
+	Transitions   int // Status changes
+	Reopens       int // Status changes out of a done status
+	Reassignments int // Assignee changes
+	Changes       int // Changelog entries, every edit or transition by a user
+}
+
+// metric returns the churn count a -sort value ranks by
+func (t TicketChurn) metric(sortBy string) int {
+	switch sortBy {
+	case "transitions":
+		return t.Transitions
+	case "reassignments":
+		return t.Reassignments
+	case "changes":
+		return t.Changes
+	}
+	return t.Reopens
+}
+
+// ChurnReport is the data model of the churn command
+type ChurnReport struct {
+	Project        string
+	Start          string
+	End            string
+	JQL            string
+	SortBy         string
+	DoneStatuses   string
+	Tickets        int // Tickets changed in the period
+	Mana           float64
+	Reopened       int // Tickets reopened at least once
+	ReopenedMana   float64
+	Reassigned     int // Tickets reassigned at least once
+	ReassignedMana float64
+	Churned        []TicketChurn // Most churn first, at most the top tickets
+}
+
+// ticketChurn counts the changes the changelog records between from and to
+func ticketChurn(issue Issue, ji jira.Issue, from, to time.Time, done map[string]bool) TicketChurn {
+	churn := TicketChurn{Issue: issue}
+	if ji.Changelog == nil {
+		return churn
+	}
+	for _, history := range ji.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil || created.Before(from) || !created.Before(to) {
+			continue
+		}
+		churn.Changes++
+		for _, item := range history.Items {
+			switch item.Field {
+			case "status":
+				churn.Transitions++
+				if done[strings.ToLower(item.FromString)] && !done[strings.ToLower(item.ToString)] {
+					churn.Reopens++
+				}
+			case "assignee":
+				churn.Reassignments++
+			}
+		}
+	}
+	return churn
+}
+
+// analyzeChurn counts the transitions, reopens, reassignments and changes of
+// every ticket from start to the end of the end day, and ranks the tickets
+// with any churn by the -sort metric. histories are the tickets with their
+// changelogs, keyed by issue key.
+func analyzeChurn(issues []Issue, histories map[string]jira.Issue, start, end time.Time, done map[string]bool, sortBy string, top int) *ChurnReport {
+	report := &ChurnReport{SortBy: sortBy}
+	to := end.AddDate(0, 0, 1)
+	var churned []TicketChurn
+	for _, issue := range issues {
+		churn := ticketChurn(issue, histories[issue.Key], start, to, done)
+		if churn.Changes == 0 {
+			continue
+		}
+		report.Tickets++
+		report.Mana += issue.Mana
+		if churn.Reopens > 0 {
+			report.Reopened++
+			report.ReopenedMana += issue.Mana
+		}
+		if churn.Reassignments > 0 {
+			report.Reassigned++
+			report.ReassignedMana += issue.Mana
+		}
+		if churn.metric(sortBy) > 0 {
+			churned = append(churned, churn)
+		}
+	}
+
+	sort.Slice(churned, func(i, j int) bool {
+		a, b := churned[i], churned[j]
+		if a.metric(sortBy) != b.metric(sortBy) {
+			return a.metric(sortBy) > b.metric(sortBy)
+		}
+		if a.Transitions+a.Reassignments != b.Transitions+b.Reassignments {
+			return a.Transitions+a.Reassignments > b.Transitions+b.Reassignments
+		}
+		if a.Mana != b.Mana {
+			return a.Mana > b.Mana
+		}
+		return a.Key < b.Key
+	})
+	if len(churned) > top {
+		churned = churned[:top]
+	}
+	report.Churned = churned
+	return report
+}
+
+// writeChurnReport writes the churn totals and the most churned tickets
+func writeChurnReport(w io.Writer, report *ChurnReport, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "\nIssue Churn: %s to %s\n", report.Start, report.End)
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	share := func(mana float64) float64 {
+		if report.Mana == 0 {
+			return 0
+		}
+		return mana / report.Mana * 100
+	}
+	fmt.Fprintf(w, "\nSummary:\n")
+	fmt.Fprintf(w, "  Tickets Changed: %s (%s)\n", layout.Numbers.count(report.Tickets), layout.Numbers.manaAmount(report.Mana))
+	fmt.Fprintf(w, "  Reopened Tickets: %s (%s, %.1f%% of the mana)\n",
+		layout.Numbers.count(report.Reopened), layout.Numbers.manaAmount(report.ReopenedMana), share(report.ReopenedMana))
+	fmt.Fprintf(w, "  Reassigned Tickets: %s (%s, %.1f%% of the mana)\n",
+		layout.Numbers.count(report.Reassigned), layout.Numbers.manaAmount(report.ReassignedMana), share(report.ReassignedMana))
+
+	fmt.Fprintf(w, "\nMost Churned Tickets (by %s): %d\n", report.SortBy, len(report.Churned))
+	if len(report.Churned) > 0 {
+		table := newTextTable(
+			tableColumn{Header: "Key"},
+			tableColumn{Header: "Team", MaxWidth: 30},
+			tableColumn{Header: "Issue Type"},
+			tableColumn{Header: "Status", MaxWidth: 20},
+			tableColumn{Header: "Mana", Right: true},
+			tableColumn{Header: "Reopens", Right: true},
+			tableColumn{Header: "Transitions", Right: true},
+			tableColumn{Header: "Reassignments", Right: true},
+			tableColumn{Header: "Changes", Right: true},
+			tableColumn{Header: "Link"},
+			tableColumn{Header: "Summary", MaxWidth: 60},
+		)
+		for _, t := range report.Churned {
+			mana := "-"
+			if t.ManaSet {
+				mana = layout.Numbers.mana(t.Mana)
+			}
+			table.addRow(t.Key,
+				teamDimension.Group(t.Issue),
+				t.Type,
+				t.Status,
+				mana,
+				layout.Numbers.count(t.Reopens),
+				layout.Numbers.count(t.Transitions),
+				layout.Numbers.count(t.Reassignments),
+				layout.Numbers.count(t.Changes),
+				fmt.Sprintf("%s/browse/%s", jiraURL, t.Key),
+				removeEmojis(t.Summary))
+		}
+		table.write(w, layout.Style)
+	}
+	fmt.Fprintf(w, "Only changes made in the period count. A reopen is a move out of a done status (%s); changes are changelog entries, every edit or transition.\n", report.DoneStatuses)
+}
+
+func runChurnCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	sortBy := flag.String("sort", "reopens", "Rank tickets by "+strings.Join(churnSorts, ", "))
+	top := flag.Int("top", defaultChurnTop, "Number of tickets listed")
+	doneStatusList := flag.String("done-statuses", defaultDoneStatuses, "Comma-separated statuses moving out of which reopens a ticket")
+	noCache := flag.Bool("no-cache", false, "Always fetch from Jira, ignoring and not updating the local cache")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	validSort := false
+	for _, s := range churnSorts {
+		validSort = validSort || *sortBy == s
+	}
+	if !validSort {
+		log.Fatalf("invalid -sort %q, expected one of %s", *sortBy, strings.Join(churnSorts, ", "))
+	}
+	if *top < 1 {
+		log.Fatal("-top must be at least 1")
+	}
+	doneStatuses, err := parseTouchStatuses(*doneStatusList)
+	if err != nil {
+		log.Fatal("no -done-statuses given")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every ticket that may have changed in the period
+	jql := fmt.Sprintf(`project = "%s" AND
+		updated >= "%s" AND
+		created < "%s" AND
+		issuetype not in (Epic, Initiative)
+		ORDER BY created DESC`,
+		*projectKey,
+		start.Format("2006-01-02"),
+		end.AddDate(0, 0, 1).Format("2006-01-02"))
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	// Mana and teams are read from the project's own fields
+	issues, err := config.fieldsOf(*projectKey).fetchIssues(client, jql, churnFields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	issues = dropAutomationIssues(os.Stdout, issues, config)
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	withChangelog, err := fetchChangelogs(client, openChangelogCache(*noCache), keys)
+	if err != nil {
+		log.Fatalf("Error fetching changelogs: %v", err)
+	}
+	histories := make(map[string]jira.Issue, len(withChangelog))
+	for _, ji := range withChangelog {
+		histories[ji.Key] = ji
+	}
+
+	report := analyzeChurn(issues, histories, start, end, doneStatuses, *sortBy, *top)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.JQL = jql
+	var done []string
+	for _, s := range strings.Split(*doneStatusList, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			done = append(done, s)
+		}
+	}
+	report.DoneStatuses = strings.Join(done, ", ")
+
+	title := fmt.Sprintf("%s Issue Churn %s to %s", report.Project, report.Start, report.End)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeChurnReport(w, report, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Categories and markers used by the optional classification rules
+const (
+	brokenWindowLabel      = "ux-broken-window"
+	brokenWindowCategory   = "Broken Window"
+	vulnerabilityIssueType = "Product Vulnerability"
+	securityCategory       = "Security Vuln."
+	researchCategory       = "Research"
+)
+
+// Research handling modes accepted by -research
+const (
+	researchSeparate = "separate" // Research issues are their own category
+	researchStory    = "story"    // Research issues count as stories
+	researchExclude  = "exclude"  // Research issues are left out of the tables
+)
+
+// defaultResearchTypes are the issue types counted as research
+const defaultResearchTypes = "Spike,Research"
+
+// classifyOptions enables the optional classification rules
+type classifyOptions struct {
+	BrokenWindows bool // Issues labeled ux-broken-window are Broken Windows
+	Security      bool // Issues linked to a Product Vulnerability are Security Vulns
+	// VulnerabilityPaths are the issues linked to a Product Vulnerability
+	// through other issues with -security-depth, by key, with the keys of
+	// the linked issues on the way
+	VulnerabilityPaths map[string][]string
+	// ResearchTypes are the issue types handled as research according to
+	// Research, one of the research modes. Without research types every
+	// issue type is classified by its own name.
+	ResearchTypes map[string]bool
+	Research      string
+	// Overrides are the category overrides of -overrides, by issue key
+	Overrides issueOverrides
+	// AutomationReporters are the reporters whose issues are left out, by
+	// lower-cased account ID, user name or display name
+	AutomationReporters map[string]bool
+}
+
+// newClassifyOptions returns the classification options of a command, which
+// leave out the issues of the config's automation reporters like every report
+func newClassifyOptions(brokenWindows, security bool, config *Config) classifyOptions {
+	return classifyOptions{
+		BrokenWindows:       brokenWindows,
+		Security:            security,
+		AutomationReporters: parseAutomationReporters(config.AutomationReporters, ""),
+	}
+}
+
+// isResearch reports whether the issue is of a research type
+func (o classifyOptions) isResearch(issue Issue) bool {
+	return o.ResearchTypes[issue.Type]
+}
+
+// isAutomation reports whether an automation account reported the issue
+func (o classifyOptions) isAutomation(issue Issue) bool {
+	return o.AutomationReporters[strings.ToLower(issue.Reporter)] || o.AutomationReporters[strings.ToLower(issue.ReporterName)]
+}
+
+// excluded reports whether the issue is left out of the analysis
+func (o classifyOptions) excluded(issue Issue) bool {
+	return (o.Research == researchExclude && o.isResearch(issue)) || o.isAutomation(issue)
+}
+
+// Classification rules, as reported by -explain-classification
+const (
+	ruleLabel         = "label"
+	ruleLink          = "link"
+	ruleResearch      = "research type"
+	ruleNormalization = "type normalization"
+	ruleIssueType     = "issue type"
+	ruleOverride      = "override"
+	ruleAutomation    = "automation reporter"
+)
+
+// classification is the category of an issue and the rule that assigned it
+type classification struct {
+	Category string
+	Rule     string
+	Detail   string // What the rule matched
+}
+
+// typeClassification classifies an issue type before labels and links are
+// considered. Research types are their own category or stories, depending
+// on the research mode.
+func typeClassification(issueType string, opts classifyOptions) classification {
+	if opts.ResearchTypes[issueType] {
+		if opts.Research == researchStory {
+			return classification{normalizeIssueType("Story"), ruleResearch, issueType + " counted as a story"}
+		}
+		return classification{researchCategory, ruleResearch, issueType}
+	}
+	if category := normalizeIssueType(issueType); category != issueType {
+		return classification{category, ruleNormalization, issueType}
+	}
+	return classification{issueType, ruleIssueType, issueType}
+}
+
+// typeCategory returns the category of an issue type before labels and
+// links are considered
+func typeCategory(issueType string, opts classifyOptions) string {
+	return typeClassification(issueType, opts).Category
+}
+
+// explainIssue classifies an issue and tells which rule decided. Overrides
+// take precedence over broken windows, which take precedence over security,
+// which takes precedence over the normalized issue type.
+func explainIssue(issue Issue, opts classifyOptions) classification {
+	if o, ok := opts.Overrides[issue.Key]; ok {
+		return overrideClassification(o)
+	}
+
+	// Check for broken window label if enabled
+	if opts.BrokenWindows && issue.HasLabel(brokenWindowLabel) {
+		return classification{brokenWindowCategory, ruleLabel, brokenWindowLabel}
+	}
+
+	// Check for linked Product Vulnerability tickets if enabled
+	if opts.Security {
+		if key := vulnerabilityLink(issue); key != "" {
+			return classification{securityCategory, ruleLink, key}
+		}
+		if path := opts.VulnerabilityPaths[issue.Key]; path != nil {
+			return classification{securityCategory, ruleLink, strings.Join(path, " > ")}
+		}
+	}
+
+	return typeClassification(issue.Type, opts)
+}
+
+// classifyIssue returns the category an issue is counted under
+func classifyIssue(issue Issue, opts classifyOptions) string {
+	return explainIssue(issue, opts).Category
+}
+
+// vulnerabilityLink returns the key of the first Product Vulnerability issue
+// the issue is linked to, or "" if there is none
+func vulnerabilityLink(issue Issue) string {
+	for _, link := range issue.Links {
+		if link.IssueType == vulnerabilityIssueType {
+			return link.Key
+		}
+	}
+	return ""
+}
+
+// researchFlags are the research handling flags shared by the report commands
+type researchFlags struct {
+	mode  *string
+	types *string
+}
+
+// defineResearchFlags defines the research handling flags on the command line
+func defineResearchFlags() *researchFlags {
+	return &researchFlags{
+		mode:  flag.String("research", "", "Research issues: separate (own category), story (count as stories) or exclude; by default they are classified by their own issue types"),
+		types: flag.String("research-types", defaultResearchTypes, "Comma-separated issue types counted as research with -research"),
+	}
+}
+
+// apply checks the parsed flags and sets the research handling of opts.
+// Without -research, research types are classified like any other type.
+func (f *researchFlags) apply(opts *classifyOptions) error {
+	switch *f.mode {
+	case "":
+		if flagPassed("research-types") {
+			return fmt.Errorf("-research-types needs -research")
+		}
+		return nil
+	case researchSeparate, researchStory, researchExclude:
+	default:
+		return fmt.Errorf("unknown research mode %q, expected separate, story or exclude", *f.mode)
+	}
+	opts.Research = *f.mode
+	opts.ResearchTypes = make(map[string]bool)
+	for _, t := range strings.Split(*f.types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			opts.ResearchTypes[t] = true
+		}
+	}
+	return nil
+}
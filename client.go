@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// newClientFromEnv creates a Jira client from the JIRA_URL, JIRA_USERNAME and
+// JIRA_TOKEN environment variables, with the write policy of the global
+// flags. It returns the Jira URL too, for links.
+func newClientFromEnv() (*jira.Client, string, error) {
+	jiraURL := os.Getenv("JIRA_URL")
+	username := os.Getenv("JIRA_USERNAME")
+	apiToken := os.Getenv("JIRA_TOKEN")
+	if jiraURL == "" || username == "" || apiToken == "" {
+		return nil, "", fmt.Errorf("missing required environment variables, please set JIRA_URL, JIRA_USERNAME and JIRA_TOKEN")
+	}
+
+	guard, err := newWriteGuard(http.DefaultTransport, jiraWrites, username)
+	if err != nil {
+		return nil, "", err
+	}
+	tp := jira.BasicAuthTransport{
+		Username:  username,
+		Password:  apiToken,
+		Transport: guard,
+	}
+	client, err := jira.NewClient(tp.Client(), jiraURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating JIRA client: %w", err)
+	}
+	// The URL is returned for links and messages, without any credentials
+	return client, withoutUserinfo(jiraURL), nil
+}
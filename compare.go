@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// ProjectSummary is the analysis of one side of a project comparison
+type ProjectSummary struct {
+	Project    string
+	Analysis   map[string]*TicketAnalysis
+	TotalCount int
+	TotalMana  float64
+	People     int // Distinct assignees of the analyzed issues
+}
+
+// share returns the percentage of the project's mana spent on the category
+func (p *ProjectSummary) share(category string) float64 {
+	a, ok := p.Analysis[category]
+	if !ok || p.TotalMana == 0 {
+		return 0
+	}
+	return a.TotalMana / p.TotalMana * 100
+}
+
+// countShare returns the category's share of the issues in percent
+func (p *ProjectSummary) countShare(category string) float64 {
+	a, ok := p.Analysis[category]
+	if !ok || p.TotalCount == 0 {
+		return 0
+	}
+	return float64(a.Count) / float64(p.TotalCount) * 100
+}
+
+// manaPerPerson returns the mana spent per distinct assignee
+func (p *ProjectSummary) manaPerPerson() float64 {
+	if p.People == 0 {
+		return 0
+	}
+	return p.TotalMana / float64(p.People)
+}
+
+// summarizeProject classifies the issues of one project
+func summarizeProject(project string, issues []Issue, opts classifyOptions) *ProjectSummary {
+	summary := &ProjectSummary{
+		Project:  project,
+		Analysis: make(map[string]*TicketAnalysis),
+	}
+	people := make(map[string]bool)
+	for _, issue := range issues {
+		if opts.excluded(issue) {
+			continue
+		}
+		addToAnalysis(summary.Analysis, classifyIssue(issue, opts), issue.Mana)
+		summary.TotalCount++
+		summary.TotalMana += issue.Mana
+		if issue.Assignee != "" {
+			people[issue.Assignee] = true
+		}
+	}
+	summary.People = len(people)
+	return summary
+}
+
+// writeProjectComparison writes the comparison of two projects over a period
+func writeProjectComparison(w io.Writer, a, b *ProjectSummary, start, end string, inclusive bool, layout tableOptions) {
+	fmt.Fprintf(w, "\nComparison Period: %s to %s\n", start, end)
+	fmt.Fprintln(w, endBoundaryNote(end, inclusive))
+	fmt.Fprintf(w, "Projects: %s vs %s\n", a.Project, b.Project)
+	writeComparisonTable(w, a, b, layout)
+}
+
+// writeComparisonTable writes the categories of both summaries side by side
+// as shares of each summary's mana, sorted by combined share. The summaries
+// are labelled by their Project field.
+func writeComparisonTable(w io.Writer, a, b *ProjectSummary, layout tableOptions) {
+	categories := make(map[string]bool)
+	for c := range a.Analysis {
+		categories[c] = true
+	}
+	for c := range b.Analysis {
+		categories[c] = true
+	}
+	var rows []string
+	for c := range categories {
+		rows = append(rows, c)
+	}
+	// Share columns by mana and/or issue count, with the diff of each
+	type share struct {
+		name string
+		of   func(p *ProjectSummary, c string) float64
+	}
+	var shares []share
+	if layout.manaShares() {
+		shares = append(shares, share{"Mana", (*ProjectSummary).share})
+	}
+	if layout.countShares() {
+		shares = append(shares, share{"Issues", (*ProjectSummary).countShare})
+	}
+
+	// Sort by the combined share of the first kind
+	sortShare := shares[0].of
+	sort.Slice(rows, func(i, j int) bool {
+		si := sortShare(a, rows[i]) + sortShare(b, rows[i])
+		sj := sortShare(a, rows[j]) + sortShare(b, rows[j])
+		if si != sj {
+			return si > sj
+		}
+		return rows[i] < rows[j]
+	})
+
+	columns := []tableColumn{{Header: "Issue Type", MaxWidth: 30}}
+	for _, p := range []*ProjectSummary{a, b} {
+		columns = append(columns, tableColumn{Header: p.Project + " Count", Right: true})
+		for _, sh := range shares {
+			columns = append(columns, tableColumn{Header: p.Project + " % " + sh.name, Right: true})
+		}
+	}
+	for _, sh := range shares {
+		header := "Diff (pp)"
+		if len(shares) > 1 {
+			header = "Diff " + sh.name + " (pp)"
+		}
+		columns = append(columns, tableColumn{Header: header, Right: true})
+	}
+	table := newTextTable(columns...)
+
+	count := func(p *ProjectSummary, c string) int {
+		if a, ok := p.Analysis[c]; ok {
+			return a.Count
+		}
+		return 0
+	}
+	for _, c := range rows {
+		row := []string{c}
+		for _, p := range []*ProjectSummary{a, b} {
+			row = append(row, layout.Numbers.count(count(p, c)))
+			for _, sh := range shares {
+				row = append(row, fmt.Sprintf("%.1f%%", sh.of(p, c)))
+			}
+		}
+		for _, sh := range shares {
+			row = append(row, fmt.Sprintf("%+.1f", sh.of(b, c)-sh.of(a, c)))
+		}
+		table.addRow(row...)
+	}
+
+	// Footer rows put each project's value under its first share column
+	footer := func(label string, value func(p *ProjectSummary) string) {
+		row := []string{label}
+		for _, p := range []*ProjectSummary{a, b} {
+			row = append(row, "", value(p))
+			for range shares[1:] {
+				row = append(row, "")
+			}
+		}
+		table.addFooter(row...)
+	}
+	total := []string{"TOTAL"}
+	for _, p := range []*ProjectSummary{a, b} {
+		total = append(total, layout.Numbers.count(p.TotalCount))
+		for range shares {
+			total = append(total, "100.0%")
+		}
+	}
+	table.addFooter(total...)
+	footer("Total Mana", func(p *ProjectSummary) string { return layout.Numbers.mana(p.TotalMana) })
+	footer("People", func(p *ProjectSummary) string { return layout.Numbers.count(p.People) })
+	footer("Mana per Person", func(p *ProjectSummary) string { return layout.Numbers.mana(p.manaPerPerson()) })
+	fmt.Fprintln(w)
+	table.write(w, layout.Style)
+	fmt.Fprintf(w, "\nDiff is %s's share minus %s's, in percentage points. People are distinct assignees of the analyzed issues.\n",
+		b.Project, a.Project)
+}
+
+func runCompareProjectsCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	projectA := flag.String("a", "", "First JIRA project key")
+	projectB := flag.String("b", "", "Second JIRA project key")
+	brokenWindows := flag.Bool("broken-windows", false, "Consider tickets with ux-broken-window label as separate type")
+	security := flag.Bool("security", false, "Consider tickets linked to Product Vulnerability issues as a separate type")
+	securityDepth := flag.Int("security-depth", 1, securityDepthUsage)
+	research := defineResearchFlags()
+	tables := defineTableFlags()
+	overridesFile := flag.String("overrides", "", overridesUsage)
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectA == "" || *projectB == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	layout, err := tables.options("text")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, _, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Parse dates
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := newClassifyOptions(*brokenWindows, *security, config)
+	if err := research.apply(&opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSecurityDepth(*securityDepth); err != nil {
+		log.Fatal(err)
+	}
+	var overrides issueOverrides
+	if *overridesFile != "" {
+		if overrides, err = loadOverrides(*overridesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fields := []string{"issuetype", manaFieldID, "labels", "issuelinks", "assignee", "reporter"}
+
+	projects := []string{*projectA, *projectB}
+	jqlOf := func(project string) string {
+		return projectTicketJQLFilter(project, resolvedBetween(start, end, *endInclusive), config.fieldsOf(project))
+	}
+
+	// Validate the queries before fetching anything
+	for _, project := range projects {
+		if err := validateJQL(client, jqlOf(project)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Both projects are fetched at once
+	perProject, err := fetchProjectIssues(client, os.Stdout, projects, jqlOf, fields, config, nil)
+	if err != nil {
+		log.Fatalf("Error fetching %v", err)
+	}
+	for _, projectIssues := range perProject {
+		if err := applySecurityDepth(client, os.Stdout, projectIssues, *securityDepth, &opts); err != nil {
+			log.Fatal(err)
+		}
+		applyOverrides(os.Stdout, projectIssues, overrides, &opts)
+	}
+	var summaries []*ProjectSummary
+	for i, project := range projects {
+		summaries = append(summaries, summarizeProject(project, perProject[i], opts))
+	}
+
+	title := fmt.Sprintf("%s vs %s Mana Comparison %s to %s", *projectA, *projectB, *startDate, *endDate)
+	if err := writeFormatted("text", "", title, func(w io.Writer) {
+		writeProjectComparison(w, summaries[0], summaries[1], *startDate, *endDate, *endInclusive, layout)
+	}); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config is the optional theia configuration file
+type Config struct {
+	// OrgChart groups teams into groups and groups into orgs
+	OrgChart []OrgGroup `json:"orgChart"`
+	// Calendar is the business calendar of business time columns
+	Calendar *CalendarConfig `json:"calendar"`
+	// GitLab maps GitLab issues onto tickets for -source gitlab
+	GitLab *GitLabConfig `json:"gitlab"`
+	// Projects overrides the custom fields read per project key
+	Projects map[string]ProjectConfig `json:"projects"`
+	// Metrics defines the reports of the metric command, by name
+	Metrics map[string]MetricConfig `json:"metrics"`
+	// AutomationReporters are the bot and automation accounts whose issues
+	// every report leaves out, by account ID, user name or display name
+	AutomationReporters []string `json:"automationReporters"`
+}
+
+// OrgGroup is a group of teams within an org
+type OrgGroup struct {
+	Name  string   `json:"name"`
+	Org   string   `json:"org"`
+	Teams []string `json:"teams"`
+}
+
+// configUsage is the usage of the -config flag
+const configUsage = "Config file (default theia/config.json in the user config directory, if present)"
+
+// defaultConfigPath returns the path of the config file used without -config
+func defaultConfigPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "theia", "config.json"), nil
+}
+
+// loadConfig reads the config file at path. Without a path it reads the
+// default config file, if there is one.
+func loadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return &Config{}, nil
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if !explicit && errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+	config, err := parseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// parseConfig decodes and checks a config file. Unknown keys are rejected so
+// typos don't go unnoticed.
+func parseConfig(b []byte) (*Config, error) {
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	groupOf := make(map[string]string)
+	groups := make(map[string]bool)
+	for _, g := range config.OrgChart {
+		if g.Name == "" || g.Org == "" {
+			return nil, fmt.Errorf("invalid config: every orgChart group needs a name and an org")
+		}
+		if groups[g.Name] {
+			return nil, fmt.Errorf("invalid config: group %q is in the orgChart twice", g.Name)
+		}
+		groups[g.Name] = true
+		for _, team := range g.Teams {
+			if other, ok := groupOf[team]; ok {
+				return nil, fmt.Errorf("invalid config: team %q is in groups %q and %q", team, other, g.Name)
+			}
+			groupOf[team] = g.Name
+		}
+	}
+	if _, err := newBusinessCalendar(config.Calendar); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if config.GitLab != nil && config.GitLab.ManaPerWeight < 0 {
+		return nil, fmt.Errorf("invalid config: gitlab manaPerWeight must not be negative")
+	}
+	if err := checkProjectConfigs(config.Projects); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := checkMetricConfigs(config.Metrics); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := checkAutomationReporters(config.AutomationReporters); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &config, nil
+}
+
+// findOrgGroup returns the org chart group of a team, or nil if it has none
+func findOrgGroup(chart []OrgGroup, team string) *OrgGroup {
+	for i, g := range chart {
+		for _, t := range g.Teams {
+			if t == team {
+				return &chart[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// countCategory is a category counted with its own targeted JQL clause
+type countCategory struct {
+	Name   string
+	Clause string
+}
+
+// countIssues returns the number of issues matching the query without
+// downloading any of them, by searching with maxResults=0
+func countIssues(client *jira.Client, jql string) (int, error) {
+	uv := url.Values{}
+	uv.Add("jql", jql)
+	uv.Add("maxResults", "0")
+	uv.Add("fields", "key")
+
+	req, err := client.NewRequest("GET", "rest/api/2/search?"+uv.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	result := new(struct {
+		Total int `json:"total"`
+	})
+	resp, err := client.Do(req, result)
+	if err != nil {
+		return 0, fmt.Errorf("counting issues: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return result.Total, nil
+}
+
+// countCategories builds the category clauses for the project's issue types,
+// mirroring the classification used when issues are downloaded
+func countCategories(client *jira.Client, projectKey string, classify classifyOptions) ([]countCategory, error) {
+	project, resp, err := client.Project.Get(projectKey)
+	if err != nil {
+		return nil, fmt.Errorf("fetching project issue types: %s", describeJiraError(resp, err))
+	}
+
+	// Group the project's issue types by their normalized name. The clauses
+	// keep the names Jira returned, which its JQL accepts.
+	typesByCategory := make(map[string][]string)
+	for _, it := range project.IssueTypes {
+		name := jiraLabels.issueType(it.Name)
+		if name == "Epic" || name == "Initiative" {
+			continue
+		}
+		if classify.excluded(Issue{Type: name}) {
+			continue
+		}
+		category := typeCategory(name, classify)
+		typesByCategory[category] = append(typesByCategory[category], fmt.Sprintf("%q", it.Name))
+	}
+
+	var categories []countCategory
+	notBrokenWindow := ""
+	if classify.BrokenWindows {
+		categories = append(categories, countCategory{
+			Name:   "Broken Window",
+			Clause: `labels = "ux-broken-window"`,
+		})
+		notBrokenWindow = ` AND (labels is EMPTY OR labels not in ("ux-broken-window"))`
+	}
+	for category, types := range typesByCategory {
+		categories = append(categories, countCategory{
+			Name:   category,
+			Clause: fmt.Sprintf("issuetype in (%s)%s", strings.Join(types, ", "), notBrokenWindow),
+		})
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+// countPeriod counts the issues of every category for one period, and
+// returns the rows sorted by count along with the period total
+func countPeriod(client *jira.Client, jqlFilter string, categories []countCategory) ([]TicketAnalysis, int, error) {
+	total, err := countIssues(client, jqlFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []TicketAnalysis
+	categorized := 0
+	for _, c := range categories {
+		count, err := countIssues(client, fmt.Sprintf("%s AND %s", jqlFilter, c.Clause))
+		if err != nil {
+			return nil, 0, err
+		}
+		if count == 0 {
+			continue
+		}
+		categorized += count
+		results = append(results, TicketAnalysis{IssueType: c.Name, Count: count})
+	}
+
+	// Issue types that are no longer in the project scheme still count towards the total
+	if other := total - categorized; other > 0 {
+		results = append(results, TicketAnalysis{IssueType: "Other", Count: other})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Count > results[j].Count
+	})
+	return results, total, nil
+}
+
+// printCountTable prints category counts with their share of the total
+func printCountTable(results []TicketAnalysis, total int, period string, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Issue Type", MaxWidth: 30},
+		tableColumn{Header: "Count", Right: true},
+		tableColumn{Header: "% of Total", Right: true},
+	)
+	for _, r := range results {
+		percentOfTotalStr := ""
+		if total > 0 {
+			percentOfTotalStr = fmt.Sprintf("%.1f%%", float64(r.Count)/float64(total)*100)
+		}
+		table.addRow(r.IssueType, layout.Numbers.count(r.Count), percentOfTotalStr)
+	}
+	table.addFooter("TOTAL", layout.Numbers.count(total), "100.0%")
+
+	if period != "" {
+		fmt.Printf("\n%s\n", period)
+	}
+	table.write(os.Stdout, layout.Style)
+}
+
+// runCountOnly prints issue counts per category using targeted count
+// queries, optionally broken down by month, without fetching any issues
+func runCountOnly(client *jira.Client, projectKey, jqlFilter string, start, end time.Time, monthly bool, classify classifyOptions, layout tableOptions) error {
+	categories, err := countCategories(client, projectKey, classify)
+	if err != nil {
+		return err
+	}
+	if classify.Research == researchExclude && len(classify.ResearchTypes) > 0 {
+		var types []string
+		for t := range classify.ResearchTypes {
+			types = append(types, fmt.Sprintf("%q", t))
+		}
+		sort.Strings(types)
+		jqlFilter += fmt.Sprintf(" AND issuetype not in (%s)", strings.Join(types, ", "))
+	}
+
+	if monthly {
+		for _, current := range monthsInRange(start, end) {
+			monthFilter := fmt.Sprintf(`%s AND resolutiondate >= "%s" AND resolutiondate < "%s"`,
+				jqlFilter,
+				current.Format("2006-01-02"),
+				current.AddDate(0, 1, 0).Format("2006-01-02"))
+			results, total, err := countPeriod(client, monthFilter, categories)
+			if err != nil {
+				return err
+			}
+			printCountTable(results, total, fmt.Sprintf("Month: %s", current.Format(monthLabelFormat)), layout)
+		}
+
+		fmt.Printf("\nOVERALL SUMMARY:\n")
+	}
+
+	results, total, err := countPeriod(client, jqlFilter, categories)
+	if err != nil {
+		return err
+	}
+	printCountTable(results, total, "", layout)
+	return nil
+}
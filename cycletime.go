@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// CycleTime is the median lead and cycle time of one issue type, in calendar
+// days and in business days of the business calendar
+type CycleTime struct {
+	IssueType         string
+	LeadIssues        int // Issues with a creation and resolution time
+	LeadDays          float64
+	LeadBusinessDays  float64
+	StartedIssues     int // Issues that went through a start status
+	CycleDays         float64
+	CycleBusinessDays float64
+}
+
+// CycleTimes is the cycle time analysis of a report
+type CycleTimes struct {
+	Calendar string // Description of the business calendar
+	Types    []CycleTime
+	Overall  CycleTime
+}
+
+// workStarted returns when the issue first moved into one of the statuses,
+// according to its changelog
+func workStarted(ji jira.Issue, statuses map[string]bool) (time.Time, bool) {
+	if ji.Changelog == nil {
+		return time.Time{}, false
+	}
+	var first time.Time
+	for _, history := range ji.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil {
+			continue
+		}
+		for _, item := range history.Items {
+			if item.Field == "status" && statuses[strings.ToLower(item.ToString)] {
+				if first.IsZero() || created.Before(first) {
+					first = created
+				}
+			}
+		}
+	}
+	return first, !first.IsZero()
+}
+
+// cycleTimeValues collects the durations of one issue type
+type cycleTimeValues struct {
+	lead, leadBusiness, cycle, cycleBusiness []float64
+}
+
+// add adds the times of one issue, in days
+func (v *cycleTimeValues) add(created, started, resolved time.Time, cal *businessCalendar) {
+	if resolved.IsZero() {
+		return
+	}
+	if !created.IsZero() && !resolved.Before(created) {
+		v.lead = append(v.lead, resolved.Sub(created).Hours()/24)
+		v.leadBusiness = append(v.leadBusiness, cal.businessTime(created, resolved).Hours()/cal.workday().Hours())
+	}
+	if !started.IsZero() && !resolved.Before(started) {
+		v.cycle = append(v.cycle, resolved.Sub(started).Hours()/24)
+		v.cycleBusiness = append(v.cycleBusiness, cal.businessTime(started, resolved).Hours()/cal.workday().Hours())
+	}
+}
+
+// result returns the medians of the durations
+func (v *cycleTimeValues) result(issueType string) CycleTime {
+	return CycleTime{
+		IssueType:         issueType,
+		LeadIssues:        len(v.lead),
+		LeadDays:          calculateMedian(v.lead),
+		LeadBusinessDays:  calculateMedian(v.leadBusiness),
+		StartedIssues:     len(v.cycle),
+		CycleDays:         calculateMedian(v.cycle),
+		CycleBusinessDays: calculateMedian(v.cycleBusiness),
+	}
+}
+
+// analyzeCycleTimes computes the median lead time, from creation to
+// resolution, and cycle time, from the first move into a start status to
+// resolution, of every issue type. histories are the issues with their
+// creation times and changelogs, keyed by issue key.
+func analyzeCycleTimes(issues []Issue, histories map[string]jira.Issue, statuses map[string]bool, cal *businessCalendar, opts classifyOptions) *CycleTimes {
+	byType := make(map[string]*cycleTimeValues)
+	overall := &cycleTimeValues{}
+	for _, issue := range issues {
+		ji := histories[issue.Key]
+		created := issue.Created
+		if ji.Fields != nil && !time.Time(ji.Fields.Created).IsZero() {
+			created = time.Time(ji.Fields.Created)
+		}
+		started, _ := workStarted(ji, statuses)
+
+		issueType := classifyIssue(issue, opts)
+		if byType[issueType] == nil {
+			byType[issueType] = &cycleTimeValues{}
+		}
+		byType[issueType].add(created, started, issue.Resolved, cal)
+		overall.add(created, started, issue.Resolved, cal)
+	}
+
+	result := &CycleTimes{Calendar: cal.describe(), Overall: overall.result("Overall")}
+	for issueType, values := range byType {
+		result.Types = append(result.Types, values.result(issueType))
+	}
+	// Slowest issue types first
+	sort.Slice(result.Types, func(i, j int) bool {
+		if result.Types[i].LeadDays != result.Types[j].LeadDays {
+			return result.Types[i].LeadDays > result.Types[j].LeadDays
+		}
+		return result.Types[i].IssueType < result.Types[j].IssueType
+	})
+	return result
+}
+
+// fetchCycleTimes fetches the changelogs of the issues and analyzes their
+// lead and cycle times
+func fetchCycleTimes(client *jira.Client, changelogs *changelogCache, issues []Issue, statuses map[string]bool, cal *businessCalendar, opts classifyOptions) (*CycleTimes, error) {
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	withChangelog, err := fetchChangelogs(client, changelogs, keys)
+	if err != nil {
+		return nil, fmt.Errorf("fetching changelogs: %w", err)
+	}
+	histories := make(map[string]jira.Issue, len(withChangelog))
+	for _, ji := range withChangelog {
+		histories[ji.Key] = ji
+	}
+	return analyzeCycleTimes(issues, histories, statuses, cal, opts), nil
+}
+
+// writeCycleTimes writes the median lead and cycle times per issue type
+func writeCycleTimes(w io.Writer, c *CycleTimes, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Issue Type", MaxWidth: 30},
+		tableColumn{Header: "Issues", Right: true},
+		tableColumn{Header: "Lead Days", Right: true},
+		tableColumn{Header: "Lead Business Days", Right: true},
+		tableColumn{Header: "Started Issues", Right: true},
+		tableColumn{Header: "Cycle Days", Right: true},
+		tableColumn{Header: "Cycle Business Days", Right: true},
+	)
+	row := func(t CycleTime) []string {
+		cycle, cycleBusiness := "-", "-"
+		if t.StartedIssues > 0 {
+			cycle = layout.Numbers.decimal(t.CycleDays)
+			cycleBusiness = layout.Numbers.decimal(t.CycleBusinessDays)
+		}
+		return []string{t.IssueType,
+			layout.Numbers.count(t.LeadIssues),
+			layout.Numbers.decimal(t.LeadDays),
+			layout.Numbers.decimal(t.LeadBusinessDays),
+			layout.Numbers.count(t.StartedIssues),
+			cycle,
+			cycleBusiness}
+	}
+	for _, t := range c.Types {
+		table.addRow(row(t)...)
+	}
+	overall := row(c.Overall)
+	overall[0] = "TOTAL"
+	table.addFooter(overall...)
+
+	fmt.Fprintf(w, "\nLead and Cycle Time (medians):\n")
+	table.write(w, layout.Style)
+	fmt.Fprintf(w, "Lead time runs from creation, cycle time from the first start status, to resolution. Business days count working hours (%s) only.\n", c.Calendar)
+}
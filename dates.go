@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRangeDays is the longest date range run without confirmation
+const maxRangeDays = 2 * 366
+
+// assumeYes answers confirmation prompts, set by the -yes global flag
+var assumeYes bool
+
+// yearRegex matches a year given as a period, e.g. 2024
+var yearRegex = regexp.MustCompile(`^\d{4}$`)
+
+// agoRegex matches days relative to today such as "3 days ago"
+var agoRegex = regexp.MustCompile(`^(\d+) (day|week|month)s? ago$`)
+
+// weekdays are the weekday names of "last friday", by time.Weekday
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// periodBounds returns the first and last day of a period: a year (2024), a
+// quarter (2024-Q3 or 2024Q3), a month (2024-07), or this or last week,
+// month, quarter or year relative to today. Weeks start on Monday. ok is
+// false if s is not a period.
+func periodBounds(s string, today time.Time) (first, last time.Time, ok bool, err error) {
+	switch {
+	case yearRegex.MatchString(s):
+		year, _ := strconv.Atoi(s)
+		first = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(1, 0, -1), true, nil
+	case quarterRegex.MatchString(s):
+		first, last, err = parseQuarter(s)
+		return first, last, true, err
+	case len(s) == 7 && s[4] == '-':
+		first, last, err = parseMonth(s)
+		return first, last, true, err
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 2 || (fields[0] != "this" && fields[0] != "last") {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	back := 0
+	if fields[0] == "last" {
+		back = 1
+	}
+	switch fields[1] {
+	case "week":
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7-7*back)
+		return monday, monday.AddDate(0, 0, 6), true, nil
+	case "month":
+		first = time.Date(today.Year(), today.Month()-time.Month(back), 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 1, -1), true, nil
+	case "quarter":
+		first = time.Date(today.Year(), today.Month()-(today.Month()-1)%3-time.Month(3*back), 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 3, -1), true, nil
+	case "year":
+		first = time.Date(today.Year()-back, 1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(1, 0, -1), true, nil
+	}
+	return time.Time{}, time.Time{}, false, nil
+}
+
+// parseDate parses a start or end date relative to today: YYYY-MM-DD, a
+// period, standing for its first day as a start and its last day as an end,
+// or a day: today, yesterday, last <weekday>, or N days, weeks or months ago
+func parseDate(s string, end bool, today time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if d, err := time.Parse("2006-01-02", s); err == nil {
+		return d, nil
+	} else if len(s) == 10 && s[4] == '-' && s[7] == '-' {
+		return time.Time{}, fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	first, last, ok, err := periodBounds(s, today)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ok {
+		if end {
+			return last, nil
+		}
+		return first, nil
+	}
+
+	switch s {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if m := agoRegex.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "day":
+			return today.AddDate(0, 0, -n), nil
+		case "week":
+			return today.AddDate(0, 0, -7*n), nil
+		}
+		return today.AddDate(0, -n, 0), nil
+	}
+	if name, ok := strings.CutPrefix(s, "last "); ok {
+		for wd, day := range weekdays {
+			if name == day {
+				back := (int(today.Weekday())-wd+6)%7 + 1
+				return today.AddDate(0, 0, -back), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q, expected YYYY-MM-DD, a period such as 2024, 2024-Q3, 2024-07 or \"last month\", or a day such as \"yesterday\", \"last friday\" or \"3 weeks ago\"", s)
+}
+
+// currentDay returns the current day as midnight UTC
+func currentDay() time.Time {
+	return dayOf(time.Now())
+}
+
+// parseRange parses the start and end dates of a report, in YYYY-MM-DD
+// format or any other form parseDate accepts
+func parseRange(startDate, endDate string) (time.Time, time.Time, error) {
+	now := currentDay()
+	start, err := parseDate(startDate, false, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %w", err)
+	}
+	end, err := parseDate(endDate, true, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %w", err)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date %s is before start date %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+	return start, end, nil
+}
+
+// resolveRange parses the -start and -end flags, rewrites them as YYYY-MM-DD
+// so reports show the dates resolved, and asks for confirmation of ranges
+// longer than maxRangeDays
+func resolveRange(startDate, endDate *string) (time.Time, time.Time, error) {
+	start, end, err := parseRange(*startDate, *endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	*startDate, *endDate = start.Format("2006-01-02"), end.Format("2006-01-02")
+	if days := int(end.Sub(start).Hours()/24) + 1; days > maxRangeDays {
+		question := fmt.Sprintf("The range %s to %s spans %d days, which fetches a lot of issues.", *startDate, *endDate, days)
+		if err := confirm(question); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return start, end, nil
+}
+
+// confirm asks on the terminal whether to go on. Without a terminal, such as
+// in scheduled runs, it refuses unless -yes was given.
+func confirm(question string) error {
+	if assumeYes {
+		return nil
+	}
+	refused := fmt.Errorf("%s Pass -yes before the command to run it anyway", question)
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return refused
+	}
+	fmt.Fprintf(os.Stderr, "%s Continue? [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		// Nothing to read, as from /dev/null
+		fmt.Fprintln(os.Stderr)
+		return refused
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("cancelled, the range was not confirmed")
+}
+
+// resolvedBetween is the JQL clause selecting the issues resolved in the
+// range. Jira reads a bare date as midnight at its start, so the default end
+// bound leaves out issues resolved later on the end day; inclusive ranges
+// bound by the next day instead.
+func resolvedBetween(start, end time.Time, inclusive bool) string {
+	if inclusive {
+		return fmt.Sprintf(`resolutiondate >= "%s" AND
+		resolutiondate < "%s"`, start.Format("2006-01-02"), end.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	return fmt.Sprintf(`resolutiondate >= "%s" AND
+		resolutiondate <= "%s"`, start.Format("2006-01-02"), end.Format("2006-01-02"))
+}
+
+// endBoundaryNote tells which issues resolved on the end day a report counts
+func endBoundaryNote(end string, inclusive bool) string {
+	if inclusive {
+		return fmt.Sprintf("End Date: inclusive, issues resolved at any time on %s count", end)
+	}
+	return fmt.Sprintf("End Date: midnight at the start of %s, issues resolved later that day are left out (-end-inclusive counts them)", end)
+}
+
+// endInclusiveUsage is the usage of the -end-inclusive flag
+const endInclusiveUsage = "Count issues resolved at any time on the end date, querying before the next day instead of Jira's midnight reading of the end date"
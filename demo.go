@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// demoRange is the flags selecting the period of the fixture data
+const demoRange = "-project PROJ -start 2024-01-01 -end 2024-03-31"
+
+// demoOutputs are the outputs of the demo, each the selftest output of a
+// command line run against the bundled fixture data. SHOP is the fixture
+// GitLab project, standing in for a second Jira project.
+var demoOutputs = []struct {
+	Name    string // Selftest output, and file name with -out-dir
+	Command string
+}{
+	{"ticket.txt", "ticket " + demoRange},
+	{"ticket-teams.txt", "ticket " + demoRange + " -teams -broken-windows -security"},
+	{"ticket-teams.md", "ticket " + demoRange + " -teams -broken-windows -security -table-style markdown"},
+	{"ticket-teams.pdf", "ticket " + demoRange + " -teams -broken-windows -security -format pdf"},
+	{"ticket-org.txt", "ticket " + demoRange + " -teams, with an orgChart in the config file"},
+	{"ticket-monthly.txt", "ticket " + demoRange + " -monthly -stats mean,median,p90,stddev"},
+	{"ticket-box.txt", "ticket " + demoRange + " -table-style box -precision 1 -trim-whole -thousands-sep , -shares both"},
+	{"ticket-cycle.txt", "ticket " + demoRange + " -cycle-time"},
+	{"ticket-mana-time.txt", "ticket " + demoRange + " -teams -mana-units time -thousands-sep ,"},
+	{"ticket-overrides.txt", "ticket " + demoRange + " -overrides overrides.csv"},
+	{"ticket-locale.txt", "-locale-file de.json ticket " + demoRange + " -security, on a German Jira"},
+	{"ticket-emit-jql.txt", "ticket " + demoRange + " -teams -broken-windows -security -overrides overrides.csv -emit-jql"},
+	{"ticket-keys.txt", `ticket -keys "PROJ-1, proj-2 PROJ-10,PROJ-11,PROJ-12,PROJ-13,PROJ-404,PROJ-1"`},
+	{"ticket-gitlab.txt", "ticket -source gitlab -project acme/shop -start 2024-01-01 -end 2024-03-31 -teams -broken-windows"},
+	{"ticket-webhook.json", "ticket " + demoRange + " -teams -broken-windows -security -format json"},
+	{"ticket-otlp.json", "ticket " + demoRange + " -teams -broken-windows -security -otlp-endpoint URL, the payload"},
+	{"ticket-msteams.json", "ticket " + demoRange + " -msteams-webhook-url URL -report-url https://reports.example.com/proj/2024-q1.html"},
+	{"explain.csv", "ticket " + demoRange + " -broken-windows -security -research story -explain-classification explain.csv"},
+	{"compare-projects.txt", "compare-projects -a PROJ -b SHOP -start 2024-01-01 -end 2024-03-31"},
+	{"epic.txt", "epic " + demoRange},
+	{"epic-value.txt", "epic " + demoRange + ` -value-field "Business Value"`},
+	{"epic-slip.txt", "epic " + demoRange + " -slip"},
+	{"epic-eta.txt", "epic " + demoRange + " -eta"},
+	{"epic-dormancy.txt", "epic " + demoRange + " -dormancy"},
+	{"epic-status.txt", "epic " + demoRange + " -child-status"},
+	{"epic-sparkline.txt", "epic " + demoRange + " -sparkline"},
+	{"epic-category-mix.txt", "epic " + demoRange + " -category-mix -broken-windows -security"},
+	{"epic-dri.txt", "epic " + demoRange + " -dri"},
+	{"epic.ics", "epic " + demoRange + " -ics epic.ics -ics-milestones"},
+	{"epic-diff.txt", "epic-diff -epic PROJ-300 -at 2024-01-31 -vs 2024-03-31"},
+	{"orphans.txt", "orphans " + demoRange},
+	{"labels.txt", "labels " + demoRange + ` -group "area-.*"`},
+	{"churn.txt", "churn " + demoRange},
+	{"sizes.txt", "sizes " + demoRange},
+	{"balance.txt", "balance " + demoRange + " -sustained 2"},
+	{"metric.txt", "metric bug-mana-by-group, with the metric in the config file"},
+	{"incidents.txt", "incidents -project PROJ -incidents incidents.csv -label-prefix area- -window 30"},
+	{"calibrate.txt", "calibrate -project PROJ -month 2024-02 -broken-windows -security"},
+	{"calibrate-task.txt", "calibrate -project PROJ -month 2024-02 -broken-windows -security -create-task, the task description"},
+	{"report-v0-converted.json", "convert-json -input report-v0.json"},
+	{"archive-index.html", `index -dir reports -title "Engineering reports"`},
+	{"archive-index.json", `index -dir reports -title "Engineering reports"`},
+}
+
+// demoCases are the renderings of the demo that the selftest doesn't cover
+var demoCases = []selftestCase{
+	{"compare-projects.txt", func(fx *selftestFixtures) ([]byte, error) {
+		shop := selectGitLabIssues(fx.GitLabIssues, gitlabMapping(nil))
+		var buf bytes.Buffer
+		writeProjectComparison(&buf,
+			summarizeProject(fixtureProject, fx.Tickets, classifyOptions{}),
+			summarizeProject("SHOP", shop, classifyOptions{}),
+			fixtureStart, fixtureEnd, false, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+}
+
+// demoRender returns the rendering of the demo output
+func demoRender(name string) func(fx *selftestFixtures) ([]byte, error) {
+	for _, c := range append(append([]selftestCase{}, selftestCases...), demoCases...) {
+		if c.Golden == name {
+			return c.Render
+		}
+	}
+	return nil
+}
+
+func runDemoCommand() {
+	list := flag.Bool("list", false, "List the demo outputs and their command lines")
+	only := flag.String("only", "", "Comma-separated demo outputs to show, e.g. ticket-teams.txt,epic.txt (default all)")
+	outDir := flag.String("out-dir", "", "Write every output to a file in this directory instead of stdout, PDFs included")
+	flag.Parse()
+
+	if *list {
+		for _, o := range demoOutputs {
+			fmt.Printf("%-26s %s\n", o.Name, o.Command)
+		}
+		return
+	}
+	known := make(map[string]bool)
+	for _, o := range demoOutputs {
+		known[o.Name] = true
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !known[name] {
+			log.Fatalf("unknown demo output %q, -list shows them", name)
+		}
+		selected[name] = true
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			log.Fatalf("Error creating output directory: %v", err)
+		}
+	}
+
+	fx, err := loadSelftestFixtures()
+	if err != nil {
+		log.Fatalf("Error loading demo data: %v", err)
+	}
+	fmt.Printf("Demo of theia over synthetic data: %d tickets and %d epics of project PROJ resolved in Q1 2024, and %d GitLab issues of project SHOP. No Jira credentials are used; the command lines show how to get each output from your instance.\n",
+		len(fx.Tickets), len(fx.Epics), len(fx.GitLabIssues))
+	for _, o := range demoOutputs {
+		if len(selected) > 0 && !selected[o.Name] {
+			continue
+		}
+		out, err := demoRender(o.Name)(fx)
+		if err != nil {
+			log.Fatalf("Error rendering %s: %v", o.Name, err)
+		}
+		if *outDir != "" {
+			path := filepath.Join(*outDir, o.Name)
+			if err := os.WriteFile(path, out, 0o644); err != nil {
+				log.Fatalf("Error writing %s: %v", o.Name, err)
+			}
+			fmt.Printf("%s: %s\n", path, o.Command)
+			continue
+		}
+		fmt.Printf("\n==> %s: %s\n", o.Name, o.Command)
+		if filepath.Ext(o.Name) == ".pdf" {
+			fmt.Printf("(PDF, written with -out-dir)\n")
+			continue
+		}
+		os.Stdout.Write(out)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// blocksLinkType is the link type whose inward side reads "is blocked by"
+const blocksLinkType = "Blocks"
+
+// keyQueryBatch is the number of keys looked up per "key in (...)" query
+const keyQueryBatch = 50
+
+// TeamWait is the time the issues of one team spent waiting on blockers
+// owned by other teams
+type TeamWait struct {
+	Team          string
+	BlockedIssues int
+	WaitDays      float64
+	// WaitManaDays weighs every day waited by the mana of the waiting issue
+	WaitManaDays float64
+}
+
+// blockerKeys returns the keys of the issues blocking the issue
+func blockerKeys(issue Issue) []string {
+	var keys []string
+	for _, link := range issue.Links {
+		if link.Type == blocksLinkType && !link.Outward {
+			keys = append(keys, link.Key)
+		}
+	}
+	return keys
+}
+
+// searchByKeys fetches the issues with the given keys in batches
+func searchByKeys(client *jira.Client, keys []string, fields []string, expand string) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	for len(keys) > 0 {
+		n := keyQueryBatch
+		if n > len(keys) {
+			n = len(keys)
+		}
+		jql := fmt.Sprintf("key in (%s)", strings.Join(keys[:n], ", "))
+		keys = keys[n:]
+
+		found, err := searchRange(client, jql, fields, expand, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, found...)
+	}
+	return issues, nil
+}
+
+// linkAddedTimes returns when each issue link was last added, keyed by the
+// linked issue key, according to the issue's changelog
+func linkAddedTimes(ji jira.Issue) map[string]time.Time {
+	added := make(map[string]time.Time)
+	if ji.Changelog == nil {
+		return added
+	}
+	for _, history := range ji.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil {
+			continue
+		}
+		for _, item := range history.Items {
+			key, ok := item.To.(string)
+			if item.Field != "Link" || !ok || key == "" {
+				continue
+			}
+			if created.After(added[key]) {
+				added[key] = created
+			}
+		}
+	}
+	return added
+}
+
+// waitInterval is a period an issue spent waiting on a blocker
+type waitInterval struct {
+	Start, End time.Time
+}
+
+// externalWait returns the days the issue waited on blockers of other
+// teams. Each wait runs from when the blocker was linked, or the issue was
+// created if the link is older than the changelog, until the blocker or the
+// issue was resolved. Overlapping waits count once.
+func externalWait(issue Issue, blockers map[string]Issue, linkAdded map[string]time.Time) float64 {
+	var waits []waitInterval
+	for _, key := range blockerKeys(issue) {
+		blocker, ok := blockers[key]
+		if !ok || blocker.Team == "" || blocker.Team == issue.Team {
+			continue
+		}
+
+		start := issue.Created
+		if t, ok := linkAdded[key]; ok && t.After(start) {
+			start = t
+		}
+		end := issue.Resolved
+		if !blocker.Resolved.IsZero() && blocker.Resolved.Before(end) {
+			end = blocker.Resolved
+		}
+		if end.After(start) {
+			waits = append(waits, waitInterval{start, end})
+		}
+	}
+
+	// Merge overlapping waits so waiting on two teams at once counts once
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i].Start.Before(waits[j].Start)
+	})
+	var total time.Duration
+	var current waitInterval
+	for i, w := range waits {
+		if i > 0 && !w.Start.After(current.End) {
+			if w.End.After(current.End) {
+				current.End = w.End
+			}
+			continue
+		}
+		if i > 0 {
+			total += current.End.Sub(current.Start)
+		}
+		current = w
+	}
+	if len(waits) > 0 {
+		total += current.End.Sub(current.Start)
+	}
+	return total.Hours() / 24
+}
+
+// fetchExternalWaits looks up the blockers and changelogs of the blocked
+// issues and aggregates their waits on other teams per team of the waiting
+// issue, sorted by wait mana-days
+func fetchExternalWaits(client *jira.Client, changelogs *changelogCache, issues []Issue) ([]TeamWait, error) {
+	var blockedKeys, blockerKeyList []string
+	seen := make(map[string]bool)
+	for _, issue := range issues {
+		keys := blockerKeys(issue)
+		if len(keys) == 0 {
+			continue
+		}
+		blockedKeys = append(blockedKeys, issue.Key)
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				blockerKeyList = append(blockerKeyList, key)
+			}
+		}
+	}
+	if len(blockedKeys) == 0 {
+		return []TeamWait{}, nil
+	}
+
+	found, err := searchByKeys(client, blockerKeyList, []string{"resolutiondate", teamFieldID}, "")
+	if err != nil {
+		return nil, fmt.Errorf("fetching blockers: %w", err)
+	}
+	blockers := make(map[string]Issue, len(found))
+	for _, b := range issuesFromJira(found) {
+		blockers[b.Key] = b
+	}
+
+	withChangelog, err := fetchChangelogs(client, changelogs, blockedKeys)
+	if err != nil {
+		return nil, fmt.Errorf("fetching changelogs: %w", err)
+	}
+	linkAdded := make(map[string]map[string]time.Time, len(withChangelog))
+	created := make(map[string]time.Time, len(withChangelog))
+	for _, ji := range withChangelog {
+		linkAdded[ji.Key] = linkAddedTimes(ji)
+		created[ji.Key] = issueFromJira(ji).Created
+	}
+
+	// The analyzed issues are fetched without their creation time
+	waiting := make([]Issue, 0, len(issues))
+	for _, issue := range issues {
+		if t, ok := created[issue.Key]; ok {
+			issue.Created = t
+		}
+		waiting = append(waiting, issue)
+	}
+	return aggregateExternalWaits(waiting, blockers, linkAdded), nil
+}
+
+// aggregateExternalWaits sums the external waits of the issues per team
+func aggregateExternalWaits(issues []Issue, blockers map[string]Issue, linkAdded map[string]map[string]time.Time) []TeamWait {
+	byTeam := make(map[string]*TeamWait)
+	for _, issue := range issues {
+		days := externalWait(issue, blockers, linkAdded[issue.Key])
+		if days == 0 {
+			continue
+		}
+
+		team := teamDimension.Group(issue)
+		w, ok := byTeam[team]
+		if !ok {
+			w = &TeamWait{Team: team}
+			byTeam[team] = w
+		}
+		w.BlockedIssues++
+		w.WaitDays += days
+		w.WaitManaDays += days * issue.Mana
+	}
+
+	waits := []TeamWait{}
+	for _, w := range byTeam {
+		waits = append(waits, *w)
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		if waits[i].WaitManaDays != waits[j].WaitManaDays {
+			return waits[i].WaitManaDays > waits[j].WaitManaDays
+		}
+		return waits[i].Team < waits[j].Team
+	})
+	return waits
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RowQuery is the JQL selecting the issues of one row of the ticket report
+type RowQuery struct {
+	Table string // e.g. "Team: Alpha", or "Overall" for the summary
+	Row   string // Category, or TOTAL for the footer
+	Mana  float64
+	Keys  []string
+	JQL   string
+}
+
+// rowScope is what the row queries narrow down: the report's query without
+// its ORDER BY, the issues it returned and the fields of their projects
+type rowScope struct {
+	Filter string
+	Issues []Issue
+	// Fields are the fields of each project of the report, by key; a single
+	// entry under "" stands for every project
+	Fields map[string]projectFields
+}
+
+// rowClause is a JQL clause and the same condition evaluated on an issue,
+// which tells the issues the clause selects from those of the report
+type rowClause struct {
+	JQL   string
+	Match func(Issue) bool
+}
+
+// allOf joins the clauses with AND, leaving out empty ones
+func allOf(clauses ...rowClause) rowClause {
+	var parts []string
+	var matches []func(Issue) bool
+	for _, c := range clauses {
+		if c.JQL != "" {
+			parts = append(parts, c.JQL)
+			matches = append(matches, c.Match)
+		}
+	}
+	return rowClause{
+		JQL: strings.Join(parts, " AND\n\t\t"),
+		Match: func(issue Issue) bool {
+			for _, m := range matches {
+				if !m(issue) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// jqlValues quotes the values as a JQL list, e.g. ("Bug", "Defect")
+func jqlValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = jqlString(v)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// sortedSet returns the members of the set in order
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// teamClause selects the issues whose team is one of the teams or, if
+// others is set, the issues whose team is none of them, including those
+// without a team; with others and no teams, those without a team. Jira
+// matches the Team field on team IDs, taken from the issues of the teams.
+// Projects with their own Team field are each matched on it. It returns
+// false if a team of the issues has no ID to select it by.
+func (s rowScope) teamClause(teams []string, others bool) (rowClause, bool) {
+	set := make(map[string]bool)
+	for _, t := range teams {
+		set[t] = true
+	}
+	ids := make(map[string]bool)
+	for _, issue := range s.Issues {
+		if set[issue.Team] {
+			if issue.TeamID == "" {
+				return rowClause{}, false
+			}
+			ids[issue.TeamID] = true
+		}
+	}
+	if others && len(teams) > 0 && len(ids) == 0 {
+		// No issue is of the teams, so every issue is of another one
+		return rowClause{}, true
+	}
+	if !others && len(ids) == 0 {
+		return rowClause{}, false
+	}
+	values := jqlValues(sortedSet(ids))
+	on := func(ref string) string {
+		switch {
+		case !others:
+			return fmt.Sprintf("%s in %s", ref, values)
+		case len(teams) == 0:
+			return ref + " is EMPTY"
+		default:
+			return fmt.Sprintf("(%s is EMPTY OR %s not in %s)", ref, ref, values)
+		}
+	}
+
+	refs := make(map[string]bool)
+	for _, fields := range s.Fields {
+		refs[fields.teamJQL()] = true
+	}
+	var jql string
+	if len(refs) == 1 {
+		for ref := range refs {
+			jql = on(ref)
+		}
+	} else {
+		var parts []string
+		for _, project := range sortedSet(keySet(s.Fields)) {
+			parts = append(parts, fmt.Sprintf("project = %s AND %s", jqlString(project), on(s.Fields[project].teamJQL())))
+		}
+		jql = "((" + strings.Join(parts, ") OR (") + "))"
+	}
+	if others && len(teams) == 0 {
+		return rowClause{JQL: jql, Match: func(issue Issue) bool { return issue.Team == "" }}, true
+	}
+	return rowClause{JQL: jql, Match: func(issue Issue) bool { return ids[issue.TeamID] != others }}, true
+}
+
+// keySet returns the keys of the project fields as a set
+func keySet(fields map[string]projectFields) map[string]bool {
+	set := make(map[string]bool)
+	for k := range fields {
+		set[k] = true
+	}
+	return set
+}
+
+// tableClause selects the issues of the named table of a level: a team, a
+// group or org of the org chart, or a month. It returns false for tables no
+// clause expresses.
+func (s rowScope) tableClause(level, name string, chart []OrgGroup) (rowClause, bool) {
+	var charted []string
+	for _, g := range chart {
+		charted = append(charted, g.Teams...)
+	}
+	switch level {
+	case "Team":
+		if name == "No Team" {
+			return s.teamClause(nil, true)
+		}
+		return s.teamClause([]string{name}, false)
+	case "Group", "Org":
+		if name == "No Group" || name == "No Org" {
+			if len(charted) == 0 {
+				return rowClause{}, true
+			}
+			return s.teamClause(charted, true)
+		}
+		var teams []string
+		for _, g := range chart {
+			if (level == "Group" && g.Name == name) || (level == "Org" && g.Org == name) {
+				teams = append(teams, g.Teams...)
+			}
+		}
+		return s.teamClause(teams, false)
+	case "Month":
+		month, _ := time.Parse(monthLabelFormat, name)
+		next := month.AddDate(0, 1, 0)
+		return rowClause{
+			JQL: fmt.Sprintf(`resolutiondate >= "%s" AND resolutiondate < "%s"`, month.Format("2006-01-02"), next.Format("2006-01-02")),
+			Match: func(issue Issue) bool {
+				return !issue.Resolved.Before(month) && issue.Resolved.Before(next)
+			},
+		}, true
+	}
+	return rowClause{}, true
+}
+
+// exclusionClause leaves out the issues the analysis excludes: those of
+// automation reporters, by the reporters found among the issues, and
+// research issues with -research exclude
+func (s rowScope) exclusionClause(opts classifyOptions) rowClause {
+	reporters := make(map[string]bool)
+	for _, issue := range s.Issues {
+		if opts.isAutomation(issue) && issue.Reporter != "" {
+			reporters[issue.Reporter] = true
+		}
+	}
+	var clauses []rowClause
+	if len(reporters) > 0 {
+		clauses = append(clauses, rowClause{
+			JQL:   fmt.Sprintf("(reporter is EMPTY OR reporter not in %s)", jqlValues(sortedSet(reporters))),
+			Match: func(issue Issue) bool { return !reporters[issue.Reporter] },
+		})
+	}
+	if opts.Research == researchExclude && len(opts.ResearchTypes) > 0 {
+		clauses = append(clauses, rowClause{
+			JQL:   "issuetype not in " + jqlValues(sortedSet(opts.ResearchTypes)),
+			Match: func(issue Issue) bool { return !opts.ResearchTypes[issue.Type] },
+		})
+	}
+	return allOf(clauses...)
+}
+
+// categoryClause selects the issues of a category, following the precedence
+// of the classification: broken windows by their label, security issues by
+// their links to the Product Vulnerabilities found among the issues, and
+// the rest by the issue types of the category. It returns false for
+// categories no clause expresses, such as those of overrides only.
+func (s rowScope) categoryClause(category string, opts classifyOptions) (rowClause, bool) {
+	vulnerabilities := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, issue := range s.Issues {
+		for _, link := range issue.Links {
+			if link.IssueType == vulnerabilityIssueType {
+				vulnerabilities[link.Key] = true
+			}
+		}
+		if typeCategory(issue.Type, opts) == category {
+			types[issue.Type] = true
+		}
+	}
+
+	brokenWindow := rowClause{
+		JQL:   "labels = " + jqlString(brokenWindowLabel),
+		Match: func(issue Issue) bool { return issue.HasLabel(brokenWindowLabel) },
+	}
+	notBrokenWindow := rowClause{
+		JQL:   fmt.Sprintf("(labels is EMPTY OR labels not in (%s))", jqlString(brokenWindowLabel)),
+		Match: func(issue Issue) bool { return !issue.HasLabel(brokenWindowLabel) },
+	}
+	var linked []string
+	for _, key := range sortedSet(vulnerabilities) {
+		linked = append(linked, fmt.Sprintf("issue in linkedIssues(%s)", jqlString(key)))
+	}
+	linkedMatch := func(issue Issue) bool {
+		for _, link := range issue.Links {
+			if vulnerabilities[link.Key] {
+				return true
+			}
+		}
+		return false
+	}
+	vulnerable := rowClause{JQL: "(" + strings.Join(linked, " OR ") + ")", Match: linkedMatch}
+	notVulnerable := rowClause{JQL: "NOT (" + strings.Join(linked, " OR ") + ")", Match: func(issue Issue) bool { return !linkedMatch(issue) }}
+
+	var precedence []rowClause
+	if opts.BrokenWindows {
+		if category == brokenWindowCategory {
+			return brokenWindow, true
+		}
+		precedence = append(precedence, notBrokenWindow)
+	}
+	if opts.Security {
+		if category == securityCategory {
+			if len(linked) == 0 {
+				return rowClause{}, false
+			}
+			return allOf(append(precedence, vulnerable)...), true
+		}
+		if len(linked) > 0 {
+			precedence = append(precedence, notVulnerable)
+		}
+	}
+	if len(types) == 0 {
+		return rowClause{}, false
+	}
+	byType := rowClause{
+		JQL:   "issuetype in " + jqlValues(sortedSet(types)),
+		Match: func(issue Issue) bool { return types[issue.Type] },
+	}
+	return allOf(append(precedence, byType)...), true
+}
+
+// rowJQL returns the query of a row: the report's filter and the row's
+// clause, corrected by key for the issues the clause gets wrong, such as
+// overridden or transitively linked tickets. Rows without a clause list
+// their keys.
+func (s rowScope) rowJQL(clause rowClause, ok bool, keys []string) string {
+	if !ok {
+		return keysJQL(keys) + " ORDER BY key"
+	}
+	inRow := make(map[string]bool)
+	for _, key := range keys {
+		inRow[key] = true
+	}
+	var extra, missing []string
+	selected := make(map[string]bool)
+	for _, issue := range s.Issues {
+		if clause.Match(issue) {
+			selected[issue.Key] = true
+			if !inRow[issue.Key] {
+				extra = append(extra, issue.Key)
+			}
+		}
+	}
+	for _, key := range keys {
+		if !selected[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	jql := s.Filter
+	if clause.JQL != "" {
+		jql += " AND\n\t\t" + clause.JQL
+	}
+	if len(extra) > 0 {
+		sortIssueKeys(extra)
+		jql += " AND\n\t\tkey not in (" + strings.Join(extra, ", ") + ")"
+	}
+	if len(missing) > 0 {
+		sortIssueKeys(missing)
+		jql = "(" + jql + ") OR\n\t\t" + keysJQL(missing)
+	}
+	return jql + "\n\t\tORDER BY key"
+}
+
+// sortIssueKeys sorts issue keys by project, then by number, as Jira does,
+// e.g. PROJ-9 before PROJ-10
+func sortIssueKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		pi, ni, _ := strings.Cut(keys[i], "-")
+		pj, nj, _ := strings.Cut(keys[j], "-")
+		if pi != pj {
+			return pi < pj
+		}
+		a, errA := strconv.Atoi(ni)
+		b, errB := strconv.Atoi(nj)
+		if errA != nil || errB != nil || a == b {
+			return ni < nj
+		}
+		return a < b
+	})
+}
+
+// rowQueryTable collects the keys of the rows of one table
+type rowQueryTable struct {
+	level string // "Team", "Group", "Org" or "Month", "" for the summary
+	name  string
+	rows  map[string]*RowQuery
+	total RowQuery
+}
+
+// add records the issue in the row of the category and in the footer
+func (t *rowQueryTable) add(issue Issue, category string) {
+	row := t.rows[category]
+	if row == nil {
+		row = &RowQuery{Table: t.total.Table, Row: category}
+		t.rows[category] = row
+	}
+	row.Keys = append(row.Keys, issue.Key)
+	row.Mana += issue.Mana
+	t.total.Keys = append(t.total.Keys, issue.Key)
+	t.total.Mana += issue.Mana
+}
+
+// queries returns the rows in the order of the report, most mana first,
+// then the footer, each with its JQL
+func (t *rowQueryTable) queries(s rowScope, opts ticketOptions) []RowQuery {
+	level, tableOK := s.tableClause(t.level, t.name, opts.OrgChart)
+	table := allOf(level, s.exclusionClause(opts.Classify))
+
+	var rows []RowQuery
+	for _, row := range t.rows {
+		sortIssueKeys(row.Keys)
+		category, ok := s.categoryClause(row.Row, opts.Classify)
+		row.JQL = s.rowJQL(allOf(table, category), ok && tableOK, row.Keys)
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Mana != rows[j].Mana {
+			return rows[i].Mana > rows[j].Mana
+		}
+		return rows[i].Row < rows[j].Row
+	})
+	sortIssueKeys(t.total.Keys)
+	t.total.JQL = s.rowJQL(table, tableOK, t.total.Keys)
+	return append(rows, t.total)
+}
+
+// ticketRowQueries groups the issues into the tables and rows of the ticket
+// report analyzeTickets builds with the same options, and returns the JQL of
+// every row with issues: the team, group and org tables with -teams, else
+// the month tables with -monthly, then the overall summary
+func ticketRowQueries(s rowScope, issues []Issue, opts ticketOptions) []RowQuery {
+	type level struct {
+		title     string
+		dimension Dimension
+	}
+	var levels []level
+	if opts.Teams {
+		levels = append(levels, level{"Team", teamDimension})
+		if len(opts.OrgChart) > 0 {
+			group, org := orgChartDimensions(opts.OrgChart)
+			levels = append(levels, level{"Group", group}, level{"Org", org})
+		}
+	} else if opts.Monthly {
+		levels = append(levels, level{"Month", monthDimension(monthsInRange(opts.Start, opts.End))})
+	}
+
+	newTable := func(level, name string) *rowQueryTable {
+		title := "Overall"
+		if level != "" {
+			title = level + ": " + name
+		}
+		return &rowQueryTable{level: level, name: name, rows: make(map[string]*RowQuery), total: RowQuery{Table: title, Row: "TOTAL"}}
+	}
+	tables := make([]map[string]*rowQueryTable, len(levels))
+	for i := range tables {
+		tables[i] = make(map[string]*rowQueryTable)
+	}
+	overall := newTable("", "")
+	for _, issue := range issues {
+		if opts.Classify.excluded(issue) {
+			continue
+		}
+		category := classifyIssue(issue, opts.Classify)
+		overall.add(issue, category)
+		for i, l := range levels {
+			name := l.dimension.Group(issue)
+			if name == "" {
+				continue
+			}
+			if tables[i][name] == nil {
+				tables[i][name] = newTable(l.title, name)
+			}
+			tables[i][name].add(issue, category)
+		}
+	}
+
+	var queries []RowQuery
+	for i, l := range levels {
+		var names []string
+		for name := range tables[i] {
+			names = append(names, name)
+		}
+		if l.title == "Month" {
+			// Months in calendar order, as in the report
+			sort.Slice(names, func(a, b int) bool {
+				ma, _ := time.Parse(monthLabelFormat, names[a])
+				mb, _ := time.Parse(monthLabelFormat, names[b])
+				return ma.Before(mb)
+			})
+		} else {
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			queries = append(queries, tables[i][name].queries(s, opts)...)
+		}
+	}
+	if len(overall.total.Keys) > 0 {
+		queries = append(queries, overall.queries(s, opts)...)
+	}
+	return queries
+}
+
+// jiraSearchURL returns the link to Jira's issue search for the query
+func jiraSearchURL(jiraURL, jql string) string {
+	return strings.TrimSuffix(jiraURL, "/") + "/issues/?" + url.Values{"jql": {jql}}.Encode()
+}
+
+// writeRowQueries writes the JQL of every row, with a link to search it in
+// Jira
+func writeRowQueries(w io.Writer, queries []RowQuery, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "\nJQL per Report Row:\n")
+	for _, q := range queries {
+		fmt.Fprintf(w, "\n%s / %s: %s issues, %s\n", q.Table, q.Row, layout.Numbers.count(len(q.Keys)), layout.Numbers.manaAmount(q.Mana))
+		fmt.Fprintln(w, q.JQL)
+		fmt.Fprintln(w, jiraSearchURL(jiraURL, q.JQL))
+	}
+}
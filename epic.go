@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// EpicDetails is one row of the epic details table
+type EpicDetails struct {
+	Key             string
+	Summary         string
+	Status          string
+	Team            string    // Empty if the epic has no team
+	Resolved        time.Time // Zero if the epic has no resolution date
+	Due             time.Time // Zero if the epic has no due date
+	TotalTickets    int
+	ZeroManaTickets int
+	MissingMana     int            // Children without Mana Spent, left out of the totals
+	MissingTeam     int            // Children without a Team
+	ForeignTickets  int            // Children of another Team than the epic
+	ForeignMana     float64        // Mana of the foreign children
+	ForeignTeams    map[string]int // Foreign children per team
+	TotalMana       float64
+	Stats           map[string]float64
+	Value           float64 // Value of the epic's value field, if ValueSet
+	ValueSet        bool
+	Quadrant        string   // Value quadrant, empty if the epic has no value
+	SlipDays        int      // Resolution minus due date in days, if SlipSet
+	SlipSet         bool     // Set if the epic has a due date and a resolution date
+	LateMana        float64  // Mana of children resolved after the due date
+	ETA             *EpicETA // Set for open epics if ETAs were projected
+	Activity        []int    // Child resolutions per week, if counted
+	// FirstMana and LastMana are the first and last resolution days of
+	// children with mana, zero if there are none or dormancy wasn't measured
+	FirstMana  time.Time
+	LastMana   time.Time
+	LongestGap int  // Most days without mana between FirstMana and LastMana, or since LastMana
+	GapOngoing bool // Set if LongestGap runs from LastMana to the measuring day
+	// ChildStatuses are the children per status category, nil if they
+	// weren't counted
+	ChildStatuses *EpicChildStatuses
+	// CategoryMana is the mana of the children per category, nil if they
+	// weren't classified
+	CategoryMana map[string]float64
+	// DRI is the directly responsible individual of the epic, empty if it
+	// has none or DRIs weren't read
+	DRI string
+}
+
+// EpicReport is the data model of an epic analysis run
+type EpicReport struct {
+	Project       string
+	Start         string
+	End           string
+	EndInclusive  bool // Set if epics resolved at any time on the end date count
+	JQL           string
+	ChildJQL      string // Child JQL with EPIC_KEY standing for each epic
+	Epics         []EpicDetails
+	StatusResults []TicketAnalysis // Each epic counts once with its total mana
+	ValueField    string           // Name of the value field, empty if values weren't read
+	DRIField      string           // Name of the DRI field, empty if DRIs weren't read
+	DRIs          []EpicDRI        // Epic mana per DRI, most delivered mana first
+	Quadrants     []EpicQuadrant   // Epics with a value by value and cost
+	Slip          *EpicSlip        // Set if due date slips were measured
+	ETAAsOf       time.Time        // Day ETAs were projected from, zero if they weren't
+	ActivityStart time.Time        // First day of the weekly resolutions, zero if they weren't counted
+	DormancyAsOf  time.Time        // Day dormancy was measured up to, zero if it wasn't
+	ChildStatuses bool             // Set if children were counted per status category
+	CategoryMix   bool             // Set if children were classified into categories
+	Keys          *KeySelection    // Set if listed epics are analyzed, from their first child resolution to today
+	Stats         []Statistic      `json:"-"`
+}
+
+// periodLabel returns what the report covers, as shown in titles
+func (r *EpicReport) periodLabel() string {
+	if r.Keys != nil {
+		return fmt.Sprintf("%d listed epics", len(r.Keys.Keys))
+	}
+	return fmt.Sprintf("%s to %s", r.Start, r.End)
+}
+
+// analyzeEpics computes the details of every epic from its child tickets,
+// keyed by epic key, and the rollup of epics by status
+func analyzeEpics(epics []Issue, children map[string][]Issue, stats []Statistic) *EpicReport {
+	report := &EpicReport{Stats: stats}
+	analysis := make(map[string]*TicketAnalysis)
+
+	for _, epic := range epics {
+		details := EpicDetails{
+			Key:      epic.Key,
+			Summary:  removeEmojis(epic.Summary),
+			Status:   epic.Status,
+			Team:     epic.Team,
+			Resolved: epic.Resolved,
+			Due:      epic.Due,
+		}
+
+		var childManaValues []float64
+		for _, child := range children[epic.Key] {
+			if child.Team == "" {
+				details.MissingTeam++
+			} else if epic.Team != "" && child.Team != epic.Team {
+				details.addForeign(child)
+			}
+			if !child.ManaSet {
+				details.MissingMana++
+				continue
+			}
+
+			details.TotalTickets++
+			if child.Mana == 0 {
+				details.ZeroManaTickets++
+			}
+			details.TotalMana += child.Mana
+			childManaValues = append(childManaValues, child.Mana)
+		}
+		details.Stats = computeStatistics(childManaValues, stats)
+		report.Epics = append(report.Epics, details)
+	}
+
+	// Sort epic details by total mana spent, then by key so ties keep the
+	// same order whatever order the epics were fetched in
+	sort.SliceStable(report.Epics, func(i, j int) bool {
+		if report.Epics[i].TotalMana != report.Epics[j].TotalMana {
+			return report.Epics[i].TotalMana > report.Epics[j].TotalMana
+		}
+		return report.Epics[i].Key < report.Epics[j].Key
+	})
+
+	// Roll the epics up by status in that order, so floating point totals
+	// come out the same on every run
+	for _, details := range report.Epics {
+		addToAnalysis(analysis, details.Status, details.TotalMana)
+	}
+
+	// Calculate statistics for status analysis, sorted by total mana spent
+	report.StatusResults = analysisResults(analysis, stats)
+	return report
+}
+
+// writeEpicReport writes the epic report as text tables in the layout
+func writeEpicReport(w io.Writer, report *EpicReport, layout tableOptions) {
+	// Print header information
+	if report.Keys != nil {
+		fmt.Fprintln(w)
+		writeKeySelection(w, report.Keys)
+		fmt.Fprintf(w, "Epic Analysis Period: %s to %s (first child resolution to today)\n", report.Start, report.End)
+	} else {
+		fmt.Fprintf(w, "\nEpic Analysis Period: %s to %s\n", report.Start, report.End)
+		fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	}
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nEpics JQL Query:\n%s\n", report.JQL)
+	fmt.Fprintf(w, "\nChildren JQL Query (per epic):\n%s\n", report.ChildJQL)
+
+	// Print epic details table
+	columns := []tableColumn{
+		{Header: "Epic Key"},
+		{Header: "Summary", MaxWidth: 60},
+		{Header: "Status", MaxWidth: 20},
+		{Header: "Total Tickets", Right: true},
+		{Header: "Zero Mana Tickets", Right: true},
+		{Header: "Total Mana", Right: true},
+		{Header: "Missing Mana/Team", Right: true},
+	}
+	for _, s := range report.Stats {
+		columns = append(columns, tableColumn{Header: s.Column, Right: true})
+	}
+	if report.DRIField != "" {
+		columns = append(columns, tableColumn{Header: "DRI", MaxWidth: 30})
+	}
+	if report.ChildStatuses {
+		columns = append(columns, tableColumn{Header: "Child Statuses"})
+	}
+	if report.ValueField != "" {
+		columns = append(columns,
+			tableColumn{Header: report.ValueField, Right: true},
+			tableColumn{Header: "Value per Mana", Right: true},
+			tableColumn{Header: "Quadrant"})
+	}
+	if report.Slip != nil {
+		columns = append(columns,
+			tableColumn{Header: "Due"},
+			tableColumn{Header: "Slip Days", Right: true})
+	}
+	if !report.ETAAsOf.IsZero() {
+		columns = append(columns,
+			tableColumn{Header: "Remaining Mana", Right: true},
+			tableColumn{Header: "Unestimated", Right: true},
+			tableColumn{Header: "Burn/Week", Right: true},
+			tableColumn{Header: "ETA"},
+			tableColumn{Header: "ETA Range"})
+	}
+	if !report.DormancyAsOf.IsZero() {
+		columns = append(columns,
+			tableColumn{Header: "First Mana"},
+			tableColumn{Header: "Last Mana"},
+			tableColumn{Header: "Longest Gap Days", Right: true})
+	}
+	if !report.ActivityStart.IsZero() {
+		columns = append(columns, tableColumn{Header: "Weekly Resolutions"})
+	}
+	table := newTextTable(columns...)
+	for _, epic := range report.Epics {
+		row := []string{
+			epic.Key,
+			epic.Summary,
+			epic.Status,
+			layout.Numbers.count(epic.TotalTickets),
+			layout.Numbers.count(epic.ZeroManaTickets),
+			layout.Numbers.mana(epic.TotalMana),
+			fmt.Sprintf("%d/%d", epic.MissingMana, epic.MissingTeam),
+		}
+		for _, s := range report.Stats {
+			row = append(row, layout.Numbers.statistic(s, epic.Stats[s.Key]))
+		}
+		if report.DRIField != "" {
+			row = append(row, epic.driLabel())
+		}
+		if report.ChildStatuses {
+			row = append(row, epic.childStatusLabel(layout.Numbers))
+		}
+		if report.ValueField != "" {
+			value := "-"
+			if epic.ValueSet {
+				value = layout.Numbers.decimal(epic.Value)
+			}
+			row = append(row, value, epic.valuePerMana(layout.Numbers), epic.Quadrant)
+		}
+		if report.Slip != nil {
+			due := "-"
+			if !epic.Due.IsZero() {
+				due = epic.Due.Format("2006-01-02")
+			}
+			row = append(row, due, epic.slipLabel())
+		}
+		if !report.ETAAsOf.IsZero() {
+			remaining, unestimated, burn := "-", "-", "-"
+			if epic.ETA != nil {
+				remaining = layout.Numbers.mana(epic.ETA.Remaining)
+				unestimated = layout.Numbers.count(epic.ETA.Unestimated)
+				burn = layout.Numbers.mana(epic.ETA.BurnRate)
+			}
+			eta, etaRange := epic.etaLabels()
+			row = append(row, remaining, unestimated, burn, eta, etaRange)
+		}
+		if !report.DormancyAsOf.IsZero() {
+			first, last, gap := epic.dormancyLabels()
+			row = append(row, first, last, gap)
+		}
+		if !report.ActivityStart.IsZero() {
+			row = append(row, sparkline(epic.Activity))
+		}
+		table.addRow(row...)
+	}
+	fmt.Fprintf(w, "\nEpic Details:\n")
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Missing Mana/Team: children without Mana Spent, which are left out of the totals, and children without a Team")
+	if report.ChildStatuses {
+		writeEpicChildStatusNote(w)
+	}
+	if !report.ETAAsOf.IsZero() {
+		writeEpicETANote(w, report)
+	}
+	if !report.DormancyAsOf.IsZero() {
+		writeEpicDormancyNote(w, report)
+	}
+	if !report.ActivityStart.IsZero() {
+		writeEpicActivityNote(w, report)
+	}
+
+	// Print status rollup
+	printGroupedTable(w, report.StatusResults, "Epics by Status:", "Status", "% of Total", nil, report.Stats, layout)
+
+	if report.DRIField != "" {
+		writeEpicDRIs(w, report, layout)
+	}
+
+	writeEpicTeamConsistency(w, report, layout)
+
+	if report.CategoryMix {
+		writeEpicCategoryMix(w, report, layout)
+	}
+
+	if report.Slip != nil {
+		writeEpicSlip(w, report.Slip, layout)
+	}
+
+	if report.ValueField != "" {
+		writeEpicQuadrants(w, report, layout)
+	}
+}
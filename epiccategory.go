@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// applyEpicCategoryMix classifies the resolved children of every epic like
+// the ticket command does and sums their mana per category. Children
+// without Mana Spent and excluded research issues are left out.
+func applyEpicCategoryMix(report *EpicReport, children map[string][]Issue, opts classifyOptions) {
+	report.CategoryMix = true
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		epic.CategoryMana = make(map[string]float64)
+		for _, child := range children[epic.Key] {
+			if !child.ManaSet || opts.excluded(child) {
+				continue
+			}
+			epic.CategoryMana[classifyIssue(child, opts)] += child.Mana
+		}
+	}
+}
+
+// epicMixCategories returns the categories of the category mix, most mana
+// across the epics first, then by name
+func epicMixCategories(epics []EpicDetails) []string {
+	totals := make(map[string]float64)
+	for _, epic := range epics {
+		for category, mana := range epic.CategoryMana {
+			totals[category] += mana
+		}
+	}
+	categories := make([]string, 0, len(totals))
+	for category := range totals {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if totals[categories[i]] != totals[categories[j]] {
+			return totals[categories[i]] > totals[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// mainCategory returns the category with the most of the epic's mana, or ""
+// if its children have none
+func (e EpicDetails) mainCategory() string {
+	main := ""
+	for category, mana := range e.CategoryMana {
+		if mana > 0 && (main == "" || mana > e.CategoryMana[main] || (mana == e.CategoryMana[main] && category < main)) {
+			main = category
+		}
+	}
+	return main
+}
+
+// writeEpicCategoryMix writes the share of every epic's mana per category,
+// so rework and security remediation epics stand out from feature work
+func writeEpicCategoryMix(w io.Writer, report *EpicReport, layout tableOptions) {
+	categories := epicMixCategories(report.Epics)
+	columns := []tableColumn{
+		{Header: "Epic Key"},
+		{Header: "Summary", MaxWidth: 40},
+		{Header: "Total Mana", Right: true},
+	}
+	for _, category := range categories {
+		columns = append(columns, tableColumn{Header: category, Right: true})
+	}
+	columns = append(columns, tableColumn{Header: "Main Category"})
+	table := newTextTable(columns...)
+
+	mainCounts := make(map[string]int)
+	for _, epic := range report.Epics {
+		total := 0.0
+		for _, mana := range epic.CategoryMana {
+			total += mana
+		}
+		row := []string{epic.Key, epic.Summary, layout.Numbers.mana(total)}
+		for _, category := range categories {
+			share := "-"
+			if total > 0 {
+				share = fmt.Sprintf("%.1f%%", epic.CategoryMana[category]/total*100)
+			}
+			row = append(row, share)
+		}
+		main := epic.mainCategory()
+		if main == "" {
+			row = append(row, "-")
+		} else {
+			row = append(row, main)
+			mainCounts[main]++
+		}
+		table.addRow(row...)
+	}
+
+	fmt.Fprintf(w, "\nEpic Category Mix:\n")
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Shares of each epic's child mana per category, classified like the ticket command; Main Category has the largest share")
+	for _, category := range categories {
+		if mainCounts[category] > 0 {
+			fmt.Fprintf(w, "  Mostly %s: %d epics\n", category, mainCounts[category])
+		}
+	}
+}
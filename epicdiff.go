@@ -0,0 +1,421 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// epicDiffFields are the current fields of the children read by epic-diff,
+// besides their changelogs
+var epicDiffFields = []string{"issuetype", "summary", "created", manaFieldID, epicLinkFieldID, "parent"}
+
+// Names of the changelog fields epic-diff replays. Team-managed projects and
+// newer Jira Cloud sites record parent changes as IssueParentAssociation.
+const (
+	manaFieldName    = "Mana Spent"
+	epicChildField   = "Epic Child"
+	epicLinkField    = "Epic Link"
+	parentField      = "Parent"
+	parentAssocField = "IssueParentAssociation"
+)
+
+// epicDiffChildJQL selects the current children of an epic
+const epicDiffChildJQL = `"Epic Link" = "%s" OR parent = "%s"`
+
+// EpicScopeChild is a child of an epic as of one date
+type EpicScopeChild struct {
+	Key     string
+	Type    string
+	Summary string
+	Mana    float64
+}
+
+// EpicScopeResize is a child in the epic on both dates whose mana changed
+type EpicScopeResize struct {
+	EpicScopeChild
+	ManaAt float64 // Mana Spent on the first date, the child's Mana on the second
+}
+
+// EpicScope is the child set of an epic as of one date
+type EpicScope struct {
+	Date     string
+	Children int
+	Mana     float64
+}
+
+// EpicScopeDiff is the data model of the epic-diff command
+type EpicScopeDiff struct {
+	Epic    string
+	Summary string
+	At      EpicScope
+	Vs      EpicScope
+	Added   []EpicScopeChild // Most mana first
+	Removed []EpicScopeChild
+	Resized []EpicScopeResize // Largest change first
+}
+
+// changeAfter returns the first change of one of the fields after t, from
+// the changelog of the issue. Replaying from its from value gives the value
+// of the field at t; without a later change the current value holds.
+func changeAfter(ji jira.Issue, fields []string, t time.Time) (jira.ChangelogItems, bool) {
+	if ji.Changelog == nil {
+		return jira.ChangelogItems{}, false
+	}
+	type entry struct {
+		created time.Time
+		items   []jira.ChangelogItems
+	}
+	var entries []entry
+	for _, history := range ji.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil || !created.After(t) {
+			continue
+		}
+		entries = append(entries, entry{created, history.Items})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].created.Before(entries[j].created)
+	})
+	for _, e := range entries {
+		for _, item := range e.items {
+			if containsString(fields, item.Field) {
+				return item, true
+			}
+		}
+	}
+	return jira.ChangelogItems{}, false
+}
+
+// changedFrom returns the value of a changelog item before the change, as
+// the key or name if Jira recorded one, or else as the raw value
+func changedFrom(item jira.ChangelogItems) []string {
+	values := []string{item.FromString}
+	if s, ok := item.From.(string); ok {
+		values = append(values, s)
+	}
+	return values
+}
+
+// childAt returns the child as of t, the end of a day, and whether it was a
+// child of the epic then: created by t and linked to the epic through its
+// Epic Link or its parent. Its mana is the Mana Spent it had at t.
+func childAt(ji jira.Issue, current jira.Issue, epicKey, epicID string, t time.Time) (EpicScopeChild, bool) {
+	if current.Fields == nil || !time.Time(current.Fields.Created).Before(t) {
+		return EpicScopeChild{}, false
+	}
+	f := current.Fields
+	isEpic := func(values ...string) bool {
+		for _, v := range values {
+			if v != "" && (v == epicKey || v == epicID) {
+				return true
+			}
+		}
+		return false
+	}
+
+	epicLink, _ := f.Unknowns[epicLinkFieldID].(string)
+	in := isEpic(epicLink)
+	if item, ok := changeAfter(ji, []string{epicLinkField}, t); ok {
+		in = isEpic(changedFrom(item)...)
+	}
+	if !in {
+		var parent string
+		if f.Parent != nil {
+			parent = f.Parent.Key
+		}
+		in = isEpic(parent)
+		if item, ok := changeAfter(ji, []string{parentField, parentAssocField}, t); ok {
+			in = isEpic(changedFrom(item)...)
+		}
+	}
+	if !in {
+		return EpicScopeChild{}, false
+	}
+
+	child := EpicScopeChild{Key: current.Key, Type: jiraLabels.issueType(f.Type.Name), Summary: f.Summary, Mana: getManaPoints(f.Unknowns[manaFieldID])}
+	if item, ok := changeAfter(ji, []string{manaFieldName}, t); ok {
+		child.Mana = getManaPoints(item.FromString)
+	}
+	return child, true
+}
+
+// epicChildCandidates returns the keys of the issues that are or were
+// children of the epic: its current children and those the epic's
+// changelog records as added or removed, in key order
+func epicChildCandidates(epic jira.Issue, current []jira.Issue) []string {
+	seen := make(map[string]bool)
+	for _, ji := range current {
+		seen[ji.Key] = true
+	}
+	if epic.Changelog != nil {
+		for _, history := range epic.Changelog.Histories {
+			for _, item := range history.Items {
+				if item.Field != epicChildField {
+					continue
+				}
+				for _, key := range []string{item.FromString, item.ToString} {
+					if issueKeyRegex.MatchString(key) {
+						seen[key] = true
+					}
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffEpicScope compares the child sets of the epic at the ends of two days.
+// changelogs are the candidate children with their changelogs and current
+// their current fields, both by key.
+func diffEpicScope(epicKey, epicID string, keys []string, changelogs, current map[string]jira.Issue, at, vs time.Time) *EpicScopeDiff {
+	diff := &EpicScopeDiff{
+		Epic: epicKey,
+		At:   EpicScope{Date: at.Format("2006-01-02")},
+		Vs:   EpicScope{Date: vs.Format("2006-01-02")},
+	}
+	atEnd, vsEnd := at.AddDate(0, 0, 1), vs.AddDate(0, 0, 1)
+	for _, key := range keys {
+		before, wasChild := childAt(changelogs[key], current[key], epicKey, epicID, atEnd)
+		after, isChild := childAt(changelogs[key], current[key], epicKey, epicID, vsEnd)
+		if wasChild {
+			diff.At.Children++
+			diff.At.Mana += before.Mana
+		}
+		if isChild {
+			diff.Vs.Children++
+			diff.Vs.Mana += after.Mana
+		}
+		switch {
+		case wasChild && !isChild:
+			diff.Removed = append(diff.Removed, before)
+		case !wasChild && isChild:
+			diff.Added = append(diff.Added, after)
+		case wasChild && isChild && before.Mana != after.Mana:
+			diff.Resized = append(diff.Resized, EpicScopeResize{EpicScopeChild: after, ManaAt: before.Mana})
+		}
+	}
+	for _, children := range [][]EpicScopeChild{diff.Added, diff.Removed} {
+		sort.SliceStable(children, func(i, j int) bool {
+			return children[i].Mana > children[j].Mana
+		})
+	}
+	sort.SliceStable(diff.Resized, func(i, j int) bool {
+		a, b := diff.Resized[i], diff.Resized[j]
+		return abs(a.Mana-a.ManaAt) > abs(b.Mana-b.ManaAt)
+	})
+	return diff
+}
+
+// abs returns the absolute value of v
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// signedMana formats a change of mana with its sign, e.g. +4.00, with
+// numberFormat.mana or manaAmount
+func signedMana(format func(float64) string, v float64) string {
+	switch {
+	case v > 0:
+		return "+" + format(v)
+	case v == 0:
+		// Not -0.00
+		return format(0)
+	}
+	return format(v)
+}
+
+// writeEpicScopeDiff writes the scope changes of the epic between the two
+// dates in the layout
+func writeEpicScopeDiff(w io.Writer, diff *EpicScopeDiff, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "Epic Scope Diff: %s %s\n", diff.Epic, removeEmojis(diff.Summary))
+	fmt.Fprintf(w, "Link: %s/browse/%s\n", jiraURL, diff.Epic)
+	fmt.Fprintf(w, "Scope as of the end of each day, replayed from the changelogs of the children\n\n")
+
+	scopeTable := newTextTable(
+		tableColumn{Header: "Date"},
+		tableColumn{Header: "Children", Right: true},
+		tableColumn{Header: "Total Mana", Right: true},
+	)
+	for _, s := range []EpicScope{diff.At, diff.Vs} {
+		scopeTable.addRow(s.Date, layout.Numbers.count(s.Children), layout.Numbers.mana(s.Mana))
+	}
+	scopeTable.addFooter("Change",
+		fmt.Sprintf("%+d", diff.Vs.Children-diff.At.Children),
+		signedMana(layout.Numbers.mana, diff.Vs.Mana-diff.At.Mana))
+	scopeTable.write(w, layout.Style)
+
+	childTable := func(title string, children []EpicScopeChild, sign float64) {
+		mana := 0.0
+		for _, c := range children {
+			mana += c.Mana
+		}
+		fmt.Fprintf(w, "\n%s: %d children (%s)\n", title, len(children), signedMana(layout.Numbers.manaAmount, sign*mana))
+		if len(children) == 0 {
+			return
+		}
+		table := newTextTable(
+			tableColumn{Header: "Key"},
+			tableColumn{Header: "Issue Type"},
+			tableColumn{Header: "Mana", Right: true},
+			tableColumn{Header: "Summary", MaxWidth: 60},
+		)
+		for _, c := range children {
+			table.addRow(c.Key, c.Type, layout.Numbers.mana(c.Mana), removeEmojis(c.Summary))
+		}
+		table.write(w, layout.Style)
+	}
+	childTable("Added", diff.Added, 1)
+	childTable("Removed", diff.Removed, -1)
+
+	change := 0.0
+	for _, r := range diff.Resized {
+		change += r.Mana - r.ManaAt
+	}
+	fmt.Fprintf(w, "\nResized: %d children (%s)\n", len(diff.Resized), signedMana(layout.Numbers.manaAmount, change))
+	if len(diff.Resized) == 0 {
+		return
+	}
+	table := newTextTable(
+		tableColumn{Header: "Key"},
+		tableColumn{Header: "Issue Type"},
+		tableColumn{Header: "Mana " + diff.At.Date, Right: true},
+		tableColumn{Header: "Mana " + diff.Vs.Date, Right: true},
+		tableColumn{Header: "Change", Right: true},
+		tableColumn{Header: "Summary", MaxWidth: 60},
+	)
+	for _, r := range diff.Resized {
+		table.addRow(r.Key, r.Type,
+			layout.Numbers.mana(r.ManaAt),
+			layout.Numbers.mana(r.Mana),
+			signedMana(layout.Numbers.mana, r.Mana-r.ManaAt),
+			removeEmojis(r.Summary))
+	}
+	table.write(w, layout.Style)
+}
+
+func runEpicDiffCommand() {
+	// Command line flags
+	epicKey := flag.String("epic", "", "Key of the epic (e.g., PROJ-123)")
+	atDate := flag.String("at", "", "First date (YYYY-MM-DD)")
+	vsDate := flag.String("vs", "", "Second date (YYYY-MM-DD), default today")
+	noCache := flag.Bool("no-cache", false, "Always fetch changelogs from Jira, ignoring and not updating the local cache")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	configPath := flag.String("config", "", configUsage)
+	tables := defineTableFlags()
+	flag.Parse()
+
+	// Validate flags
+	if *epicKey == "" || *atDate == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	*epicKey = strings.ToUpper(*epicKey)
+	if !issueKeyRegex.MatchString(*epicKey) {
+		log.Fatalf("-epic %q is not an issue key like PROJ-123", *epicKey)
+	}
+	today := currentDay()
+	at, err := parseDate(*atDate, false, today)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vs := today
+	if *vsDate != "" {
+		if vs, err = parseDate(*vsDate, false, today); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !at.Before(vs) {
+		log.Fatal("-at must be before -vs")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jql := fmt.Sprintf(epicDiffChildJQL, *epicKey, *epicKey)
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	epics, err := searchByKeys(client, []string{*epicKey}, []string{"summary"}, "changelog")
+	if err != nil {
+		log.Fatalf("Error fetching the epic: %v", err)
+	}
+	if len(epics) == 0 {
+		log.Fatalf("Epic %s not found", *epicKey)
+	}
+	epic := epics[0]
+
+	children, err := searchRange(client, jql, []string{"key"}, "", 0, 0)
+	if err != nil {
+		log.Fatalf("Error fetching the children: %v", err)
+	}
+	keys := epicChildCandidates(epic, children)
+	// Mana changes are replayed by the field's name in changelogs
+	for _, project := range strings.Split(keyProjects(keys), ",") {
+		if err := config.fieldsOf(project).requireStandard(project, "epic-diff", true, false); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("Replaying the changelogs of %d current and former children of %s\n", len(keys), *epicKey)
+
+	changelogs := make(map[string]jira.Issue)
+	found, err := fetchChangelogs(client, openChangelogCache(*noCache), keys)
+	if err != nil {
+		log.Fatalf("Error fetching changelogs: %v", err)
+	}
+	for _, ji := range found {
+		changelogs[ji.Key] = ji
+	}
+	current := make(map[string]jira.Issue)
+	if found, err = searchByKeys(client, keys, epicDiffFields, ""); err != nil {
+		log.Fatalf("Error fetching the children: %v", err)
+	}
+	for _, ji := range found {
+		current[ji.Key] = ji
+	}
+
+	diff := diffEpicScope(*epicKey, epic.ID, keys, changelogs, current, at, vs)
+	if epic.Fields != nil {
+		diff.Summary = epic.Fields.Summary
+	}
+
+	title := fmt.Sprintf("%s Epic Scope %s vs %s", diff.Epic, diff.At.Date, diff.Vs.Date)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeEpicScopeDiff(w, diff, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
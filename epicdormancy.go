@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// applyEpicDormancy finds the days every epic first and last accrued mana,
+// the resolution days of its children with mana, and the longest gap between
+// them without any. Epics still open at asOf also count the gap from their
+// last mana to asOf, as that dormancy is still going on.
+func applyEpicDormancy(report *EpicReport, children map[string][]Issue, asOf time.Time) {
+	report.DormancyAsOf = asOf
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		var days []time.Time
+		for _, child := range children[epic.Key] {
+			if child.Mana > 0 && !child.Resolved.IsZero() {
+				days = append(days, dayOf(child.Resolved))
+			}
+		}
+		if len(days) == 0 {
+			continue
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		epic.FirstMana, epic.LastMana = days[0], days[len(days)-1]
+		for j := 1; j < len(days); j++ {
+			if gap := daysBetween(days[j-1], days[j]); gap > epic.LongestGap {
+				epic.LongestGap = gap
+			}
+		}
+		if isOpenEpic(epic.Status, epic.Resolved) && asOf.After(epic.LastMana) {
+			if gap := daysBetween(epic.LastMana, asOf); gap >= epic.LongestGap {
+				epic.LongestGap = gap
+				epic.GapOngoing = true
+			}
+		}
+	}
+}
+
+// daysBetween returns the number of days from one day to a later one
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// dormancyLabels formats the first and last mana days and the longest gap of
+// an epic
+func (e EpicDetails) dormancyLabels() (first, last, gap string) {
+	if e.FirstMana.IsZero() {
+		return "-", "-", "-"
+	}
+	gap = fmt.Sprintf("%d", e.LongestGap)
+	if e.GapOngoing {
+		gap += " (open)"
+	}
+	return e.FirstMana.Format("2006-01-02"), e.LastMana.Format("2006-01-02"), gap
+}
+
+// writeEpicDormancyNote explains the dormancy columns of the epic details table
+func writeEpicDormancyNote(w io.Writer, report *EpicReport) {
+	fmt.Fprintf(w, "First/Last Mana: resolution days of the first and last children with mana. Longest Gap Days: most days between two of them; (open) if the epic is still open and the days since its last mana, up to %s, are the longest gap.\n",
+		report.DormancyAsOf.Format("2006-01-02"))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// noDRI is the row of the epics without a DRI
+const noDRI = "No DRI"
+
+// EpicDRI is one row of the rollup of epic mana per DRI
+type EpicDRI struct {
+	Name          string
+	Epics         int
+	Delivered     int     // Epics resolved or in GA Release
+	DeliveredMana float64 // Mana of the delivered epics
+	OpenMana      float64 // Mana resolved so far on the open epics
+}
+
+// fieldUserName returns the name of the user, or users, in a field value: a
+// user picker, a multi-user picker, a text or a select option. It returns ""
+// for an empty value.
+func fieldUserName(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		for _, key := range []string{"displayName", "name", "value"} {
+			if s, ok := v[key].(string); ok && s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			if name := fieldUserName(item); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// epicDRIs returns the DRI of every epic, keyed by epic key, from the
+// assignee or the field. Epics without a DRI are left out.
+func epicDRIs(epics []jira.Issue, fieldID string) map[string]string {
+	dris := make(map[string]string)
+	for _, epic := range epics {
+		if epic.Fields == nil {
+			continue
+		}
+		name := ""
+		if fieldID == "assignee" {
+			if epic.Fields.Assignee != nil {
+				name = epic.Fields.Assignee.DisplayName
+			}
+		} else {
+			name = fieldUserName(epic.Fields.Unknowns[fieldID])
+		}
+		if name != "" {
+			dris[epic.Key] = name
+		}
+	}
+	return dris
+}
+
+// fetchEpicDRIs fetches the DRIs of the epics, from the assignee or the field
+func fetchEpicDRIs(client *jira.Client, fieldID string, epics []Issue) (map[string]string, error) {
+	if len(epics) == 0 {
+		return map[string]string{}, nil
+	}
+	keys := make([]string, len(epics))
+	for i, epic := range epics {
+		keys[i] = epic.Key
+	}
+	found, err := searchByKeys(client, keys, []string{fieldID}, "")
+	if err != nil {
+		return nil, err
+	}
+	return epicDRIs(found, fieldID), nil
+}
+
+// applyEpicDRIs sets the DRIs of the epics of the report and rolls their
+// mana up per DRI, most delivered mana first and epics without a DRI last
+func applyEpicDRIs(report *EpicReport, field string, dris map[string]string) {
+	report.DRIField = field
+	byDRI := make(map[string]*EpicDRI)
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		epic.DRI = dris[epic.Key]
+		name := epic.DRI
+		if name == "" {
+			name = noDRI
+		}
+		row := byDRI[name]
+		if row == nil {
+			row = &EpicDRI{Name: name}
+			byDRI[name] = row
+		}
+		row.Epics++
+		if isOpenEpic(epic.Status, epic.Resolved) {
+			row.OpenMana += epic.TotalMana
+		} else {
+			row.Delivered++
+			row.DeliveredMana += epic.TotalMana
+		}
+	}
+
+	report.DRIs = nil
+	for _, row := range byDRI {
+		report.DRIs = append(report.DRIs, *row)
+	}
+	sort.Slice(report.DRIs, func(i, j int) bool {
+		a, b := report.DRIs[i], report.DRIs[j]
+		if (a.Name == noDRI) != (b.Name == noDRI) {
+			return b.Name == noDRI
+		}
+		if a.DeliveredMana != b.DeliveredMana {
+			return a.DeliveredMana > b.DeliveredMana
+		}
+		return a.Name < b.Name
+	})
+}
+
+// driLabel returns the DRI of the epic as shown in the epic details
+func (e EpicDetails) driLabel() string {
+	if e.DRI == "" {
+		return "-"
+	}
+	return e.DRI
+}
+
+// writeEpicDRIs writes the rollup of epic mana per DRI
+func writeEpicDRIs(w io.Writer, report *EpicReport, layout tableOptions) {
+	withOpen := !report.ETAAsOf.IsZero()
+	columns := []tableColumn{
+		{Header: "DRI", MaxWidth: 30},
+		{Header: "Epics", Right: true},
+		{Header: "Delivered", Right: true},
+		{Header: "Delivered Mana", Right: true},
+		{Header: "% of Delivered", Right: true},
+		{Header: "Avg per Delivered", Right: true},
+	}
+	if withOpen {
+		columns = append(columns, tableColumn{Header: "Open Epic Mana", Right: true})
+	}
+	table := newTextTable(columns...)
+
+	total := EpicDRI{Name: "TOTAL"}
+	for _, row := range report.DRIs {
+		total.Epics += row.Epics
+		total.Delivered += row.Delivered
+		total.DeliveredMana += row.DeliveredMana
+		total.OpenMana += row.OpenMana
+	}
+	cells := func(row EpicDRI) []string {
+		share := 0.0
+		if total.DeliveredMana > 0 {
+			share = row.DeliveredMana / total.DeliveredMana * 100
+		}
+		c := []string{row.Name,
+			layout.Numbers.count(row.Epics),
+			layout.Numbers.count(row.Delivered),
+			layout.Numbers.mana(row.DeliveredMana),
+			fmt.Sprintf("%.1f%%", share),
+			layout.Numbers.mana(safeAverage(row.DeliveredMana, row.Delivered))}
+		if withOpen {
+			c = append(c, layout.Numbers.mana(row.OpenMana))
+		}
+		return c
+	}
+	for _, row := range report.DRIs {
+		table.addRow(cells(row)...)
+	}
+	table.addFooter(cells(total)...)
+
+	fmt.Fprintf(w, "\nDelivered Epic Mana by DRI (%s):\n", report.DRIField)
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Delivered epics are resolved or in GA Release; an epic's mana is that of its resolved children.")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// etaWeeks is the number of trailing weeks the burn rate is measured over
+const etaWeeks = 4
+
+// epicRemainingJQL is the template selecting the unresolved child tickets of
+// an open epic, whose Mana Spent is the estimate of the work left
+const epicRemainingJQL = `{{.Projects}} AND "Epic Link" = "{{.EpicKey}}" AND resolution is EMPTY`
+
+// EpicETA is the projected completion of an open epic
+type EpicETA struct {
+	Remaining   float64   // Mana of the unresolved children with Mana Spent
+	Unestimated int       // Unresolved children without Mana Spent
+	BurnRate    float64   // Mana resolved per week over the trailing weeks
+	BurnSpread  float64   // Standard deviation of the weekly mana resolved
+	Date        time.Time // Zero if nothing is left or nothing was burnt
+	Earliest    time.Time // At the burn rate plus its spread
+	Latest      time.Time // At the burn rate minus its spread, zero if that is not positive
+}
+
+// isOpenEpic reports whether an epic with the status and resolution date is
+// neither resolved nor released
+func isOpenEpic(status string, resolved time.Time) bool {
+	return resolved.IsZero() && status != "GA Release"
+}
+
+// projectDate returns the day the remaining mana is burnt at the weekly rate
+func projectDate(asOf time.Time, remaining, rate float64) time.Time {
+	days := math.Ceil(remaining / rate * 7)
+	return asOf.AddDate(0, 0, int(days))
+}
+
+// applyEpicETA projects the completion date of every open epic from the mana
+// of its children resolved in the trailing weeks before asOf and the mana of
+// its unresolved children. remaining are unresolved children fetched on top
+// of the epic's children, keyed by epic key; children of both are counted
+// once.
+func applyEpicETA(report *EpicReport, children, remaining map[string][]Issue, asOf time.Time) {
+	report.ETAAsOf = asOf
+	from := asOf.AddDate(0, 0, -7*etaWeeks)
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		if !isOpenEpic(epic.Status, epic.Resolved) {
+			continue
+		}
+		eta := &EpicETA{}
+		weekly := make([]float64, etaWeeks)
+		seen := make(map[string]bool)
+		for _, child := range append(append([]Issue{}, children[epic.Key]...), remaining[epic.Key]...) {
+			if seen[child.Key] {
+				continue
+			}
+			seen[child.Key] = true
+			switch {
+			case child.Resolved.IsZero() && !child.ManaSet:
+				eta.Unestimated++
+			case child.Resolved.IsZero():
+				eta.Remaining += child.Mana
+			case child.ManaSet && !child.Resolved.Before(from) && child.Resolved.Before(asOf):
+				weekly[int(child.Resolved.Sub(from).Hours()/24/7)] += child.Mana
+			}
+		}
+		eta.BurnRate = calculateMean(weekly)
+		eta.BurnSpread = calculateStdDev(weekly)
+		if eta.Remaining > 0 && eta.BurnRate > 0 {
+			eta.Date = projectDate(asOf, eta.Remaining, eta.BurnRate)
+			eta.Earliest = projectDate(asOf, eta.Remaining, eta.BurnRate+eta.BurnSpread)
+			if eta.BurnRate > eta.BurnSpread {
+				eta.Latest = projectDate(asOf, eta.Remaining, eta.BurnRate-eta.BurnSpread)
+			}
+		}
+		epic.ETA = eta
+	}
+}
+
+// etaLabels formats the projected completion date and its range of an epic
+func (e EpicDetails) etaLabels() (string, string) {
+	switch {
+	case e.ETA == nil, e.ETA.Remaining == 0:
+		return "-", "-"
+	case e.ETA.Date.IsZero():
+		return "no burn", "-"
+	}
+	latest := "open-ended"
+	if !e.ETA.Latest.IsZero() {
+		latest = e.ETA.Latest.Format("2006-01-02")
+	}
+	return e.ETA.Date.Format("2006-01-02"), e.ETA.Earliest.Format("2006-01-02") + " to " + latest
+}
+
+// writeEpicETANote explains the ETA columns of the epic details table
+func writeEpicETANote(w io.Writer, report *EpicReport) {
+	fmt.Fprintf(w, "ETA: open epics' remaining mana, the Mana Spent of unresolved children, at the mana resolved per week over the %d weeks before %s. The range uses that rate plus and minus its weekly standard deviation; open-ended if the slower rate is not positive. Unresolved children without Mana Spent are left out.\n",
+		etaWeeks, report.ETAAsOf.Format("2006-01-02"))
+}
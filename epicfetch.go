@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// epicFetchWorkers is the number of epics whose children are fetched at once
+const epicFetchWorkers = 4
+
+// epicChildFields are the fields the epic analysis reads from child tickets
+var epicChildFields = []string{"issuetype", "status", manaFieldID, teamFieldID, "resolutiondate", "labels", "issuelinks", "reporter"}
+
+// epicFields are the fields the epic analysis reads from epics
+var epicFields = []string{"issuetype", "summary", "status", "resolutiondate", "duedate", epicLinkFieldID, teamFieldID}
+
+// epicJQL selects the epics in GA Release or resolved within the date range,
+// the end day included if inclusive is set, and the open epics too if
+// includeOpen is set. Epics need a team in the project's own Team field.
+func epicJQL(projectKey string, own projectFields, start, end time.Time, inclusive, includeOpen bool) string {
+	open := ""
+	if includeOpen {
+		open = ` OR
+			(resolution is EMPTY)`
+	}
+	return fmt.Sprintf(`project = "%s" AND
+		issuetype = Epic AND
+		(
+			(status = "GA Release") OR
+			(status in (Resolved, Closed) AND
+			resolution not in ("Won't Do", "Invalid", "Duplicate") AND
+			%s)%s
+		) AND
+		%s IS NOT EMPTY
+		ORDER BY created DESC`,
+		projectKey,
+		resolvedBetween(start, end, inclusive),
+		open,
+		own.teamJQL())
+}
+
+// fetchEpics fetches every epic selected by the query, reading each with the
+// fields of its project
+func fetchEpics(client *jira.Client, config *Config, jql string) ([]Issue, error) {
+	return config.fetchIssues(client, jql, epicFields)
+}
+
+// defaultEpicChildJQL is the default template selecting the child tickets of
+// an epic. Tickets without Mana Spent are included so the audit can count them.
+const defaultEpicChildJQL = `{{.Projects}} AND "Epic Link" = "{{.EpicKey}}" AND resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined")`
+
+// epicChildOrder orders the child tickets by key, so pages don't shift
+// between requests
+const epicChildOrder = " ORDER BY key ASC"
+
+// epicChildQuery is the data the child JQL template is executed with
+type epicChildQuery struct {
+	ProjectKey string
+	Projects   string // JQL clause selecting the projects of the child tickets
+	EpicKey    string
+}
+
+// projectKeyRegex matches a Jira project key
+var projectKeyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// childScope is the projects the child tickets of the epics are searched in
+type childScope struct {
+	Project string   // Project of the epics
+	Others  []string // Other projects, from -child-projects
+	All     bool     // Every project
+}
+
+// parseChildProjects parses the -child-projects value: comma-separated
+// project keys searched besides the epics' project, or "all" for every
+// project
+func parseChildProjects(projectKey, list string) (childScope, error) {
+	scope := childScope{Project: projectKey}
+	if strings.EqualFold(strings.TrimSpace(list), "all") {
+		scope.All = true
+		return scope, nil
+	}
+	seen := map[string]bool{strings.ToUpper(projectKey): true}
+	for _, key := range strings.Split(list, ",") {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if key == "" || seen[key] {
+			continue
+		}
+		if !projectKeyRegex.MatchString(key) {
+			return childScope{}, fmt.Errorf("invalid -child-projects project key %q", key)
+		}
+		seen[key] = true
+		scope.Others = append(scope.Others, key)
+	}
+	return scope, nil
+}
+
+// clause returns the JQL clause selecting the projects of the scope
+func (s childScope) clause() string {
+	if s.All {
+		return "project is not EMPTY"
+	}
+	if len(s.Others) == 0 {
+		return fmt.Sprintf(`project = "%s"`, s.Project)
+	}
+	quoted := []string{fmt.Sprintf(`"%s"`, s.Project)}
+	for _, key := range s.Others {
+		quoted = append(quoted, fmt.Sprintf(`"%s"`, key))
+	}
+	return fmt.Sprintf("project in (%s)", strings.Join(quoted, ", "))
+}
+
+// checkChildScope fails if the scope reaches beyond the epics' project but
+// the child JQL template ignores it
+func checkChildScope(tmpl *template.Template, scope childScope) error {
+	if !scope.All && len(scope.Others) == 0 {
+		return nil
+	}
+	jql, err := epicChildrenJQL(tmpl, scope, "EPIC_KEY")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(jql, scope.clause()) {
+		return fmt.Errorf("-child-projects needs {{.Projects}} in the child JQL template")
+	}
+	return nil
+}
+
+// parseEpicChildTemplate parses a child JQL template. The template must use
+// {{.EpicKey}}, and must not order the results, as epicChildrenJQL does.
+func parseEpicChildTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("child-jql").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid child JQL template: %w", err)
+	}
+	jql, err := epicChildrenJQL(tmpl, childScope{Project: "PROJECT_KEY"}, "EPIC_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(jql, "EPIC_KEY") {
+		return nil, fmt.Errorf("invalid child JQL template: it must select the epic with {{.EpicKey}}")
+	}
+	if strings.Contains(strings.ToUpper(strings.TrimSuffix(jql, epicChildOrder)), "ORDER BY") {
+		return nil, fmt.Errorf("invalid child JQL template: ORDER BY is added by theia")
+	}
+	return tmpl, nil
+}
+
+// epicChildrenJQL selects the child tickets of an epic through the template
+func epicChildrenJQL(tmpl *template.Template, scope childScope, epicKey string) (string, error) {
+	var b strings.Builder
+	query := epicChildQuery{ProjectKey: scope.Project, Projects: scope.clause(), EpicKey: epicKey}
+	if err := tmpl.Execute(&b, query); err != nil {
+		return "", fmt.Errorf("invalid child JQL template: %w", err)
+	}
+	return strings.TrimSpace(b.String()) + epicChildOrder, nil
+}
+
+// validateEpicChildJQL checks the child JQL of the first epic with Jira, so a
+// broken template fails once instead of for every epic
+func validateEpicChildJQL(client *jira.Client, tmpl *template.Template, scope childScope, epics []Issue) error {
+	if len(epics) == 0 {
+		return nil
+	}
+	jql, err := epicChildrenJQL(tmpl, scope, epics[0].Key)
+	if err != nil {
+		return err
+	}
+	return validateJQL(client, jql)
+}
+
+// fetchEpicChildren fetches the child tickets of every epic in parallel,
+// keyed by epic key. Progress is written to w once all fetches are done, one
+// block per epic in the order of epics, so it never interleaves between
+// workers and is the same from run to run. Children are read with the fields
+// of their project.
+func fetchEpicChildren(client *jira.Client, config *Config, w io.Writer, jiraURL string, scope childScope, tmpl *template.Template, epics []Issue) (map[string][]Issue, error) {
+	type epicResult struct {
+		children []Issue
+		err      error
+	}
+	results := make([]epicResult, len(epics))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, epicFetchWorkers)
+	for i, epic := range epics {
+		wg.Add(1)
+		go func(i int, epic Issue) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			jql, err := epicChildrenJQL(tmpl, scope, epic.Key)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			children, err := config.fetchIssues(client, jql, epicChildFields)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].children = children
+		}(i, epic)
+	}
+	wg.Wait()
+
+	children := make(map[string][]Issue, len(epics))
+	for i, r := range results {
+		epic := epics[i]
+		if r.err != nil {
+			return nil, fmt.Errorf("epic %s: %w", epic.Key, r.err)
+		}
+		fmt.Fprintf(w, "%s: %d child tickets\n", epic.Key, len(r.children))
+		for _, child := range r.children {
+			if child.ManaSet && child.Mana == 0 {
+				fmt.Fprintf(w, "  Debug: Zero mana ticket in epic %s - %s/browse/%s\n", epic.Key, jiraURL, child.Key)
+			}
+		}
+		children[epic.Key] = r.children
+	}
+	return children, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// slipBuckets are the upper bounds in days of the late slip buckets
+var slipBuckets = []int{7, 30}
+
+// TeamSlip is the due date slip of the epics of one team
+type TeamSlip struct {
+	Team        string
+	Epics       int // Epics with a due date
+	Resolved    int // Epics with a due date and a resolution date
+	OnTime      int // Resolved on or before the due date
+	LateBuckets []int
+	MedianSlip  float64 // Days, over the resolved epics
+	MaxSlip     int
+	Mana        float64 // Mana of the children of the epics with a due date
+	LateMana    float64 // Mana of children resolved after their epic's due date
+	slips       []float64
+}
+
+// lateShare returns the share of the mana spent after the due date
+func (t TeamSlip) lateShare() float64 {
+	if t.Mana == 0 {
+		return 0
+	}
+	return t.LateMana / t.Mana * 100
+}
+
+// EpicSlip is the due date slip analysis of the epic report
+type EpicSlip struct {
+	Teams []TeamSlip
+	Total TeamSlip
+}
+
+// dayOf returns the calendar day of t, in t's location, as midnight UTC
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// slipBucket returns the index of the late bucket for a slip in days
+func slipBucket(days int) int {
+	for i, limit := range slipBuckets {
+		if days <= limit {
+			return i
+		}
+	}
+	return len(slipBuckets)
+}
+
+// applyEpicSlip measures, for every epic with a due date, its slip, the days
+// from the due date to its resolution, and the mana of its children resolved
+// after the due date, and sums them up per epic team
+func applyEpicSlip(report *EpicReport, children map[string][]Issue) {
+	teams := make(map[string]*TeamSlip)
+	total := &TeamSlip{Team: "TOTAL", LateBuckets: make([]int, len(slipBuckets)+1)}
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		if epic.Due.IsZero() {
+			continue
+		}
+		due := dayOf(epic.Due)
+		var mana float64
+		for _, child := range children[epic.Key] {
+			if !child.ManaSet {
+				continue
+			}
+			mana += child.Mana
+			if !child.Resolved.IsZero() && dayOf(child.Resolved).After(due) {
+				epic.LateMana += child.Mana
+			}
+		}
+		if !epic.Resolved.IsZero() {
+			epic.SlipDays = int(dayOf(epic.Resolved).Sub(due).Hours() / 24)
+			epic.SlipSet = true
+		}
+
+		name := epic.Team
+		if name == "" {
+			name = "No Team"
+		}
+		if teams[name] == nil {
+			teams[name] = &TeamSlip{Team: name, LateBuckets: make([]int, len(slipBuckets)+1)}
+		}
+		for _, t := range []*TeamSlip{teams[name], total} {
+			t.Epics++
+			t.Mana += mana
+			t.LateMana += epic.LateMana
+			if !epic.SlipSet {
+				continue
+			}
+			t.Resolved++
+			t.slips = append(t.slips, float64(epic.SlipDays))
+			if epic.SlipDays <= 0 {
+				t.OnTime++
+			} else {
+				t.LateBuckets[slipBucket(epic.SlipDays)]++
+			}
+			if t.Resolved == 1 || epic.SlipDays > t.MaxSlip {
+				t.MaxSlip = epic.SlipDays
+			}
+		}
+	}
+
+	report.Slip = &EpicSlip{}
+	for _, t := range teams {
+		t.MedianSlip = calculateMedian(t.slips)
+		report.Slip.Teams = append(report.Slip.Teams, *t)
+	}
+	sort.Slice(report.Slip.Teams, func(i, j int) bool {
+		return report.Slip.Teams[i].Team < report.Slip.Teams[j].Team
+	})
+	total.MedianSlip = calculateMedian(total.slips)
+	report.Slip.Total = *total
+}
+
+// slipLabel formats a slip in days with its sign, or "-" if unknown
+func (e EpicDetails) slipLabel() string {
+	if !e.SlipSet {
+		return "-"
+	}
+	return fmt.Sprintf("%+d", e.SlipDays)
+}
+
+// writeEpicSlip writes the due date slip distribution per team
+func writeEpicSlip(w io.Writer, slip *EpicSlip, layout tableOptions) {
+	columns := []tableColumn{
+		{Header: "Team", MaxWidth: 30},
+		{Header: "Epics", Right: true},
+		{Header: "Resolved", Right: true},
+		{Header: "On Time", Right: true},
+	}
+	prev := 1
+	for _, limit := range slipBuckets {
+		columns = append(columns, tableColumn{Header: fmt.Sprintf("%d-%dd Late", prev, limit), Right: true})
+		prev = limit + 1
+	}
+	columns = append(columns,
+		tableColumn{Header: fmt.Sprintf(">%dd Late", slipBuckets[len(slipBuckets)-1]), Right: true},
+		tableColumn{Header: "Median Slip", Right: true},
+		tableColumn{Header: "Max Slip", Right: true},
+		tableColumn{Header: "Late Mana", Right: true},
+		tableColumn{Header: "% Late", Right: true},
+	)
+	table := newTextTable(columns...)
+	row := func(t TeamSlip) []string {
+		cells := []string{t.Team,
+			layout.Numbers.count(t.Epics),
+			layout.Numbers.count(t.Resolved),
+			layout.Numbers.count(t.OnTime)}
+		for _, n := range t.LateBuckets {
+			cells = append(cells, layout.Numbers.count(n))
+		}
+		median, max := "-", "-"
+		if t.Resolved > 0 {
+			median = layout.Numbers.decimal(t.MedianSlip)
+			max = fmt.Sprintf("%+d", t.MaxSlip)
+		}
+		return append(cells, median, max,
+			layout.Numbers.mana(t.LateMana),
+			fmt.Sprintf("%.1f%%", t.lateShare()))
+	}
+	for _, t := range slip.Teams {
+		table.addRow(row(t)...)
+	}
+	table.addFooter(row(slip.Total)...)
+
+	fmt.Fprintf(w, "\nDue Date Slip by Team:\n")
+	if slip.Total.Epics == 0 {
+		fmt.Fprintln(w, "No epic has a due date.")
+		return
+	}
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Slip is the days from an epic's due date to its resolution date, negative if early; epics without a resolution date only count towards Late Mana, the mana of children resolved after the due date.")
+}
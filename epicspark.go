@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// sparkBlocks are the sparkline levels, from a week without resolutions to
+// the epic's busiest week
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// applyEpicActivity counts the child resolutions of every epic per week of
+// the range from start to the end day, the last week being cut short by end
+func applyEpicActivity(report *EpicReport, children map[string][]Issue, start, end time.Time) {
+	weeks := (int(end.Sub(start).Hours()/24) + 7) / 7
+	report.ActivityStart = start
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		epic.Activity = make([]int, weeks)
+		for _, child := range children[epic.Key] {
+			if child.Resolved.IsZero() {
+				continue
+			}
+			day := dayOf(child.Resolved)
+			if day.Before(start) || day.After(end) {
+				continue
+			}
+			epic.Activity[int(day.Sub(start).Hours()/24)/7]++
+		}
+	}
+}
+
+// sparkline draws one block per week, as high as the week's resolutions
+// relative to the busiest week
+func sparkline(counts []int) string {
+	most := 0
+	for _, n := range counts {
+		if n > most {
+			most = n
+		}
+	}
+	line := make([]rune, len(counts))
+	for i, n := range counts {
+		level := 0
+		if n > 0 {
+			level = int(math.Ceil(float64(n) / float64(most) * float64(len(sparkBlocks)-1)))
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
+
+// writeEpicActivityNote explains the sparkline column of the epic details table
+func writeEpicActivityNote(w io.Writer, report *EpicReport) {
+	fmt.Fprintf(w, "Weekly Resolutions: one block per week from %s, each epic scaled to its own busiest week; %c is a week without resolved children.\n",
+		report.ActivityStart.Format("2006-01-02"), sparkBlocks[0])
+}
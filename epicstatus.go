@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Jira status categories, in the order of the child status column
+const (
+	statusCategoryDone       = "Done"
+	statusCategoryInProgress = "In Progress"
+	statusCategoryToDo       = "To Do"
+)
+
+// EpicChildStatuses are the children of an epic per status category
+type EpicChildStatuses struct {
+	Done       int
+	InProgress int
+	ToDo       int
+}
+
+// childStatusCategory returns the status category of a child. Without one,
+// resolved children count as done and the others as to do.
+func childStatusCategory(child Issue) string {
+	switch child.StatusCategory {
+	case statusCategoryDone, statusCategoryInProgress, statusCategoryToDo:
+		return child.StatusCategory
+	}
+	if !child.Resolved.IsZero() {
+		return statusCategoryDone
+	}
+	return statusCategoryToDo
+}
+
+// applyEpicChildStatus counts the children of every epic per status
+// category, the resolved children and the unresolved ones together
+func applyEpicChildStatus(report *EpicReport, children, unresolved map[string][]Issue) {
+	report.ChildStatuses = true
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		counts := &EpicChildStatuses{}
+		seen := make(map[string]bool)
+		for _, child := range append(append([]Issue{}, children[epic.Key]...), unresolved[epic.Key]...) {
+			if seen[child.Key] {
+				continue
+			}
+			seen[child.Key] = true
+			switch childStatusCategory(child) {
+			case statusCategoryDone:
+				counts.Done++
+			case statusCategoryInProgress:
+				counts.InProgress++
+			default:
+				counts.ToDo++
+			}
+		}
+		epic.ChildStatuses = counts
+	}
+}
+
+// childStatusLabel formats the children of an epic per status category,
+// e.g. "12 Done / 3 In Progress / 5 To Do"
+func (e EpicDetails) childStatusLabel(numbers numberFormat) string {
+	s := e.ChildStatuses
+	if s == nil || s.Done+s.InProgress+s.ToDo == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s / %s %s / %s %s",
+		numbers.count(s.Done), statusCategoryDone,
+		numbers.count(s.InProgress), statusCategoryInProgress,
+		numbers.count(s.ToDo), statusCategoryToDo)
+}
+
+// writeEpicChildStatusNote explains the child status column of the epic
+// details table
+func writeEpicChildStatusNote(w io.Writer) {
+	fmt.Fprintln(w, "Child Statuses: children per status category, resolved and unresolved ones")
+}
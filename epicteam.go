@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// addForeign counts a child ticket filed under another Team than the epic.
+// Children without Mana Spent count as tickets but add no mana.
+func (e *EpicDetails) addForeign(child Issue) {
+	if e.ForeignTeams == nil {
+		e.ForeignTeams = make(map[string]int)
+	}
+	e.ForeignTickets++
+	e.ForeignTeams[child.Team]++
+	if child.ManaSet {
+		e.ForeignMana += child.Mana
+	}
+}
+
+// foreignTeamList lists the foreign teams of the epic with their number of
+// children, most children first
+func (e EpicDetails) foreignTeamList() string {
+	teams := make([]string, 0, len(e.ForeignTeams))
+	for team := range e.ForeignTeams {
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if e.ForeignTeams[teams[i]] != e.ForeignTeams[teams[j]] {
+			return e.ForeignTeams[teams[i]] > e.ForeignTeams[teams[j]]
+		}
+		return teams[i] < teams[j]
+	})
+	for i, team := range teams {
+		teams[i] = fmt.Sprintf("%s (%d)", team, e.ForeignTeams[team])
+	}
+	return strings.Join(teams, ", ")
+}
+
+// writeEpicTeamConsistency lists the epics with child tickets of another
+// Team than the epic's own, which team reports credit to the other team
+func writeEpicTeamConsistency(w io.Writer, report *EpicReport, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Epic Key"},
+		tableColumn{Header: "Epic Team", MaxWidth: 30},
+		tableColumn{Header: "Foreign Tickets", Right: true},
+		tableColumn{Header: "Foreign Mana", Right: true},
+		tableColumn{Header: "% of Epic Mana", Right: true},
+		tableColumn{Header: "Foreign Teams", MaxWidth: 50},
+	)
+	withoutTeam := 0
+	for _, epic := range report.Epics {
+		if epic.Team == "" {
+			withoutTeam++
+		}
+		if epic.ForeignTickets == 0 {
+			continue
+		}
+		share := 0.0
+		if epic.TotalMana > 0 {
+			share = epic.ForeignMana / epic.TotalMana * 100
+		}
+		table.addRow(epic.Key,
+			epic.Team,
+			layout.Numbers.count(epic.ForeignTickets),
+			layout.Numbers.mana(epic.ForeignMana),
+			fmt.Sprintf("%.1f%%", share),
+			epic.foreignTeamList())
+	}
+
+	fmt.Fprintf(w, "\nEpic Team Consistency:\n")
+	if len(table.rows) == 0 {
+		fmt.Fprintln(w, "Every child ticket with a Team belongs to its epic's Team.")
+	} else {
+		table.write(w, layout.Style)
+		fmt.Fprintln(w, "Foreign tickets are children with another Team than their epic; team reports count their mana for that other team.")
+	}
+	if withoutTeam > 0 {
+		fmt.Fprintf(w, "Epics without a Team, not checked: %d\n", withoutTeam)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// Value quadrants of epics, split at the median value and mana of the epics
+// with a value
+const (
+	quadrantQuickWin = "High Value / Low Cost"
+	quadrantBigBet   = "High Value / High Cost"
+	quadrantFillIn   = "Low Value / Low Cost"
+	quadrantMoneyPit = "Low Value / High Cost"
+)
+
+// epicQuadrants is the order quadrants are listed in
+var epicQuadrants = []string{quadrantQuickWin, quadrantBigBet, quadrantFillIn, quadrantMoneyPit}
+
+// EpicQuadrant is one row of the value quadrant summary
+type EpicQuadrant struct {
+	Name       string
+	Epics      []string // Epic keys, in the order of the epic details
+	TotalMana  float64
+	TotalValue float64
+}
+
+// valuePerMana returns the value delivered per mana spent in the quadrant
+func (q EpicQuadrant) valuePerMana() float64 {
+	if q.TotalMana == 0 {
+		return 0
+	}
+	return q.TotalValue / q.TotalMana
+}
+
+// resolveField returns the ID of the field with the ID or name, matching
+// names case-insensitively
+func resolveField(client *jira.Client, idOrName string) (id, name string, err error) {
+	fields, resp, err := client.Field.GetList()
+	if err != nil {
+		return "", "", fmt.Errorf("listing fields: %s", describeJiraError(resp, err))
+	}
+	var matches []jira.Field
+	for _, f := range fields {
+		if f.ID == idOrName {
+			return f.ID, f.Name, nil
+		}
+		if strings.EqualFold(f.Name, idOrName) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("no field %q", idOrName)
+	case 1:
+		return matches[0].ID, matches[0].Name, nil
+	}
+	ids := make([]string, len(matches))
+	for i, f := range matches {
+		ids[i] = f.ID
+	}
+	return "", "", fmt.Errorf("%d fields are named %q (%s), pass the ID of one", len(matches), idOrName, strings.Join(ids, ", "))
+}
+
+// fieldNumber returns the number in a field value: a number, a numeric text
+// or a select option with a numeric value. ok is false for anything else.
+func fieldNumber(v interface{}) (n float64, ok bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	case map[string]interface{}:
+		if option, isString := v["value"].(string); isString {
+			return fieldNumber(option)
+		}
+	}
+	return 0, false
+}
+
+// epicValues returns the numeric values of the field of the epics, keyed by
+// epic key. Epics without a numeric value are left out.
+func epicValues(epics []jira.Issue, fieldID string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, epic := range epics {
+		if epic.Fields == nil {
+			continue
+		}
+		if n, ok := fieldNumber(epic.Fields.Unknowns[fieldID]); ok {
+			values[epic.Key] = n
+		}
+	}
+	return values
+}
+
+// fetchEpicValues fetches the values of the field of the epics
+func fetchEpicValues(client *jira.Client, fieldID string, epics []Issue) (map[string]float64, error) {
+	if len(epics) == 0 {
+		return map[string]float64{}, nil
+	}
+	keys := make([]string, len(epics))
+	for i, epic := range epics {
+		keys[i] = epic.Key
+	}
+	found, err := searchByKeys(client, keys, []string{fieldID}, "")
+	if err != nil {
+		return nil, err
+	}
+	return epicValues(found, fieldID), nil
+}
+
+// applyEpicValues sets the values of the epics of the report and sorts the
+// epics with a value into quadrants. An epic counts as high value or high
+// cost if its value or mana is at least the median of the epics with a value.
+func applyEpicValues(report *EpicReport, field string, values map[string]float64) {
+	report.ValueField = field
+	var valued, mana []float64
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		if v, ok := values[epic.Key]; ok {
+			epic.Value = v
+			epic.ValueSet = true
+			valued = append(valued, v)
+			mana = append(mana, epic.TotalMana)
+		}
+	}
+	if len(valued) == 0 {
+		return
+	}
+	valueMedian := calculateMedian(valued)
+	manaMedian := calculateMedian(mana)
+
+	quadrants := make(map[string]*EpicQuadrant)
+	for _, name := range epicQuadrants {
+		quadrants[name] = &EpicQuadrant{Name: name}
+	}
+	for i := range report.Epics {
+		epic := &report.Epics[i]
+		if !epic.ValueSet {
+			continue
+		}
+		highValue := epic.Value >= valueMedian
+		highCost := epic.TotalMana >= manaMedian
+		switch {
+		case highValue && !highCost:
+			epic.Quadrant = quadrantQuickWin
+		case highValue:
+			epic.Quadrant = quadrantBigBet
+		case !highCost:
+			epic.Quadrant = quadrantFillIn
+		default:
+			epic.Quadrant = quadrantMoneyPit
+		}
+		q := quadrants[epic.Quadrant]
+		q.Epics = append(q.Epics, epic.Key)
+		q.TotalMana += epic.TotalMana
+		q.TotalValue += epic.Value
+	}
+	for _, name := range epicQuadrants {
+		report.Quadrants = append(report.Quadrants, *quadrants[name])
+	}
+}
+
+// valuePerMana returns the epic's value per mana spent, or "-" if it has no
+// value or no mana
+func (e EpicDetails) valuePerMana(numbers numberFormat) string {
+	if !e.ValueSet || e.TotalMana == 0 {
+		return "-"
+	}
+	return numbers.decimal(e.Value / e.TotalMana)
+}
+
+// writeEpicQuadrants writes the value quadrant summary
+func writeEpicQuadrants(w io.Writer, report *EpicReport, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Quadrant"},
+		tableColumn{Header: "Epics", Right: true},
+		tableColumn{Header: "Total Mana", Right: true},
+		tableColumn{Header: "Total " + report.ValueField, Right: true},
+		tableColumn{Header: "Value per Mana", Right: true},
+		tableColumn{Header: "Epic Keys", MaxWidth: 50},
+	)
+	for _, q := range report.Quadrants {
+		table.addRow(q.Name,
+			layout.Numbers.count(len(q.Epics)),
+			layout.Numbers.mana(q.TotalMana),
+			layout.Numbers.decimal(q.TotalValue),
+			layout.Numbers.decimal(q.valuePerMana()),
+			strings.Join(q.Epics, ", "))
+	}
+
+	missing := 0
+	for _, epic := range report.Epics {
+		if !epic.ValueSet {
+			missing++
+		}
+	}
+	fmt.Fprintf(w, "\nEpics by %s and Cost:\n", report.ValueField)
+	if len(report.Quadrants) == 0 {
+		fmt.Fprintf(w, "No epic has a numeric %s.\n", report.ValueField)
+		return
+	}
+	table.write(w, layout.Style)
+	fmt.Fprintf(w, "High value or cost is at least the median of the epics with a %s. Epics without one: %d\n", report.ValueField, missing)
+}
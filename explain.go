@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExplainedIssue is the classification decision for one issue, as written
+// by -explain-classification
+type ExplainedIssue struct {
+	Key       string  `json:"key"`
+	IssueType string  `json:"issue_type"`
+	Team      string  `json:"team"`
+	Mana      float64 `json:"mana"`
+	Category  string  `json:"category"` // Empty if the issue is excluded
+	Excluded  bool    `json:"excluded"`
+	Rule      string  `json:"rule"`
+	Detail    string  `json:"detail"` // What the rule matched
+}
+
+// explainFormat returns the format of an -explain-classification file, from
+// its extension
+func explainFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv", ".json":
+		return ext[1:], nil
+	}
+	return "", fmt.Errorf("-explain-classification must be a .csv or .json file, got %q", path)
+}
+
+// explainClassification lists the category of every issue and the rule that
+// assigned it, in the order of the issues
+func explainClassification(issues []Issue, opts classifyOptions) []ExplainedIssue {
+	explained := make([]ExplainedIssue, 0, len(issues))
+	for _, issue := range issues {
+		e := ExplainedIssue{
+			Key:       issue.Key,
+			IssueType: issue.Type,
+			Team:      issue.Team,
+			Mana:      issue.Mana,
+		}
+		if opts.isAutomation(issue) {
+			e.Excluded = true
+			e.Rule = ruleAutomation
+			e.Detail = "reported by " + issue.ReporterName
+		} else if opts.excluded(issue) {
+			e.Excluded = true
+			e.Rule = ruleResearch
+			e.Detail = issue.Type + " excluded"
+		} else {
+			c := explainIssue(issue, opts)
+			e.Category, e.Rule, e.Detail = c.Category, c.Rule, c.Detail
+		}
+		explained = append(explained, e)
+	}
+	return explained
+}
+
+// renderExplanations renders the classification decisions as CSV or JSON
+func renderExplanations(explained []ExplainedIssue, format string) ([]byte, error) {
+	if format == "json" {
+		b, err := json.MarshalIndent(explained, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"key", "issue_type", "team", "mana", "category", "excluded", "rule", "detail"})
+	for _, e := range explained {
+		w.Write([]string{e.Key, e.IssueType, e.Team,
+			strconv.FormatFloat(e.Mana, 'f', -1, 64),
+			e.Category,
+			strconv.FormatBool(e.Excluded),
+			e.Rule,
+			e.Detail})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeExplanations writes the classification decisions to the file, as CSV
+// or JSON depending on its extension
+func writeExplanations(path string, explained []ExplainedIssue) error {
+	format, err := explainFormat(path)
+	if err != nil {
+		return err
+	}
+	b, err := renderExplanations(explained, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// fieldProbeSize is how many issues of a query are checked for the fields
+// they return
+const fieldProbeSize = 50
+
+// optionalField is a field that only some features of a report read, so the
+// report can go without it
+type optionalField struct {
+	ID       string
+	Name     string
+	Features string // The features left out without the field
+}
+
+// Optional fields of the ticket report
+var (
+	teamField       = optionalField{ID: teamFieldID, Name: "Team", Features: "-teams, -touched-by and -external-wait"}
+	labelsField     = optionalField{ID: "labels", Name: "Labels", Features: "-broken-windows"}
+	issueLinksField = optionalField{ID: "issuelinks", Name: "Linked Issues", Features: "-security and -external-wait"}
+)
+
+// findMissingFields returns the fields absent from every issue of the first
+// page of the query. Jira leaves out fields the user is not granted or that
+// are not on the project's screens, while fields without a value come back
+// as null. A query without issues tells nothing, so no field is missing.
+// The project's own fields are probed in place of the standard ones.
+func findMissingFields(client *jira.Client, jql string, fields []optionalField, own projectFields) ([]optionalField, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(fields))
+	for i, f := range fields {
+		ids[i] = f.ID
+	}
+	ids = own.request(ids)
+	raw, err := searchRawPage(client, jql, ids, 0, fieldProbeSize)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Issues []struct {
+			Fields map[string]json.RawMessage `json:"fields"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, fmt.Errorf("decoding search results: %w", err)
+	}
+
+	returned := make(map[string]bool)
+	for _, issue := range page.Issues {
+		for id := range issue.Fields {
+			returned[id] = true
+		}
+	}
+	var missing []optionalField
+	for i, f := range fields {
+		if len(page.Issues) > 0 && !returned[ids[i]] {
+			missing = append(missing, f)
+		}
+	}
+	return missing, nil
+}
+
+// describeMissingField tells which features are left out without the field,
+// naming the project's own field in place of the standard one
+func describeMissingField(f optionalField, own projectFields) string {
+	return fmt.Sprintf("%s (%s) is not returned by Jira; left out: %s", f.Name, own.request([]string{f.ID})[0], f.Features)
+}
+
+// writeMissingFields writes a warning listing the fields Jira did not
+// return, if any
+func writeMissingFields(w io.Writer, missing []string) {
+	if len(missing) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nWARNING: Jira fields some features read are missing, check that the API user may see them and that they are on the project's screens:\n")
+	for _, m := range missing {
+		fmt.Fprintf(w, "  - %s\n", m)
+	}
+}
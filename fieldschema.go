@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// watchedFieldIDs are the custom fields theia reads. Reports quietly go
+// wrong when one of them is recreated, retyped or gets other options.
+var watchedFieldIDs = []string{manaFieldID, teamFieldID, epicLinkFieldID}
+
+// fieldState is what a run saw of one watched field
+type fieldState struct {
+	ID      string   `json:"id"`
+	Missing bool     `json:"missing,omitempty"` // Jira has no field with the ID
+	Name    string   `json:"name,omitempty"`
+	Type    string   `json:"type,omitempty"`   // Schema type, e.g. number or array of option
+	Custom  string   `json:"custom,omitempty"` // Custom field type, e.g. com.atlassian.jira.plugin.system.customfieldtypes:select
+	Options []string `json:"options"`          // Sorted values of select fields, nil if unknown
+}
+
+// fieldSnapshot is the state of the watched fields at one run
+type fieldSnapshot struct {
+	Taken  time.Time    `json:"taken"`
+	Fields []fieldState `json:"fields"`
+}
+
+// fieldType describes the schema type of a field
+func fieldType(s jira.FieldSchema) string {
+	if s.Type == "array" && s.Items != "" {
+		return "array of " + s.Items
+	}
+	return s.Type
+}
+
+// hasOptions reports whether values of the field are select options
+func hasOptions(s jira.FieldSchema) bool {
+	return s.Type == "option" || s.Items == "option" || s.Type == "option-with-child"
+}
+
+// fieldOptions returns the sorted option values of a select field over all
+// its contexts. Disabled options are marked, as they no longer accept values.
+func fieldOptions(client *jira.Client, fieldID string) ([]string, error) {
+	var contexts struct {
+		Values []struct {
+			ID string `json:"id"`
+		} `json:"values"`
+	}
+	if err := getJSON(client, fmt.Sprintf("rest/api/2/field/%s/context?maxResults=1000", url.PathEscape(fieldID)), &contexts); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	options := []string{}
+	for _, c := range contexts.Values {
+		var page struct {
+			Values []struct {
+				Value    string `json:"value"`
+				Disabled bool   `json:"disabled"`
+			} `json:"values"`
+		}
+		endpoint := fmt.Sprintf("rest/api/2/field/%s/context/%s/option?maxResults=1000", url.PathEscape(fieldID), url.PathEscape(c.ID))
+		if err := getJSON(client, endpoint, &page); err != nil {
+			return nil, err
+		}
+		for _, o := range page.Values {
+			value := o.Value
+			if o.Disabled {
+				value += " (disabled)"
+			}
+			if !seen[value] {
+				seen[value] = true
+				options = append(options, value)
+			}
+		}
+	}
+	sort.Strings(options)
+	return options, nil
+}
+
+// getJSON decodes the response of a GET request to the Jira endpoint
+func getJSON(client *jira.Client, endpoint string, v interface{}) error {
+	req, err := client.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req, v)
+	if err != nil {
+		return errors.New(describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return nil
+}
+
+// takeFieldSnapshot reads the state of the watched fields from Jira. Options
+// stay unknown where Jira has no field context API, as on Jira Server. All
+// fields are returned too, to find watched fields recreated under a new ID.
+func takeFieldSnapshot(client *jira.Client) (*fieldSnapshot, []jira.Field, error) {
+	fields, resp, err := client.Field.GetList()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing fields: %s", describeJiraError(resp, err))
+	}
+	byID := make(map[string]jira.Field, len(fields))
+	for _, f := range fields {
+		byID[f.ID] = f
+	}
+
+	snapshot := &fieldSnapshot{Taken: time.Now().UTC()}
+	for _, id := range watchedFieldIDs {
+		f, ok := byID[id]
+		if !ok {
+			snapshot.Fields = append(snapshot.Fields, fieldState{ID: id, Missing: true})
+			continue
+		}
+		state := fieldState{ID: id, Name: f.Name, Type: fieldType(f.Schema), Custom: f.Schema.Custom}
+		if hasOptions(f.Schema) {
+			state.Options, _ = fieldOptions(client, id)
+		}
+		snapshot.Fields = append(snapshot.Fields, state)
+	}
+	return snapshot, fields, nil
+}
+
+// compareFieldSnapshots lists how the watched fields changed from the
+// previous snapshot to the current one, with fields the current list of all
+// fields. A watched field gone or renamed while another field took its name
+// was most likely recreated under a new ID, which theia does not read.
+func compareFieldSnapshots(prev, cur *fieldSnapshot, fields []jira.Field) []string {
+	before := make(map[string]fieldState, len(prev.Fields))
+	for _, f := range prev.Fields {
+		before[f.ID] = f
+	}
+	var changes []string
+	for _, now := range cur.Fields {
+		was, ok := before[now.ID]
+		if !ok || was.Missing {
+			if ok && !now.Missing {
+				changes = append(changes, fmt.Sprintf("%s exists again, as %q", now.ID, now.Name))
+			}
+			continue
+		}
+		label := fmt.Sprintf("%s (%s)", was.Name, was.ID)
+
+		if now.Missing || now.Name != was.Name {
+			for _, f := range fields {
+				if f.Name == was.Name && f.ID != was.ID {
+					changes = append(changes, fmt.Sprintf("%s: a field named %q now has the ID %s, which theia does not read", label, was.Name, f.ID))
+					break
+				}
+			}
+		}
+		if now.Missing {
+			changes = append(changes, fmt.Sprintf("%s: the field no longer exists", label))
+			continue
+		}
+		if now.Name != was.Name {
+			changes = append(changes, fmt.Sprintf("%s: renamed to %q", label, now.Name))
+		}
+		if now.Type != was.Type || now.Custom != was.Custom {
+			changes = append(changes, fmt.Sprintf("%s: type changed from %s to %s", label, describeFieldType(was), describeFieldType(now)))
+		}
+		if was.Options != nil && now.Options != nil {
+			added, removed := diffOptions(was.Options, now.Options)
+			if len(added) > 0 {
+				changes = append(changes, fmt.Sprintf("%s: options added: %s", label, strings.Join(added, ", ")))
+			}
+			if len(removed) > 0 {
+				changes = append(changes, fmt.Sprintf("%s: options removed: %s", label, strings.Join(removed, ", ")))
+			}
+		}
+	}
+	return changes
+}
+
+// describeFieldType describes the type of a field state with its custom type
+func describeFieldType(f fieldState) string {
+	if f.Custom == "" {
+		return f.Type
+	}
+	return fmt.Sprintf("%s (%s)", f.Type, f.Custom)
+}
+
+// diffOptions returns the options only in now, and those only in was
+func diffOptions(was, now []string) (added, removed []string) {
+	in := func(list []string, v string) bool {
+		for _, o := range list {
+			if o == v {
+				return true
+			}
+		}
+		return false
+	}
+	for _, o := range now {
+		if !in(was, o) {
+			added = append(added, o)
+		}
+	}
+	for _, o := range was {
+		if !in(now, o) {
+			removed = append(removed, o)
+		}
+	}
+	return added, removed
+}
+
+// fieldSnapshotPath returns the file keeping the field snapshot of the Jira
+// site in the user config directory
+func fieldSnapshotPath(jiraURL string) (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no config directory for field snapshots: %w", err)
+	}
+	site := jiraURL
+	if u, err := url.Parse(jiraURL); err == nil && u.Host != "" {
+		site = u.Host
+	}
+	site = strings.NewReplacer("/", "_", ":", "_").Replace(site)
+	return filepath.Join(base, "theia", "field-snapshots", site+".json"), nil
+}
+
+// loadFieldSnapshot reads the snapshot of the previous run, nil if there is
+// none yet
+func loadFieldSnapshot(path string) (*fieldSnapshot, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := new(fieldSnapshot)
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// saveFieldSnapshot stores the snapshot for the next run to compare against
+func saveFieldSnapshot(path string, snapshot *fieldSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// fieldDriftCheck compares the watched fields with the previous scheduled
+// run. The new snapshot is only saved once the report went out, so a failed
+// run alerts again.
+type fieldDriftCheck struct {
+	path     string
+	snapshot *fieldSnapshot
+	Since    time.Time // When the previous snapshot was taken, zero on the first run
+	Changes  []string
+}
+
+// checkFieldDrift takes a snapshot of the watched fields and compares it with
+// the one saved by the previous run
+func checkFieldDrift(client *jira.Client, jiraURL string) (*fieldDriftCheck, error) {
+	path, err := fieldSnapshotPath(jiraURL)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := loadFieldSnapshot(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading field snapshot: %w", err)
+	}
+	cur, fields, err := takeFieldSnapshot(client)
+	if err != nil {
+		return nil, err
+	}
+	check := &fieldDriftCheck{path: path, snapshot: cur}
+	if prev != nil {
+		check.Since = prev.Taken
+		check.Changes = compareFieldSnapshots(prev, cur, fields)
+	}
+	return check, nil
+}
+
+// save stores the snapshot of this run
+func (c *fieldDriftCheck) save() error {
+	return saveFieldSnapshot(c.path, c.snapshot)
+}
+
+// writeFieldChanges writes a warning listing the field changes, if any
+func writeFieldChanges(w io.Writer, changes []string) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nWARNING: Jira fields theia reads changed since the last run, check the results:\n")
+	for _, change := range changes {
+		fmt.Fprintf(w, "  - %s\n", change)
+	}
+}
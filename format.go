@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// outputFormats are the values accepted by -format
+var outputFormats = []string{"text", "pdf"}
+
+// validateFormat checks the -format flag value
+func validateFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q, expected text or pdf", format)
+}
+
+// writeFormatted renders the text report and writes it in the format to the
+// output file, or to stdout if output is empty. A file in a report archive
+// is added to its index.
+func writeFormatted(format, output, title string, render func(w io.Writer)) error {
+	if output == "" {
+		return writeFormat(os.Stdout, format, title, render)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := writeFormat(f, format, title, render); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	recordArchiveReport(output, title)
+	return nil
+}
+
+// writeFormat writes the text report rendered by render to w in the format,
+// and adds it to the job summary with -github-actions
+func writeFormat(w io.Writer, format, title string, render func(w io.Writer)) error {
+	if format != "pdf" && !githubActions {
+		render(w)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	render(&buf)
+	if githubActions {
+		if err := writeStepSummary(title, buf.String()); err != nil {
+			return err
+		}
+	}
+	if format != "pdf" {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	return writeTextPDF(w, title, buf.String())
+}
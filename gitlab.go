@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultGitLabURL is the GitLab instance used without GITLAB_URL
+const defaultGitLabURL = "https://gitlab.com"
+
+// gitlabPageSize is the number of issues requested per page, GitLab's maximum
+const gitlabPageSize = 100
+
+// GitLabConfig maps GitLab issues onto the ticket report. Every field is
+// optional.
+type GitLabConfig struct {
+	TypeLabels      map[string]string `json:"typeLabels"`      // Label to issue type, case-insensitive
+	TeamLabelPrefix string            `json:"teamLabelPrefix"` // Prefix of the labels naming the team, default "team::"
+	ManaPerWeight   float64           `json:"manaPerWeight"`   // Mana of one weight point, default 1
+	ExcludeLabels   []string          `json:"excludeLabels"`   // Closed issues left out, like Won't Do in Jira
+}
+
+// defaultGitLabConfig is the mapping of the common GitLab labels
+var defaultGitLabConfig = GitLabConfig{
+	TypeLabels: map[string]string{
+		"bug":               "Bug",
+		"type::bug":         "Bug",
+		"feature":           "Story",
+		"type::feature":     "Story",
+		"enhancement":       "Improvement",
+		"type::maintenance": "Improvement",
+		"spike":             "Spike",
+	},
+	TeamLabelPrefix: "team::",
+	ManaPerWeight:   1,
+	ExcludeLabels:   []string{"duplicate", "invalid", "wontfix"},
+}
+
+// gitlabMapping returns the config's GitLab mapping with the defaults of the
+// fields it leaves out, type labels lower-cased
+func gitlabMapping(config *GitLabConfig) GitLabConfig {
+	m := defaultGitLabConfig
+	if config == nil {
+		return m
+	}
+	if config.TypeLabels != nil {
+		m.TypeLabels = make(map[string]string, len(config.TypeLabels))
+		for label, issueType := range config.TypeLabels {
+			m.TypeLabels[strings.ToLower(label)] = issueType
+		}
+	}
+	if config.TeamLabelPrefix != "" {
+		m.TeamLabelPrefix = config.TeamLabelPrefix
+	}
+	if config.ManaPerWeight != 0 {
+		m.ManaPerWeight = config.ManaPerWeight
+	}
+	if config.ExcludeLabels != nil {
+		m.ExcludeLabels = config.ExcludeLabels
+	}
+	return m
+}
+
+// gitlabIssue is an issue as the GitLab issues API returns it
+type gitlabIssue struct {
+	IID        int        `json:"iid"`
+	Title      string     `json:"title"`
+	State      string     `json:"state"`
+	IssueType  string     `json:"issue_type"`
+	Labels     []string   `json:"labels"`
+	Weight     *int       `json:"weight"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ClosedAt   *time.Time `json:"closed_at"`
+	DueDate    string     `json:"due_date"`
+	References struct {
+		Full string `json:"full"`
+	} `json:"references"`
+	Assignee *struct {
+		Username string `json:"username"`
+	} `json:"assignee"`
+	Epic *struct {
+		IID int `json:"iid"`
+	} `json:"epic"`
+}
+
+// gitlabClient reads issues from the GitLab REST API
+type gitlabClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// newGitLabClientFromEnv creates a GitLab client from the GITLAB_URL and
+// GITLAB_TOKEN environment variables. GITLAB_URL defaults to gitlab.com.
+func newGitLabClientFromEnv() (*gitlabClient, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("missing required environment variable, please set GITLAB_TOKEN")
+	}
+	baseURL := os.Getenv("GITLAB_URL")
+	if baseURL == "" {
+		baseURL = defaultGitLabURL
+	}
+	return &gitlabClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// closedIssues fetches the issues of the project closed from start to the end
+// of the end day. GitLab can't filter on the closing time, so the issues
+// updated since start are fetched and filtered.
+func (c *gitlabClient) closedIssues(project string, start, end time.Time) ([]gitlabIssue, error) {
+	query := url.Values{
+		"state":         {"closed"},
+		"scope":         {"all"},
+		"updated_after": {start.Format(time.RFC3339)},
+		"order_by":      {"created_at"},
+		"per_page":      {strconv.Itoa(gitlabPageSize)},
+	}
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/issues", c.baseURL, url.PathEscape(project))
+
+	var closed []gitlabIssue
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching GitLab issues: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitLab returned %s: %s", resp.Status, string(body))
+		}
+		var issues []gitlabIssue
+		err = json.NewDecoder(resp.Body).Decode(&issues)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding GitLab issues: %w", err)
+		}
+		for _, gi := range issues {
+			if gi.ClosedAt != nil && !gi.ClosedAt.Before(start) && gi.ClosedAt.Before(end.AddDate(0, 0, 1)) {
+				closed = append(closed, gi)
+			}
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return closed, nil
+}
+
+// issueFromGitLab converts a GitLab issue into an Issue. The weight is the
+// Mana Spent, the first type label the issue type, incidents being bugs and
+// other issues stories, and the team label the Team.
+func issueFromGitLab(gi gitlabIssue, m GitLabConfig) Issue {
+	issue := Issue{
+		Key:     gi.References.Full,
+		Type:    "Story",
+		Summary: gi.Title,
+		Status:  gi.State,
+		Labels:  gi.Labels,
+		Created: gi.CreatedAt,
+	}
+	if issue.Key == "" {
+		issue.Key = "#" + strconv.Itoa(gi.IID)
+	}
+	if gi.IssueType == "incident" {
+		issue.Type = "Bug"
+	}
+	typed := false
+	for _, label := range gi.Labels {
+		if t, ok := m.TypeLabels[strings.ToLower(label)]; ok && !typed {
+			issue.Type = t
+			typed = true
+		}
+		if issue.Team == "" && strings.HasPrefix(label, m.TeamLabelPrefix) {
+			issue.Team = strings.TrimPrefix(label, m.TeamLabelPrefix)
+		}
+	}
+	if gi.Weight != nil {
+		issue.Mana = float64(*gi.Weight) * m.ManaPerWeight
+		issue.ManaSet = true
+	}
+	if gi.ClosedAt != nil {
+		issue.Resolved = *gi.ClosedAt
+	}
+	if due, err := time.Parse("2006-01-02", gi.DueDate); err == nil {
+		issue.Due = due
+	}
+	if gi.Assignee != nil {
+		issue.Assignee = gi.Assignee.Username
+	}
+	if gi.Epic != nil {
+		issue.Parent = "&" + strconv.Itoa(gi.Epic.IID)
+	}
+	return issue
+}
+
+// selectGitLabIssues converts the closed issues, leaving out those with an
+// exclude label and those without a weight, as the Jira query leaves out
+// issues resolved as Won't Do and issues without Mana Spent
+func selectGitLabIssues(found []gitlabIssue, m GitLabConfig) []Issue {
+	var issues []Issue
+	for _, gi := range found {
+		excluded := false
+		for _, label := range gi.Labels {
+			for _, ex := range m.ExcludeLabels {
+				excluded = excluded || strings.EqualFold(label, ex)
+			}
+		}
+		issue := issueFromGitLab(gi, m)
+		if !excluded && issue.ManaSet {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// gitlabQuery describes the GitLab issue selection, shown where Jira reports
+// show their JQL
+func gitlabQuery(project string, start, end time.Time, m GitLabConfig) string {
+	q := fmt.Sprintf("GitLab project %s: issues closed from %s to %s with a weight",
+		project, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if len(m.ExcludeLabels) > 0 {
+		q += ", without the labels " + strings.Join(m.ExcludeLabels, ", ")
+	}
+	return q
+}
@@ -2,8 +2,9 @@ module github.com/jwilander/theia
 
 go 1.21.9
 
+require github.com/andygrunwald/go-jira v1.16.0
+
 require (
-	github.com/andygrunwald/go-jira v1.16.0 // indirect
 	github.com/fatih/structs v1.1.0 // indirect
 	github.com/golang-jwt/jwt/v4 v4.4.2 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
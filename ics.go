@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// calendarEvent is an all-day iCalendar event
+type calendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// epicCalendarEvents returns an event on the resolution day of every
+// resolved epic, and with milestones an event on the first day after every
+// month of the range summing up the epics completed in it. Epics without a
+// resolution date, e.g. in GA Release, have no completion to show.
+func epicCalendarEvents(report *EpicReport, jiraURL string, start, end time.Time, milestones bool) []calendarEvent {
+	var events []calendarEvent
+	completed := make(map[string][]EpicDetails)
+	for _, epic := range report.Epics {
+		if epic.Resolved.IsZero() {
+			continue
+		}
+		events = append(events, calendarEvent{
+			UID:     fmt.Sprintf("epic-%s@theia", epic.Key),
+			Date:    epic.Resolved,
+			Summary: fmt.Sprintf("%s completed: %s", epic.Key, epic.Summary),
+			Description: fmt.Sprintf("%.2f mana across %d tickets\n%s/browse/%s",
+				epic.TotalMana, epic.TotalTickets, jiraURL, epic.Key),
+		})
+		month := epic.Resolved.Format(monthLabelFormat)
+		completed[month] = append(completed[month], epic)
+	}
+
+	if milestones {
+		for _, m := range monthsInRange(start, end) {
+			month := m.Format(monthLabelFormat)
+			var mana float64
+			var keys []string
+			for _, epic := range completed[month] {
+				mana += epic.TotalMana
+				keys = append(keys, epic.Key)
+			}
+			description := "No epics completed"
+			if len(keys) > 0 {
+				description = fmt.Sprintf("Epics completed: %d (%.2f mana): %s", len(keys), mana, strings.Join(keys, ", "))
+			}
+			events = append(events, calendarEvent{
+				UID:         fmt.Sprintf("report-%s-%s@theia", report.Project, m.Format("2006-01")),
+				Date:        m.AddDate(0, 1, 0),
+				Summary:     fmt.Sprintf("%s monthly report: %s", report.Project, month),
+				Description: description,
+			})
+		}
+	}
+	return events
+}
+
+// writeCalendar writes the events as an iCalendar (RFC 5545) file, with
+// stamp as the time the events were generated
+func writeCalendar(w io.Writer, events []calendarEvent, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		writeICSLine(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//theia//Mana Analysis//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText(e.Summary))
+		line("DESCRIPTION:" + escapeICSText(e.Description))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// escapeICSText escapes a TEXT property value
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine writes a content line with CRLF ending, folding it into
+// continuation lines of at most 75 octets without splitting UTF-8 characters
+func writeICSLine(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	w.WriteString(s + "\r\n")
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// incidentFields are the fields the incidents command reads
+var incidentFields = []string{"issuetype", "summary", "status", "created", "resolutiondate", manaFieldID, teamFieldID, "labels"}
+
+// defaultIncidentLabelPrefix starts the label of an incident's follow-ups,
+// followed by the incident ID, e.g. incident-1234
+const defaultIncidentLabelPrefix = "incident-"
+
+// incidentLabelBatch is the number of labels per follow-up query, keeping
+// the JQL short
+const incidentLabelBatch = 50
+
+// incidentColumns are the header names accepted for each column of an
+// incident list, covering the PagerDuty and Statuspage exports
+var incidentColumns = map[string][]string{
+	"id":       {"id", "incident_number", "incident id", "incident number", "number"},
+	"title":    {"title", "name", "summary", "description"},
+	"started":  {"started", "started_at", "start", "created_at", "created_on", "created", "date"},
+	"severity": {"severity", "urgency", "impact", "priority"},
+	"jira":     {"jira", "jira key", "jira_key", "issue", "ticket"},
+}
+
+// incidentTimeLayouts are the time formats accepted for the start of an
+// incident
+var incidentTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// Incident is one entry of an incident list
+type Incident struct {
+	ID       string
+	Title    string
+	Severity string
+	Key      string // Jira issue of the incident, such as its postmortem, empty if none
+	Started  time.Time
+}
+
+// label returns the label of the incident's follow-up tickets. Jira labels
+// cannot contain spaces.
+func (i Incident) label(prefix string) string {
+	return strings.ToLower(strings.ReplaceAll(prefix+i.ID, " ", "-"))
+}
+
+// parseIncidentsCSV parses an incident list with a header row. Only the id
+// and started columns are required.
+func parseIncidentsCSV(b []byte) ([]Incident, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the incident list is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	column := make(map[string]int)
+	for field, names := range incidentColumns {
+		for i, h := range header {
+			h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+			if _, found := column[field]; !found && containsString(names, h) {
+				column[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"id", "started"} {
+		if _, ok := column[field]; !ok {
+			return nil, fmt.Errorf("the header row has no %s column, expected one of %s", field, strings.Join(incidentColumns[field], ", "))
+		}
+	}
+	cell := func(record []string, field string) string {
+		if i, ok := column[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var incidents []Incident
+	seen := make(map[string]bool)
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		incident := Incident{
+			ID:       cell(record, "id"),
+			Title:    cell(record, "title"),
+			Severity: cell(record, "severity"),
+			Key:      strings.ToUpper(cell(record, "jira")),
+		}
+		if incident.ID == "" {
+			continue
+		}
+		if seen[incident.ID] {
+			return nil, fmt.Errorf("line %d: incident %s is listed twice", line, incident.ID)
+		}
+		seen[incident.ID] = true
+		if incident.Key != "" && !issueKeyRegex.MatchString(incident.Key) {
+			return nil, fmt.Errorf("line %d: %q is not an issue key like PROJ-123", line, incident.Key)
+		}
+		if incident.Started, err = parseIncidentTime(cell(record, "started")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		incidents = append(incidents, incident)
+	}
+	if len(incidents) == 0 {
+		return nil, errors.New("the incident list has no incidents")
+	}
+	return incidents, nil
+}
+
+// parseIncidentTime parses the start of an incident in one of the
+// incidentTimeLayouts, as UTC
+func parseIncidentTime(s string) (time.Time, error) {
+	for _, layout := range incidentTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid incident start %q, expected a date like 2024-01-15 or 2024-01-15T10:30:00Z", s)
+}
+
+// IncidentCost is the follow-up work of one incident
+type IncidentCost struct {
+	Incident
+	Tickets []Issue // Oldest first
+	Open    int     // Unresolved follow-up tickets
+	Mana    float64
+}
+
+// IncidentReport is the data model of the incidents command
+type IncidentReport struct {
+	Project     string
+	Source      string // The incident list file
+	LabelPrefix string
+	Window      int // Days after the start follow-ups count, 0 for no limit
+	JQL         string
+	Incidents   []IncidentCost // Most mana first
+	// Totals over the distinct follow-up tickets, which may follow up on
+	// several incidents
+	Tickets int
+	Open    int
+	Mana    float64
+}
+
+// withoutFollowUps returns the number of incidents without follow-up tickets
+func (r *IncidentReport) withoutFollowUps() int {
+	n := 0
+	for _, c := range r.Incidents {
+		if len(c.Tickets) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// correlateIncidents assigns the follow-up tickets to the incidents: the
+// tickets labeled for an incident and those linked to its Jira issue, which
+// were created from the day it started up to window days later. Earlier
+// tickets are left out, as their label or link may predate the incident.
+func correlateIncidents(incidents []Incident, labeled []Issue, linked map[string][]Issue, prefix string, window int) *IncidentReport {
+	report := &IncidentReport{LabelPrefix: prefix, Window: window}
+	counted := make(map[string]bool)
+	for _, incident := range incidents {
+		cost := IncidentCost{Incident: incident}
+		from := incident.Started.Truncate(24 * time.Hour)
+		seen := make(map[string]bool)
+		candidates := append([]Issue{}, linked[incident.Key]...)
+		for _, issue := range labeled {
+			if issue.HasLabel(incident.label(prefix)) {
+				candidates = append(candidates, issue)
+			}
+		}
+		for _, issue := range candidates {
+			if seen[issue.Key] || issue.Created.Before(from) {
+				continue
+			}
+			if window > 0 && !issue.Created.Before(from.AddDate(0, 0, window+1)) {
+				continue
+			}
+			seen[issue.Key] = true
+			cost.Tickets = append(cost.Tickets, issue)
+			cost.Mana += issue.Mana
+			if issue.Resolved.IsZero() {
+				cost.Open++
+			}
+			if !counted[issue.Key] {
+				counted[issue.Key] = true
+				report.Tickets++
+				report.Mana += issue.Mana
+				if issue.Resolved.IsZero() {
+					report.Open++
+				}
+			}
+		}
+		sort.SliceStable(cost.Tickets, func(i, j int) bool {
+			return cost.Tickets[i].Created.Before(cost.Tickets[j].Created)
+		})
+		report.Incidents = append(report.Incidents, cost)
+	}
+	sort.SliceStable(report.Incidents, func(i, j int) bool {
+		a, b := report.Incidents[i], report.Incidents[j]
+		if a.Mana != b.Mana {
+			return a.Mana > b.Mana
+		}
+		return a.Started.Before(b.Started)
+	})
+	return report
+}
+
+// incidentLabelJQL is the query of the tickets labeled for the incidents
+func incidentLabelJQL(projectKey string, labels []string) string {
+	quoted := make([]string, len(labels))
+	for i, l := range labels {
+		quoted[i] = fmt.Sprintf("%q", l)
+	}
+	return fmt.Sprintf(`project = "%s" AND
+		labels in (%s)
+		ORDER BY created ASC`, projectKey, strings.Join(quoted, ", "))
+}
+
+// fetchIncidentFollowUps fetches the tickets labeled for the incidents, in
+// batches of labels, and the tickets linked to the incidents' Jira issues,
+// by incident issue key. Tickets are read with the fields of their project.
+func fetchIncidentFollowUps(client *jira.Client, config *Config, projectKey string, incidents []Incident, prefix string) ([]Issue, map[string][]Issue, error) {
+	var labels []string
+	for _, incident := range incidents {
+		labels = append(labels, incident.label(prefix))
+	}
+	var labeled []Issue
+	for len(labels) > 0 {
+		n := incidentLabelBatch
+		if n > len(labels) {
+			n = len(labels)
+		}
+		found, err := config.fieldsOf(projectKey).fetchIssues(client, incidentLabelJQL(projectKey, labels[:n]), incidentFields)
+		if err != nil {
+			return nil, nil, err
+		}
+		labeled = append(labeled, found...)
+		labels = labels[n:]
+	}
+
+	var keys []string
+	for _, incident := range incidents {
+		if incident.Key != "" {
+			keys = append(keys, incident.Key)
+		}
+	}
+	linked := make(map[string][]Issue)
+	if len(keys) == 0 {
+		return labeled, linked, nil
+	}
+	found, err := searchByKeys(client, keys, []string{"issuelinks"}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	linksOf := make(map[string][]string)
+	var linkedKeys []string
+	queued := make(map[string]bool)
+	for _, issue := range issuesFromJira(found) {
+		for _, link := range issue.Links {
+			linksOf[issue.Key] = append(linksOf[issue.Key], link.Key)
+			if !queued[link.Key] {
+				queued[link.Key] = true
+				linkedKeys = append(linkedKeys, link.Key)
+			}
+		}
+	}
+	if len(linkedKeys) == 0 {
+		return labeled, linked, nil
+	}
+	// Linked tickets may be of any project
+	followUps, err := searchByKeys(client, linkedKeys, config.requestAll(incidentFields), "")
+	if err != nil {
+		return nil, nil, err
+	}
+	config.normalizeByProject(followUps)
+	byKey := make(map[string]Issue)
+	for _, issue := range issuesFromJira(followUps) {
+		byKey[issue.Key] = issue
+	}
+	for key, links := range linksOf {
+		for _, l := range links {
+			if issue, ok := byKey[l]; ok {
+				linked[key] = append(linked[key], issue)
+			}
+		}
+	}
+	return labeled, linked, nil
+}
+
+// writeIncidentReport writes the follow-up cost of every incident and the
+// follow-up tickets in the layout
+func writeIncidentReport(w io.Writer, report *IncidentReport, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "Incident Follow-up Cost: %s\n", report.Project)
+	fmt.Fprintf(w, "Incidents: %d from %s\n", len(report.Incidents), report.Source)
+	window := "any time after it started"
+	if report.Window > 0 {
+		window = fmt.Sprintf("up to %d days after it started", report.Window)
+	}
+	fmt.Fprintf(w, "Follow-ups: tickets labeled %s<id> or linked to the incident's Jira issue, created %s\n", report.LabelPrefix, window)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	incidentTable := newTextTable(
+		tableColumn{Header: "Incident"},
+		tableColumn{Header: "Started"},
+		tableColumn{Header: "Severity"},
+		tableColumn{Header: "Tickets", Right: true},
+		tableColumn{Header: "Open", Right: true},
+		tableColumn{Header: "Mana", Right: true},
+		tableColumn{Header: "Title", MaxWidth: 50},
+	)
+	for _, c := range report.Incidents {
+		incidentTable.addRow(c.ID,
+			c.Started.Format("2006-01-02"),
+			c.Severity,
+			layout.Numbers.count(len(c.Tickets)),
+			layout.Numbers.count(c.Open),
+			layout.Numbers.mana(c.Mana),
+			removeEmojis(c.Title))
+	}
+	incidentTable.addFooter("TOTAL", "", "",
+		layout.Numbers.count(report.Tickets),
+		layout.Numbers.count(report.Open),
+		layout.Numbers.mana(report.Mana), "")
+	fmt.Fprintf(w, "\nFollow-up Cost by Incident:\n")
+	incidentTable.write(w, layout.Style)
+	if n := report.withoutFollowUps(); n > 0 {
+		fmt.Fprintf(w, "  Incidents without follow-up tickets: %d\n", n)
+	}
+
+	if report.Tickets == 0 {
+		return
+	}
+	ticketTable := newTextTable(
+		tableColumn{Header: "Incident"},
+		tableColumn{Header: "Key"},
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Issue Type"},
+		tableColumn{Header: "Status"},
+		tableColumn{Header: "Mana", Right: true},
+		tableColumn{Header: "Link"},
+		tableColumn{Header: "Summary", MaxWidth: 60},
+	)
+	for _, c := range report.Incidents {
+		for _, issue := range c.Tickets {
+			ticketTable.addRow(c.ID,
+				issue.Key,
+				teamDimension.Group(issue),
+				issue.Type,
+				issue.Status,
+				layout.Numbers.mana(issue.Mana),
+				fmt.Sprintf("%s/browse/%s", jiraURL, issue.Key),
+				removeEmojis(issue.Summary))
+		}
+	}
+	fmt.Fprintf(w, "\nFollow-up Tickets:\n")
+	ticketTable.write(w, layout.Style)
+}
+
+func runIncidentsCommand() {
+	// Command line flags
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	incidentsFile := flag.String("incidents", "", "CSV incident list, such as a PagerDuty or Statuspage export")
+	labelPrefix := flag.String("label-prefix", defaultIncidentLabelPrefix, "Prefix of the labels of follow-up tickets, followed by the incident ID")
+	window := flag.Int("window", 90, "Days after an incident started that follow-up tickets count (0 for no limit)")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	configPath := flag.String("config", "", configUsage)
+	tables := defineTableFlags()
+	flag.Parse()
+
+	// Validate flags
+	if *projectKey == "" || *incidentsFile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *window < 0 {
+		log.Fatal("-window must not be negative")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := os.ReadFile(*incidentsFile)
+	if err != nil {
+		log.Fatalf("Error reading incidents: %v", err)
+	}
+	incidents, err := parseIncidentsCSV(b)
+	if err != nil {
+		log.Fatalf("%s: %v", *incidentsFile, err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate the first query before fetching anything
+	var firstLabels []string
+	for _, incident := range incidents {
+		if len(firstLabels) < incidentLabelBatch {
+			firstLabels = append(firstLabels, incident.label(*labelPrefix))
+		}
+	}
+	jql := incidentLabelJQL(*projectKey, firstLabels)
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	labeled, linked, err := fetchIncidentFollowUps(client, config, *projectKey, incidents, *labelPrefix)
+	if err != nil {
+		log.Fatalf("Error fetching follow-up tickets: %v", err)
+	}
+
+	report := correlateIncidents(incidents, labeled, linked, *labelPrefix, *window)
+	report.Project = *projectKey
+	report.Source = *incidentsFile
+	report.JQL = jql
+
+	title := fmt.Sprintf("%s Incident Follow-up Cost", report.Project)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeIncidentReport(w, report, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
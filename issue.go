@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// Custom field IDs of the fields theia reads. customfield_10014 is
+// typically the Epic Link field.
+const (
+	manaFieldID     = "customfield_11267"
+	teamFieldID     = "customfield_10800"
+	epicLinkFieldID = "customfield_10014"
+)
+
+// Issue is the data source independent view of an issue used by
+// classification and aggregation
+type Issue struct {
+	Key      string
+	Type     string
+	Summary  string
+	Status   string
+	Priority string
+	Labels   []string
+	Links    []IssueLink
+	Team     string // Empty if the issue has no team
+	TeamID   string // ID of the team, which JQL matches the Team field on
+	Parent   string // Key of the epic (Epic Link) or parent issue, empty if none
+	Assignee string // Account ID (user name on Server), empty if unassigned
+	Owner    string // Display name of the assignee
+	Reporter string // Account ID (user name on Server), empty if Jira returned none
+	Mana     float64
+	ManaSet  bool // Set if Mana Spent has a value, Mana is 0 otherwise
+	Created  time.Time
+	Resolved time.Time
+	Due      time.Time // Zero if the issue has no due date
+	// StatusCategory is the category of Status: To Do, In Progress or Done,
+	// empty if Jira returned none
+	StatusCategory string
+	// ReporterName is the display name of the reporter
+	ReporterName string
+}
+
+// IssueLink is a link from an issue to another issue
+type IssueLink struct {
+	Type      string // Link type name, e.g. "Blocks"
+	Outward   bool   // Set if the issue is the source of the link
+	Key       string // Key of the linked issue
+	IssueType string // Issue type of the linked issue
+}
+
+// HasLabel reports whether the issue has the label
+func (i Issue) HasLabel(label string) bool {
+	for _, l := range i.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// issueFromJira converts a go-jira issue into an Issue
+func issueFromJira(ji jira.Issue) Issue {
+	issue := Issue{Key: ji.Key}
+	if ji.Fields == nil {
+		return issue
+	}
+	f := ji.Fields
+
+	issue.Type = jiraLabels.issueType(f.Type.Name)
+	issue.Summary = f.Summary
+	if f.Status != nil {
+		issue.Status = f.Status.Name
+		issue.StatusCategory = f.Status.StatusCategory.Name
+	}
+	if f.Priority != nil {
+		issue.Priority = f.Priority.Name
+	}
+	issue.Labels = f.Labels
+	if f.Assignee != nil {
+		issue.Assignee = f.Assignee.AccountID
+		issue.Owner = f.Assignee.DisplayName
+		if issue.Assignee == "" {
+			issue.Assignee = f.Assignee.Name
+		}
+	}
+	if f.Reporter != nil {
+		issue.Reporter = f.Reporter.AccountID
+		issue.ReporterName = f.Reporter.DisplayName
+		if issue.Reporter == "" {
+			issue.Reporter = f.Reporter.Name
+		}
+	}
+	issue.Created = time.Time(f.Created)
+	issue.Resolved = time.Time(f.Resolutiondate)
+	issue.Due = time.Time(f.Duedate)
+	issue.Mana = getManaPoints(f.Unknowns[manaFieldID])
+	issue.ManaSet = f.Unknowns[manaFieldID] != nil
+	issue.Team = jiraTeamName(f.Unknowns[teamFieldID])
+	issue.TeamID = jiraTeamID(f.Unknowns[teamFieldID])
+	if epic, ok := f.Unknowns[epicLinkFieldID].(string); ok {
+		issue.Parent = epic
+	}
+	if issue.Parent == "" && f.Parent != nil {
+		issue.Parent = f.Parent.Key
+	}
+
+	for _, link := range f.IssueLinks {
+		if link == nil {
+			continue
+		}
+		if link.OutwardIssue != nil {
+			issue.Links = append(issue.Links, issueLinkFromJira(link.Type.Name, true, link.OutwardIssue))
+		}
+		if link.InwardIssue != nil {
+			issue.Links = append(issue.Links, issueLinkFromJira(link.Type.Name, false, link.InwardIssue))
+		}
+	}
+	return issue
+}
+
+// issueLinkFromJira converts one side of a go-jira issue link
+func issueLinkFromJira(linkType string, outward bool, linked *jira.Issue) IssueLink {
+	l := IssueLink{
+		Type:    linkType,
+		Outward: outward,
+		Key:     linked.Key,
+	}
+	if linked.Fields != nil {
+		l.IssueType = jiraLabels.issueType(linked.Fields.Type.Name)
+	}
+	return l
+}
+
+// issuesFromJira converts a slice of go-jira issues
+func issuesFromJira(jiraIssues []jira.Issue) []Issue {
+	issues := make([]Issue, 0, len(jiraIssues))
+	for _, ji := range jiraIssues {
+		issues = append(issues, issueFromJira(ji))
+	}
+	return issues
+}
+
+// jiraTeamName returns the name of the Team field value, or "" if unset
+func jiraTeamName(teamField interface{}) string {
+	if teamObj, ok := teamField.(map[string]interface{}); ok {
+		if teamName, ok := teamObj["name"].(string); ok {
+			return teamName
+		}
+	}
+	return ""
+}
+
+// jiraTeamID extracts the team ID from the Jira team field, a string for
+// Atlassian teams and a number for Advanced Roadmaps teams
+func jiraTeamID(teamField interface{}) string {
+	if teamObj, ok := teamField.(map[string]interface{}); ok {
+		switch id := teamObj["id"].(type) {
+		case string:
+			return id
+		case float64:
+			return strconv.FormatFloat(id, 'f', -1, 64)
+		}
+	}
+	return ""
+}
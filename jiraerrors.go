@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// jiraErrorKind classifies a failed Jira API call
+type jiraErrorKind int
+
+const (
+	jiraErrorUnknown jiraErrorKind = iota
+	jiraErrorAuth
+	jiraErrorPermissionDenied
+	jiraErrorProjectNotFound
+	jiraErrorUnknownField
+	jiraErrorInvalidJQL
+	jiraErrorRateLimited
+	jiraErrorServer
+)
+
+var (
+	unknownFieldRegex    = regexp.MustCompile(`(?i)field '([^']+)' does not exist`)
+	projectNotFoundRegex = regexp.MustCompile(`(?i)value '([^']+)' does not exist for the field 'project'`)
+)
+
+// jiraErrorMessages extracts the individual error messages returned by Jira
+func jiraErrorMessages(err error) []string {
+	var jerr *jira.Error
+	if !errors.As(err, &jerr) {
+		return []string{err.Error()}
+	}
+
+	messages := append([]string{}, jerr.ErrorMessages...)
+	for key, value := range jerr.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", key, value))
+	}
+	if len(messages) == 0 && jerr.HTTPError != nil {
+		messages = append(messages, jerr.HTTPError.Error())
+	}
+	return messages
+}
+
+// classifyJiraError determines the kind of a Jira API failure from the
+// response status and the error messages
+func classifyJiraError(resp *jira.Response, messages []string) jiraErrorKind {
+	for _, msg := range messages {
+		switch {
+		case projectNotFoundRegex.MatchString(msg):
+			return jiraErrorProjectNotFound
+		case unknownFieldRegex.MatchString(msg):
+			return jiraErrorUnknownField
+		}
+	}
+
+	if resp == nil {
+		return jiraErrorUnknown
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return jiraErrorAuth
+	case resp.StatusCode == http.StatusForbidden:
+		return jiraErrorPermissionDenied
+	case resp.StatusCode == http.StatusNotFound:
+		return jiraErrorProjectNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return jiraErrorRateLimited
+	case resp.StatusCode == http.StatusBadRequest:
+		return jiraErrorInvalidJQL
+	case resp.StatusCode >= 500:
+		return jiraErrorServer
+	}
+	return jiraErrorUnknown
+}
+
+// jiraErrorHint returns a remediation hint for the given kind of failure
+func jiraErrorHint(kind jiraErrorKind, messages []string) string {
+	switch kind {
+	case jiraErrorAuth:
+		return "Authentication failed. Check that JIRA_USERNAME is the account email and JIRA_TOKEN is a valid API token."
+	case jiraErrorPermissionDenied:
+		return "The JIRA_USERNAME account does not have permission for this request. Ask a Jira admin for Browse Projects permission on the project."
+	case jiraErrorProjectNotFound:
+		for _, msg := range messages {
+			if m := projectNotFoundRegex.FindStringSubmatch(msg); m != nil {
+				return fmt.Sprintf("Project '%s' was not found. Check the -project key, and that JIRA_URL points at the right instance.", m[1])
+			}
+		}
+		return "The requested resource was not found. Check the -project key, and that JIRA_URL points at the right instance."
+	case jiraErrorUnknownField:
+		for _, msg := range messages {
+			if m := unknownFieldRegex.FindStringSubmatch(msg); m != nil {
+				return fmt.Sprintf("Field '%s' not found. Run theia init to list the fields theia reads and the IDs this Jira instance uses for them, and check the field is visible to the JIRA_USERNAME account.", m[1])
+			}
+		}
+		return "A field used in the query was not found. Run theia init to list the fields theia reads and the IDs this Jira instance uses for them, and check the field is visible to the JIRA_USERNAME account."
+	case jiraErrorInvalidJQL:
+		return "Jira rejected the JQL query. Check the query printed above for invalid values."
+	case jiraErrorRateLimited:
+		return "Jira is rate limiting requests. Wait a few minutes and try again."
+	case jiraErrorServer:
+		return "Jira returned a server error. Try again later."
+	}
+	return ""
+}
+
+// describeJiraError turns a failed Jira API call into a readable message
+// with a remediation hint, instead of the raw go-jira error
+func describeJiraError(resp *jira.Response, err error) string {
+	messages := jiraErrorMessages(err)
+	msg := strings.Join(messages, "; ")
+	if hint := jiraErrorHint(classifyJiraError(resp, messages), messages); hint != "" {
+		msg = fmt.Sprintf("%s\n%s", msg, hint)
+	}
+	return msg
+}
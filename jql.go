@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// ticketJQLFilter returns the filter selecting the resolved issues analyzed by
+// the ticket command, with the given clause bounding the resolution date
+func ticketJQLFilter(projectKey, resolutionClause string) string {
+	return projectTicketJQLFilter(projectKey, resolutionClause, standardProjectFields)
+}
+
+// projectTicketJQLFilter is ticketJQLFilter for a project with its own fields
+func projectTicketJQLFilter(projectKey, resolutionClause string, fields projectFields) string {
+	return fmt.Sprintf(`project = "%s" AND
+		status in (Resolved, Closed) AND
+		resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined") AND
+		%s AND
+		%s is not EMPTY AND
+		issuetype not in (Epic, Initiative)`,
+		projectKey,
+		resolutionClause,
+		fields.manaJQL())
+}
+
+// jqlPositionRegex matches the position Jira reports for JQL syntax errors
+var jqlPositionRegex = regexp.MustCompile(`line (\d+), character (\d+)`)
+
+// jqlQuotedRegex matches quoted names Jira reports for unknown fields and values
+var jqlQuotedRegex = regexp.MustCompile(`'([^']+)'`)
+
+type jqlParseRequest struct {
+	Queries []string `json:"queries"`
+}
+
+type jqlParseResponse struct {
+	Queries []struct {
+		Query  string   `json:"query"`
+		Errors []string `json:"errors"`
+	} `json:"queries"`
+}
+
+// JQLValidationError holds the errors Jira reported for a query
+type JQLValidationError struct {
+	JQL    string
+	Errors []string
+}
+
+func (e *JQLValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid JQL query:")
+	for _, msg := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(msg)
+		if highlight := highlightJQLError(e.JQL, msg); highlight != "" {
+			b.WriteString("\n")
+			b.WriteString(highlight)
+		}
+	}
+	return b.String()
+}
+
+// validateJQL checks the query with Jira's JQL parse endpoint before running
+// the full search. It returns a *JQLValidationError if Jira rejects the query.
+// Instances without the parse endpoint are skipped with a warning.
+func validateJQL(client *jira.Client, jql string) error {
+	return validateJQLQueries(client, []string{jql})
+}
+
+// jqlParseBatch is the number of queries checked with one parse request
+const jqlParseBatch = 50
+
+// validateJQLQueries is validateJQL for several queries, checked a batch at a
+// time. It returns the *JQLValidationError of the first query Jira rejects.
+func validateJQLQueries(client *jira.Client, queries []string) error {
+	for len(queries) > 0 {
+		batch := queries
+		if len(batch) > jqlParseBatch {
+			batch = batch[:jqlParseBatch]
+		}
+		queries = queries[len(batch):]
+
+		req, err := client.NewRequest("POST", "rest/api/3/jql/parse?validation=strict", &jqlParseRequest{
+			Queries: batch,
+		})
+		if err != nil {
+			return err
+		}
+
+		result := new(jqlParseResponse)
+		resp, err := client.Do(req, result)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				fmt.Println("Warning: JQL parse endpoint not available on this Jira instance, skipping validation")
+				return nil
+			}
+			return fmt.Errorf("validating JQL: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+		}
+
+		for i, q := range result.Queries {
+			if len(q.Errors) > 0 && i < len(batch) {
+				return &JQLValidationError{JQL: batch[i], Errors: q.Errors}
+			}
+		}
+	}
+	return nil
+}
+
+// jqlString quotes a JQL string value, escaping quotes and backslashes as
+// JQL does, e.g. "Team \"A\""
+func jqlString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// highlightJQLError returns the offending line of the query with a caret under
+// the reported position, or under the first mention of a quoted name in the
+// error message. It returns an empty string if nothing can be located.
+func highlightJQLError(jql, msg string) string {
+	lines := strings.Split(jql, "\n")
+
+	if m := jqlPositionRegex.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		char, _ := strconv.Atoi(m[2])
+		if line >= 1 && line <= len(lines) {
+			return caretLine(lines[line-1], char-1)
+		}
+	}
+
+	for _, m := range jqlQuotedRegex.FindAllStringSubmatch(msg, -1) {
+		for _, l := range lines {
+			if i := strings.Index(l, m[1]); i >= 0 {
+				return caretLine(l, i)
+			}
+		}
+	}
+	return ""
+}
+
+// caretLine renders a query line with a caret under the given character offset
+func caretLine(line string, char int) string {
+	line = strings.ReplaceAll(line, "\t", " ")
+	if char < 0 {
+		char = 0
+	}
+	if char > len(line) {
+		char = len(line)
+	}
+	return fmt.Sprintf("    %s\n    %s^", line, strings.Repeat(" ", char))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// issueKeyRegex matches a Jira issue key such as PROJ-123
+var issueKeyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[1-9][0-9]*$`)
+
+// parseIssueKeys parses the -keys value: issue keys separated by commas or
+// whitespace, or "-" to read them from stdin. Keys are upper-cased and
+// duplicates dropped, keeping the order of first appearance.
+func parseIssueKeys(value string, stdin io.Reader) ([]string, error) {
+	if value == "-" {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading issue keys from stdin: %w", err)
+		}
+		value = string(b)
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, field := range fields {
+		key := strings.ToUpper(field)
+		if !issueKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid issue key %q", field)
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no issue keys given")
+	}
+	return keys, nil
+}
+
+// keyProjects returns the projects of the issue keys, comma-separated
+func keyProjects(keys []string) string {
+	seen := make(map[string]bool)
+	var projects []string
+	for _, key := range keys {
+		project := key[:strings.LastIndex(key, "-")]
+		if !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return strings.Join(projects, ",")
+}
+
+// keysJQL is the query shown for a report over explicit issue keys
+func keysJQL(keys []string) string {
+	return fmt.Sprintf("key in (%s)", strings.Join(keys, ", "))
+}
+
+// keyedIssues are the issues of a report over explicit issue keys
+type keyedIssues struct {
+	Issues    []Issue
+	Selection KeySelection
+	First     time.Time
+	Last      time.Time // Resolution times of the issues, zero if none is resolved
+}
+
+// fetchKeyedIssues fetches the issues with the keys. Keys of projects with
+// their own fields in the config are fetched with those, read as the
+// standard ones.
+func fetchKeyedIssues(client *jira.Client, keys []string, config *Config) (*keyedIssues, error) {
+	var order []projectFields
+	byFields := make(map[projectFields][]string)
+	for _, key := range keys {
+		own := config.fieldsOf(key[:strings.LastIndex(key, "-")])
+		if byFields[own] == nil {
+			order = append(order, own)
+		}
+		byFields[own] = append(byFields[own], key)
+	}
+	var found []jira.Issue
+	for _, own := range order {
+		issues, err := own.searchByKeys(client, byFields[own], ticketFields, "")
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, issues...)
+	}
+	return selectKeyedIssues(keys, found), nil
+}
+
+// selectKeyedIssues selects the issues to analyze from the issues Jira
+// returned for the keys. Like the date range query, it leaves out epics,
+// initiatives and issues without Mana Spent; unlike it, unresolved issues
+// are analyzed too.
+func selectKeyedIssues(keys []string, found []jira.Issue) *keyedIssues {
+	result := &keyedIssues{Selection: KeySelection{Keys: keys}}
+	returned := make(map[string]bool, len(found))
+	for _, ji := range found {
+		returned[ji.Key] = true
+		issue := issueFromJira(ji)
+		switch {
+		case issue.Type == "Epic" || issue.Type == "Initiative":
+			result.Selection.Epics = append(result.Selection.Epics, issue.Key)
+			continue
+		case !issue.ManaSet:
+			result.Selection.NoMana = append(result.Selection.NoMana, issue.Key)
+			continue
+		}
+		result.Issues = append(result.Issues, issue)
+		if issue.Resolved.IsZero() {
+			continue
+		}
+		if result.First.IsZero() || issue.Resolved.Before(result.First) {
+			result.First = issue.Resolved
+		}
+		if issue.Resolved.After(result.Last) {
+			result.Last = issue.Resolved
+		}
+	}
+	for _, key := range keys {
+		if !returned[key] {
+			result.Selection.Missing = append(result.Selection.Missing, key)
+		}
+	}
+	return result
+}
+
+// fetchKeyedEpics fetches the epics with the keys, in the order listed. They
+// are analyzed whatever their status and resolution; issues of other types
+// are left out. Each is read with the fields of its project.
+func fetchKeyedEpics(client *jira.Client, config *Config, keys []string) ([]Issue, *KeySelection, error) {
+	found, err := searchByKeys(client, keys, config.requestAll(epicFields), "")
+	if err != nil {
+		return nil, nil, err
+	}
+	config.normalizeByProject(found)
+	epics, selection := selectKeyedEpics(keys, issuesFromJira(found))
+	return epics, selection, nil
+}
+
+// selectKeyedEpics selects the epics to analyze from the issues Jira
+// returned for the keys
+func selectKeyedEpics(keys []string, found []Issue) ([]Issue, *KeySelection) {
+	selection := &KeySelection{Keys: keys}
+	byKey := make(map[string]Issue, len(found))
+	for _, issue := range found {
+		byKey[issue.Key] = issue
+	}
+	var epics []Issue
+	for _, key := range keys {
+		issue, ok := byKey[key]
+		switch {
+		case !ok:
+			selection.Missing = append(selection.Missing, key)
+		case issue.Type != "Epic":
+			selection.NotEpics = append(selection.NotEpics, key)
+		default:
+			epics = append(epics, issue)
+		}
+	}
+	return epics, selection
+}
+
+// keyedChildScope returns the projects the children of listed epics are
+// searched in: the comma-separated projects of the epics and -child-projects
+func keyedChildScope(projects, childProjects string) (childScope, error) {
+	keys := strings.Split(projects, ",")
+	if strings.EqualFold(strings.TrimSpace(childProjects), "all") {
+		return parseChildProjects(keys[0], childProjects)
+	}
+	return parseChildProjects(keys[0], strings.Join(append(keys[1:], childProjects), ","))
+}
+
+// firstChildResolution returns the day the first child of the epics was
+// resolved, or fallback if none is
+func firstChildResolution(children map[string][]Issue, fallback time.Time) time.Time {
+	first := fallback
+	for _, issues := range children {
+		for _, child := range issues {
+			if !child.Resolved.IsZero() && dayOf(child.Resolved).Before(first) {
+				first = dayOf(child.Resolved)
+			}
+		}
+	}
+	return first
+}
+
+// writeKeySelection writes the number of listed keys and which of them were
+// left out
+func writeKeySelection(w io.Writer, k *KeySelection) {
+	fmt.Fprintf(w, "Issue Keys: %d listed\n", len(k.Keys))
+	notes := []struct {
+		label string
+		keys  []string
+	}{
+		{"Not found (or moved to another key)", k.Missing},
+		{"Left out, no Mana Spent", k.NoMana},
+		{"Left out, epics and initiatives", k.Epics},
+		{"Left out, not epics", k.NotEpics},
+	}
+	for _, note := range notes {
+		if len(note.keys) > 0 {
+			fmt.Fprintf(w, "%s: %d (%s)\n", note.label, len(note.keys), strings.Join(note.keys, ", "))
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// noLabel is the row of the tickets without labels
+const noLabel = "No Label"
+
+// defaultLabelMatrixSize is the number of labels in the co-occurrence matrix
+const defaultLabelMatrixSize = 8
+
+// labelFields are the fields the label analysis reads
+var labelFields = []string{"issuetype", manaFieldID, "resolutiondate", "labels", "reporter"}
+
+// labelGroup collects the labels matching a regular expression under one
+// name, the expression as given
+type labelGroup struct {
+	Name    string
+	pattern *regexp.Regexp
+}
+
+// parseLabelGroups parses comma-separated regular expressions. Each must
+// match a whole label.
+func parseLabelGroups(list string) ([]labelGroup, error) {
+	var groups []labelGroup
+	for _, expr := range strings.Split(list, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid label group %q: %w", expr, err)
+		}
+		pattern := regexp.MustCompile("^(?:" + expr + ")$")
+		groups = append(groups, labelGroup{Name: expr, pattern: pattern})
+	}
+	return groups, nil
+}
+
+// labelKey returns the row a label is counted under: its first matching
+// group, or the label itself
+func labelKey(label string, groups []labelGroup) string {
+	for _, g := range groups {
+		if g.pattern.MatchString(label) {
+			return g.Name
+		}
+	}
+	return label
+}
+
+// LabelUsage is the mana spent on the tickets with one label or label group
+type LabelUsage struct {
+	Label   string
+	Grouped bool // Set for a label group
+	Labels  int  // Distinct labels of a group
+	Tickets int
+	Mana    float64
+}
+
+// LabelReport is the data model of the labels command
+type LabelReport struct {
+	Project      string
+	Start        string
+	End          string
+	EndInclusive bool // Set if tickets resolved at any time on the end date count
+	JQL          string
+	Tickets      int
+	Mana         float64
+	Labels       []LabelUsage // Most mana first, No Label last
+	Matrix       []string     // Labels of the co-occurrence matrix
+	CoOccurrence [][]int      // Tickets with both labels of Matrix, by index
+}
+
+// analyzeLabels sums up the mana of the tickets per label, with the labels
+// matching a group counted together, and counts how often the top labels
+// by mana appear on the same ticket. A ticket counts once under every label
+// it carries, so label totals add up to more than the total.
+func analyzeLabels(issues []Issue, groups []labelGroup, matrixSize int) *LabelReport {
+	report := &LabelReport{}
+	usage := make(map[string]*LabelUsage)
+	members := make(map[string]map[string]bool)
+	ticketKeys := make([][]string, 0, len(issues))
+	isGroup := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		isGroup[g.Name] = true
+	}
+
+	for _, issue := range issues {
+		report.Tickets++
+		report.Mana += issue.Mana
+
+		var keys []string
+		seen := make(map[string]bool)
+		for _, label := range issue.Labels {
+			key := labelKey(label, groups)
+			if members[key] == nil {
+				members[key] = make(map[string]bool)
+			}
+			members[key][label] = true
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			keys = []string{noLabel}
+		}
+		for _, key := range keys {
+			u := usage[key]
+			if u == nil {
+				u = &LabelUsage{Label: key, Grouped: isGroup[key]}
+				usage[key] = u
+			}
+			u.Tickets++
+			u.Mana += issue.Mana
+		}
+		ticketKeys = append(ticketKeys, keys)
+	}
+
+	for key, u := range usage {
+		u.Labels = len(members[key])
+		report.Labels = append(report.Labels, *u)
+	}
+	sort.Slice(report.Labels, func(i, j int) bool {
+		a, b := report.Labels[i], report.Labels[j]
+		if (a.Label == noLabel) != (b.Label == noLabel) {
+			return b.Label == noLabel
+		}
+		if a.Mana != b.Mana {
+			return a.Mana > b.Mana
+		}
+		return a.Label < b.Label
+	})
+
+	// Co-occurrence of the top labels, the diagonal being each label's tickets
+	index := make(map[string]int)
+	for _, u := range report.Labels {
+		if len(report.Matrix) == matrixSize {
+			break
+		}
+		if u.Label == noLabel {
+			continue
+		}
+		index[u.Label] = len(report.Matrix)
+		report.Matrix = append(report.Matrix, u.Label)
+	}
+	report.CoOccurrence = make([][]int, len(report.Matrix))
+	for i := range report.CoOccurrence {
+		report.CoOccurrence[i] = make([]int, len(report.Matrix))
+	}
+	for _, keys := range ticketKeys {
+		for _, a := range keys {
+			i, ok := index[a]
+			if !ok {
+				continue
+			}
+			for _, b := range keys {
+				if j, ok := index[b]; ok {
+					report.CoOccurrence[i][j]++
+				}
+			}
+		}
+	}
+	return report
+}
+
+// writeLabelReport writes the mana per label and the co-occurrence matrix
+func writeLabelReport(w io.Writer, report *LabelReport, layout tableOptions) {
+	fmt.Fprintf(w, "\nLabel Analysis Period: %s to %s\n", report.Start, report.End)
+	fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	table := newTextTable(
+		tableColumn{Header: "Label", MaxWidth: 40},
+		tableColumn{Header: "Labels", Right: true},
+		tableColumn{Header: "Tickets", Right: true},
+		tableColumn{Header: "Total Mana", Right: true},
+		tableColumn{Header: "% of All Mana", Right: true},
+		tableColumn{Header: "Avg Mana", Right: true},
+	)
+	for _, u := range report.Labels {
+		labels := "-"
+		if u.Grouped {
+			labels = layout.Numbers.count(u.Labels)
+		}
+		share, avg := 0.0, 0.0
+		if report.Mana > 0 {
+			share = u.Mana / report.Mana * 100
+		}
+		if u.Tickets > 0 {
+			avg = u.Mana / float64(u.Tickets)
+		}
+		table.addRow(u.Label, labels,
+			layout.Numbers.count(u.Tickets),
+			layout.Numbers.mana(u.Mana),
+			fmt.Sprintf("%.1f%%", share),
+			layout.Numbers.mana(avg))
+	}
+	table.addFooter("ALL TICKETS", "",
+		layout.Numbers.count(report.Tickets),
+		layout.Numbers.mana(report.Mana),
+		"100.0%",
+		layout.Numbers.mana(safeAverage(report.Mana, report.Tickets)))
+	fmt.Fprintf(w, "\nMana by Label:\n")
+	table.write(w, layout.Style)
+	fmt.Fprintln(w, "Tickets with several labels count under each of them. Labels is the number of distinct labels of a group.")
+
+	if len(report.Matrix) < 2 {
+		return
+	}
+	// Columns are numbered after the rows, as labels are too long for headers
+	columns := []tableColumn{{Header: "Label", MaxWidth: 40}}
+	for i := range report.Matrix {
+		columns = append(columns, tableColumn{Header: strconv.Itoa(i + 1), Right: true})
+	}
+	matrix := newTextTable(columns...)
+	for i, label := range report.Matrix {
+		row := []string{fmt.Sprintf("%d. %s", i+1, label)}
+		for _, n := range report.CoOccurrence[i] {
+			row = append(row, layout.Numbers.count(n))
+		}
+		matrix.addRow(row...)
+	}
+	fmt.Fprintf(w, "\nLabel Co-occurrence (tickets with both labels, top %d by mana):\n", len(report.Matrix))
+	matrix.write(w, layout.Style)
+}
+
+// safeAverage returns total divided by n, or 0 if n is 0
+func safeAverage(total float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+func runLabelsCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	groupList := flag.String("group", "", "Comma-separated regular expressions of labels counted together, e.g. \"area-.*,team-.*\"")
+	matrixSize := flag.Int("top", defaultLabelMatrixSize, "Number of labels, by mana, in the co-occurrence matrix")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	groups, err := parseLabelGroups(*groupList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *matrixSize < 0 {
+		log.Fatal("-top must not be negative")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, _, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The tickets of the ticket report
+	own := config.fieldsOf(*projectKey)
+	jql := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, *endInclusive), own) + `
+		ORDER BY created DESC`
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := own.fetchIssues(client, jql, labelFields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	issues = dropAutomationIssues(os.Stdout, issues, config)
+
+	report := analyzeLabels(issues, groups, *matrixSize)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+	report.JQL = jql
+
+	title := fmt.Sprintf("%s Label Analysis %s to %s", report.Project, report.Start, report.End)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeLabelReport(w, report, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
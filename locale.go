@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// jiraLabels translates the labels of a localized Jira instance, as read
+// from the -locale-file global flag. Without a file labels are kept.
+var jiraLabels localeLabels
+
+// LocaleFile lists the localized labels of a Jira instance that is not in
+// English, by the English label theia knows, e.g. "Bug": ["Fehler", "バグ"]
+type LocaleFile struct {
+	ManaOptions map[string][]string `json:"manaOptions"` // Options of the Mana Spent field
+	IssueTypes  map[string][]string `json:"issueTypes"`
+}
+
+// localeLabels maps localized labels to the English ones
+type localeLabels struct {
+	manaOptions map[string]string
+	issueTypes  map[string]string
+}
+
+// loadLocaleFile reads the localized labels of a locale file
+func loadLocaleFile(path string) (localeLabels, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return localeLabels{}, fmt.Errorf("error reading locale file: %w", err)
+	}
+	labels, err := parseLocaleFile(b)
+	if err != nil {
+		return localeLabels{}, fmt.Errorf("%s: invalid locale file: %w", path, err)
+	}
+	return labels, nil
+}
+
+// parseLocaleFile decodes and checks a locale file. Mana options must be the
+// English options theia maps to mana, and a localized label may only stand
+// for one English label.
+func parseLocaleFile(b []byte) (localeLabels, error) {
+	var file LocaleFile
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return localeLabels{}, err
+	}
+	for option := range file.ManaOptions {
+		if _, ok := manaOptionPoints[option]; !ok {
+			return localeLabels{}, fmt.Errorf("manaOptions: %q is not a Mana Spent option, expected one of %s", option, strings.Join(manaOptions(), ", "))
+		}
+	}
+
+	var labels localeLabels
+	var err error
+	if labels.manaOptions, err = invertLabels("manaOptions", file.ManaOptions); err != nil {
+		return localeLabels{}, err
+	}
+	if labels.issueTypes, err = invertLabels("issueTypes", file.IssueTypes); err != nil {
+		return localeLabels{}, err
+	}
+	return labels, nil
+}
+
+// invertLabels maps every localized label of a locale file section to its
+// English label
+func invertLabels(section string, localized map[string][]string) (map[string]string, error) {
+	english := make(map[string]string)
+	for label, translations := range localized {
+		if strings.TrimSpace(label) == "" {
+			return nil, fmt.Errorf("%s: labels must not be empty", section)
+		}
+		for _, t := range translations {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				return nil, fmt.Errorf("%s: %s has an empty translation", section, label)
+			}
+			if other, ok := english[t]; ok && other != label {
+				return nil, fmt.Errorf("%s: %q translates both %q and %q", section, t, other, label)
+			}
+			english[t] = label
+		}
+	}
+	return english, nil
+}
+
+// manaOption returns the English label of a Mana Spent option
+func (l localeLabels) manaOption(option string) string {
+	if english, ok := l.manaOptions[option]; ok {
+		return english
+	}
+	return option
+}
+
+// issueType returns the English name of an issue type
+func (l localeLabels) issueType(name string) string {
+	if english, ok := l.issueTypes[name]; ok {
+		return english
+	}
+	return name
+}
+
+// manaOptions returns the quoted English Mana Spent options, from least to
+// most mana
+func manaOptions() []string {
+	var options []string
+	for option := range manaOptionPoints {
+		options = append(options, option)
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return manaOptionPoints[options[i]] < manaOptionPoints[options[j]]
+	})
+	for i, option := range options {
+		options[i] = fmt.Sprintf("%q", option)
+	}
+	return options
+}
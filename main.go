@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"regexp"
@@ -17,21 +18,19 @@ type TicketAnalysis struct {
 	IssueType     string
 	Count         int
 	TotalMana     float64
-	AverageMana   float64
-	MedianMana    float64
-	ManaValues    []float64 // Store individual mana values for median calculation
+	ManaValues    []float64          // Store individual mana values for statistics
+	Stats         map[string]float64 // Computed statistics keyed by statistic key
 	ZeroManaCount int
 }
 
-type MonthlyAnalysis struct {
-	Month         time.Time
-	Analysis      map[string]*TicketAnalysis
-	ZeroManaCount int
-}
-
-type TeamAnalysis struct {
-	Team     string
-	Analysis map[string]*TicketAnalysis
+// manaOptionPoints are the story points of the Mana Spent select options
+var manaOptionPoints = map[string]float64{
+	"None (zero time spent)":  0,
+	"Small (2 hours or less)": 2,
+	"Medium (~half day)":      4,
+	"Large (~1 day)":          8,
+	"X-Large (~2-3 days)":     20,
+	"XX-Large (~1 week)":      40,
 }
 
 // getManaPoints converts the Mana Spent select value to story points
@@ -53,23 +52,9 @@ func getManaPoints(manaValue interface{}) float64 {
 		return 0
 	}
 
-	// Map the select values to story points
-	switch strings.TrimSpace(strValue) {
-	case "None (zero time spent)":
-		return 0
-	case "Small (2 hours or less)":
-		return 2
-	case "Medium (~half day)":
-		return 4
-	case "Large (~1 day)":
-		return 8
-	case "X-Large (~2-3 days)":
-		return 20
-	case "XX-Large (~1 week)":
-		return 40
-	default:
-		return 0
-	}
+	// Map the select values to story points, in English on localized
+	// instances. Unknown values count as no mana.
+	return manaOptionPoints[jiraLabels.manaOption(strings.TrimSpace(strValue))]
 }
 
 // normalizeIssueType converts Task and Sub-task types to Story
@@ -82,29 +67,41 @@ func normalizeIssueType(issueType string) string {
 	}
 }
 
-// calculateMedian returns the median value from a slice of float64
-func calculateMedian(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
+// analysisResults computes the statistics of every analysis and returns the
+// results sorted by total mana spent, then by name
+func analysisResults(analysis map[string]*TicketAnalysis, stats []Statistic) []TicketAnalysis {
+	var results []TicketAnalysis
+	for _, a := range analysis {
+		a.Stats = computeStatistics(a.ManaValues, stats)
+		results = append(results, *a)
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TotalMana != results[j].TotalMana {
+			return results[i].TotalMana > results[j].TotalMana
+		}
+		return results[i].IssueType < results[j].IssueType
+	})
+	return results
+}
 
-	// Create a copy to avoid modifying the original slice
-	sorted := make([]float64, len(values))
-	copy(sorted, values)
-	sort.Float64s(sorted)
-
-	// If odd number of values
-	if len(sorted)%2 == 1 {
-		return sorted[len(sorted)/2]
-	}
+// printAnalysisTable writes the analysis results in a formatted table, with
+// one column per statistic
+func printAnalysisTable(w io.Writer, results []TicketAnalysis, period string, stats []Statistic, layout tableOptions) {
+	printGroupedTable(w, results, period, "Issue Type", "% of Total", nil, stats, layout)
+}
 
-	// If even number of values
-	mid := len(sorted) / 2
-	return (sorted[mid-1] + sorted[mid]) / 2
+// printLevelTable writes the analysis results of a team, group or org, with
+// each issue type's share of it and of the overall report
+func printLevelTable(w io.Writer, results []TicketAnalysis, level, name string, overall *ReportSection, stats []Statistic, layout tableOptions) {
+	printGroupedTable(w, results, fmt.Sprintf("%s: %s", level, name), "Issue Type", "% of "+level, overall, stats, layout)
 }
 
-// printAnalysisTable prints the analysis results in a formatted table
-func printAnalysisTable(results []TicketAnalysis, period string) {
+// printGroupedTable writes analysis results grouped by something other than
+// issue type, with groupColumn as the header of the first column and
+// shareColumn as the header of each row's share of the table. If overall is
+// set, "% of Overall" columns add each row's share of it. Shares are of mana,
+// of issue counts or both, as selected by the layout.
+func printGroupedTable(w io.Writer, results []TicketAnalysis, period, groupColumn, shareColumn string, overall *ReportSection, stats []Statistic, layout tableOptions) {
 	// Calculate totals
 	var totalCount int
 	var totalMana float64
@@ -114,57 +111,84 @@ func printAnalysisTable(results []TicketAnalysis, period string) {
 		totalMana += r.TotalMana
 		allManaValues = append(allManaValues, r.ManaValues...)
 	}
-	overallAvgMana := 0.0
-	if totalCount > 0 {
-		overallAvgMana = totalMana / float64(totalCount)
+	overallStats := computeStatistics(allManaValues, stats)
+
+	// Share columns, of the table first and of the overall report second
+	type share struct {
+		header string
+		byMana bool
+		whole  float64
+	}
+	var shares []share
+	addShares := func(header string, count int, mana float64) {
+		if layout.manaShares() {
+			shares = append(shares, share{header, true, mana})
+		}
+		if layout.countShares() {
+			shares = append(shares, share{header + " Issues", false, float64(count)})
+		}
+	}
+	addShares(shareColumn, totalCount, totalMana)
+	if overall != nil {
+		addShares("% of Overall", overall.TotalCount, overall.TotalMana)
+	}
+	shareCells := func(count int, mana float64) []string {
+		var cells []string
+		for _, s := range shares {
+			part := float64(count)
+			if s.byMana {
+				part = mana
+			}
+			cell := ""
+			if s.whole > 0 {
+				cell = fmt.Sprintf("%.1f%%", part/s.whole*100)
+			}
+			cells = append(cells, cell)
+		}
+		return cells
 	}
-	overallMedianMana := calculateMedian(allManaValues)
 
-	// Print header
-	if period != "" {
-		fmt.Printf("\n%s\n", period)
-	}
-	fmt.Printf("%-20s %-10s %-15s %-15s %-15s %-15s\n",
-		"Issue Type",
-		"Count",
-		"Total Mana",
-		"% of Total",
-		"Avg Mana",
-		"Median Mana")
-	fmt.Println(strings.Repeat("-", 95))
-
-	// Print results
+	columns := []tableColumn{
+		{Header: groupColumn, MaxWidth: 30},
+		{Header: "Count", Right: true},
+		{Header: "Total Mana", Right: true},
+	}
+	for _, s := range shares {
+		columns = append(columns, tableColumn{Header: s.header, Right: true})
+	}
+	for _, s := range stats {
+		columns = append(columns, tableColumn{Header: s.Column, Right: true})
+	}
+	table := newTextTable(columns...)
+
+	// Add results
 	for _, r := range results {
-		percentOfTotal := 0.0
-		percentOfTotalStr := ""
-		if totalMana > 0 {
-			percentOfTotal = (r.TotalMana / totalMana) * 100
-			percentOfTotalStr = fmt.Sprintf("%4.1f%%", percentOfTotal)
-		}
-		fmt.Printf("%-20s %-10d %-15.2f %-15s %-15.2f %-15.2f\n",
-			r.IssueType,
-			r.Count,
-			r.TotalMana,
-			percentOfTotalStr,
-			r.AverageMana,
-			r.MedianMana)
-	}
-
-	// Print totals
-	fmt.Println(strings.Repeat("-", 95))
-	fmt.Printf("%-20s %-10d %-15.2f %-15s %-15.2f %-15.2f\n",
-		"TOTAL",
-		totalCount,
-		totalMana,
-		"100.0%",
-		overallAvgMana,
-		overallMedianMana)
+		row := []string{r.IssueType, layout.Numbers.count(r.Count), layout.Numbers.mana(r.TotalMana)}
+		row = append(row, shareCells(r.Count, r.TotalMana)...)
+		for _, s := range stats {
+			row = append(row, layout.Numbers.statistic(s, r.Stats[s.Key]))
+		}
+		table.addRow(row...)
+	}
+
+	// Add totals
+	total := []string{"TOTAL", layout.Numbers.count(totalCount), layout.Numbers.mana(totalMana)}
+	total = append(total, shareCells(totalCount, totalMana)...)
+	for _, s := range stats {
+		total = append(total, layout.Numbers.statistic(s, overallStats[s.Key]))
+	}
+	table.addFooter(total...)
+
+	if period != "" {
+		fmt.Fprintf(w, "\n%s\n", period)
+	}
+	table.write(w, layout.Style)
 }
 
 // removeEmojis removes emoji characters from a string
 func removeEmojis(s string) string {
 	// This regex matches emoji characters
-	emojiRegex := regexp.MustCompile(`[\x{1F300}-\x{1F9FF}]|[\x{2600}-\x{27BF}]|[\x{FE00}-\x{FE0F}]|[\x{1F000}-\x{1F644}]|[\x{1F680}-\x{1F6FF}]|[\x{2702}-\x{27B0}]|[\x{24C2}-\x{1F251}]|[\x{1F900}-\x{1F9FF}]|[\x{1F1E0}-\x{1F1FF}]|[\x{2194}-\x{2199}]|[\x{2B05}-\x{2B07}]|[\x{2934}-\x{2935}]|[\x{3030}]|[\x{FE0F}]|[\x{20E3}]`)
+	emojiRegex := regexp.MustCompile(`[\x{1F300}-\x{1F9FF}]|[\x{2600}-\x{27BF}]|[\x{FE00}-\x{FE0F}]|[\x{1F000}-\x{1F644}]|[\x{1F680}-\x{1F6FF}]|[\x{2702}-\x{27B0}]|[\x{24C2}]|[\x{1F170}-\x{1F251}]|[\x{1F900}-\x{1F9FF}]|[\x{1F1E0}-\x{1F1FF}]|[\x{2194}-\x{2199}]|[\x{2B05}-\x{2B07}]|[\x{2934}-\x{2935}]|[\x{3030}]|[\x{FE0F}]|[\x{20E3}]`)
 	return strings.TrimSpace(emojiRegex.ReplaceAllString(s, ""))
 }
 
@@ -172,297 +196,586 @@ func runTicketCommand() {
 	// Command line flags
 	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
 	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
-	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ), or comma-separated keys to analyze several projects together")
+	source := flag.String("source", "jira", "Where tickets come from: jira, or gitlab with -project the GitLab project path or ID")
+	keyList := flag.String("keys", "", "Analyze these comma-separated issue keys, or - to read them from stdin, instead of a date range")
 	monthly := flag.Bool("monthly", false, "Show monthly breakdown")
 	teams := flag.Bool("teams", false, "Group results by team")
 	brokenWindows := flag.Bool("broken-windows", false, "Consider tickets with ux-broken-window label as separate type")
 	security := flag.Bool("security", false, "Consider tickets linked to Product Vulnerability issues as a separate type")
+	securityDepth := flag.Int("security-depth", 1, securityDepthUsage)
+	research := defineResearchFlags()
+	excludeReporters := flag.String("exclude-reporters", "", "Leave out issues reported by these comma-separated bot or automation accounts, by account ID, user name or display name, besides the config's automationReporters")
+	countOnly := flag.Bool("count-only", false, "Only count issues per category, without downloading them")
+	sampleRate := flag.Float64("sample-rate", 0, "Fetch only this fraction of issues (e.g. 0.1) and scale the results")
+	statsList := flag.String("stats", defaultStatistics, "Comma-separated statistic columns (count, sum, mean, median, stddev, pXX)")
+	noCache := flag.Bool("no-cache", false, "Always fetch from Jira, ignoring and not updating the local cache")
+	warmStart := flag.Bool("warm-start", false, "Start from the issues the previous -warm-start run of the project cached, fetching only issues updated since")
+	webhookURL := flag.String("webhook-url", "", "Post the report as JSON to this URL")
+	webhookTemplate := flag.String("webhook-template", "", "Template file for the webhook JSON payload (Go text/template over the report)")
+	format := flag.String("format", "text", "Report format: text, pdf or json")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	msteamsWebhookURL := flag.String("msteams-webhook-url", "", "Post the summary as an Adaptive Card to this Microsoft Teams incoming webhook")
+	reportURL := flag.String("report-url", "", "Link to the full report, shown as a button on the Microsoft Teams card")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "Push mana, issue count and run duration gauges to this OpenTelemetry collector over OTLP/HTTP, e.g. http://localhost:4318")
+	externalWait := flag.Bool("external-wait", false, "Measure how long issues waited on blockers owned by other teams")
+	touchedBy := flag.Bool("touched-by", false, "Also attribute mana to every team that moved an issue into a touch status, from the changelog")
+	touchedShare := flag.Float64("touched-share", 0.5, "Fraction of the mana of issues other teams worked on that -touched-by gives to the teams that touched them")
+	touchStatusList := flag.String("touch-statuses", defaultTouchStatuses, "Comma-separated statuses that count as a team working on an issue, for -touched-by and -cycle-time")
+	cycleTime := flag.Bool("cycle-time", false, "Measure lead and cycle times per issue type, in calendar and business days")
+	sinceLastRun := flag.Bool("since-last-run", false, "Only analyze issues resolved since the last run with this flag, and record this run")
+	runMarkerKind := flag.String("run-marker", "local", "Where -since-last-run keeps its marker: local or jira (a project property)")
+	configPath := flag.String("config", "", configUsage)
+	overridesFile := flag.String("overrides", "", overridesUsage)
+	explainFile := flag.String("explain-classification", "", "Write every issue's category and the rule that assigned it to this .csv or .json file")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	emitJQL := flag.Bool("emit-jql", false, "Instead of the report, print the JQL selecting the issues of every row of its tables, with a Jira search link")
+	streamRecords := flag.Bool("stream", false, "Write an NDJSON record per issue to stdout as issues are fetched; everything else goes to stderr")
 	flag.Parse()
-
-	// Validate flags
-	if *startDate == "" || *endDate == "" || *projectKey == "" {
-		flag.Usage()
-		os.Exit(1)
+	started := time.Now()
+
+	// Validate flags. With -since-last-run the start comes from the run
+	// marker and the end defaults to today. With -keys the listed issues
+	// replace the date range.
+	var keys []string
+	var err error
+	if *keyList != "" {
+		if *startDate != "" || *endDate != "" || *sinceLastRun || *countOnly || *sampleRate > 0 || *monthly {
+			log.Fatal("-keys cannot be combined with -start, -end, -since-last-run, -count-only, -sample-rate or -monthly, as the listed issues replace the date range")
+		}
+		if keys, err = parseIssueKeys(*keyList, os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		if *projectKey == "" {
+			*projectKey = keyProjects(keys)
+		}
+	} else {
+		if *sinceLastRun && *endDate == "" {
+			*endDate = time.Now().Format("2006-01-02")
+		}
+		if (*startDate == "" && !*sinceLastRun) || *endDate == "" || *projectKey == "" {
+			flag.Usage()
+			os.Exit(1)
+		}
 	}
-
-	// Get JIRA credentials from environment variables
-	jiraURL := os.Getenv("JIRA_URL")
-	username := os.Getenv("JIRA_USERNAME")
-	apiToken := os.Getenv("JIRA_TOKEN")
-
-	// Validate environment variables
-	if jiraURL == "" || username == "" || apiToken == "" {
-		log.Fatal("Missing required environment variables. Please set JIRA_URL, JIRA_USERNAME, and JIRA_TOKEN")
+	// Several comma-separated projects are fetched in parallel and merged
+	var projects []string
+	if *keyList == "" && strings.Contains(*projectKey, ",") {
+		if projects, err = parseProjectKeys(*projectKey); err != nil {
+			log.Fatal(err)
+		}
+		if *source != "jira" || *countOnly || *sampleRate > 0 || *sinceLastRun {
+			log.Fatal("several -project keys cannot be combined with -source gitlab, -count-only, -sample-rate or -since-last-run, which work on one project")
+		}
+		*projectKey = strings.Join(projects, ",")
 	}
-
-	// Create JIRA client
-	tp := jira.BasicAuthTransport{
-		Username: username,
-		Password: apiToken,
+	if *emitJQL && (*countOnly || *sampleRate > 0 || *source != "jira" || *format == "json") {
+		log.Fatal("-emit-jql cannot be combined with -count-only, -sample-rate, -source gitlab or -format json, as it lists the keys of every fetched Jira issue")
+	}
+	if *streamRecords && *countOnly {
+		log.Fatal("-stream cannot be combined with -count-only, which fetches no issues")
 	}
-	client, err := jira.NewClient(tp.Client(), jiraURL)
+	if err := checkSecurityDepth(*securityDepth); err != nil {
+		log.Fatal(err)
+	}
+	if *streamRecords && *security && *securityDepth > 1 {
+		log.Fatal("-stream cannot be combined with -security-depth, as records are written before links can be followed")
+	}
+	if *streamRecords && *overridesFile != "" {
+		log.Fatal("-stream cannot be combined with -overrides, as records are written before overrides are applied")
+	}
+	if *warmStart && (*keyList != "" || projects != nil || *source != "jira" || *countOnly || *sampleRate > 0 || *monthly || *noCache) {
+		log.Fatal("-warm-start cannot be combined with -keys, several -project keys, -source gitlab, -count-only, -sample-rate, -monthly or -no-cache; -monthly caches completed months itself")
+	}
+	if *sinceLastRun && (*countOnly || *sampleRate > 0) {
+		log.Fatal("-since-last-run cannot be combined with -count-only or -sample-rate, as both need every issue of the period")
+	}
+	switch *source {
+	case "jira":
+	case "gitlab":
+		if *keyList != "" || *countOnly || *sampleRate > 0 || *sinceLastRun || *security || *externalWait || *touchedBy || *cycleTime || *excludeReporters != "" {
+			log.Fatal("-source gitlab cannot be combined with -keys, -count-only, -sample-rate, -since-last-run, -security, -external-wait, -touched-by, -cycle-time or -exclude-reporters, as they need Jira")
+		}
+	default:
+		log.Fatalf("invalid -source %q, expected jira or gitlab", *source)
+	}
+	if *format != "json" {
+		if err := validateFormat(*format); err != nil {
+			log.Fatal(err)
+		}
+	}
+	layout, err := tables.options(*format)
 	if err != nil {
-		log.Fatalf("Error creating JIRA client: %v", err)
+		log.Fatal(err)
 	}
-
-	// Parse dates
-	start, err := time.Parse("2006-01-02", *startDate)
+	if *sampleRate < 0 || *sampleRate > 1 {
+		log.Fatal("-sample-rate must be between 0 and 1")
+	}
+	if *otlpEndpoint != "" {
+		if _, err := otlpMetricsURL(*otlpEndpoint); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := otlpHeaders(os.Getenv(otlpHeadersEnv)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	stats, err := parseStatistics(*statsList)
 	if err != nil {
-		log.Fatalf("Invalid start date format: %v", err)
+		log.Fatal(err)
 	}
-	end, err := time.Parse("2006-01-02", *endDate)
+	config, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Invalid end date format: %v", err)
+		log.Fatal(err)
 	}
-
-	// Create base JQL query
-	jql := fmt.Sprintf(`project = "%s" AND
-		status in (Resolved, Closed) AND
-		resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined") AND
-		resolutiondate >= "%s" AND
-		resolutiondate <= "%s" AND
-		"Mana Spent" is not EMPTY AND
-		issuetype not in (Epic, Initiative)
-		ORDER BY created DESC`,
-		*projectKey,
-		start.Format("2006-01-02"),
-		end.Format("2006-01-02"))
-
-	// Initialize analysis maps
-	analysis := make(map[string]*TicketAnalysis)
-	var monthlyAnalyses []MonthlyAnalysis
-	var teamAnalyses []TeamAnalysis
-
-	if *teams {
-		// We'll populate the teams as we find them
-		teamAnalyses = make([]TeamAnalysis, 0)
+	classify := newClassifyOptions(*brokenWindows, *security, config)
+	classify.AutomationReporters = parseAutomationReporters(config.AutomationReporters, *excludeReporters)
+	if err := research.apply(&classify); err != nil {
+		log.Fatal(err)
+	}
+	if *touchedShare < 0 || *touchedShare > 1 {
+		log.Fatal("-touched-share must be between 0 and 1")
+	}
+	touchStatuses, err := parseTouchStatuses(*touchStatusList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Team changes are found by the field's name in changelogs, and blockers
+	// of any project are read by the standard Team field
+	if *touchedBy || *externalWait {
+		analyzed := *projectKey
+		if len(keys) > 0 {
+			analyzed = keyProjects(keys)
+		}
+		for _, project := range strings.Split(analyzed, ",") {
+			if err := config.fieldsOf(project).requireStandard(project, "-touched-by or -external-wait", false, true); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if *countOnly && *excludeReporters != "" {
+		log.Fatal("-count-only cannot be combined with -exclude-reporters, as reporters are matched on the issues themselves")
+	}
+	var overrides issueOverrides
+	if *overridesFile != "" {
+		if overrides, err = loadOverrides(*overridesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *explainFile != "" {
+		if _, err := explainFormat(*explainFile); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if *monthly {
-		// Create a map for each month in the date range
-		current := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
-		endMonth := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, end.Location())
+	// With -stream stdout carries the records only, so everything else
+	// printed, including the report without -output, goes to stderr. With
+	// -format json it carries the report only, so progress and warnings go
+	// to stderr.
+	var stream *issueStream
+	reportOut := io.Writer(os.Stdout)
+	if *streamRecords {
+		stream = newIssueStream(os.Stdout, classify)
+		os.Stdout = os.Stderr
+		reportOut = os.Stderr
+	} else if *format == "json" {
+		os.Stdout = os.Stderr
+	}
 
-		for !current.After(endMonth) {
-			monthlyAnalyses = append(monthlyAnalyses, MonthlyAnalysis{
-				Month:    current,
-				Analysis: make(map[string]*TicketAnalysis),
-			})
-			current = current.AddDate(0, 1, 0)
+	// Create JIRA client from the environment
+	var client *jira.Client
+	var jiraURL string
+	if *source == "jira" {
+		if client, jiraURL, err = newClientFromEnv(); err != nil {
+			log.Fatal(err)
 		}
 	}
 
-	// Search issues with pagination
-	var startAt int
-	for {
-		searchOpts := &jira.SearchOptions{
-			StartAt:    startAt,
-			MaxResults: 50,
-			Fields:     []string{"issuetype", "customfield_11267", "resolutiondate", "customfield_10800", "labels", "issuelinks"},
+	var markers runMarkerStore
+	var marker *runMarker
+	var drift *fieldDriftCheck
+	var missingFields []string
+	var start, end time.Time
+	var jql string
+	var issues []Issue
+	var keyed *keyedIssues
+	// The rows of -emit-jql narrow down the report's query and its issues
+	rows := rowScope{Fields: map[string]projectFields{"": standardProjectFields}}
+	var totalIssues int
+	var sampledPageIssues []int // Issues of every sampled page, in order
+	if *source == "gitlab" {
+		// Analyze the closed GitLab issues of the period
+		gitlab, err := newGitLabClientFromEnv()
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		issues, resp, err := client.Issue.Search(jql, searchOpts)
+		if start, end, err = resolveRange(startDate, endDate); err != nil {
+			log.Fatal(err)
+		}
+		mapping := gitlabMapping(config.GitLab)
+		found, err := gitlab.closedIssues(*projectKey, start, end)
 		if err != nil {
-			log.Fatalf("Error searching issues: %v", err)
+			log.Fatal(err)
 		}
-
-		if len(issues) == 0 {
-			break
+		issues = selectGitLabIssues(found, mapping)
+		stream.write(issues)
+		jql = gitlabQuery(*projectKey, start, end, mapping)
+	} else if len(keys) > 0 {
+		// Analyze the listed issues, over the range of their resolutions
+		keyed, err = fetchKeyedIssues(client, keys, config)
+		if err != nil {
+			log.Fatal(err)
 		}
+		issues = keyed.Issues
+		stream.write(issues)
+		start, end = keyed.First, keyed.Last
+		if !start.IsZero() {
+			*startDate, *endDate = start.Format("2006-01-02"), end.Format("2006-01-02")
+		}
+		jql = keysJQL(keys)
+		rows.Filter = jql
+		rows.Fields = make(map[string]projectFields)
+		for _, project := range strings.Split(keyProjects(keys), ",") {
+			rows.Fields[project] = config.fieldsOf(project)
+		}
+	} else if len(projects) > 1 {
+		// Analyze the projects together, each fetched with its own query
+		if start, end, err = resolveRange(startDate, endDate); err != nil {
+			log.Fatal(err)
+		}
+		jqlOf := func(project string) string {
+			return projectTicketJQLFilter(project, resolvedBetween(start, end, *endInclusive), config.fieldsOf(project)) + `
+			ORDER BY created DESC`
+		}
+		var queries, filters []string
+		rows.Fields = make(map[string]projectFields)
+		for _, project := range projects {
+			if err := validateJQL(client, jqlOf(project)); err != nil {
+				log.Fatal(err)
+			}
+			queries = append(queries, jqlOf(project))
+			filters = append(filters, projectTicketJQLFilter(project, resolvedBetween(start, end, *endInclusive), config.fieldsOf(project)))
+			rows.Fields[project] = config.fieldsOf(project)
+		}
+		jql = strings.Join(queries, "\n\n")
+		rows.Filter = "((" + strings.Join(filters, ") OR\n\t\t(") + "))"
 
-		// Process issues
-		for _, issue := range issues {
-			issueType := normalizeIssueType(issue.Fields.Type.Name)
-
-			// Check for broken window label if flag is enabled
-			if *brokenWindows {
-				for _, label := range issue.Fields.Labels {
-					if label == "ux-broken-window" {
-						issueType = "Broken Window"
-						break
-					}
+		perProject, err := fetchProjectIssues(client, os.Stdout, projects, jqlOf, ticketFields, config, stream)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, projectIssues := range perProject {
+			issues = append(issues, projectIssues...)
+		}
+	} else {
+		// Continue from the run marker of the project
+		if *sinceLastRun {
+			if *runMarkerKind == "jira" && jiraWrites.ReadOnly {
+				log.Fatal("-run-marker jira saves the marker in Jira, which read-only mode refuses; use -run-marker local")
+			}
+			markers, err = newRunMarkerStore(*runMarkerKind, client)
+			if err != nil {
+				log.Fatal(err)
+			}
+			marker, err = markers.Load(*projectKey)
+			if err != nil {
+				log.Fatalf("Error loading run marker: %v", err)
+			}
+			if marker != nil {
+				// Start a day early as the marker's day may differ in Jira's
+				// time zone; issues analyzed before are dropped after fetching
+				*startDate = marker.LastResolved.AddDate(0, 0, -1).Format("2006-01-02")
+				if stream != nil {
+					stream.after = marker.LastResolved
 				}
+			} else if *startDate == "" {
+				log.Fatalf("No run marker for project %s yet, pass -start for the first run", *projectKey)
 			}
 
-			// Check for linked Product Vulnerability tickets if flag is enabled
-			if *security && issueType != "Broken Window" {
-				for _, link := range issue.Fields.IssueLinks {
-					if link.OutwardIssue != nil && link.OutwardIssue.Fields != nil && link.OutwardIssue.Fields.Type.Name == "Product Vulnerability" {
-						issueType = "Security Vuln."
-						break
-					}
-					if link.InwardIssue != nil && link.InwardIssue.Fields != nil && link.InwardIssue.Fields.Type.Name == "Product Vulnerability" {
-						issueType = "Security Vuln."
-						break
-					}
-				}
+			// Scheduled runs alert when the fields they read changed
+			if drift, err = checkFieldDrift(client, jiraURL); err != nil {
+				log.Fatalf("Error checking Jira fields: %v", err)
 			}
+			writeFieldChanges(os.Stderr, drift.Changes)
+			if githubActions {
+				annotateFieldChanges(os.Stdout, drift.Changes)
+			}
+		}
 
-			manaField := issue.Fields.Unknowns["customfield_11267"]
-			manaSpent := getManaPoints(manaField)
+		// Parse dates
+		start, end, err = resolveRange(startDate, endDate)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-			// Update overall analysis
-			if _, exists := analysis[issueType]; !exists {
-				analysis[issueType] = &TicketAnalysis{
-					IssueType:  issueType,
-					ManaValues: make([]float64, 0),
-				}
+		// Create base JQL filter and query, on the project's own fields
+		own := config.fieldsOf(*projectKey)
+		fields := own.request(ticketFields)
+		jqlFilter := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, *endInclusive), own)
+		jql = jqlFilter + `
+			ORDER BY created DESC`
+		rows.Filter = jqlFilter
+		rows.Fields = map[string]projectFields{"": own}
+
+		// Validate the query before fetching anything
+		if err := validateJQL(client, jql); err != nil {
+			log.Fatal(err)
+		}
+
+		// Features reading fields the API user cannot see are left out,
+		// rather than putting every issue under No Team
+		var optional []optionalField
+		if *teams || *touchedBy || *externalWait {
+			optional = append(optional, teamField)
+		}
+		if classify.BrokenWindows {
+			optional = append(optional, labelsField)
+		}
+		if classify.Security || *externalWait {
+			optional = append(optional, issueLinksField)
+		}
+		missing, err := findMissingFields(client, jql, optional, own)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, f := range missing {
+			switch f {
+			case teamField:
+				*teams, *touchedBy, *externalWait = false, false, false
+			case labelsField:
+				classify.BrokenWindows = false
+			case issueLinksField:
+				classify.Security, *externalWait = false, false
+			}
+			missingFields = append(missingFields, describeMissingField(f, own))
+		}
+		writeMissingFields(os.Stderr, missingFields)
+		if githubActions {
+			annotateMissingFields(os.Stdout, missingFields)
+		}
+		if stream != nil {
+			stream.opts = classify
+		}
+
+		if *countOnly {
+			if *teams || *security || *externalWait || *touchedBy || *cycleTime || *explainFile != "" {
+				log.Fatal("-count-only cannot be combined with -teams, -security, -external-wait, -touched-by, -cycle-time or -explain-classification, as they need the issues themselves")
+			}
+			if len(classify.AutomationReporters) > 0 {
+				fmt.Println("Warning: -count-only also counts the issues of the config's automationReporters, as reporters are matched on the issues themselves")
 			}
-			analysis[issueType].Count++
-			analysis[issueType].TotalMana += manaSpent
-			analysis[issueType].ManaValues = append(analysis[issueType].ManaValues, manaSpent)
-			if manaSpent == 0 {
-				analysis[issueType].ZeroManaCount++
+			fmt.Printf("\nAnalysis Period: %s to %s\n", *startDate, *endDate)
+			fmt.Println(endBoundaryNote(*endDate, *endInclusive))
+			fmt.Printf("Project: %s\n", *projectKey)
+			fmt.Printf("\nJQL Query:\n%s\n", jql)
+			if err := runCountOnly(client, *projectKey, jqlFilter, start, end, *monthly, classify, layout); err != nil {
+				log.Fatal(err)
 			}
+			return
+		}
 
-			// Update team analysis if enabled
-			if *teams {
-				team := "No Team"
-				if teamField := issue.Fields.Unknowns["customfield_10800"]; teamField != nil {
-					if teamObj, ok := teamField.(map[string]interface{}); ok {
-						if teamName, ok := teamObj["name"].(string); ok && teamName != "" {
-							team = teamName
-						}
-					}
-				}
+		// When sampling, only a random subset of pages is fetched
+		var sampledPages []int
+		sampling := *sampleRate > 0 && *sampleRate < 1
+		if sampling {
+			totalIssues, err = countIssues(client, jqlFilter)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sampledPages = samplePageOffsets(totalIssues, searchPageSize, *sampleRate)
+		}
 
-				// Find or create team analysis
-				var teamAnalysis *TeamAnalysis
-				for i := range teamAnalyses {
-					if teamAnalyses[i].Team == team {
-						teamAnalysis = &teamAnalyses[i]
-						break
-					}
-				}
-				if teamAnalysis == nil {
-					teamAnalyses = append(teamAnalyses, TeamAnalysis{
-						Team:     team,
-						Analysis: make(map[string]*TicketAnalysis),
-					})
-					teamAnalysis = &teamAnalyses[len(teamAnalyses)-1]
-				}
+		cache := openIssueCache(*noCache)
 
-				// Update team's issue type analysis
-				if _, exists := teamAnalysis.Analysis[issueType]; !exists {
-					teamAnalysis.Analysis[issueType] = &TicketAnalysis{
-						IssueType:  issueType,
-						ManaValues: make([]float64, 0),
-					}
-				}
-				teamAnalysis.Analysis[issueType].Count++
-				teamAnalysis.Analysis[issueType].TotalMana += manaSpent
-				teamAnalysis.Analysis[issueType].ManaValues = append(teamAnalysis.Analysis[issueType].ManaValues, manaSpent)
+		if *monthly && !sampling {
+			// Fetch every month with its own query so completed months can be
+			// served from the cache when the range is extended
+			months, err := fetchMonthlyIssues(client, cache, *projectKey, own, start, end, *endInclusive, ticketFields, stream)
+			if err != nil {
+				log.Fatal(err)
 			}
-
-			// Update monthly analysis if enabled
-			if *monthly {
-				resolutionDate := time.Time(issue.Fields.Resolutiondate)
-
-				for i := range monthlyAnalyses {
-					maStart := monthlyAnalyses[i].Month
-					maEnd := maStart.AddDate(0, 1, 0).Add(-time.Second)
-
-					if (resolutionDate.After(maStart) || resolutionDate.Equal(maStart)) &&
-						(resolutionDate.Before(maEnd) || resolutionDate.Equal(maEnd)) {
-						if _, exists := monthlyAnalyses[i].Analysis[issueType]; !exists {
-							monthlyAnalyses[i].Analysis[issueType] = &TicketAnalysis{
-								IssueType:  issueType,
-								ManaValues: make([]float64, 0),
-							}
-						}
-						monthlyAnalyses[i].Analysis[issueType].Count++
-						if manaSpent == 0 {
-							monthlyAnalyses[i].ZeroManaCount++
-						}
-						monthlyAnalyses[i].Analysis[issueType].TotalMana += manaSpent
-						monthlyAnalyses[i].Analysis[issueType].ManaValues = append(monthlyAnalyses[i].Analysis[issueType].ManaValues, manaSpent)
-						break
+			for _, monthIssues := range months {
+				issues = append(issues, issuesFromJira(monthIssues)...)
+			}
+		} else {
+			// Search issues with pagination. Sampled pages are fetched in full
+			// even if Jira serves them in smaller pages.
+			var found []jira.Issue
+			onPage := func(page []jira.Issue, fetched, total int) {
+				// Pages share their fields with found, so it is normalized too
+				own.normalize(page)
+				stream.page(page, fetched, total)
+			}
+			if sampling {
+				for _, startAt := range sampledPages {
+					pageIssues, err := streamSearchRange(client, jql, fields, "", startAt, searchPageSize, onPage)
+					if err != nil {
+						log.Fatal(err)
 					}
+					found = append(found, pageIssues...)
+					sampledPageIssues = append(sampledPageIssues, len(pageIssues))
 				}
+			} else if *warmStart {
+				// Scheduled runs start from the issues the previous run cached
+				if found, err = fetchWarmIssues(client, cache, *projectKey, jqlFilter, start, end, *endInclusive, fields); err != nil {
+					log.Fatal(err)
+				}
+				own.normalize(found)
+				stream.write(issuesFromJira(found))
+			} else if found, err = streamSearchRange(client, jql, fields, "", 0, 0, onPage); err != nil {
+				log.Fatal(err)
 			}
+			issues = issuesFromJira(found)
 		}
 
-		startAt += len(issues)
-		if startAt >= resp.Total {
-			break
+		if !sampling {
+			totalIssues = 0
+		}
+		rows.Issues = issues
+		if marker != nil {
+			issues = issuesResolvedAfter(issues, marker.LastResolved)
 		}
 	}
+	if err := stream.Err(); err != nil {
+		log.Fatalf("Error streaming issues: %v", err)
+	}
+	if err := applySecurityDepth(client, os.Stdout, issues, *securityDepth, &classify); err != nil {
+		log.Fatal(err)
+	}
+	applyOverrides(os.Stdout, issues, overrides, &classify)
+	opts := ticketOptions{
+		Classify:    classify,
+		Teams:       *teams,
+		OrgChart:    config.OrgChart,
+		Monthly:     *monthly,
+		Start:       start,
+		End:         end,
+		Stats:       stats,
+		SamplePages: sampledPageIssues,
+	}
 
-	// Calculate averages and medians for overall analysis
-	var results []TicketAnalysis
-	for _, a := range analysis {
-		if a.Count > 0 {
-			a.AverageMana = a.TotalMana / float64(a.Count)
-			a.MedianMana = calculateMedian(a.ManaValues)
+	// Drill down into the rows instead of reporting them
+	if *emitJQL {
+		if rows.Issues == nil {
+			rows.Issues = issues
 		}
-		results = append(results, *a)
+		queries := ticketRowQueries(rows, issues, opts)
+		// The queries are only checked against the fetched issues locally,
+		// so Jira checks they are valid JQL before they are printed
+		jqls := make([]string, len(queries))
+		for i, q := range queries {
+			jqls[i] = q.JQL
+		}
+		if err := validateJQLQueries(client, jqls); err != nil {
+			log.Fatal(err)
+		}
+		title := fmt.Sprintf("%s Mana Analysis JQL %s to %s", *projectKey, *startDate, *endDate)
+		if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeRowQueries(w, queries, jiraURL, layout) }); err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+		if *output != "" {
+			fmt.Printf("\nJQL written to %s\n", *output)
+		}
+		return
 	}
 
-	// Sort by total mana spent
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].TotalMana > results[j].TotalMana
-	})
+	report := analyzeTickets(issues, opts, totalIssues)
+	report.Project = *projectKey
+	report.Start = *startDate
+	if marker != nil {
+		report.Since = marker.LastResolved
+	}
+	report.End = *endDate
+	report.EndInclusive = *endInclusive || *source == "gitlab"
+	report.JQL = jql
+	if drift != nil {
+		report.FieldChanges = drift.Changes
+	}
+	report.MissingFields = missingFields
+	if keyed != nil {
+		report.Keys = &keyed.Selection
+	}
 
-	// Calculate total zero mana tickets
-	var totalZeroMana int
-	for _, result := range results {
-		totalZeroMana += result.ZeroManaCount
-	}
-
-	// Print header information
-	fmt.Printf("\nAnalysis Period: %s to %s\n", *startDate, *endDate)
-	fmt.Printf("Project: %s\n", *projectKey)
-	fmt.Printf("\nJQL Query:\n%s\n", jql)
-
-	if *teams {
-		// Sort teams alphabetically
-		sort.Slice(teamAnalyses, func(i, j int) bool {
-			return teamAnalyses[i].Team < teamAnalyses[j].Team
-		})
-
-		// Print team breakdowns
-		for _, ta := range teamAnalyses {
-			var teamResults []TicketAnalysis
-			for _, a := range ta.Analysis {
-				if a.Count > 0 {
-					a.AverageMana = a.TotalMana / float64(a.Count)
-					a.MedianMana = calculateMedian(a.ManaValues)
-				}
-				teamResults = append(teamResults, *a)
-			}
-			sort.Slice(teamResults, func(i, j int) bool {
-				return teamResults[i].TotalMana > teamResults[j].TotalMana
-			})
-			printAnalysisTable(teamResults, fmt.Sprintf("Team: %s", ta.Team))
-		}
-
-		// Print overall summary
-		fmt.Printf("\nOVERALL SUMMARY:\n")
-	} else if *monthly {
-		// Print monthly breakdowns
-		for _, ma := range monthlyAnalyses {
-			var monthResults []TicketAnalysis
-			for _, a := range ma.Analysis {
-				if a.Count > 0 {
-					a.AverageMana = a.TotalMana / float64(a.Count)
-					a.MedianMana = calculateMedian(a.ManaValues)
-				}
-				monthResults = append(monthResults, *a)
-			}
-			sort.Slice(monthResults, func(i, j int) bool {
-				return monthResults[i].TotalMana > monthResults[j].TotalMana
-			})
-			printAnalysisTable(monthResults, fmt.Sprintf("Month: %s", ma.Month.Format("January 2006")))
-			// Print zero mana tickets for this month
-			fmt.Printf("  Zero Mana Tickets: %d\n", ma.ZeroManaCount)
+	changelogs := openChangelogCache(*noCache)
+	if *externalWait {
+		report.ExternalWaits, err = fetchExternalWaits(client, changelogs, issues)
+		if err != nil {
+			log.Fatalf("Error measuring external waits: %v", err)
 		}
+	}
+	if *touchedBy {
+		attribution, err := fetchTeamAttribution(client, changelogs, issues, touchStatuses, *touchedShare)
+		if err != nil {
+			log.Fatalf("Error attributing mana to touching teams: %v", err)
+		}
+		report.Attribution = &TouchedAttribution{Share: *touchedShare, Teams: attribution}
+	}
+	if *cycleTime {
+		cal, err := newBusinessCalendar(config.Calendar)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.CycleTimes, err = fetchCycleTimes(client, changelogs, issues, touchStatuses, cal, classify)
+		if err != nil {
+			log.Fatalf("Error measuring cycle times: %v", err)
+		}
+	}
 
-		// Print overall summary
-		fmt.Printf("\nOVERALL SUMMARY:\n")
+	title := fmt.Sprintf("%s Mana Analysis %s", report.Project, report.periodLabel())
+	if *format == "json" {
+		if err = writeReportJSON(reportOut, *output, report); err == nil && *output != "" {
+			recordArchiveReport(*output, title)
+		}
+	} else {
+		err = writeFormatted(*format, *output, title, func(w io.Writer) { writeTicketReport(w, report, layout) })
+	}
+	if err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+	if *explainFile != "" {
+		if err := writeExplanations(*explainFile, explainClassification(issues, classify)); err != nil {
+			log.Fatalf("Error writing classification decisions: %v", err)
+		}
+		fmt.Printf("\nClassification of %d issues written to %s\n", len(issues), *explainFile)
 	}
 
-	printAnalysisTable(results, "")
-	fmt.Printf("  Zero Mana Tickets: %d\n", totalZeroMana)
+	if *webhookURL != "" {
+		if err := sendWebhook(*webhookURL, report, *webhookTemplate); err != nil {
+			log.Fatalf("Error sending webhook: %v", err)
+		}
+		fmt.Printf("\nReport posted to webhook.\n")
+	}
+	if *msteamsWebhookURL != "" {
+		if err := sendTeamsWebhook(*msteamsWebhookURL, report, *reportURL); err != nil {
+			log.Fatalf("Error posting to Microsoft Teams: %v", err)
+		}
+		fmt.Printf("\nReport posted to Microsoft Teams.\n")
+	}
+	if *otlpEndpoint != "" {
+		if err := exportOTLPMetrics(*otlpEndpoint, report, time.Since(started)); err != nil {
+			log.Fatalf("Error exporting OpenTelemetry metrics: %v", err)
+		}
+		fmt.Printf("\nMetrics exported to OpenTelemetry.\n")
+	}
+
+	// Record how far this run got, once the report went out
+	if *sinceLastRun {
+		if err := drift.save(); err != nil {
+			log.Fatalf("Error saving field snapshot: %v", err)
+		}
+		latest := latestResolution(issues)
+		if latest.IsZero() {
+			fmt.Printf("\nNo new issues, run marker unchanged.\n")
+			return
+		}
+		if err := markers.Save(*projectKey, &runMarker{LastResolved: latest}); err != nil {
+			log.Fatalf("Error saving run marker: %v", err)
+		}
+		fmt.Printf("\nRun marker saved: last resolution %s\n", latest.Format("2006-01-02 15:04"))
+	}
 }
 
 func runEpicCommand() {
@@ -470,246 +783,279 @@ func runEpicCommand() {
 	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
 	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
 	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	keyList := flag.String("keys", "", "Analyze these comma-separated epic keys, or - to read them from stdin, whatever their status, instead of a date range")
+	statsList := flag.String("stats", defaultStatistics, "Comma-separated statistic columns (count, sum, mean, median, stddev, pXX)")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	icsFile := flag.String("ics", "", "Write epic completions as an iCalendar file to this path")
+	icsMilestones := flag.Bool("ics-milestones", false, "Add an event after every month of the range to the -ics calendar")
+	childJQL := flag.String("child-jql", defaultEpicChildJQL, "Template of the JQL selecting an epic's child tickets, with {{.EpicKey}}, {{.Projects}} and {{.ProjectKey}}")
+	childProjects := flag.String("child-projects", "", "Comma-separated other projects the epics' child tickets may be in, or \"all\"")
+	valueField := flag.String("value-field", "", "Name or ID of the epic field with the expected impact or business value, e.g. \"Business Value\"")
+	eta := flag.Bool("eta", false, "Add open epics and project their completion dates from the trailing 4-week mana burn rate")
+	slip := flag.Bool("slip", false, "Compare each epic's due date with its resolution date and report the slip per team")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	activity := flag.Bool("sparkline", false, "Add a sparkline of each epic's child resolutions per week to the epic details (text only)")
+	dormancy := flag.Bool("dormancy", false, "Add when each epic first and last accrued child mana and its longest gap without any to the epic details")
+	childStatus := flag.Bool("child-status", false, "Add each epic's children per status category, e.g. 12 Done / 3 In Progress / 5 To Do, to the epic details")
+	categoryMix := flag.Bool("category-mix", false, "Classify each epic's children like the ticket command and add the share of its mana per category")
+	brokenWindows := flag.Bool("broken-windows", false, "With -category-mix, consider tickets with ux-broken-window label as separate type")
+	security := flag.Bool("security", false, "With -category-mix, consider tickets linked to Product Vulnerability issues as a separate type")
+	securityDepth := flag.Int("security-depth", 1, securityDepthUsage)
+	research := defineResearchFlags()
+	dri := flag.Bool("dri", false, "Add each epic's DRI, its assignee, to the epic details and roll epic mana up per DRI")
+	driField := flag.String("dri-field", "", "Name or ID of the epic field holding the DRI instead of the assignee, e.g. a user picker; implies -dri")
+	configPath := flag.String("config", "", configUsage)
 	flag.Parse()
 
-	// Validate flags
-	if *startDate == "" || *endDate == "" || *projectKey == "" {
+	// Validate flags. With -keys the listed epics replace the date range.
+	var keys []string
+	var err error
+	if *keyList != "" {
+		if *startDate != "" || *endDate != "" || *endInclusive {
+			log.Fatal("-keys cannot be combined with -start, -end or -end-inclusive, as the listed epics replace the date range")
+		}
+		if keys, err = parseIssueKeys(*keyList, os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		if *projectKey == "" {
+			*projectKey = keyProjects(keys)
+		}
+	} else if *startDate == "" || *endDate == "" || *projectKey == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
-
-	// Get JIRA credentials from environment variables
-	jiraURL := os.Getenv("JIRA_URL")
-	username := os.Getenv("JIRA_USERNAME")
-	apiToken := os.Getenv("JIRA_TOKEN")
-
-	// Validate environment variables
-	if jiraURL == "" || username == "" || apiToken == "" {
-		log.Fatal("Missing required environment variables. Please set JIRA_URL, JIRA_USERNAME, and JIRA_TOKEN")
+	stats, err := parseStatistics(*statsList)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Create JIRA client
-	tp := jira.BasicAuthTransport{
-		Username: username,
-		Password: apiToken,
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
 	}
-	client, err := jira.NewClient(tp.Client(), jiraURL)
+	layout, err := tables.options(*format)
 	if err != nil {
-		log.Fatalf("Error creating JIRA client: %v", err)
+		log.Fatal(err)
 	}
-
-	// Parse dates
-	start, err := time.Parse("2006-01-02", *startDate)
+	if *activity && *format == "pdf" {
+		log.Fatal("-sparkline cannot be used with -format pdf, as its block characters are not in the PDF font")
+	}
+	if !*categoryMix {
+		for _, name := range []string{"broken-windows", "security", "security-depth", "research", "research-types"} {
+			if flagPassed(name) {
+				log.Fatalf("-%s needs -category-mix, which is what classifies the child tickets", name)
+			}
+		}
+	}
+	if err := checkSecurityDepth(*securityDepth); err != nil {
+		log.Fatal(err)
+	}
+	config, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Invalid start date format: %v", err)
+		log.Fatal(err)
 	}
-	end, err := time.Parse("2006-01-02", *endDate)
+	classify := newClassifyOptions(*brokenWindows, *security, config)
+	if err := research.apply(&classify); err != nil {
+		log.Fatal(err)
+	}
+	childTemplate, err := parseEpicChildTemplate(*childJQL)
 	if err != nil {
-		log.Fatalf("Invalid end date format: %v", err)
-	}
-
-	// Create JQL query for epics with activity in the date range
-	jql := fmt.Sprintf(`project = "%s" AND
-		issuetype = Epic AND
-		(
-			(status = "GA Release") OR
-			(status in (Resolved, Closed) AND
-			resolution not in ("Won't Do", "Invalid", "Duplicate") AND
-			resolutiondate >= "%s" AND
-			resolutiondate <= "%s")
-		) AND
-		"Team[Team]" IS NOT EMPTY
-		ORDER BY created DESC`,
-		*projectKey,
-		start.Format("2006-01-02"),
-		end.Format("2006-01-02"))
-
-	// Initialize analysis map
-	analysis := make(map[string]*TicketAnalysis)
-	var epicDetailsList []struct {
-		Key              string
-		Summary          string
-		Status           string
-		TotalTickets     int
-		ZeroManaTickets  int
-		TotalMana        float64
-		AvgManaPerTicket float64
-		MedianMana       float64
-	}
-
-	// Search issues with pagination
-	var startAt int
-	for {
-		searchOpts := &jira.SearchOptions{
-			StartAt:    startAt,
-			MaxResults: 50,
-			Fields:     []string{"issuetype", "summary", "status", "customfield_10014"}, // customfield_10014 is typically the Epic Link field
-		}
-
-		issues, resp, err := client.Issue.Search(jql, searchOpts)
-		if err != nil {
-			log.Fatalf("Error searching issues: %v", err)
-		}
-
-		if len(issues) == 0 {
-			break
-		}
-
-		// Process issues
-		for _, issue := range issues {
-			// Search for tickets that have this epic as their epic link
-			childJQL := fmt.Sprintf(`project = "%s" AND "Epic Link" = "%s" AND "Mana Spent" is not EMPTY`,
-				*projectKey, issue.Key)
-			childJQL = fmt.Sprintf(`%s AND resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined")`, childJQL)
-
-			// Search for child tickets in bulk
-			var childStartAt int
-			var totalManaSpent float64
-			var totalChildren int
-			var zeroManaCount int
-			var childManaValues []float64
-			for {
-				childSearchOpts := &jira.SearchOptions{
-					StartAt:    childStartAt,
-					MaxResults: 50,
-				}
+		log.Fatal(err)
+	}
+	// Children of listed epics are searched in the projects of all of them
+	var scope childScope
+	if len(keys) > 0 {
+		scope, err = keyedChildScope(*projectKey, *childProjects)
+	} else {
+		scope, err = parseChildProjects(*projectKey, *childProjects)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkChildScope(childTemplate, scope); err != nil {
+		log.Fatal(err)
+	}
 
-				children, resp, err := client.Issue.Search(childJQL, childSearchOpts)
-				if err != nil {
-					log.Fatalf("Error searching child tickets: %v", err)
-				}
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-				if len(children) == 0 {
-					break
-				}
+	// Look up the value and DRI fields before fetching anything
+	var valueFieldID, valueFieldName string
+	if *valueField != "" {
+		if valueFieldID, valueFieldName, err = resolveField(client, *valueField); err != nil {
+			log.Fatalf("Error looking up -value-field: %v", err)
+		}
+	}
+	var driFieldID, driFieldName string
+	if *driField != "" {
+		if driFieldID, driFieldName, err = resolveField(client, *driField); err != nil {
+			log.Fatalf("Error looking up -dri-field: %v", err)
+		}
+	} else if *dri {
+		driFieldID, driFieldName = "assignee", "Assignee"
+	}
 
-				totalChildren += len(children)
+	var start, end time.Time
+	var jql string
+	var epics []Issue
+	var selection *KeySelection
+	if len(keys) > 0 {
+		// Fetch the listed epics, whatever their status
+		jql = keysJQL(keys)
+		if epics, selection, err = fetchKeyedEpics(client, config, keys); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// Parse dates
+		if start, end, err = resolveRange(startDate, endDate); err != nil {
+			log.Fatal(err)
+		}
 
-				// Process child tickets
-				for _, child := range children {
-					manaField := child.Fields.Unknowns["customfield_11267"]
-					manaSpent := getManaPoints(manaField)
-					if manaSpent == 0 {
-						zeroManaCount++
-						fmt.Printf("  Debug: Zero mana ticket in epic %s - %s/browse/%s\n", issue.Key, jiraURL, child.Key)
-					}
-					totalManaSpent += manaSpent
-					childManaValues = append(childManaValues, manaSpent)
-				}
+		// Create JQL query for epics with activity in the date range
+		jql = epicJQL(*projectKey, config.fieldsOf(*projectKey), start, end, *endInclusive, *eta)
 
-				childStartAt += len(children)
-				if childStartAt >= resp.Total {
-					break
-				}
-			}
+		// Validate the query before fetching anything
+		if err := validateJQL(client, jql); err != nil {
+			log.Fatal(err)
+		}
 
-			// Calculate statistics for this epic
-			avgManaPerTicket := 0.0
-			if totalChildren > 0 {
-				avgManaPerTicket = totalManaSpent / float64(totalChildren)
-			}
-			medianManaPerTicket := calculateMedian(childManaValues)
+		if epics, err = fetchEpics(client, config, jql); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-			// Update analysis
-			if _, exists := analysis[issue.Fields.Status.Name]; !exists {
-				analysis[issue.Fields.Status.Name] = &TicketAnalysis{
-					IssueType:  issue.Fields.Status.Name,
-					ManaValues: make([]float64, 0),
-				}
-			}
-			analysis[issue.Fields.Status.Name].Count++
-			analysis[issue.Fields.Status.Name].TotalMana += totalManaSpent
-			analysis[issue.Fields.Status.Name].ManaValues = append(analysis[issue.Fields.Status.Name].ManaValues, totalManaSpent)
-
-			// Store epic details for table output
-			epicDetails := struct {
-				Key              string
-				Summary          string
-				Status           string
-				TotalTickets     int
-				ZeroManaTickets  int
-				TotalMana        float64
-				AvgManaPerTicket float64
-				MedianMana       float64
-			}{
-				Key:              issue.Key,
-				Summary:          removeEmojis(issue.Fields.Summary),
-				Status:           issue.Fields.Status.Name,
-				TotalTickets:     totalChildren,
-				ZeroManaTickets:  zeroManaCount,
-				TotalMana:        totalManaSpent,
-				AvgManaPerTicket: avgManaPerTicket,
-				MedianMana:       medianManaPerTicket,
+	// Validate the child query on the first epic before fetching any children
+	if err := validateEpicChildJQL(client, childTemplate, scope, epics); err != nil {
+		log.Fatal(err)
+	}
+
+	// Search for tickets that have each epic as their epic link
+	children, err := fetchEpicChildren(client, config, os.Stdout, jiraURL, scope, childTemplate, epics)
+	if err != nil {
+		log.Fatalf("Error searching child tickets: %v", err)
+	}
+	dropAutomationChildren(os.Stdout, children, config)
+	if selection != nil {
+		// Listed epics are analyzed from their first child resolution to today
+		end = currentDay()
+		start = firstChildResolution(children, end)
+		*startDate, *endDate = start.Format("2006-01-02"), end.Format("2006-01-02")
+	}
+
+	report := analyzeEpics(epics, children, stats)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+	report.JQL = jql
+	report.ChildJQL, _ = epicChildrenJQL(childTemplate, scope, "EPIC_KEY")
+	report.Keys = selection
+	if valueFieldID != "" {
+		values, err := fetchEpicValues(client, valueFieldID, epics)
+		if err != nil {
+			log.Fatalf("Error fetching epic values: %v", err)
+		}
+		applyEpicValues(report, valueFieldName, values)
+	}
+	if driFieldID != "" {
+		dris, err := fetchEpicDRIs(client, driFieldID, epics)
+		if err != nil {
+			log.Fatalf("Error fetching epic DRIs: %v", err)
+		}
+		applyEpicDRIs(report, driFieldName, dris)
+	}
+	if *slip {
+		applyEpicSlip(report, children)
+	}
+	// Unresolved children are fetched for the ETAs of open epics, and for
+	// the child statuses of every epic
+	var remaining map[string][]Issue
+	if *eta || *childStatus {
+		var unresolvedOf []Issue
+		for _, epic := range epics {
+			if *childStatus || isOpenEpic(epic.Status, epic.Resolved) {
+				unresolvedOf = append(unresolvedOf, epic)
 			}
-			epicDetailsList = append(epicDetailsList, epicDetails)
 		}
-
-		startAt += len(issues)
-		if startAt >= resp.Total {
-			break
+		remainingTemplate, err := parseEpicChildTemplate(epicRemainingJQL)
+		if err != nil {
+			log.Fatal(err)
 		}
+		remaining, err = fetchEpicChildren(client, config, io.Discard, jiraURL, scope, remainingTemplate, unresolvedOf)
+		if err != nil {
+			log.Fatalf("Error searching unresolved child tickets: %v", err)
+		}
+		dropAutomationChildren(io.Discard, remaining, config)
 	}
-
-	// Calculate averages and medians for status analysis
-	var results []TicketAnalysis
-	for _, a := range analysis {
-		if a.Count > 0 {
-			a.AverageMana = a.TotalMana / float64(a.Count)
-			a.MedianMana = calculateMedian(a.ManaValues)
+	if *childStatus {
+		applyEpicChildStatus(report, children, remaining)
+	}
+	if *eta {
+		// Burn rates are measured up to the end of the range, or today if sooner
+		asOf := end.AddDate(0, 0, 1)
+		if today := currentDay(); today.Before(asOf) {
+			asOf = today
 		}
-		results = append(results, *a)
+		applyEpicETA(report, children, remaining, asOf)
+	}
+	if *dormancy {
+		// Dormancy is measured up to the end of the range, or today if sooner
+		asOf := end
+		if today := currentDay(); today.Before(asOf) {
+			asOf = today
+		}
+		applyEpicDormancy(report, children, asOf)
+	}
+	if *activity {
+		applyEpicActivity(report, children, start, end)
+	}
+	if *categoryMix {
+		var all []Issue
+		for _, epic := range epics {
+			all = append(all, children[epic.Key]...)
+		}
+		if err := applySecurityDepth(client, os.Stdout, all, *securityDepth, &classify); err != nil {
+			log.Fatal(err)
+		}
+		applyEpicCategoryMix(report, children, classify)
 	}
 
-	// Sort by total mana spent
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].TotalMana > results[j].TotalMana
-	})
-
-	// Sort epic details by total mana spent
-	sort.Slice(epicDetailsList, func(i, j int) bool {
-		return epicDetailsList[i].TotalMana > epicDetailsList[j].TotalMana
-	})
+	title := fmt.Sprintf("%s Epic Analysis %s", report.Project, report.periodLabel())
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeEpicReport(w, report, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
 
-	// Print header information
-	fmt.Printf("\nEpic Analysis Period: %s to %s\n", *startDate, *endDate)
-	fmt.Printf("Project: %s\n", *projectKey)
-	fmt.Printf("\nEpics JQL Query:\n%s\n", jql)
-	fmt.Printf("\nChildren JQL Query (per epic):\n" +
-		`project = "PROJECT_KEY" AND ` +
-		`"Epic Link" = "EPIC_KEY" AND ` +
-		`"Mana Spent" is not EMPTY AND ` +
-		`resolution not in ("Won't Do", "Invalid", "Duplicate")` +
-		"\n")
-
-	// Print epic details table
-	fmt.Printf("\nEpic Details:\n")
-	fmt.Printf("%-15s %-60s %-15s %-15s %-20s %-15s %-15s %-15s\n",
-		"Epic Key",
-		"Summary",
-		"Status",
-		"Total Tickets",
-		"Zero Mana Tickets",
-		"Total Mana",
-		"Avg Mana/Ticket",
-		"Median Mana")
-	fmt.Println(strings.Repeat("-", 185))
-
-	for _, epic := range epicDetailsList {
-		fmt.Printf("%-15s %-60s %-15s %-15d %-20d %-15.2f %-15.2f %-15.2f\n",
-			epic.Key,
-			epic.Summary,
-			epic.Status,
-			epic.TotalTickets,
-			epic.ZeroManaTickets,
-			epic.TotalMana,
-			epic.AvgManaPerTicket,
-			epic.MedianMana)
+	if *icsFile != "" {
+		events := epicCalendarEvents(report, jiraURL, start, end, *icsMilestones)
+		f, err := os.Create(*icsFile)
+		if err != nil {
+			log.Fatalf("Error writing calendar: %v", err)
+		}
+		if err := writeCalendar(f, events, time.Now()); err != nil {
+			f.Close()
+			log.Fatalf("Error writing calendar: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Error writing calendar: %v", err)
+		}
+		fmt.Printf("\nCalendar with %d events written to %s\n", len(events), *icsFile)
 	}
 }
 
 func main() {
+	// Errors and warnings never show credentials
+	log.SetOutput(newSecretScrubber(os.Stderr, os.Getenv))
+	if err := parseGlobalFlags(); err != nil {
+		log.Fatal(err)
+	}
 	if len(os.Args) < 2 {
-		fmt.Println("Expected subcommand: ticket or epic")
+		fmt.Println("Expected subcommand: ticket, epic, epic-diff, orphans, labels, sizes, balance, churn, calibrate, compare-projects, close-quarter, security, metric, incidents, index, init, self-update, selftest, demo or convert-json")
 		os.Exit(1)
 	}
 
@@ -722,8 +1068,80 @@ func main() {
 		// Remove the "epic" subcommand from os.Args
 		os.Args = append(os.Args[:1], os.Args[2:]...)
 		runEpicCommand()
+	case "epic-diff":
+		// Remove the "epic-diff" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runEpicDiffCommand()
+	case "orphans":
+		// Remove the "orphans" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runOrphansCommand()
+	case "labels":
+		// Remove the "labels" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runLabelsCommand()
+	case "sizes":
+		// Remove the "sizes" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runSizesCommand()
+	case "balance":
+		// Remove the "balance" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runBalanceCommand()
+	case "churn":
+		// Remove the "churn" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runChurnCommand()
+	case "calibrate":
+		// Remove the "calibrate" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runCalibrateCommand()
+	case "compare-projects":
+		// Remove the "compare-projects" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runCompareProjectsCommand()
+	case "close-quarter":
+		// Remove the "close-quarter" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runCloseQuarterCommand()
+	case "security":
+		// Remove the "security" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runSecurityCommand()
+	case "metric":
+		// Remove the "metric" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runMetricCommand()
+	case "incidents":
+		// Remove the "incidents" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runIncidentsCommand()
+	case "index":
+		// Remove the "index" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runIndexCommand()
+	case "init":
+		// Remove the "init" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runInitCommand()
+	case "self-update":
+		// Remove the "self-update" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runSelfUpdateCommand()
+	case "selftest":
+		// Remove the "selftest" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runSelftestCommand()
+	case "demo":
+		// Remove the "demo" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runDemoCommand()
+	case "convert-json":
+		// Remove the "convert-json" subcommand from os.Args
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		runConvertJSONCommand()
 	default:
-		fmt.Println("Expected subcommand: ticket or epic")
+		fmt.Println("Expected subcommand: ticket, epic, epic-diff, orphans, labels, sizes, balance, churn, calibrate, compare-projects, close-quarter, security, metric, incidents, index, init, self-update, selftest, demo or convert-json")
 		os.Exit(1)
 	}
 }
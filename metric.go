@@ -0,0 +1,457 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricConfig defines a report of the metric command: the issues of a base
+// query, grouped by a field, with a statistic over a value of each issue
+type MetricConfig struct {
+	Description string `json:"description"`
+	// JQL selects the issues; with -start and -end the resolution date range
+	// is added to it
+	JQL string `json:"jql"`
+	// GroupBy is one of metricGroupBys, empty for a single total
+	GroupBy string `json:"groupBy"`
+	// Value is mana (the default), count, or the ID of a numeric custom field
+	Value string `json:"value"`
+	// Statistic is a statistic key of -stats, e.g. sum (the default) or p90
+	Statistic string `json:"statistic"`
+	// Label names the value in the column header, default Mana or the field ID
+	Label string `json:"label"`
+}
+
+// Values of MetricConfig.Value besides custom field IDs
+const (
+	metricValueMana  = "mana"
+	metricValueCount = "count"
+)
+
+// metricGroupBys are the fields metrics can be grouped by
+var metricGroupBys = []string{"team", "group", "org", "type", "status", "priority", "assignee", "label", "month"}
+
+// metricFields are the fields the metric command reads besides the value
+var metricFields = []string{"issuetype", "summary", "status", "priority", "assignee", "labels", "resolutiondate", manaFieldID, teamFieldID, "reporter"}
+
+// checkMetricConfigs checks the metrics of a config file
+func checkMetricConfigs(metrics map[string]MetricConfig) error {
+	for name, m := range metrics {
+		if name == "" || strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("metrics: %q is not a metric name, use names like bug-mana-by-team", name)
+		}
+		if strings.TrimSpace(m.JQL) == "" {
+			return fmt.Errorf("metrics: %s has no jql", name)
+		}
+		if m.GroupBy != "" && !containsString(metricGroupBys, m.GroupBy) {
+			return fmt.Errorf("metrics: %s: unknown groupBy %q, expected one of %s", name, m.GroupBy, strings.Join(metricGroupBys, ", "))
+		}
+		switch m.Value {
+		case "", metricValueMana, metricValueCount:
+		default:
+			if !customFieldIDRegex.MatchString(m.Value) {
+				return fmt.Errorf("metrics: %s: value %q is not mana, count or a custom field ID like customfield_12345", name, m.Value)
+			}
+		}
+		if _, err := m.statistic(); err != nil {
+			return fmt.Errorf("metrics: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// containsString reports whether the list contains s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of the metric, mana if none is set
+func (m MetricConfig) value() string {
+	if m.Value == "" {
+		return metricValueMana
+	}
+	return m.Value
+}
+
+// statistic returns the statistic of the metric, sum if none is set
+func (m MetricConfig) statistic() (Statistic, error) {
+	if m.Statistic == "" {
+		return lookupStatistic("sum")
+	}
+	return lookupStatistic(strings.ToLower(m.Statistic))
+}
+
+// column returns the header of the value column, e.g. "Total Mana" or
+// "P90 Story Points"
+func (m MetricConfig) column(stat Statistic) string {
+	label := m.Label
+	if label == "" {
+		switch m.value() {
+		case metricValueMana:
+			return stat.Column
+		case metricValueCount:
+			label = "Issues"
+		default:
+			label = m.Value
+		}
+	}
+	if stat.Key == "count" {
+		return stat.Column
+	}
+	return strings.Replace(stat.Column, "Mana", label, 1)
+}
+
+// metricGroups returns the groups of an issue for the groupBy. Issues with
+// several labels are in the group of each.
+func metricGroups(groupBy string, chart []OrgGroup) func(issue Issue) []string {
+	orDefault := func(s, none string) []string {
+		if s == "" {
+			return []string{none}
+		}
+		return []string{s}
+	}
+	group, org := orgChartDimensions(chart)
+	switch groupBy {
+	case "team":
+		return func(issue Issue) []string { return []string{teamDimension.Group(issue)} }
+	case "group":
+		return func(issue Issue) []string { return []string{group.Group(issue)} }
+	case "org":
+		return func(issue Issue) []string { return []string{org.Group(issue)} }
+	case "type":
+		return func(issue Issue) []string { return orDefault(issue.Type, "No Type") }
+	case "status":
+		return func(issue Issue) []string { return orDefault(issue.Status, "No Status") }
+	case "priority":
+		return func(issue Issue) []string { return orDefault(issue.Priority, "No Priority") }
+	case "assignee":
+		return func(issue Issue) []string { return orDefault(issue.Owner, "Unassigned") }
+	case "label":
+		return func(issue Issue) []string {
+			if len(issue.Labels) == 0 {
+				return []string{"No Label"}
+			}
+			return issue.Labels
+		}
+	case "month":
+		return func(issue Issue) []string {
+			if issue.Resolved.IsZero() {
+				return []string{"Unresolved"}
+			}
+			return []string{issue.Resolved.Format(monthLabelFormat)}
+		}
+	}
+	return func(issue Issue) []string { return []string{"All"} }
+}
+
+// metricFieldValue reads a numeric field value, which Jira returns as a
+// number, or as a string or option for text and select fields
+func metricFieldValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	case map[string]interface{}:
+		if s, ok := v["value"].(string); ok {
+			return metricFieldValue(s)
+		}
+	}
+	return 0, false
+}
+
+// MetricGroup is the statistic over the issues of one group of a metric
+type MetricGroup struct {
+	Name    string
+	Issues  int
+	Value   float64
+	NoValue int // Issues without a value, left out of the statistic
+	values  []float64
+}
+
+// MetricReport is the data model of the metric command
+type MetricReport struct {
+	Name         string
+	Description  string
+	JQL          string
+	Start        string // Empty without a date range
+	End          string
+	EndInclusive bool
+	GroupBy      string
+	Column       string
+	Groups       []MetricGroup // Highest value first, months in order
+	Total        MetricGroup
+}
+
+// analyzeMetric groups the issues and computes the statistic of every group
+// over the values, keyed by issue key. Issues without a value count as
+// issues but are left out of the statistic.
+func analyzeMetric(issues []Issue, values map[string]float64, metric MetricConfig, chart []OrgGroup) (*MetricReport, error) {
+	stat, err := metric.statistic()
+	if err != nil {
+		return nil, err
+	}
+	report := &MetricReport{
+		Description: metric.Description,
+		GroupBy:     metric.GroupBy,
+		Column:      metric.column(stat),
+		Total:       MetricGroup{Name: "TOTAL"},
+	}
+	groupsOf := metricGroups(metric.GroupBy, chart)
+	groups := make(map[string]*MetricGroup)
+	var order []string
+	for _, issue := range issues {
+		v, ok := values[issue.Key]
+		sums := []*MetricGroup{&report.Total}
+		for _, name := range groupsOf(issue) {
+			g := groups[name]
+			if g == nil {
+				g = &MetricGroup{Name: name}
+				groups[name] = g
+				order = append(order, name)
+			}
+			sums = append(sums, g)
+		}
+		for _, g := range sums {
+			g.Issues++
+			if ok {
+				g.values = append(g.values, v)
+			} else {
+				g.NoValue++
+			}
+		}
+	}
+
+	report.Total.Value = stat.Compute(report.Total.values)
+	for _, name := range order {
+		g := groups[name]
+		g.Value = stat.Compute(g.values)
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if metric.GroupBy == "month" {
+			ta, erra := time.Parse(monthLabelFormat, a.Name)
+			tb, errb := time.Parse(monthLabelFormat, b.Name)
+			if erra != nil || errb != nil {
+				// Unresolved issues come last
+				return erra == nil
+			}
+			return ta.Before(tb)
+		}
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// metricValues reads the value of the metric of every fetched issue, by
+// issue key. Issues without a value are left out.
+func metricValues(issues []Issue, raw map[string]interface{}, metric MetricConfig) map[string]float64 {
+	values := make(map[string]float64)
+	for _, issue := range issues {
+		switch metric.value() {
+		case metricValueMana:
+			if issue.ManaSet {
+				values[issue.Key] = issue.Mana
+			}
+		case metricValueCount:
+			values[issue.Key] = 1
+		default:
+			if v, ok := metricFieldValue(raw[issue.Key]); ok {
+				values[issue.Key] = v
+			}
+		}
+	}
+	return values
+}
+
+// writeMetricReport writes the metric report as text
+func writeMetricReport(w io.Writer, report *MetricReport, layout tableOptions) {
+	fmt.Fprintf(w, "Metric: %s\n", report.Name)
+	if report.Description != "" {
+		fmt.Fprintf(w, "%s\n", report.Description)
+	}
+	if report.Start != "" {
+		fmt.Fprintf(w, "Analysis Period: %s to %s\n", report.Start, report.End)
+		fmt.Fprintf(w, "%s\n", endBoundaryNote(report.End, report.EndInclusive))
+	}
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n\n", report.JQL)
+
+	header := "Group"
+	if report.GroupBy != "" {
+		header = strings.ToUpper(report.GroupBy[:1]) + report.GroupBy[1:]
+	}
+	table := newTextTable(
+		tableColumn{Header: header, MaxWidth: 40},
+		tableColumn{Header: "Issues", Right: true},
+		tableColumn{Header: report.Column, Right: true},
+	)
+	for _, g := range report.Groups {
+		if report.GroupBy == "" {
+			// The total is the only group
+			break
+		}
+		table.addRow(g.Name, layout.Numbers.count(g.Issues), layout.Numbers.decimal(g.Value))
+	}
+	table.addFooter(report.Total.Name, layout.Numbers.count(report.Total.Issues), layout.Numbers.decimal(report.Total.Value))
+	table.write(w, layout.Style)
+
+	if report.Total.NoValue > 0 {
+		fmt.Fprintf(w, "  Issues without a value, left out of %s: %s\n", report.Column, layout.Numbers.count(report.Total.NoValue))
+	}
+}
+
+// writeMetricList lists the metrics of the config
+func writeMetricList(w io.Writer, metrics map[string]MetricConfig) {
+	if len(metrics) == 0 {
+		fmt.Fprintln(w, "No metrics defined, add them under \"metrics\" in the config file")
+		return
+	}
+	var names []string
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Metrics defined in the config file:")
+	for _, name := range names {
+		if d := metrics[name].Description; d != "" {
+			fmt.Fprintf(w, "  %s: %s\n", name, d)
+		} else {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
+
+func runMetricCommand() {
+	// The metric name comes before the flags
+	var name string
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		name = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD), to add a resolution date range to the metric's query")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	configPath := flag.String("config", "", configUsage)
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	flag.Parse()
+	if name == "" && flag.NArg() > 0 {
+		name = flag.Arg(0)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate flags
+	if name == "" {
+		fmt.Println("Usage: theia metric <name> [flags]")
+		writeMetricList(os.Stdout, config.Metrics)
+		os.Exit(1)
+	}
+	metric, ok := config.Metrics[name]
+	if !ok {
+		writeMetricList(os.Stderr, config.Metrics)
+		log.Fatalf("No metric %q in the config file", name)
+	}
+	if (*startDate == "") != (*endDate == "") {
+		log.Fatal("-start and -end must be given together")
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jql := strings.TrimSpace(metric.JQL)
+	if *startDate != "" {
+		start, end, err := resolveRange(startDate, endDate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// ORDER BY must stay at the end of the query
+		clause, order := jql, ""
+		if i := strings.LastIndex(strings.ToUpper(jql), "ORDER BY"); i >= 0 {
+			clause, order = strings.TrimSpace(jql[:i]), "\n\t\t"+jql[i:]
+		}
+		jql = fmt.Sprintf("(%s) AND\n\t\t%s%s", clause, resolvedBetween(start, end, *endInclusive), order)
+	}
+
+	// Create JIRA client from the environment
+	client, _, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	fields := metricFields
+	if v := metric.value(); v != metricValueMana && v != metricValueCount {
+		fields = append(append([]string{}, metricFields...), v)
+	}
+	// The query may span projects, so each issue is read with the fields of
+	// its own project
+	pages, err := fetchAllPages(client, jql, config.requestAll(fields))
+	if err != nil {
+		log.Fatalf("Error fetching issues: %v", err)
+	}
+	jiraIssues, err := decodePages(pages)
+	if err != nil {
+		log.Fatalf("Error fetching issues: %v", err)
+	}
+	config.normalizeByProject(jiraIssues)
+	raw := make(map[string]interface{})
+	for _, ji := range jiraIssues {
+		if ji.Fields != nil && ji.Fields.Unknowns != nil {
+			raw[ji.Key] = ji.Fields.Unknowns[metric.Value]
+		}
+	}
+	issues := dropAutomationIssues(os.Stdout, issuesFromJira(jiraIssues), config)
+
+	report, err := analyzeMetric(issues, metricValues(issues, raw, metric), metric, config.OrgChart)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report.Name = name
+	report.JQL = jql
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+
+	title := fmt.Sprintf("Metric %s", name)
+	if report.Start != "" {
+		title = fmt.Sprintf("Metric %s %s to %s", name, report.Start, report.End)
+	}
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeMetricReport(w, report, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// monthlyFetchWorkers is the number of months fetched from Jira at once
+const monthlyFetchWorkers = 4
+
+// monthQuery is the query selecting the issues resolved in one month of the range
+type monthQuery struct {
+	Month  time.Time
+	Filter string // JQL without its ORDER BY
+	JQL    string
+	// Complete is set when the month is entirely in the past, so its
+	// results can no longer change by resolving more issues
+	Complete bool
+}
+
+// planMonthlyQueries splits the range into one query per calendar month.
+// Inner months are bounded by the month itself rather than the range, so
+// their queries stay the same when the range is extended.
+func planMonthlyQueries(projectKey string, own projectFields, start, end time.Time, inclusive bool) []monthQuery {
+	today := currentDay()
+
+	var queries []monthQuery
+	months := monthsInRange(start, end)
+	for i, current := range months {
+		next := current.AddDate(0, 1, 0)
+
+		from := current
+		if start.After(from) {
+			from = start
+		}
+
+		// The last month keeps the range's own end bound
+		toClause := fmt.Sprintf(`resolutiondate < "%s"`, next.Format("2006-01-02"))
+		upper := next
+		if i == len(months)-1 && inclusive {
+			toClause = fmt.Sprintf(`resolutiondate < "%s"`, end.AddDate(0, 0, 1).Format("2006-01-02"))
+			upper = end
+		} else if i == len(months)-1 {
+			toClause = fmt.Sprintf(`resolutiondate <= "%s"`, end.Format("2006-01-02"))
+			upper = end
+		}
+
+		filter := projectTicketJQLFilter(projectKey, fmt.Sprintf(`resolutiondate >= "%s" AND
+		%s`, from.Format("2006-01-02"), toClause), own)
+		queries = append(queries, monthQuery{
+			Month:  current,
+			Filter: filter,
+			JQL: filter + `
+		ORDER BY created DESC`,
+			Complete: upper.Before(today),
+		})
+	}
+	return queries
+}
+
+// monthValidator returns what tells whether the issues of a month changed
+// since they were cached: their number, which drops when an issue leaves the
+// month, and their latest update, which moves whenever one is edited, e.g.
+// its mana, team or resolution, or joins the month. Both come from a single
+// one-issue search.
+func monthValidator(client *jira.Client, filter string) (string, error) {
+	raw, err := searchRawPage(client, filter+`
+		ORDER BY updated DESC`, []string{"updated"}, 0, 1)
+	if err != nil {
+		return "", err
+	}
+	var page struct {
+		Total  int `json:"total"`
+		Issues []struct {
+			Fields struct {
+				Updated string `json:"updated"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return "", fmt.Errorf("decoding search results: %w", err)
+	}
+	latest := ""
+	if len(page.Issues) > 0 {
+		latest = page.Issues[0].Fields.Updated
+	}
+	return fmt.Sprintf("%d issues, last updated %s", page.Total, latest), nil
+}
+
+// fetchMonthlyIssues runs the per-month queries in parallel and returns the
+// issues of each month in month order. Complete months are read from and
+// stored in the cache independently of each other, and a cached month is
+// only used while its validator is unchanged, so edits made in Jira since
+// are fetched. Each month is written to the stream as soon as it is fetched.
+// The project's own fields are requested and read as the standard ones.
+func fetchMonthlyIssues(client *jira.Client, cache *issueCache, projectKey string, own projectFields, start, end time.Time, inclusive bool, fields []string, stream *issueStream) ([][]jira.Issue, error) {
+	queries := planMonthlyQueries(projectKey, own, start, end, inclusive)
+	fields = own.request(fields)
+
+	type monthResult struct {
+		issues []jira.Issue
+		cached bool
+		stale  bool // Set if the month was cached but changed since
+		err    error
+	}
+	results := make([]monthResult, len(queries))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, monthlyFetchWorkers)
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q monthQuery) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// The validator is read before the issues, so edits made while
+			// fetching show up as a change next time
+			key := cacheKey(q.JQL, fields)
+			var pages []json.RawMessage
+			var validator string
+			cached := false
+			if q.Complete && cache != nil {
+				var err error
+				if validator, err = monthValidator(client, q.Filter); err != nil {
+					results[i].err = err
+					return
+				}
+				if entry, ok := cache.load(key); ok {
+					cached = entry.Validator == validator
+					results[i].stale = !cached
+					pages = entry.Pages
+				}
+			}
+			if !cached {
+				var err error
+				pages, err = fetchAllPages(client, q.JQL, fields)
+				if err != nil {
+					results[i].err = err
+					return
+				}
+				if q.Complete {
+					cache.store(key, validator, pages)
+				}
+			}
+
+			issues, err := decodePages(pages)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			own.normalize(issues)
+			results[i].issues = issues
+			results[i].cached = cached
+			stream.write(issuesFromJira(issues))
+		}(i, q)
+	}
+	wg.Wait()
+
+	months := make([][]jira.Issue, len(queries))
+	for i, r := range results {
+		if r.err != nil {
+			return nil, fmt.Errorf("%s: %w", queries[i].Month.Format("January 2006"), r.err)
+		}
+		source := "fetched"
+		if r.cached {
+			source = "cached"
+		} else if r.stale {
+			source = "fetched, changed since cached"
+		}
+		fmt.Printf("%s: %d issues (%s)\n", queries[i].Month.Format("January 2006"), len(r.issues), source)
+		months[i] = r.issues
+	}
+	return months, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Adaptive Card elements, as documented at https://adaptivecards.io/explorer/
+type (
+	adaptiveCard struct {
+		Schema  string                 `json:"$schema"`
+		Type    string                 `json:"type"`
+		Version string                 `json:"version"`
+		Body    []interface{}          `json:"body"`
+		Actions []adaptiveAction       `json:"actions,omitempty"`
+		MSTeams map[string]interface{} `json:"msteams,omitempty"`
+	}
+	adaptiveText struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Size     string `json:"size,omitempty"`
+		Weight   string `json:"weight,omitempty"`
+		IsSubtle bool   `json:"isSubtle,omitempty"`
+		Wrap     bool   `json:"wrap,omitempty"`
+	}
+	adaptiveTable struct {
+		Type              string          `json:"type"`
+		Columns           []adaptiveWidth `json:"columns"`
+		Rows              []adaptiveRow   `json:"rows"`
+		FirstRowAsHeaders bool            `json:"firstRowAsHeaders"`
+	}
+	adaptiveWidth struct {
+		Width int `json:"width"`
+	}
+	adaptiveRow struct {
+		Type  string         `json:"type"`
+		Cells []adaptiveCell `json:"cells"`
+	}
+	adaptiveCell struct {
+		Type  string         `json:"type"`
+		Items []adaptiveText `json:"items"`
+	}
+	adaptiveAction struct {
+		Type  string `json:"type"`
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+)
+
+// teamsMessage is the payload of a Microsoft Teams incoming webhook
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+// adaptiveTableRow builds a table row of text cells, in bold if header is set
+func adaptiveTableRow(header bool, cells ...string) adaptiveRow {
+	row := adaptiveRow{Type: "TableRow"}
+	for _, c := range cells {
+		text := adaptiveText{Type: "TextBlock", Text: c, Wrap: true}
+		if header {
+			text.Weight = "Bolder"
+		}
+		row.Cells = append(row.Cells, adaptiveCell{Type: "TableCell", Items: []adaptiveText{text}})
+	}
+	return row
+}
+
+// renderTeamsCard renders the summary table of the report as an Adaptive
+// Card message, linking to the full report if reportURL is set
+func renderTeamsCard(report *Report, reportURL string) ([]byte, error) {
+	table := adaptiveTable{
+		Type:              "Table",
+		Columns:           []adaptiveWidth{{3}, {1}, {2}, {2}},
+		FirstRowAsHeaders: true,
+		Rows:              []adaptiveRow{adaptiveTableRow(true, "Issue Type", "Count", "Total Mana", "% of Total")},
+	}
+	for _, r := range report.Summary.Results {
+		var percent float64
+		if report.Summary.TotalMana > 0 {
+			percent = r.TotalMana / report.Summary.TotalMana * 100
+		}
+		table.Rows = append(table.Rows, adaptiveTableRow(false,
+			r.IssueType,
+			fmt.Sprintf("%d", r.Count),
+			fmt.Sprintf("%.2f", r.TotalMana),
+			fmt.Sprintf("%.1f%%", percent)))
+	}
+	table.Rows = append(table.Rows, adaptiveTableRow(true,
+		"TOTAL",
+		fmt.Sprintf("%d", report.Summary.TotalCount),
+		fmt.Sprintf("%.2f", report.Summary.TotalMana),
+		"100.0%"))
+
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.5",
+		Body: []interface{}{
+			adaptiveText{Type: "TextBlock", Text: fmt.Sprintf("Mana Analysis: %s", report.Project), Size: "Large", Weight: "Bolder"},
+			adaptiveText{Type: "TextBlock", Text: report.periodLabel(), IsSubtle: true},
+			table,
+			adaptiveText{Type: "TextBlock", Text: fmt.Sprintf("Zero Mana Tickets: %d", report.Summary.ZeroManaCount), IsSubtle: true},
+		},
+		MSTeams: map[string]interface{}{"width": "Full"},
+	}
+	if reportURL != "" {
+		card.Actions = []adaptiveAction{{Type: "Action.OpenUrl", Title: "Open full report", URL: reportURL}}
+	}
+
+	return json.MarshalIndent(teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}, "", "  ")
+}
+
+// sendTeamsWebhook posts the report as an Adaptive Card to a Microsoft Teams
+// incoming webhook
+func sendTeamsWebhook(url string, report *Report, reportURL string) error {
+	payload, err := renderTeamsCard(report, reportURL)
+	if err != nil {
+		return err
+	}
+	return postJSON(url, payload)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// orphanFields are the fields the orphans command reads
+var orphanFields = []string{"issuetype", "summary", manaFieldID, "resolutiondate", teamFieldID, epicLinkFieldID, "parent", "reporter"}
+
+// OrphanTeam is the epic-less work of one team
+type OrphanTeam struct {
+	Team         string
+	Issues       int
+	Mana         float64
+	OrphanIssues int // Issues with neither an Epic Link nor a parent
+	OrphanMana   float64
+}
+
+// orphanShare returns the share of the team's mana spent on epic-less issues
+func (t OrphanTeam) orphanShare() float64 {
+	if t.Mana == 0 {
+		return 0
+	}
+	return t.OrphanMana / t.Mana * 100
+}
+
+// OrphanReport is the data model of the orphans command
+type OrphanReport struct {
+	Project string
+	Start   string
+	End     string
+	JQL     string
+	Teams   []OrphanTeam // Most epic-less mana first
+	Total   OrphanTeam
+	Orphans []Issue // By team, then most mana first
+	// EndInclusive is set if tickets resolved at any time on the end date count
+	EndInclusive bool
+}
+
+// analyzeOrphans finds the issues with neither an Epic Link nor a parent and
+// sums them up per team
+func analyzeOrphans(issues []Issue) *OrphanReport {
+	report := &OrphanReport{Total: OrphanTeam{Team: "TOTAL"}}
+	teams := make(map[string]*OrphanTeam)
+	for _, issue := range issues {
+		name := teamDimension.Group(issue)
+		t := teams[name]
+		if t == nil {
+			t = &OrphanTeam{Team: name}
+			teams[name] = t
+		}
+		for _, sum := range []*OrphanTeam{t, &report.Total} {
+			sum.Issues++
+			sum.Mana += issue.Mana
+			if issue.Parent == "" {
+				sum.OrphanIssues++
+				sum.OrphanMana += issue.Mana
+			}
+		}
+		if issue.Parent == "" {
+			report.Orphans = append(report.Orphans, issue)
+		}
+	}
+
+	for _, t := range teams {
+		report.Teams = append(report.Teams, *t)
+	}
+	sort.Slice(report.Teams, func(i, j int) bool {
+		if report.Teams[i].OrphanMana != report.Teams[j].OrphanMana {
+			return report.Teams[i].OrphanMana > report.Teams[j].OrphanMana
+		}
+		return report.Teams[i].Team < report.Teams[j].Team
+	})
+	sort.SliceStable(report.Orphans, func(i, j int) bool {
+		a, b := report.Orphans[i], report.Orphans[j]
+		if ta, tb := teamDimension.Group(a), teamDimension.Group(b); ta != tb {
+			return ta < tb
+		}
+		if a.Mana != b.Mana {
+			return a.Mana > b.Mana
+		}
+		return a.Key < b.Key
+	})
+	return report
+}
+
+// writeEpicLessWork writes the ticket report line on work outside any epic
+func writeEpicLessWork(w io.Writer, e *EpicLessWork, summaryMana float64, layout tableOptions) {
+	share := 0.0
+	if summaryMana > 0 {
+		share = e.Mana / summaryMana * 100
+	}
+	fmt.Fprintf(w, "  Epic-less Tickets: %s (%s, %.1f%% of all mana)\n",
+		layout.Numbers.count(e.Issues), layout.Numbers.manaAmount(e.Mana), share)
+}
+
+// writeOrphanReport writes the epic-less work per team and the list of
+// epic-less issues in the layout
+func writeOrphanReport(w io.Writer, report *OrphanReport, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "\nEpic-less Work: %s to %s\n", report.Start, report.End)
+	fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	teamTable := newTextTable(
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Epic-less Tickets", Right: true},
+		tableColumn{Header: "Epic-less Mana", Right: true},
+		tableColumn{Header: "% of Team Mana", Right: true},
+		tableColumn{Header: "All Tickets", Right: true},
+		tableColumn{Header: "All Mana", Right: true},
+	)
+	row := func(t OrphanTeam) []string {
+		return []string{t.Team,
+			layout.Numbers.count(t.OrphanIssues),
+			layout.Numbers.mana(t.OrphanMana),
+			fmt.Sprintf("%.1f%%", t.orphanShare()),
+			layout.Numbers.count(t.Issues),
+			layout.Numbers.mana(t.Mana)}
+	}
+	for _, t := range report.Teams {
+		teamTable.addRow(row(t)...)
+	}
+	teamTable.addFooter(row(report.Total)...)
+	fmt.Fprintf(w, "\nEpic-less Work by Team:\n")
+	teamTable.write(w, layout.Style)
+
+	fmt.Fprintf(w, "\nEpic-less Tickets: %d\n", len(report.Orphans))
+	if len(report.Orphans) == 0 {
+		return
+	}
+	issueTable := newTextTable(
+		tableColumn{Header: "Key"},
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Issue Type"},
+		tableColumn{Header: "Mana", Right: true},
+		tableColumn{Header: "Resolved"},
+		tableColumn{Header: "Link"},
+		tableColumn{Header: "Summary", MaxWidth: 60},
+	)
+	for _, issue := range report.Orphans {
+		issueTable.addRow(issue.Key,
+			teamDimension.Group(issue),
+			issue.Type,
+			layout.Numbers.mana(issue.Mana),
+			issue.Resolved.Format("2006-01-02"),
+			fmt.Sprintf("%s/browse/%s", jiraURL, issue.Key),
+			removeEmojis(issue.Summary))
+	}
+	issueTable.write(w, layout.Style)
+}
+
+func runOrphansCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The tickets of the ticket report, with their epic and parent
+	own := config.fieldsOf(*projectKey)
+	jql := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, *endInclusive), own) + `
+		ORDER BY created DESC`
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := own.fetchIssues(client, jql, orphanFields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	issues = dropAutomationIssues(os.Stdout, issues, config)
+
+	report := analyzeOrphans(issues)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+	report.JQL = jql
+
+	title := fmt.Sprintf("%s Epic-less Work %s to %s", report.Project, report.Start, report.End)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeOrphanReport(w, report, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
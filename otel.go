@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpHeadersEnv names the headers OTLP exporters send with every request,
+// as comma-separated key=value pairs, usually for the collector's API key
+const otlpHeadersEnv = "OTEL_EXPORTER_OTLP_HEADERS"
+
+// OTLP/HTTP JSON metrics, as documented at
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. 64-bit
+// integers are strings, as in the protobuf JSON mapping.
+type (
+	otlpMetricsRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	otlpMetric struct {
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Unit        string    `json:"unit"`
+		Gauge       otlpGauge `json:"gauge"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpDataPoint struct {
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		TimeUnixNano string          `json:"timeUnixNano"`
+		AsDouble     *float64        `json:"asDouble,omitempty"`
+		AsInt        string          `json:"asInt,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// otlpString is a string attribute
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+// renderOTLPMetrics renders the run-level gauges of the report: mana and
+// issues per category, per team and category if teams were broken down, and
+// how long the run took
+func renderOTLPMetrics(report *Report, duration time.Duration, now time.Time) ([]byte, error) {
+	stamp := strconv.FormatInt(now.UnixNano(), 10)
+	mana := otlpMetric{Name: "theia.mana", Description: "Mana spent on resolved issues", Unit: "{mana}"}
+	issues := otlpMetric{Name: "theia.issues", Description: "Resolved issues", Unit: "{issue}"}
+
+	// The overall gauges come first, then with -teams those of every team
+	sections := []ReportSection{report.Summary}
+	if report.Breakdown == "team" {
+		sections = append(sections, report.Sections...)
+	}
+	for i, section := range sections {
+		for _, r := range section.Results {
+			attributes := []otlpAttribute{otlpString("category", r.IssueType)}
+			if i > 0 {
+				attributes = append([]otlpAttribute{otlpString("team", section.Title)}, attributes...)
+			}
+			value := r.TotalMana
+			mana.Gauge.DataPoints = append(mana.Gauge.DataPoints, otlpDataPoint{Attributes: attributes, TimeUnixNano: stamp, AsDouble: &value})
+			issues.Gauge.DataPoints = append(issues.Gauge.DataPoints, otlpDataPoint{Attributes: attributes, TimeUnixNano: stamp, AsInt: strconv.Itoa(r.Count)})
+		}
+	}
+	seconds := duration.Seconds()
+	runDuration := otlpMetric{Name: "theia.run.duration", Description: "Time the run took to fetch and analyze the issues", Unit: "s",
+		Gauge: otlpGauge{DataPoints: []otlpDataPoint{{TimeUnixNano: stamp, AsDouble: &seconds}}}}
+
+	// The period is on the resource, so every gauge of the run carries it
+	resource := []otlpAttribute{
+		otlpString("service.name", "theia"),
+		otlpString("theia.project", report.Project),
+		otlpString("theia.period.start", report.Start),
+		otlpString("theia.period.end", report.End),
+	}
+	return json.Marshal(otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/jwilander/theia", Version: version},
+			Metrics: []otlpMetric{mana, issues, runDuration},
+		}},
+	}}})
+}
+
+// otlpMetricsURL returns the metrics URL of an OTLP/HTTP endpoint. A base
+// URL like http://collector:4318 gets the /v1/metrics path, as with
+// OTEL_EXPORTER_OTLP_ENDPOINT; a URL with a path is used as it is.
+func otlpMetricsURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid -otlp-endpoint %q, expected a URL like http://localhost:4318", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	return u.String(), nil
+}
+
+// otlpHeaders parses OTEL_EXPORTER_OTLP_HEADERS, whose values are URL
+// encoded
+func otlpHeaders(s string) (http.Header, error) {
+	header := make(http.Header)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s: expected key=value pairs, got %q", otlpHeadersEnv, strings.TrimSpace(pair))
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", otlpHeadersEnv, key, err)
+		}
+		header.Set(key, value)
+	}
+	return header, nil
+}
+
+// exportOTLPMetrics pushes the gauges of the report to an OpenTelemetry
+// collector over OTLP/HTTP
+func exportOTLPMetrics(endpoint string, report *Report, duration time.Duration) error {
+	metricsURL, err := otlpMetricsURL(endpoint)
+	if err != nil {
+		return err
+	}
+	header, err := otlpHeaders(os.Getenv(otlpHeadersEnv))
+	if err != nil {
+		return err
+	}
+	payload, err := renderOTLPMetrics(report, duration, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, metricsURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint returned %s: %s", resp.Status, string(body))
+	}
+	return nil
+}
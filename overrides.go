@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// overridesUsage documents the -overrides flag of the report commands
+const overridesUsage = "CSV or JSON file correcting the mana or category of listed issues, applied without changing Jira"
+
+// IssueOverride corrects the mana or category of one issue, for known
+// misestimates and misfiled tickets
+type IssueOverride struct {
+	Key      string   `json:"key"`
+	Mana     *float64 `json:"mana"`     // nil keeps the Mana Spent of Jira
+	Category string   `json:"category"` // Empty keeps the classification
+	Reason   string   `json:"reason"`
+}
+
+// issueOverrides are the overrides of a file, by issue key
+type issueOverrides map[string]IssueOverride
+
+// loadOverrides reads an overrides file, as CSV or JSON depending on its
+// extension. CSV files have a header row naming the key, mana, category and
+// optional reason columns; JSON files are a list of overrides.
+func loadOverrides(path string) (issueOverrides, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading overrides: %w", err)
+	}
+	return parseOverrides(path, b)
+}
+
+// parseOverrides parses and checks the overrides read from path
+func parseOverrides(path string, b []byte) (issueOverrides, error) {
+	var err error
+	var list []IssueOverride
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		list, err = parseOverridesCSV(b)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&list)
+	default:
+		return nil, fmt.Errorf("-overrides must be a .csv or .json file, got %q", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid overrides: %w", path, err)
+	}
+
+	overrides := make(issueOverrides, len(list))
+	for _, o := range list {
+		o.Key = strings.ToUpper(strings.TrimSpace(o.Key))
+		o.Category = strings.TrimSpace(o.Category)
+		if !issueKeyRegex.MatchString(o.Key) {
+			return nil, fmt.Errorf("%s: %q is not an issue key like PROJ-123", path, o.Key)
+		}
+		if _, ok := overrides[o.Key]; ok {
+			return nil, fmt.Errorf("%s: %s is overridden twice", path, o.Key)
+		}
+		if o.Mana == nil && o.Category == "" {
+			return nil, fmt.Errorf("%s: %s overrides nothing, set its mana or category", path, o.Key)
+		}
+		if o.Mana != nil && *o.Mana < 0 {
+			return nil, fmt.Errorf("%s: %s: mana must not be negative", path, o.Key)
+		}
+		overrides[o.Key] = o
+	}
+	return overrides, nil
+}
+
+// parseOverridesCSV parses overrides from CSV with a header row. Empty mana
+// and category cells keep the values from Jira.
+func parseOverridesCSV(b []byte) ([]IssueOverride, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	column := make(map[string]int)
+	for i, name := range header {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := column["key"]; !ok {
+		return nil, errors.New("the header row has no key column")
+	}
+	cell := func(record []string, name string) string {
+		if i, ok := column[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var list []IssueOverride
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return list, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		o := IssueOverride{Key: cell(record, "key"), Category: cell(record, "category"), Reason: cell(record, "reason")}
+		if o.Key == "" {
+			continue
+		}
+		if s := cell(record, "mana"); s != "" {
+			mana, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid mana %q", o.Key, s)
+			}
+			o.Mana = &mana
+		}
+		list = append(list, o)
+	}
+}
+
+// applyOverrides corrects the mana of the overridden issues and adds the
+// category overrides to the classification options, then tells how many
+// were applied. Overrides of issues that are not among them are counted, as
+// they may be stale or for another range.
+func applyOverrides(w io.Writer, issues []Issue, overrides issueOverrides, opts *classifyOptions) {
+	if len(overrides) == 0 {
+		return
+	}
+	if opts.Overrides == nil {
+		opts.Overrides = make(issueOverrides)
+	}
+	var applied, mana, categories int
+	for i := range issues {
+		o, ok := overrides[issues[i].Key]
+		if !ok {
+			continue
+		}
+		applied++
+		if o.Mana != nil {
+			issues[i].Mana = *o.Mana
+			issues[i].ManaSet = true
+			mana++
+		}
+		if o.Category != "" {
+			opts.Overrides[o.Key] = o
+			categories++
+		}
+	}
+	fmt.Fprintf(w, "Overrides applied: %d tickets (%d mana, %d category), %d listed tickets not in the results\n",
+		applied, mana, categories, len(overrides)-applied)
+}
+
+// overrideClassification is the classification of an issue whose category
+// is overridden
+func overrideClassification(o IssueOverride) classification {
+	detail := o.Reason
+	if detail == "" {
+		detail = "overrides file"
+	}
+	return classification{o.Category, ruleOverride, detail}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// ProjectConfig overrides the custom fields theia reads for one project, for
+// projects whose Mana Spent or Team field is a different field than the
+// standard one. Empty fields keep the standard field.
+type ProjectConfig struct {
+	ManaField string `json:"manaField"` // e.g. customfield_12345
+	TeamField string `json:"teamField"`
+}
+
+// customFieldIDRegex matches custom field IDs
+var customFieldIDRegex = regexp.MustCompile(`^customfield_[0-9]+$`)
+
+// projectFields are the IDs of the Mana Spent and Team fields of a project
+type projectFields struct {
+	Mana string
+	Team string
+}
+
+// standardProjectFields are the fields of projects without overrides
+var standardProjectFields = projectFields{Mana: manaFieldID, Team: teamFieldID}
+
+// checkProjectConfigs checks the per-project overrides of a config file
+func checkProjectConfigs(projects map[string]ProjectConfig) error {
+	for key, p := range projects {
+		if !projectKeyRegex.MatchString(key) {
+			return fmt.Errorf("projects: %q is not a project key, keys are upper case like PROJ", key)
+		}
+		if p.ManaField == "" && p.TeamField == "" {
+			return fmt.Errorf("projects: %s overrides no field, set manaField or teamField", key)
+		}
+		for _, id := range []string{p.ManaField, p.TeamField} {
+			if id != "" && !customFieldIDRegex.MatchString(id) {
+				return fmt.Errorf("projects: %s: %q is not a custom field ID like customfield_12345", key, id)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldsOf returns the fields of the project, with the overrides of the
+// config applied. A nil config has no overrides.
+func (c *Config) fieldsOf(project string) projectFields {
+	fields := standardProjectFields
+	if c == nil {
+		return fields
+	}
+	if p, ok := c.Projects[strings.ToUpper(project)]; ok {
+		if p.ManaField != "" {
+			fields.Mana = p.ManaField
+		}
+		if p.TeamField != "" {
+			fields.Team = p.TeamField
+		}
+	}
+	return fields
+}
+
+// manaJQL returns how JQL refers to the Mana Spent field of the project: by
+// name for the standard field, which reads better in the printed query, and
+// as cf[id] for an override, whose name may be shared with other fields
+func (p projectFields) manaJQL() string {
+	if p.Mana == manaFieldID {
+		return `"Mana Spent"`
+	}
+	return fmt.Sprintf("cf[%s]", strings.TrimPrefix(p.Mana, "customfield_"))
+}
+
+// teamJQL returns how JQL refers to the Team field of the project, like
+// manaJQL. The standard field is named as the team field it is, so JQL
+// matches it on team IDs like the baseline query does.
+func (p projectFields) teamJQL() string {
+	if p.Team == teamFieldID {
+		return `"Team[Team]"`
+	}
+	return fmt.Sprintf("cf[%s]", strings.TrimPrefix(p.Team, "customfield_"))
+}
+
+// request returns the fields to request from Jira, with the standard Mana
+// Spent and Team fields replaced by those of the project
+func (p projectFields) request(fields []string) []string {
+	requested := make([]string, len(fields))
+	for i, f := range fields {
+		switch f {
+		case manaFieldID:
+			requested[i] = p.Mana
+		case teamFieldID:
+			requested[i] = p.Team
+		default:
+			requested[i] = f
+		}
+	}
+	return requested
+}
+
+// normalize moves the values of the project's fields to the standard field
+// IDs, so the issues convert like those of any other project
+func (p projectFields) normalize(issues []jira.Issue) {
+	for _, ji := range issues {
+		if ji.Fields == nil || ji.Fields.Unknowns == nil || p == standardProjectFields {
+			continue
+		}
+		for from, to := range map[string]string{p.Mana: manaFieldID, p.Team: teamFieldID} {
+			if from != to {
+				ji.Fields.Unknowns[to] = ji.Fields.Unknowns[from]
+				delete(ji.Fields.Unknowns, from)
+			}
+		}
+	}
+}
+
+// fetchIssues is fetchIssues for the issues of the project, requesting its
+// own fields and reading them as the standard ones
+func (p projectFields) fetchIssues(client *jira.Client, jql string, fields []string) ([]Issue, error) {
+	pages, err := fetchAllPages(client, jql, p.request(fields))
+	if err != nil {
+		return nil, err
+	}
+	issues, err := decodePages(pages)
+	if err != nil {
+		return nil, err
+	}
+	p.normalize(issues)
+	return issuesFromJira(issues), nil
+}
+
+// searchByKeys is searchByKeys for issues of the project, like fetchIssues
+func (p projectFields) searchByKeys(client *jira.Client, keys []string, fields []string, expand string) ([]jira.Issue, error) {
+	found, err := searchByKeys(client, keys, p.request(fields), expand)
+	if err != nil {
+		return nil, err
+	}
+	p.normalize(found)
+	return found, nil
+}
+
+// requireStandard returns an error if the project has its own Mana Spent
+// field, with mana, or Team field, with team, for the features that can only
+// read the standard one, such as those reading the fields' changes by name
+// from changelogs
+func (p projectFields) requireStandard(project, feature string, mana, team bool) error {
+	switch {
+	case mana && p.Mana != manaFieldID:
+		return fmt.Errorf("%s only reads the standard Mana Spent field (%s), but the config gives project %s its own (%s)", feature, manaFieldID, project, p.Mana)
+	case team && p.Team != teamFieldID:
+		return fmt.Errorf("%s only reads the standard Team field (%s), but the config gives project %s its own (%s)", feature, teamFieldID, project, p.Team)
+	}
+	return nil
+}
+
+// requestAll returns the fields to request for issues of any project: the
+// fields, with every project's own Mana Spent and Team fields besides the
+// standard ones
+func (c *Config) requestAll(fields []string) []string {
+	requested := append([]string{}, fields...)
+	if c == nil {
+		return requested
+	}
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		seen[f] = true
+	}
+	for _, project := range sortedSet(projectSet(c.Projects)) {
+		for _, f := range c.fieldsOf(project).request(fields) {
+			if !seen[f] {
+				seen[f] = true
+				requested = append(requested, f)
+			}
+		}
+	}
+	return requested
+}
+
+// normalizeByProject normalizes every issue with the fields of its project,
+// for queries spanning projects
+func (c *Config) normalizeByProject(issues []jira.Issue) {
+	for i := range issues {
+		project, _, _ := strings.Cut(issues[i].Key, "-")
+		c.fieldsOf(project).normalize(issues[i : i+1])
+	}
+}
+
+// projectSet returns the keys of the projects section as a set
+func projectSet(projects map[string]ProjectConfig) map[string]bool {
+	set := make(map[string]bool)
+	for k := range projects {
+		set[k] = true
+	}
+	return set
+}
+
+// fetchIssues is fetchIssues for a query that may span projects, reading
+// every issue with the fields of its project
+func (c *Config) fetchIssues(client *jira.Client, jql string, fields []string) ([]Issue, error) {
+	pages, err := fetchAllPages(client, jql, c.requestAll(fields))
+	if err != nil {
+		return nil, err
+	}
+	issues, err := decodePages(pages)
+	if err != nil {
+		return nil, err
+	}
+	c.normalizeByProject(issues)
+	return issuesFromJira(issues), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// projectFetchWorkers is the number of projects fetched from Jira at once
+const projectFetchWorkers = 4
+
+// parseProjectKeys parses a comma-separated list of Jira project keys,
+// dropping duplicates
+func parseProjectKeys(list string) ([]string, error) {
+	seen := make(map[string]bool)
+	var projects []string
+	for _, key := range strings.Split(list, ",") {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if key == "" || seen[key] {
+			continue
+		}
+		if !projectKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid project key %q", key)
+		}
+		seen[key] = true
+		projects = append(projects, key)
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no project keys in %q", list)
+	}
+	return projects, nil
+}
+
+// projectProgress writes the progress of the projects fetched at once, one
+// line per page prefixed with the project, so a slow project stands out
+type projectProgress struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// page reports that fetched of total issues of the project are in
+func (p *projectProgress) page(project string, fetched, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s: %d of %d issues\n", project, fetched, total)
+}
+
+// fetchProjectIssues runs the query of every project in parallel, each
+// paginating on its own, and returns the issues of each project in the order
+// of projects. Progress is written to w per page of every project, and each
+// page is written to the stream as soon as it is fetched. Projects with their
+// own fields in the config are fetched with those, read as the standard ones.
+func fetchProjectIssues(client *jira.Client, w io.Writer, projects []string, jqlOf func(project string) string, fields []string, config *Config, stream *issueStream) ([][]Issue, error) {
+	type projectResult struct {
+		issues []Issue
+		err    error
+	}
+	results := make([]projectResult, len(projects))
+	progress := &projectProgress{w: w}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, projectFetchWorkers)
+	for i, project := range projects {
+		wg.Add(1)
+		go func(i int, project string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			own := config.fieldsOf(project)
+			found, err := streamSearchRange(client, jqlOf(project), own.request(fields), "", 0, 0, func(page []jira.Issue, fetched, total int) {
+				// Pages share their fields with found, so it is normalized too
+				own.normalize(page)
+				stream.page(page, fetched, total)
+				progress.page(project, fetched, total)
+			})
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].issues = issuesFromJira(found)
+		}(i, project)
+	}
+	wg.Wait()
+
+	issues := make([][]Issue, len(projects))
+	for i, r := range results {
+		if r.err != nil {
+			return nil, fmt.Errorf("%s: %w", projects[i], r.err)
+		}
+		fmt.Fprintf(w, "%s: %d issues (fetched)\n", projects[i], len(r.issues))
+		issues[i] = r.issues
+	}
+	return issues, nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quarterRegex matches quarters written as 2024Q1 or 2024-Q1
+var quarterRegex = regexp.MustCompile(`^(\d{4})-?[Qq]([1-4])$`)
+
+// parseQuarter returns the first and last day of a quarter such as 2024Q1
+func parseQuarter(s string) (time.Time, time.Time, error) {
+	m := quarterRegex.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid quarter %q, expected e.g. 2024Q1", s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	q, _ := strconv.Atoi(m[2])
+	start := time.Date(year, time.Month(3*(q-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 3, -1), nil
+}
+
+// quarterLabel returns the label of the quarter starting at start, e.g. 2024Q1
+func quarterLabel(start time.Time) string {
+	return fmt.Sprintf("%dQ%d", start.Year(), (int(start.Month())-1)/3+1)
+}
+
+// missingManaJQL selects the issues the ticket command would analyze in the
+// date range, end day included, if only they had a value in the project's
+// Mana Spent field
+func missingManaJQL(projectKey string, own projectFields, start, end time.Time) string {
+	return fmt.Sprintf(`project = "%s" AND
+		status in (Resolved, Closed) AND
+		resolution not in ("Won't Do", "Invalid", "Duplicate", "Won't Fix", "Declined") AND
+		%s AND
+		%s is EMPTY AND
+		issuetype not in (Epic, Initiative)
+		ORDER BY key ASC`,
+		projectKey,
+		resolvedBetween(start, end, true),
+		own.manaJQL())
+}
+
+// writeMissingManaAudit writes the resolved issues without Mana Spent per
+// team, with links to fix them
+func writeMissingManaAudit(w io.Writer, projectKey, quarter, jiraURL string, issues []Issue, layout tableOptions) {
+	fmt.Fprintf(w, "\nMissing Mana Audit: %s %s\n", projectKey, quarter)
+	fmt.Fprintf(w, "%d resolved issues have no Mana Spent and are left out of the reports.\n", len(issues))
+
+	byTeam := make(map[string][]Issue)
+	for _, issue := range issues {
+		team := teamDimension.Group(issue)
+		byTeam[team] = append(byTeam[team], issue)
+	}
+	var teams []string
+	for team := range byTeam {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	for _, team := range teams {
+		table := newTextTable(
+			tableColumn{Header: "Key"},
+			tableColumn{Header: "Type", MaxWidth: 20},
+			tableColumn{Header: "Link"},
+			tableColumn{Header: "Summary", MaxWidth: 60},
+		)
+		for _, issue := range byTeam[team] {
+			table.addRow(issue.Key, issue.Type, fmt.Sprintf("%s/browse/%s", jiraURL, issue.Key), removeEmojis(issue.Summary))
+		}
+		fmt.Fprintf(w, "\nTeam: %s (%d)\n", team, len(byTeam[team]))
+		table.write(w, layout.Style)
+	}
+}
+
+// quarterArtifact is a report written by close-quarter
+type quarterArtifact struct {
+	Name   string
+	Title  string
+	Render func(w io.Writer)
+}
+
+func runCloseQuarterCommand() {
+	// Command line flags
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	quarter := flag.String("quarter", "", "Quarter to close (e.g., 2024Q1)")
+	outDir := flag.String("out-dir", ".", "Directory the reports are written to")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	brokenWindows := flag.Bool("broken-windows", false, "Consider tickets with ux-broken-window label as separate type")
+	security := flag.Bool("security", false, "Consider tickets linked to Product Vulnerability issues as a separate type")
+	securityDepth := flag.Int("security-depth", 1, securityDepthUsage)
+	overridesFile := flag.String("overrides", "", overridesUsage)
+	research := defineResearchFlags()
+	teams := flag.Bool("teams", false, "Group the ticket report by team")
+	statsList := flag.String("stats", defaultStatistics, "Comma-separated statistic columns (count, sum, mean, median, stddev, pXX)")
+	webhookURL := flag.String("webhook-url", "", "Post the ticket report as JSON to this URL")
+	webhookTemplate := flag.String("webhook-template", "", "Template file for the webhook JSON payload (Go text/template over the report)")
+	msteamsWebhookURL := flag.String("msteams-webhook-url", "", "Post the summary as an Adaptive Card to this Microsoft Teams incoming webhook")
+	reportURL := flag.String("report-url", "", "Link to the full report, shown as a button on the Microsoft Teams card")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	childJQL := flag.String("child-jql", defaultEpicChildJQL, "Template of the JQL selecting an epic's child tickets, with {{.EpicKey}}, {{.Projects}} and {{.ProjectKey}}")
+	childProjects := flag.String("child-projects", "", "Comma-separated other projects the epics' child tickets may be in, or \"all\"")
+	flag.Parse()
+
+	// Validate flags
+	if *projectKey == "" || *quarter == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	start, end, err := parseQuarter(*quarter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats, err := parseStatistics(*statsList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	childTemplate, err := parseEpicChildTemplate(*childJQL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scope, err := parseChildProjects(*projectKey, *childProjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkChildScope(childTemplate, scope); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	label := quarterLabel(start)
+	prevStart := start.AddDate(0, -3, 0)
+	prevEnd := start.AddDate(0, 0, -1)
+	prevLabel := quarterLabel(prevStart)
+	startDate := start.Format("2006-01-02")
+	endDate := end.Format("2006-01-02")
+	classify := newClassifyOptions(*brokenWindows, *security, config)
+	if err := research.apply(&classify); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSecurityDepth(*securityDepth); err != nil {
+		log.Fatal(err)
+	}
+	var overrides issueOverrides
+	if *overridesFile != "" {
+		if overrides, err = loadOverrides(*overridesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Assignees count the people of each quarter in the comparison
+	fields := append(append([]string{}, ticketFields...), "assignee")
+
+	// Validate every query before fetching anything. Quarters meet without
+	// a gap, so each counts its last day in full.
+	own := config.fieldsOf(*projectKey)
+	ticketJQL := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, true), own) + `
+		ORDER BY created DESC`
+	prevJQL := projectTicketJQLFilter(*projectKey, resolvedBetween(prevStart, prevEnd, true), own)
+	auditJQL := missingManaJQL(*projectKey, own, start, end)
+	epicsJQL := epicJQL(*projectKey, own, start, end, true, false)
+	for _, jql := range []string{ticketJQL, prevJQL, auditJQL, epicsJQL} {
+		if err := validateJQL(client, jql); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("Closing %s %s (%s to %s)\n", *projectKey, label, startDate, endDate)
+
+	fmt.Printf("\n[1/5] Auditing issues without Mana Spent\n")
+	missing, err := own.fetchIssues(client, auditJQL, []string{"issuetype", "summary", teamFieldID, "reporter"})
+	if err != nil {
+		log.Fatalf("Error fetching issues without mana: %v", err)
+	}
+	missing = dropAutomationIssues(os.Stdout, missing, config)
+
+	fmt.Printf("[2/5] Fetching %s tickets\n", label)
+	issues, err := own.fetchIssues(client, ticketJQL, fields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	if err := applySecurityDepth(client, os.Stdout, issues, *securityDepth, &classify); err != nil {
+		log.Fatal(err)
+	}
+	applyOverrides(os.Stdout, issues, overrides, &classify)
+	report := analyzeTickets(issues, ticketOptions{Classify: classify, Teams: *teams, OrgChart: config.OrgChart, Start: start, End: end, Stats: stats}, 0)
+	report.Project = *projectKey
+	report.Start = startDate
+	report.End = endDate
+	report.EndInclusive = true
+	report.JQL = ticketJQL
+
+	fmt.Printf("[3/5] Fetching %s epics\n", label)
+	epics, err := fetchEpics(client, config, epicsJQL)
+	if err != nil {
+		log.Fatalf("Error fetching epics: %v", err)
+	}
+	if err := validateEpicChildJQL(client, childTemplate, scope, epics); err != nil {
+		log.Fatal(err)
+	}
+	children, err := fetchEpicChildren(client, config, io.Discard, jiraURL, scope, childTemplate, epics)
+	if err != nil {
+		log.Fatalf("Error searching child tickets: %v", err)
+	}
+	dropAutomationChildren(os.Stdout, children, config)
+	epicReport := analyzeEpics(epics, children, stats)
+	epicReport.Project = *projectKey
+	epicReport.Start = startDate
+	epicReport.End = endDate
+	epicReport.EndInclusive = true
+	epicReport.JQL = epicsJQL
+	epicReport.ChildJQL, _ = epicChildrenJQL(childTemplate, scope, "EPIC_KEY")
+
+	fmt.Printf("[4/5] Fetching %s tickets for comparison\n", prevLabel)
+	prevIssues, err := own.fetchIssues(client, prevJQL, fields)
+	if err != nil {
+		log.Fatalf("Error fetching %s tickets: %v", prevLabel, err)
+	}
+	if err := applySecurityDepth(client, os.Stdout, prevIssues, *securityDepth, &classify); err != nil {
+		log.Fatal(err)
+	}
+	applyOverrides(os.Stdout, prevIssues, overrides, &classify)
+	current := summarizeProject(label, issues, classify)
+	previous := summarizeProject(prevLabel, prevIssues, classify)
+
+	fmt.Printf("[5/5] Writing reports\n")
+	ext := "txt"
+	if *format == "pdf" {
+		ext = "pdf"
+	}
+	prefix := fmt.Sprintf("%s-%s", *projectKey, label)
+	artifacts := []quarterArtifact{
+		{"audit", fmt.Sprintf("%s Missing Mana Audit", prefix), func(w io.Writer) {
+			writeMissingManaAudit(w, *projectKey, label, jiraURL, missing, layout)
+		}},
+		{"tickets", fmt.Sprintf("%s Mana Analysis", prefix), func(w io.Writer) {
+			writeTicketReport(w, report, layout)
+		}},
+		{"epics", fmt.Sprintf("%s Epic Analysis", prefix), func(w io.Writer) {
+			writeEpicReport(w, epicReport, layout)
+		}},
+		{"comparison", fmt.Sprintf("%s Quarter Comparison", prefix), func(w io.Writer) {
+			fmt.Fprintf(w, "\nQuarter Comparison: %s %s vs %s\n", *projectKey, prevLabel, label)
+			writeComparisonTable(w, previous, current, layout)
+		}},
+	}
+
+	// Keep the out-dir browsable as a report archive, unless it is in one
+	// already; every report written is then added to the index
+	archive, err := findArchive(filepath.Join(*outDir, archiveIndexJSON))
+	if err != nil {
+		log.Fatalf("Error indexing reports: %v", err)
+	}
+	if archive == "" {
+		if _, err := buildArchiveIndex(*outDir, ""); err != nil {
+			log.Fatalf("Error indexing reports: %v", err)
+		}
+		archive = *outDir
+	}
+
+	var written []string
+	for _, a := range artifacts {
+		path := filepath.Join(*outDir, fmt.Sprintf("%s-%s.%s", prefix, a.Name, ext))
+		if err := writeFormatted(*format, path, a.Title, a.Render); err != nil {
+			log.Fatalf("Error writing %s report: %v", a.Name, err)
+		}
+		written = append(written, path)
+	}
+
+	// Publish to the configured sinks
+	var published []string
+	if *webhookURL != "" {
+		if err := sendWebhook(*webhookURL, report, *webhookTemplate); err != nil {
+			log.Fatalf("Error sending webhook: %v", err)
+		}
+		published = append(published, "webhook")
+	}
+	if *msteamsWebhookURL != "" {
+		if err := sendTeamsWebhook(*msteamsWebhookURL, report, *reportURL); err != nil {
+			log.Fatalf("Error posting to Microsoft Teams: %v", err)
+		}
+		published = append(published, "Microsoft Teams")
+	}
+
+	// Print the closing checklist
+	fmt.Printf("\n%s %s closed:\n", *projectKey, label)
+	fmt.Printf("  Missing mana:  %d issues\n", len(missing))
+	fmt.Printf("  Tickets:       %d issues, %.2f mana\n", report.Summary.TotalCount, report.Summary.TotalMana)
+	fmt.Printf("  Epics:         %d epics\n", len(epicReport.Epics))
+	fmt.Printf("  vs %s:     %+.2f mana\n", prevLabel, current.TotalMana-previous.TotalMana)
+	fmt.Printf("  Reports:       %s\n", strings.Join(written, ", "))
+	fmt.Printf("  Archive index: %s\n", filepath.Join(archive, archiveIndexHTML))
+	if len(published) > 0 {
+		fmt.Printf("  Published to:  %s\n", strings.Join(published, ", "))
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReportSection is a single table of the report, e.g. one team or one month
+type ReportSection struct {
+	Title         string
+	Results       []TicketAnalysis
+	TotalCount    int
+	TotalMana     float64
+	ZeroManaCount int
+}
+
+// Report is the data model of a ticket analysis run, shared by all sinks
+type Report struct {
+	Project   string
+	Start     string    // YYYY-MM-DD
+	End       string    // YYYY-MM-DD
+	Since     time.Time // Set if only issues resolved after the last run were analyzed
+	JQL       string
+	Breakdown string // "team" or "month" if Sections break the summary down
+	Sections  []ReportSection
+	Summary   ReportSection
+	// Rollups are the team sections rolled up along the org chart, groups
+	// before orgs, if an org chart is configured
+	Rollups []ReportRollup
+	Sample  *SampleSummary // Set if the report was computed from a sample
+	// EndInclusive is set if issues resolved at any time on the end date
+	// count, rather than only up to midnight at its start
+	EndInclusive bool
+	// FieldChanges lists how the Jira fields theia reads changed since the
+	// last -since-last-run run
+	FieldChanges []string
+	// MissingFields lists the fields Jira did not return and the features
+	// left out without them
+	MissingFields []string
+	// ExternalWaits is set if waits on other teams' blockers were measured
+	ExternalWaits []TeamWait
+	// Attribution is set if mana was attributed to the teams that touched
+	// the issues
+	Attribution *TouchedAttribution
+	// Keys is set if the report analyzes listed issue keys; Start and End
+	// are then the range of their resolution dates
+	Keys *KeySelection
+	// EpicLess is the work that belongs to no epic
+	EpicLess *EpicLessWork
+	// Research is set if research issue types are configured
+	Research *ResearchInvestment
+	// Automation is set if automation reporters are configured
+	Automation *AutomationExclusion
+	// CycleTimes is set if lead and cycle times were measured
+	CycleTimes *CycleTimes
+	Stats      []Statistic `json:"-"`
+}
+
+// KeySelection describes the issue keys a -keys report analyzes instead of
+// a date range
+type KeySelection struct {
+	Keys    []string // Listed keys
+	Missing []string // Keys Jira returned no issue for
+	NoMana  []string // Issues without Mana Spent, left out
+	Epics   []string // Epics and Initiatives, left out
+	// NotEpics are the issues of other types listed for the epic report,
+	// left out
+	NotEpics []string
+}
+
+// EpicLessWork counts the issues with neither an Epic Link nor a parent
+type EpicLessWork struct {
+	Issues int
+	Mana   float64
+}
+
+// ResearchInvestment is the mana spent on research issue types, whether
+// they are a category of their own, counted as stories or excluded
+type ResearchInvestment struct {
+	Mode   string
+	Issues int
+	Mana   float64
+}
+
+// periodLabel returns what the report covers, as shown in titles
+func (r *Report) periodLabel() string {
+	if r.Keys != nil {
+		return fmt.Sprintf("%d listed issues", len(r.Keys.Keys))
+	}
+	return fmt.Sprintf("%s to %s", r.startLabel(), r.End)
+}
+
+// startLabel returns the start of the period as shown in reports
+func (r *Report) startLabel() string {
+	if r.Since.IsZero() {
+		return r.Start
+	}
+	return fmt.Sprintf("last run (%s)", r.Since.Format("2006-01-02 15:04"))
+}
+
+// ReportRollup is one level of the org chart, with a section per group or org
+type ReportRollup struct {
+	Level    string // "Group" or "Org"
+	Sections []ReportSection
+}
+
+// newReportSection builds a section from already sorted analysis results
+func newReportSection(title string, results []TicketAnalysis, zeroManaCount int) ReportSection {
+	section := ReportSection{
+		Title:         title,
+		Results:       results,
+		ZeroManaCount: zeroManaCount,
+	}
+	for _, r := range results {
+		section.TotalCount += r.Count
+		section.TotalMana += r.TotalMana
+	}
+	return section
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// runMarkerPropertyKey is the project entity property holding the run marker
+const runMarkerPropertyKey = "theia.run-marker"
+
+// runMarker records how far the reports of a project have got
+type runMarker struct {
+	// LastResolved is the latest resolution time of the issues analyzed so far
+	LastResolved time.Time `json:"lastResolved"`
+}
+
+// runMarkerStore loads and saves the run marker of a project. Load returns
+// nil if the project has no marker yet.
+type runMarkerStore interface {
+	Load(projectKey string) (*runMarker, error)
+	Save(projectKey string, marker *runMarker) error
+}
+
+// newRunMarkerStore returns the store selected by the -run-marker flag
+func newRunMarkerStore(kind string, client *jira.Client) (runMarkerStore, error) {
+	switch kind {
+	case "local":
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("no config directory for run markers: %w", err)
+		}
+		return &localRunMarkerStore{dir: filepath.Join(base, "theia", "run-markers")}, nil
+	case "jira":
+		return &jiraRunMarkerStore{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown run marker store %q, expected local or jira", kind)
+	}
+}
+
+// localRunMarkerStore keeps one marker file per project in the user config dir
+type localRunMarkerStore struct {
+	dir string
+}
+
+func (s *localRunMarkerStore) path(projectKey string) string {
+	return filepath.Join(s.dir, projectKey+".json")
+}
+
+func (s *localRunMarkerStore) Load(projectKey string) (*runMarker, error) {
+	b, err := os.ReadFile(s.path(projectKey))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	marker := new(runMarker)
+	if err := json.Unmarshal(b, marker); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", s.path(projectKey), err)
+	}
+	return marker, nil
+}
+
+func (s *localRunMarkerStore) Save(projectKey string, marker *runMarker) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(projectKey), b, 0o600)
+}
+
+// jiraRunMarkerStore keeps the marker as an entity property of the Jira
+// project, so every machine running the report shares it
+type jiraRunMarkerStore struct {
+	client *jira.Client
+}
+
+func (s *jiraRunMarkerStore) endpoint(projectKey string) string {
+	return fmt.Sprintf("rest/api/2/project/%s/properties/%s", url.PathEscape(projectKey), runMarkerPropertyKey)
+}
+
+func (s *jiraRunMarkerStore) Load(projectKey string) (*runMarker, error) {
+	req, err := s.client.NewRequest("GET", s.endpoint(projectKey), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var property struct {
+		Value runMarker `json:"value"`
+	}
+	resp, err := s.client.Do(req, &property)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading run marker: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return &property.Value, nil
+}
+
+func (s *jiraRunMarkerStore) Save(projectKey string, marker *runMarker) error {
+	req, err := s.client.NewRequest("PUT", s.endpoint(projectKey), marker)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req, nil)
+	if err != nil {
+		return fmt.Errorf("writing run marker: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return nil
+}
+
+// issuesResolvedAfter returns the issues resolved strictly after t. Queries
+// only bound the resolution date by day, so this drops the issues of the
+// marker's day that the previous run already analyzed.
+func issuesResolvedAfter(issues []Issue, t time.Time) []Issue {
+	var after []Issue
+	for _, issue := range issues {
+		if issue.Resolved.After(t) {
+			after = append(after, issue)
+		}
+	}
+	return after
+}
+
+// latestResolution returns the latest resolution time of the issues
+func latestResolution(issues []Issue) time.Time {
+	var latest time.Time
+	for _, issue := range issues {
+		if issue.Resolved.After(latest) {
+			latest = issue.Resolved
+		}
+	}
+	return latest
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// samplePageOffsets picks a random subset of result pages covering roughly
+// rate of the total issues, returned as sorted startAt offsets. At least two
+// pages are picked when there are, so the sample has a margin of error.
+func samplePageOffsets(total, pageSize int, rate float64) []int {
+	pages := (total + pageSize - 1) / pageSize
+	if pages == 0 {
+		return []int{}
+	}
+
+	n := int(math.Ceil(float64(pages) * rate))
+	if n < 2 {
+		n = 2
+	}
+	if n > pages {
+		n = pages
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	offsets := make([]int, 0, n)
+	for _, page := range rng.Perm(pages)[:n] {
+		offsets = append(offsets, page*pageSize)
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// samplePage is one sampled result page, the unit of a page sample: its
+// issues and the count and mana of every category among them
+type samplePage struct {
+	Issues int
+	Count  map[string]float64
+	Mana   map[string]float64
+}
+
+// splitSamplePages returns the sampled pages of the issues, given the issues
+// fetched from every page in order, and the page of every issue. Without
+// page sizes matching the issues, every issue is a page of its own.
+func splitSamplePages(issues int, sizes []int) ([]samplePage, []int) {
+	sum := 0
+	for _, size := range sizes {
+		sum += size
+	}
+	if sum != issues {
+		sizes = make([]int, issues)
+		for i := range sizes {
+			sizes[i] = 1
+		}
+	}
+	pages := make([]samplePage, len(sizes))
+	pageOf := make([]int, 0, issues)
+	for i, size := range sizes {
+		pages[i] = samplePage{Issues: size, Count: make(map[string]float64), Mana: make(map[string]float64)}
+		for j := 0; j < size; j++ {
+			pageOf = append(pageOf, i)
+		}
+	}
+	return pages, pageOf
+}
+
+// add counts an issue of the category on the page
+func (p samplePage) add(category string, mana float64) {
+	p.Count[category]++
+	p.Mana[category] += mana
+}
+
+// scaleAnalysis scales the counts and totals of sampled analyses up to the
+// full population. Averages and medians are left as the sample estimates.
+func scaleAnalysis(analysis map[string]*TicketAnalysis, factor float64) {
+	for _, a := range analysis {
+		a.Count = int(math.Round(float64(a.Count) * factor))
+		a.TotalMana *= factor
+		a.ZeroManaCount = int(math.Round(float64(a.ZeroManaCount) * factor))
+	}
+}
+
+// sampleEstimate is a scaled estimate with its 95% confidence half-width
+type sampleEstimate struct {
+	Value  float64
+	Margin float64
+}
+
+// estimateTotal estimates a population total from a sample of result pages
+// with the ratio estimator: values are the totals of a category on the
+// sampled pages and sizes their issues, with totalPages pages and total
+// issues in the population. The page is the sampling unit, as the issues of
+// a page are fetched together rather than picked one by one.
+func estimateTotal(values, sizes []float64, totalPages, total int) sampleEstimate {
+	var sum, sumSizes float64
+	for i, v := range values {
+		sum += v
+		sumSizes += sizes[i]
+	}
+	if sumSizes == 0 {
+		return sampleEstimate{}
+	}
+	ratio := sum / sumSizes
+
+	estimate := sampleEstimate{Value: ratio * float64(total)}
+	if m := len(values); m > 1 {
+		var residuals float64
+		for i, v := range values {
+			r := v - ratio*sizes[i]
+			residuals += r * r
+		}
+		variance := residuals / float64(m-1)
+		finitePopulation := math.Max(0, 1-float64(m)/float64(totalPages))
+		estimate.Margin = 1.96 * float64(totalPages) * math.Sqrt(variance/float64(m)*finitePopulation)
+	}
+	return estimate
+}
+
+// SampleSummary describes the sample a report was computed from, with the
+// scaled count and mana of every category and their 95% confidence intervals
+type SampleSummary struct {
+	Sampled   int
+	Total     int
+	Estimates []CategoryEstimate
+}
+
+// CategoryEstimate is the estimated count and mana of one category
+type CategoryEstimate struct {
+	IssueType string
+	Count     sampleEstimate
+	Mana      sampleEstimate
+}
+
+// newSampleSummary estimates every category of the unscaled sampled analysis
+// from its sampled pages, out of totalPages pages
+func newSampleSummary(analysis map[string]*TicketAnalysis, pages []samplePage, sampled, total, totalPages int) *SampleSummary {
+	var types []string
+	for issueType := range analysis {
+		types = append(types, issueType)
+	}
+	sort.Strings(types)
+
+	sizes := make([]float64, len(pages))
+	for i, p := range pages {
+		sizes[i] = float64(p.Issues)
+	}
+	summary := &SampleSummary{Sampled: sampled, Total: total}
+	for _, issueType := range types {
+		counts := make([]float64, len(pages))
+		mana := make([]float64, len(pages))
+		for i, p := range pages {
+			counts[i] = p.Count[issueType]
+			mana[i] = p.Mana[issueType]
+		}
+		summary.Estimates = append(summary.Estimates, CategoryEstimate{
+			IssueType: issueType,
+			Count:     estimateTotal(counts, sizes, totalPages, total),
+			Mana:      estimateTotal(mana, sizes, totalPages, total),
+		})
+	}
+	return summary
+}
+
+// writeSampleEstimates writes the sample size and the estimated count and
+// mana of every category with their confidence intervals
+func writeSampleEstimates(w io.Writer, s *SampleSummary, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Issue Type", MaxWidth: 30},
+		tableColumn{Header: "Est. Count (95% CI)", Right: true},
+		tableColumn{Header: "Est. Total Mana (95% CI)", Right: true},
+	)
+	for _, e := range s.Estimates {
+		table.addRow(e.IssueType,
+			fmt.Sprintf("%.0f ± %.0f", e.Count.Value, e.Count.Margin),
+			fmt.Sprintf("%.1f ± %.1f", e.Mana.Value, e.Mana.Margin))
+	}
+
+	fmt.Fprintf(w, "\nSampled %d of %d issues (%.1f%%). Counts and totals below are scaled estimates.\n",
+		s.Sampled, s.Total, float64(s.Sampled)/float64(s.Total)*100)
+	table.write(w, layout.Style)
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// reportSchemaVersion is the version of the JSON report schema written by
+// this build. Within a version fields are only ever added; removing, renaming
+// or changing the meaning of a field needs a new version and an upgrade from
+// the previous one in upgradeReportJSON.
+const reportSchemaVersion = 1
+
+// snapshotDecimals is the number of decimals JSON numbers are rounded to, so
+// the same data always gives the same document
+const snapshotDecimals = 4
+
+// snapshot rounds a value to snapshotDecimals decimals
+func snapshot(v float64) float64 {
+	scale := math.Pow(10, snapshotDecimals)
+	return math.Round(v*scale) / scale
+}
+
+// snapshotStats rounds every statistic of a result
+func snapshotStats(stats map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(stats))
+	for k, v := range stats {
+		out[k] = snapshot(v)
+	}
+	return out
+}
+
+// reportV1 is version 1 of the JSON ticket report
+type reportV1 struct {
+	SchemaVersion int            `json:"schema_version"`
+	Project       string         `json:"project"`
+	Start         string         `json:"start"`           // YYYY-MM-DD
+	End           string         `json:"end"`             // YYYY-MM-DD
+	EndInclusive  bool           `json:"end_inclusive"`   // Issues resolved at any time on the end date count
+	Since         string         `json:"since,omitempty"` // RFC 3339 in UTC, set with -since-last-run
+	JQL           string         `json:"jql"`
+	Keys          *keysV1        `json:"keys,omitempty"`
+	Breakdown     string         `json:"breakdown,omitempty"`
+	Sections      []sectionV1    `json:"sections"`
+	Summary       sectionV1      `json:"summary"`
+	Rollups       []rollupV1     `json:"rollups,omitempty"`
+	Sample        *sampleV1      `json:"sample,omitempty"`
+	ExternalWaits []teamWaitV1   `json:"external_waits,omitempty"`
+	Attribution   *attributionV1 `json:"attribution,omitempty"`
+	CycleTimes    *cycleTimesV1  `json:"cycle_times,omitempty"`
+	EpicLess      *epicLessV1    `json:"epic_less,omitempty"`
+	Research      *researchV1    `json:"research,omitempty"`
+	Automation    *automationV1  `json:"automation,omitempty"`
+	FieldChanges  []string       `json:"field_changes,omitempty"` // Set with -since-last-run if Jira fields theia reads changed
+	// MissingFields lists the Jira fields not returned, with the features
+	// left out without them
+	MissingFields []string `json:"missing_fields,omitempty"`
+}
+
+// keysV1 describes the listed issue keys of a version 1 report
+type keysV1 struct {
+	Listed  []string `json:"listed"`
+	Missing []string `json:"missing"`
+	NoMana  []string `json:"no_mana"`
+	Epics   []string `json:"epics"`
+}
+
+// sectionV1 is one table of a version 1 report
+type sectionV1 struct {
+	Title         string        `json:"title"`
+	Count         int           `json:"count"`
+	TotalMana     float64       `json:"total_mana"`
+	ZeroManaCount int           `json:"zero_mana_count"`
+	Types         []issueTypeV1 `json:"types"`
+}
+
+// issueTypeV1 is one row of a version 1 report section
+type issueTypeV1 struct {
+	IssueType     string             `json:"issue_type"`
+	Count         int                `json:"count"`
+	TotalMana     float64            `json:"total_mana"`
+	ZeroManaCount int                `json:"zero_mana_count"`
+	Stats         map[string]float64 `json:"stats"`
+}
+
+// rollupV1 is one org chart level of a version 1 report
+type rollupV1 struct {
+	Level    string      `json:"level"`
+	Sections []sectionV1 `json:"sections"`
+}
+
+// sampleV1 describes the sample of a version 1 report
+type sampleV1 struct {
+	Sampled   int          `json:"sampled"`
+	Total     int          `json:"total"`
+	Estimates []estimateV1 `json:"estimates"`
+}
+
+// estimateV1 is the estimated count and mana of one issue type, with the
+// margins of their 95% confidence intervals
+type estimateV1 struct {
+	IssueType   string  `json:"issue_type"`
+	Count       float64 `json:"count"`
+	CountMargin float64 `json:"count_margin"`
+	Mana        float64 `json:"mana"`
+	ManaMargin  float64 `json:"mana_margin"`
+}
+
+// teamWaitV1 is the external dependency wait of one team
+type teamWaitV1 struct {
+	Team          string  `json:"team"`
+	BlockedIssues int     `json:"blocked_issues"`
+	WaitDays      float64 `json:"wait_days"`
+	WaitManaDays  float64 `json:"wait_mana_days"`
+}
+
+// attributionV1 is the touched-by attribution of a version 1 report
+type attributionV1 struct {
+	Share float64             `json:"share"`
+	Teams []teamAttributionV1 `json:"teams"`
+}
+
+// teamAttributionV1 is the touched-by attribution of one team
+type teamAttributionV1 struct {
+	Team           string  `json:"team"`
+	OwnedIssues    int     `json:"owned_issues"`
+	OwnedMana      float64 `json:"owned_mana"`
+	TouchedIssues  int     `json:"touched_issues"`
+	AttributedMana float64 `json:"attributed_mana"`
+}
+
+// epicLessV1 is the work outside any epic of a version 1 report
+type epicLessV1 struct {
+	Issues int     `json:"issues"`
+	Mana   float64 `json:"mana"`
+}
+
+// researchV1 is the research investment of a version 1 report
+type researchV1 struct {
+	Mode   string  `json:"mode"`
+	Issues int     `json:"issues"`
+	Mana   float64 `json:"mana"`
+}
+
+// automationV1 is what the issues reported by automation accounts, left
+// out of a version 1 report, amounted to
+type automationV1 struct {
+	Issues    int            `json:"issues"`
+	Mana      float64        `json:"mana"`
+	Reporters map[string]int `json:"reporters"`
+}
+
+// cycleTimesV1 is the lead and cycle time analysis of a version 1 report
+type cycleTimesV1 struct {
+	Calendar string        `json:"calendar"`
+	Types    []cycleTimeV1 `json:"types"`
+	Overall  cycleTimeV1   `json:"overall"`
+}
+
+// cycleTimeV1 is the median lead and cycle time of one issue type, in days
+type cycleTimeV1 struct {
+	IssueType         string  `json:"issue_type"`
+	LeadIssues        int     `json:"lead_issues"`
+	LeadDays          float64 `json:"lead_days"`
+	LeadBusinessDays  float64 `json:"lead_business_days"`
+	StartedIssues     int     `json:"started_issues"`
+	CycleDays         float64 `json:"cycle_days"`
+	CycleBusinessDays float64 `json:"cycle_business_days"`
+}
+
+// nonNilStrings returns the strings, or an empty slice instead of nil, so
+// lists are written as [] rather than null
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// newCycleTimeV1 converts the cycle time of an issue type
+func newCycleTimeV1(t CycleTime) cycleTimeV1 {
+	return cycleTimeV1{
+		IssueType:         t.IssueType,
+		LeadIssues:        t.LeadIssues,
+		LeadDays:          snapshot(t.LeadDays),
+		LeadBusinessDays:  snapshot(t.LeadBusinessDays),
+		StartedIssues:     t.StartedIssues,
+		CycleDays:         snapshot(t.CycleDays),
+		CycleBusinessDays: snapshot(t.CycleBusinessDays),
+	}
+}
+
+// newSectionV1 converts a report section
+func newSectionV1(s ReportSection) sectionV1 {
+	section := sectionV1{
+		Title:         s.Title,
+		Count:         s.TotalCount,
+		TotalMana:     snapshot(s.TotalMana),
+		ZeroManaCount: s.ZeroManaCount,
+		Types:         []issueTypeV1{},
+	}
+	for _, r := range s.Results {
+		section.Types = append(section.Types, issueTypeV1{
+			IssueType:     r.IssueType,
+			Count:         r.Count,
+			TotalMana:     snapshot(r.TotalMana),
+			ZeroManaCount: r.ZeroManaCount,
+			Stats:         snapshotStats(r.Stats),
+		})
+	}
+	return section
+}
+
+// newReportV1 converts a report to the version 1 schema
+func newReportV1(report *Report) *reportV1 {
+	doc := &reportV1{
+		SchemaVersion: reportSchemaVersion,
+		Project:       report.Project,
+		Start:         report.Start,
+		End:           report.End,
+		EndInclusive:  report.EndInclusive,
+		JQL:           report.JQL,
+		Breakdown:     report.Breakdown,
+		Sections:      []sectionV1{},
+		Summary:       newSectionV1(report.Summary),
+	}
+	if !report.Since.IsZero() {
+		doc.Since = report.Since.UTC().Format(time.RFC3339)
+	}
+	doc.FieldChanges = report.FieldChanges
+	doc.MissingFields = report.MissingFields
+	for _, s := range report.Sections {
+		doc.Sections = append(doc.Sections, newSectionV1(s))
+	}
+	for _, rollup := range report.Rollups {
+		r := rollupV1{Level: rollup.Level, Sections: []sectionV1{}}
+		for _, s := range rollup.Sections {
+			r.Sections = append(r.Sections, newSectionV1(s))
+		}
+		doc.Rollups = append(doc.Rollups, r)
+	}
+	if report.Sample != nil {
+		doc.Sample = &sampleV1{Sampled: report.Sample.Sampled, Total: report.Sample.Total, Estimates: []estimateV1{}}
+		for _, e := range report.Sample.Estimates {
+			doc.Sample.Estimates = append(doc.Sample.Estimates, estimateV1{
+				IssueType:   e.IssueType,
+				Count:       snapshot(e.Count.Value),
+				CountMargin: snapshot(e.Count.Margin),
+				Mana:        snapshot(e.Mana.Value),
+				ManaMargin:  snapshot(e.Mana.Margin),
+			})
+		}
+	}
+	for _, t := range report.ExternalWaits {
+		doc.ExternalWaits = append(doc.ExternalWaits, teamWaitV1{
+			Team:          t.Team,
+			BlockedIssues: t.BlockedIssues,
+			WaitDays:      snapshot(t.WaitDays),
+			WaitManaDays:  snapshot(t.WaitManaDays),
+		})
+	}
+	if report.Attribution != nil {
+		doc.Attribution = &attributionV1{Share: report.Attribution.Share, Teams: []teamAttributionV1{}}
+		for _, t := range report.Attribution.Teams {
+			doc.Attribution.Teams = append(doc.Attribution.Teams, teamAttributionV1{
+				Team:           t.Team,
+				OwnedIssues:    t.OwnedIssues,
+				OwnedMana:      snapshot(t.OwnedMana),
+				TouchedIssues:  t.TouchedIssues,
+				AttributedMana: snapshot(t.AttributedMana),
+			})
+		}
+	}
+	if report.Keys != nil {
+		doc.Keys = &keysV1{
+			Listed:  report.Keys.Keys,
+			Missing: nonNilStrings(report.Keys.Missing),
+			NoMana:  nonNilStrings(report.Keys.NoMana),
+			Epics:   nonNilStrings(report.Keys.Epics),
+		}
+	}
+	if report.EpicLess != nil {
+		doc.EpicLess = &epicLessV1{Issues: report.EpicLess.Issues, Mana: snapshot(report.EpicLess.Mana)}
+	}
+	if report.Research != nil {
+		doc.Research = &researchV1{Mode: report.Research.Mode, Issues: report.Research.Issues, Mana: snapshot(report.Research.Mana)}
+	}
+	if report.Automation != nil {
+		doc.Automation = &automationV1{Issues: report.Automation.Issues, Mana: snapshot(report.Automation.Mana), Reporters: report.Automation.Reporters}
+	}
+	if report.CycleTimes != nil {
+		doc.CycleTimes = &cycleTimesV1{
+			Calendar: report.CycleTimes.Calendar,
+			Types:    []cycleTimeV1{},
+			Overall:  newCycleTimeV1(report.CycleTimes.Overall),
+		}
+		for _, t := range report.CycleTimes.Types {
+			doc.CycleTimes.Types = append(doc.CycleTimes.Types, newCycleTimeV1(t))
+		}
+	}
+	return doc
+}
+
+// renderReportJSON renders the report as an indented JSON document of the
+// current schema version
+func renderReportJSON(report *Report) ([]byte, error) {
+	b, err := json.MarshalIndent(newReportV1(report), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// writeReportJSON writes the JSON report to the output file, or to w if
+// output is empty
+func writeReportJSON(w io.Writer, output string, report *Report) error {
+	b, err := renderReportJSON(report)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = w.Write(b)
+		return err
+	}
+	return os.WriteFile(output, b, 0o644)
+}
+
+// reportV0 is the unversioned payload of the default webhook template
+// before schema versions were introduced
+type reportV0 struct {
+	Project  string      `json:"project"`
+	Start    string      `json:"start"`
+	End      string      `json:"end"`
+	Sections []sectionV0 `json:"sections"`
+	Summary  sectionV0   `json:"summary"`
+}
+
+// sectionV0 is one table of an unversioned report
+type sectionV0 struct {
+	Title         string  `json:"title"`
+	Count         int     `json:"count"`
+	TotalMana     float64 `json:"total_mana"`
+	ZeroManaCount int     `json:"zero_mana_count"`
+	Types         []struct {
+		IssueType string             `json:"issue_type"`
+		Count     int                `json:"count"`
+		TotalMana float64            `json:"total_mana"`
+		Stats     map[string]float64 `json:"stats"`
+	} `json:"types"`
+}
+
+// upgradeV0 converts an unversioned report to version 1. Fields version 0
+// did not have are left empty; zero mana counts of issue types are unknown
+// and left at 0.
+func upgradeV0(v0 *reportV0) *reportV1 {
+	convert := func(s sectionV0) sectionV1 {
+		section := sectionV1{
+			Title:         s.Title,
+			Count:         s.Count,
+			TotalMana:     snapshot(s.TotalMana),
+			ZeroManaCount: s.ZeroManaCount,
+			Types:         []issueTypeV1{},
+		}
+		for _, t := range s.Types {
+			section.Types = append(section.Types, issueTypeV1{
+				IssueType: t.IssueType,
+				Count:     t.Count,
+				TotalMana: snapshot(t.TotalMana),
+				Stats:     snapshotStats(t.Stats),
+			})
+		}
+		return section
+	}
+
+	doc := &reportV1{
+		SchemaVersion: 1,
+		Project:       v0.Project,
+		Start:         v0.Start,
+		End:           v0.End,
+		Sections:      []sectionV1{},
+		Summary:       convert(v0.Summary),
+	}
+	for _, s := range v0.Sections {
+		doc.Sections = append(doc.Sections, convert(s))
+	}
+	return doc
+}
+
+// upgradeReportJSON decodes a JSON report of any supported schema version
+// and converts it to the current version. Reports without a schema_version
+// are version 0.
+func upgradeReportJSON(b []byte) (*reportV1, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("invalid JSON report: %w", err)
+	}
+
+	switch header.SchemaVersion {
+	case 0:
+		var v0 reportV0
+		if err := json.Unmarshal(b, &v0); err != nil {
+			return nil, fmt.Errorf("invalid version 0 report: %w", err)
+		}
+		return upgradeV0(&v0), nil
+	case 1:
+		var v1 reportV1
+		if err := json.Unmarshal(b, &v1); err != nil {
+			return nil, fmt.Errorf("invalid version 1 report: %w", err)
+		}
+		return &v1, nil
+	}
+	return nil, fmt.Errorf("schema version %d is newer than this build supports (%d)", header.SchemaVersion, reportSchemaVersion)
+}
+
+func runConvertJSONCommand() {
+	input := flag.String("input", "", "JSON report to convert (default stdin)")
+	output := flag.String("output", "", "Write the converted report to this file instead of stdout")
+	flag.Parse()
+
+	var b []byte
+	var err error
+	if *input == "" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		log.Fatalf("Error reading report: %v", err)
+	}
+
+	doc, err := upgradeReportJSON(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	converted, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	converted = append(converted, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(converted)
+		return
+	}
+	if err := os.WriteFile(*output, converted, 0o644); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+}
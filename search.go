@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// searchPageSize is the number of issues requested per search page
+const searchPageSize = 50
+
+// pageSizeMu guards cappedPageSize
+var pageSizeMu sync.Mutex
+
+// cappedPageSize is the page size Jira was found to cap searches at, or 0
+// while no page came back short. Searches request at most this many issues
+// per page from then on, so later pages line up with what Jira serves.
+var cappedPageSize int
+
+// pageSize returns the number of issues to request per search page
+func pageSize() int {
+	pageSizeMu.Lock()
+	defer pageSizeMu.Unlock()
+	if cappedPageSize > 0 {
+		return cappedPageSize
+	}
+	return searchPageSize
+}
+
+// checkPage checks a page of got issues returned for a request of requested
+// issues from startAt, of total. A short page with more issues remaining
+// means Jira caps the page size, which is logged and adopted. An empty page
+// with issues remaining is an error, so results are never silently cut off.
+func checkPage(requested, got, startAt, total int) error {
+	remaining := total - startAt
+	if got == 0 && remaining > 0 {
+		return fmt.Errorf("Jira returned no issues at %d of %d, the results would be incomplete", startAt, total)
+	}
+	if got >= requested || got >= remaining {
+		return nil
+	}
+	pageSizeMu.Lock()
+	defer pageSizeMu.Unlock()
+	if cappedPageSize == 0 || got < cappedPageSize {
+		cappedPageSize = got
+		log.Printf("Jira returned %d of %d requested issues per page, continuing with pages of %d", got, requested, got)
+	}
+	return nil
+}
+
+// searchRange fetches the issues of the query from startAt, at most limit of
+// them, or all remaining issues if limit is 0
+func searchRange(client *jira.Client, jql string, fields []string, expand string, startAt, limit int) ([]jira.Issue, error) {
+	return streamSearchRange(client, jql, fields, expand, startAt, limit, nil)
+}
+
+// pageFunc receives every page of search results as soon as it is fetched,
+// with the number of issues fetched so far and the total of the query
+type pageFunc func(page []jira.Issue, fetched, total int)
+
+// streamSearchRange is searchRange passing every page to onPage, if not nil
+func streamSearchRange(client *jira.Client, jql string, fields []string, expand string, startAt, limit int, onPage pageFunc) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	for limit == 0 || len(issues) < limit {
+		n := pageSize()
+		if limit > 0 && limit-len(issues) < n {
+			n = limit - len(issues)
+		}
+		page, resp, err := client.Issue.Search(jql, &jira.SearchOptions{
+			StartAt:    startAt,
+			MaxResults: n,
+			Fields:     fields,
+			Expand:     expand,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("searching issues: %s", describeJiraError(resp, err))
+		}
+		if err := checkPage(n, len(page), startAt, resp.Total); err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		issues = append(issues, page...)
+		if onPage != nil {
+			onPage(page, len(issues), resp.Total)
+		}
+
+		startAt += len(page)
+		if startAt >= resp.Total {
+			break
+		}
+	}
+	return issues, nil
+}
+
+// searchPage is one page of Jira search results
+type searchPage struct {
+	StartAt    int          `json:"startAt"`
+	MaxResults int          `json:"maxResults"`
+	Total      int          `json:"total"`
+	Issues     []jira.Issue `json:"issues"`
+}
+
+// searchRawPage fetches one page of search results as raw JSON, so it can be
+// cached exactly as Jira returned it
+func searchRawPage(client *jira.Client, jql string, fields []string, startAt, maxResults int) (json.RawMessage, error) {
+	uv := url.Values{}
+	uv.Add("jql", jql)
+	uv.Add("startAt", strconv.Itoa(startAt))
+	uv.Add("maxResults", strconv.Itoa(maxResults))
+	uv.Add("fields", strings.Join(fields, ","))
+
+	req, err := client.NewRequest("GET", "rest/api/2/search?"+uv.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	resp, err := client.Do(req, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("searching issues: %s", describeJiraError(resp, jira.NewJiraError(resp, err)))
+	}
+	return raw, nil
+}
+
+// decodeSearchPage decodes a raw page of search results
+func decodeSearchPage(raw json.RawMessage) (*searchPage, error) {
+	page := new(searchPage)
+	if err := json.Unmarshal(raw, page); err != nil {
+		return nil, fmt.Errorf("decoding search results: %w", err)
+	}
+	return page, nil
+}
+
+// decodePages decodes raw pages of search results into their issues
+func decodePages(pages []json.RawMessage) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	for _, raw := range pages {
+		page, err := decodeSearchPage(raw)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+	}
+	return issues, nil
+}
+
+// fetchAllPages fetches every page of the query as raw JSON
+func fetchAllPages(client *jira.Client, jql string, fields []string) ([]json.RawMessage, error) {
+	var pages []json.RawMessage
+	var startAt int
+	for {
+		n := pageSize()
+		raw, err := searchRawPage(client, jql, fields, startAt, n)
+		if err != nil {
+			return nil, err
+		}
+		page, err := decodeSearchPage(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPage(n, len(page.Issues), startAt, page.Total); err != nil {
+			return nil, err
+		}
+		pages = append(pages, raw)
+
+		if len(page.Issues) == 0 {
+			break
+		}
+		startAt += len(page.Issues)
+		if startAt >= page.Total {
+			break
+		}
+	}
+	return pages, nil
+}
+
+// fetchIssues fetches and converts every issue selected by the query
+func fetchIssues(client *jira.Client, jql string, fields []string) ([]Issue, error) {
+	pages, err := fetchAllPages(client, jql, fields)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := decodePages(pages)
+	if err != nil {
+		return nil, err
+	}
+	return issuesFromJira(issues), nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSecuritySLAs are the remediation SLAs in days per priority
+const defaultSecuritySLAs = "Highest=7,High=30,Medium=90,Low=180,Lowest=365"
+
+// noSLASeverity groups the issues whose priority has no SLA
+const noSLASeverity = "No SLA"
+
+// securityAgeBuckets are the upper bounds in days of the aging buckets
+var securityAgeBuckets = []int{7, 30, 90, 180}
+
+// securityFields are the fields the security backlog reads
+var securityFields = []string{"issuetype", "summary", "status", "priority", "created", "labels", "issuelinks", manaFieldID, "reporter"}
+
+// severitySLA is the remediation SLA of one severity
+type severitySLA struct {
+	Severity string
+	Days     int
+}
+
+// parseSecuritySLAs parses a comma-separated list of severity=days pairs,
+// keeping their order
+func parseSecuritySLAs(list string) ([]severitySLA, error) {
+	var slas []severitySLA
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		severity, days, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid SLA %q, expected severity=days", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(days))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid SLA %q, days must be a positive number", pair)
+		}
+		slas = append(slas, severitySLA{Severity: strings.TrimSpace(severity), Days: n})
+	}
+	if len(slas) == 0 {
+		return nil, fmt.Errorf("no SLAs given")
+	}
+	return slas, nil
+}
+
+// SecurityIssue is an open security issue measured against its SLA
+type SecurityIssue struct {
+	Key      string
+	Summary  string
+	Status   string
+	Severity string
+	AgeDays  int
+	SLADays  int // 0 if the severity has no SLA
+	Mana     float64
+}
+
+// Breached reports whether the issue is open past its SLA
+func (s SecurityIssue) Breached() bool {
+	return s.SLADays > 0 && s.AgeDays > s.SLADays
+}
+
+// SeveritySummary is the open security backlog of one severity
+type SeveritySummary struct {
+	Severity   string
+	SLADays    int
+	Open       int
+	Breached   int
+	DueSoon    int // Within SLA, but due in the next 7 days
+	Mana       float64
+	OldestDays int
+	AgeBuckets []int // Count per securityAgeBuckets entry, plus one for older
+}
+
+// SecurityReport is the data model of the security command
+type SecurityReport struct {
+	Project    string
+	AsOf       time.Time
+	JQL        string
+	Severities []SeveritySummary
+	Total      SeveritySummary
+	Breached   []SecurityIssue // Sorted by days over SLA, worst first
+}
+
+// isSecurityIssue reports whether the issue is a security issue: linked to a
+// Product Vulnerability or carrying the security label
+func isSecurityIssue(issue Issue, label string) bool {
+	return vulnerabilityLink(issue) != "" || (label != "" && issue.HasLabel(label))
+}
+
+// ageBucket returns the index of the aging bucket for an age in days
+func ageBucket(days int) int {
+	for i, limit := range securityAgeBuckets {
+		if days <= limit {
+			return i
+		}
+	}
+	return len(securityAgeBuckets)
+}
+
+// analyzeSecurityBacklog measures the open security issues against the SLA
+// of their priority as of now
+func analyzeSecurityBacklog(issues []Issue, slas []severitySLA, label string, now time.Time) *SecurityReport {
+	report := &SecurityReport{AsOf: now}
+
+	summaries := make(map[string]*SeveritySummary)
+	order := make([]string, 0, len(slas)+1)
+	slaDays := make(map[string]int)
+	for _, s := range slas {
+		slaDays[s.Severity] = s.Days
+		summaries[s.Severity] = &SeveritySummary{Severity: s.Severity, SLADays: s.Days}
+		order = append(order, s.Severity)
+	}
+	summaries[noSLASeverity] = &SeveritySummary{Severity: noSLASeverity}
+	order = append(order, noSLASeverity)
+
+	report.Total = SeveritySummary{Severity: "TOTAL"}
+	for _, issue := range issues {
+		if !isSecurityIssue(issue, label) {
+			continue
+		}
+
+		severity := issue.Priority
+		if _, ok := slaDays[severity]; !ok {
+			severity = noSLASeverity
+		}
+		si := SecurityIssue{
+			Key:      issue.Key,
+			Summary:  removeEmojis(issue.Summary),
+			Status:   issue.Status,
+			Severity: issue.Priority,
+			AgeDays:  int(math.Floor(now.Sub(issue.Created).Hours() / 24)),
+			SLADays:  slaDays[severity],
+			Mana:     issue.Mana,
+		}
+
+		for _, s := range []*SeveritySummary{summaries[severity], &report.Total} {
+			if s.AgeBuckets == nil {
+				s.AgeBuckets = make([]int, len(securityAgeBuckets)+1)
+			}
+			s.Open++
+			s.Mana += si.Mana
+			s.AgeBuckets[ageBucket(si.AgeDays)]++
+			if si.AgeDays > s.OldestDays {
+				s.OldestDays = si.AgeDays
+			}
+			if si.Breached() {
+				s.Breached++
+			} else if si.SLADays > 0 && si.SLADays-si.AgeDays <= 7 {
+				s.DueSoon++
+			}
+		}
+		if si.Breached() {
+			report.Breached = append(report.Breached, si)
+		}
+	}
+
+	for _, severity := range order {
+		s := summaries[severity]
+		// Severities without SLA are only shown if they have issues
+		if severity == noSLASeverity && s.Open == 0 {
+			continue
+		}
+		if s.AgeBuckets == nil {
+			s.AgeBuckets = make([]int, len(securityAgeBuckets)+1)
+		}
+		report.Severities = append(report.Severities, *s)
+	}
+	if report.Total.AgeBuckets == nil {
+		report.Total.AgeBuckets = make([]int, len(securityAgeBuckets)+1)
+	}
+
+	sort.Slice(report.Breached, func(i, j int) bool {
+		oi := report.Breached[i].AgeDays - report.Breached[i].SLADays
+		oj := report.Breached[j].AgeDays - report.Breached[j].SLADays
+		if oi != oj {
+			return oi > oj
+		}
+		return report.Breached[i].Key < report.Breached[j].Key
+	})
+	return report
+}
+
+// writeSecurityReport writes the SLA, aging and breach tables in the layout
+func writeSecurityReport(w io.Writer, report *SecurityReport, jiraURL string, layout tableOptions) {
+	fmt.Fprintf(w, "\nSecurity Backlog as of %s\n", report.AsOf.Format("2006-01-02"))
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	// SLA table
+	slaTable := newTextTable(
+		tableColumn{Header: "Severity"},
+		tableColumn{Header: "SLA Days", Right: true},
+		tableColumn{Header: "Open", Right: true},
+		tableColumn{Header: "Breached", Right: true},
+		tableColumn{Header: "Due 7d", Right: true},
+		tableColumn{Header: "Mana to Date", Right: true},
+		tableColumn{Header: "Oldest Days", Right: true},
+	)
+	row := func(s SeveritySummary) []string {
+		sla := "-"
+		if s.SLADays > 0 {
+			sla = strconv.Itoa(s.SLADays)
+		}
+		return []string{s.Severity, sla, layout.Numbers.count(s.Open), layout.Numbers.count(s.Breached), layout.Numbers.count(s.DueSoon),
+			layout.Numbers.mana(s.Mana), strconv.Itoa(s.OldestDays)}
+	}
+	for _, s := range report.Severities {
+		slaTable.addRow(row(s)...)
+	}
+	slaTable.addFooter(row(report.Total)...)
+	fmt.Fprintf(w, "\nRemediation SLAs:\n")
+	slaTable.write(w, layout.Style)
+
+	// Aging table
+	columns := []tableColumn{{Header: "Severity"}}
+	prev := 0
+	for _, limit := range securityAgeBuckets {
+		columns = append(columns, tableColumn{Header: fmt.Sprintf("%d-%dd", prev, limit), Right: true})
+		prev = limit + 1
+	}
+	columns = append(columns, tableColumn{Header: fmt.Sprintf(">%dd", securityAgeBuckets[len(securityAgeBuckets)-1]), Right: true})
+	agingTable := newTextTable(columns...)
+	aging := func(s SeveritySummary) []string {
+		cells := []string{s.Severity}
+		for _, n := range s.AgeBuckets {
+			cells = append(cells, layout.Numbers.count(n))
+		}
+		return cells
+	}
+	for _, s := range report.Severities {
+		agingTable.addRow(aging(s)...)
+	}
+	agingTable.addFooter(aging(report.Total)...)
+	fmt.Fprintf(w, "\nAging:\n")
+	agingTable.write(w, layout.Style)
+
+	// Breached issues
+	fmt.Fprintf(w, "\nBreached SLAs: %d\n", len(report.Breached))
+	if len(report.Breached) == 0 {
+		return
+	}
+	breachTable := newTextTable(
+		tableColumn{Header: "Key"},
+		tableColumn{Header: "Severity"},
+		tableColumn{Header: "Days Open", Right: true},
+		tableColumn{Header: "Over SLA", Right: true},
+		tableColumn{Header: "Link"},
+		tableColumn{Header: "Summary", MaxWidth: 60},
+	)
+	for _, si := range report.Breached {
+		breachTable.addRow(si.Key, si.Severity, strconv.Itoa(si.AgeDays), strconv.Itoa(si.AgeDays-si.SLADays),
+			fmt.Sprintf("%s/browse/%s", jiraURL, si.Key), si.Summary)
+	}
+	breachTable.write(w, layout.Style)
+}
+
+func runSecurityCommand() {
+	// Command line flags
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	slaList := flag.String("sla", defaultSecuritySLAs, "Remediation SLA in days per priority, as priority=days pairs")
+	label := flag.String("label", "security", "Label marking security issues besides Product Vulnerability links (empty to disable)")
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	slas, err := parseSecuritySLAs(*slaList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, jiraURL, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// JQL can't select issues by the type of linked issues, so every open
+	// issue is fetched and the security ones are picked out afterwards
+	jql := fmt.Sprintf(`project = "%s" AND
+		statusCategory != Done AND
+		issuetype not in (Epic, Initiative)
+		ORDER BY created ASC`, *projectKey)
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := config.fieldsOf(*projectKey).fetchIssues(client, jql, securityFields)
+	if err != nil {
+		log.Fatalf("Error fetching open issues: %v", err)
+	}
+	issues = dropAutomationIssues(os.Stdout, issues, config)
+
+	report := analyzeSecurityBacklog(issues, slas, *label, time.Now())
+	report.Project = *projectKey
+	report.JQL = jql
+
+	title := fmt.Sprintf("%s Security Backlog %s", report.Project, report.AsOf.Format("2006-01-02"))
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeSecurityReport(w, report, jiraURL, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+	if githubActions {
+		annotateSLABreaches(os.Stdout, report)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// securityDepthUsage documents the -security-depth flag of the commands
+// with -security
+const securityDepthUsage = "Links followed from a ticket to a Product Vulnerability for -security, e.g. 2 also counts tickets linked to a bug linked to one"
+
+// vulnerabilityLinkFields are the fields read to follow issue links
+var vulnerabilityLinkFields = []string{"issuetype", "issuelinks"}
+
+// checkSecurityDepth checks the -security-depth flag
+func checkSecurityDepth(depth int) error {
+	if depth < 1 {
+		return fmt.Errorf("-security-depth must be at least 1")
+	}
+	return nil
+}
+
+// resolveVulnerabilityPaths finds the issues linked to a Product
+// Vulnerability through other issues, up to depth links away. Every hop
+// beyond the first fetches the links of the issues reached so far. The path
+// of each issue found is returned by its key: the keys of the issues in
+// between, followed by the key of the vulnerability. Issues linked to one
+// directly are left out.
+func resolveVulnerabilityPaths(client *jira.Client, issues []Issue, depth int) (map[string][]string, error) {
+	links := make(map[string][]IssueLink)
+	for _, issue := range issues {
+		links[issue.Key] = issue.Links
+	}
+	// next returns the linked issues whose links are not known yet
+	next := func(from []IssueLink, queued map[string]bool) []string {
+		var keys []string
+		for _, link := range from {
+			if _, known := links[link.Key]; !known && !queued[link.Key] && link.IssueType != vulnerabilityIssueType {
+				queued[link.Key] = true
+				keys = append(keys, link.Key)
+			}
+		}
+		return keys
+	}
+
+	queued := make(map[string]bool)
+	var frontier []string
+	for _, issue := range issues {
+		if vulnerabilityLink(issue) == "" {
+			frontier = append(frontier, next(issue.Links, queued)...)
+		}
+	}
+	for hop := 2; hop <= depth && len(frontier) > 0; hop++ {
+		found, err := searchByKeys(client, frontier, vulnerabilityLinkFields, "")
+		if err != nil {
+			return nil, err
+		}
+		// Issues that can't be read are dead ends
+		for _, key := range frontier {
+			links[key] = nil
+		}
+		fetched := issuesFromJira(found)
+		for _, issue := range fetched {
+			links[issue.Key] = issue.Links
+		}
+		frontier = nil
+		queued = make(map[string]bool)
+		if hop < depth {
+			for _, issue := range fetched {
+				frontier = append(frontier, next(issue.Links, queued)...)
+			}
+		}
+	}
+
+	paths := make(map[string][]string)
+	for _, issue := range issues {
+		if vulnerabilityLink(issue) != "" {
+			continue
+		}
+		if path := vulnerabilityPath(issue.Key, links, depth); path != nil {
+			paths[issue.Key] = path
+		}
+	}
+	return paths, nil
+}
+
+// vulnerabilityPath searches the links breadth first for the shortest path
+// of at most depth links from the issue to a Product Vulnerability
+func vulnerabilityPath(key string, links map[string][]IssueLink, depth int) []string {
+	type step struct {
+		key  string
+		path []string
+	}
+	seen := map[string]bool{key: true}
+	level := []step{{key: key}}
+	for d := 1; d <= depth; d++ {
+		var next []step
+		for _, s := range level {
+			for _, link := range links[s.key] {
+				path := append(append([]string{}, s.path...), link.Key)
+				if link.IssueType == vulnerabilityIssueType {
+					return path
+				}
+				if !seen[link.Key] {
+					seen[link.Key] = true
+					next = append(next, step{link.Key, path})
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// applySecurityDepth follows the links of the issues for -security-depth,
+// adding the issues linked to a Product Vulnerability through others to the
+// classification options. It does nothing without -security or beyond direct
+// links.
+func applySecurityDepth(client *jira.Client, w io.Writer, issues []Issue, depth int, opts *classifyOptions) error {
+	if !opts.Security || depth <= 1 {
+		return nil
+	}
+	paths, err := resolveVulnerabilityPaths(client, issues, depth)
+	if err != nil {
+		return fmt.Errorf("following links to Product Vulnerabilities: %w", err)
+	}
+	if opts.VulnerabilityPaths == nil {
+		opts.VulnerabilityPaths = make(map[string][]string)
+	}
+	for key, path := range paths {
+		opts.VulnerabilityPaths[key] = path
+	}
+	fmt.Fprintf(w, "Links followed up to %d deep: %d tickets linked to a Product Vulnerability through other issues\n", depth, len(paths))
+	return nil
+}
@@ -0,0 +1,822 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+//go:embed selftest
+var selftestFS embed.FS
+
+// Fixture data covers this period of the PROJ project
+const (
+	fixtureProject = "PROJ"
+	fixtureStart   = "2024-01-01"
+	fixtureEnd     = "2024-03-31"
+	// fixtureValueField is the business value field of the fixture epics
+	fixtureValueField = "customfield_12100"
+)
+
+// selftestFixtures is the bundled fixture data, decoded from Jira search results
+type selftestFixtures struct {
+	Tickets []Issue
+	// TicketHistories are the fixture tickets with their changelogs
+	TicketHistories map[string]jira.Issue
+	Epics           []Issue
+	EpicChildren    map[string][]Issue
+	EpicValues      map[string]float64 // Values of fixtureValueField
+	EpicDRIs        map[string]string  // Assignees of the epics
+	GitLabIssues    []gitlabIssue
+	Config          *Config
+}
+
+// selftestCase renders one output to compare against its golden file
+type selftestCase struct {
+	Golden string
+	Render func(fx *selftestFixtures) ([]byte, error)
+}
+
+// selftestCases covers every output format with the main flag combinations
+var selftestCases = []selftestCase{
+	{"ticket.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{Stats: mustParseStatistics(defaultStatistics)}, defaultTableOptions)
+	}},
+	{"ticket-teams.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}, defaultTableOptions)
+	}},
+	{"ticket-teams.md", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}, tableOptions{Style: tableStyleMarkdown, Numbers: defaultNumberFormat})
+	}},
+	{"ticket-org.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{
+			Teams:    true,
+			OrgChart: fx.Config.OrgChart,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}, defaultTableOptions)
+	}},
+	{"ticket-monthly.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{
+			Monthly: true,
+			Stats:   mustParseStatistics("mean,median,p90,stddev"),
+		}, defaultTableOptions)
+	}},
+	{"ticket-box.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{Stats: mustParseStatistics(defaultStatistics)}, tableOptions{
+			Style:   tableStyleBox,
+			Numbers: numberFormat{Precision: 1, TrimWhole: true, Thousands: ","},
+			Shares:  sharesBoth,
+		})
+	}},
+	{"ticket-overrides.txt", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/overrides.csv")
+		if err != nil {
+			return nil, err
+		}
+		overrides, err := parseOverrides("overrides.csv", b)
+		if err != nil {
+			return nil, err
+		}
+		opts := ticketOptions{Stats: mustParseStatistics(defaultStatistics)}
+		opts.Start, _ = time.Parse("2006-01-02", fixtureStart)
+		opts.End, _ = time.Parse("2006-01-02", fixtureEnd)
+		// Overrides correct a copy, leaving the fixtures to the other cases
+		issues := append([]Issue(nil), fx.Tickets...)
+		var buf bytes.Buffer
+		applyOverrides(&buf, issues, overrides, &opts.Classify)
+		report := analyzeTickets(issues, opts, 0)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		writeTicketReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"ticket-locale.txt", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/locale-de.json")
+		if err != nil {
+			return nil, err
+		}
+		labels, err := parseLocaleFile(b)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = selftestFS.ReadFile("selftest/fixtures/tickets-de.json"); err != nil {
+			return nil, err
+		}
+		page, err := decodeSearchPage(b)
+		if err != nil {
+			return nil, err
+		}
+		// Labels are translated as issues are read, so the German tickets
+		// are read with the locale file in place, as with -locale-file
+		english := jiraLabels
+		jiraLabels = labels
+		issues := issuesFromJira(page.Issues)
+		jiraLabels = english
+
+		opts := ticketOptions{Classify: classifyOptions{Security: true}, Stats: mustParseStatistics(defaultStatistics)}
+		opts.Start, _ = time.Parse("2006-01-02", fixtureStart)
+		opts.End, _ = time.Parse("2006-01-02", fixtureEnd)
+		report := analyzeTickets(issues, opts, 0)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeTicketReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"ticket-mana-time.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return renderFixtureTicketText(fx, ticketOptions{
+			Teams: true,
+			Stats: mustParseStatistics(defaultStatistics),
+		}, tableOptions{
+			Style:   tableStylePlain,
+			Numbers: numberFormat{Precision: 2, Thousands: ",", ManaUnits: manaUnitsTime},
+			Shares:  sharesMana,
+		})
+	}},
+	{"ticket-emit-jql.txt", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/overrides.csv")
+		if err != nil {
+			return nil, err
+		}
+		overrides, err := parseOverrides("overrides.csv", b)
+		if err != nil {
+			return nil, err
+		}
+		opts := ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}
+		opts.Start, _ = time.Parse("2006-01-02", fixtureStart)
+		opts.End, _ = time.Parse("2006-01-02", fixtureEnd)
+		issues := append([]Issue(nil), fx.Tickets...)
+		var buf bytes.Buffer
+		applyOverrides(&buf, issues, overrides, &opts.Classify)
+		rows := rowScope{
+			Filter: ticketJQLFilter(fixtureProject, resolvedBetween(opts.Start, opts.End, false)),
+			Issues: issues,
+			Fields: map[string]projectFields{"": standardProjectFields},
+		}
+		writeRowQueries(&buf, ticketRowQueries(rows, issues, opts), "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"ticket-cycle.txt", func(fx *selftestFixtures) ([]byte, error) {
+		opts := ticketOptions{Stats: mustParseStatistics(defaultStatistics)}
+		report := fixtureTicketReport(fx, opts)
+		cal, err := newBusinessCalendar(fx.Config.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		statuses, err := parseTouchStatuses(defaultTouchStatuses)
+		if err != nil {
+			return nil, err
+		}
+		report.CycleTimes = analyzeCycleTimes(fx.Tickets, fx.TicketHistories, statuses, cal, opts.Classify)
+		var buf bytes.Buffer
+		writeTicketReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"ticket-keys.txt", func(fx *selftestFixtures) ([]byte, error) {
+		keys, err := parseIssueKeys("PROJ-1, proj-2\nPROJ-10,PROJ-11,PROJ-12,PROJ-13,PROJ-404,PROJ-1", strings.NewReader(""))
+		if err != nil {
+			return nil, err
+		}
+		var found []jira.Issue
+		for _, key := range keys {
+			if ji, ok := fx.TicketHistories[key]; ok {
+				found = append(found, ji)
+			}
+		}
+		keyed := selectKeyedIssues(keys, found)
+		report := analyzeTickets(keyed.Issues, ticketOptions{Stats: mustParseStatistics(defaultStatistics)}, 0)
+		report.Project = keyProjects(keys)
+		report.Start = keyed.First.Format("2006-01-02")
+		report.End = keyed.Last.Format("2006-01-02")
+		report.JQL = keysJQL(keys)
+		report.Keys = &keyed.Selection
+		var buf bytes.Buffer
+		writeTicketReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"orphans.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeOrphans(fx.Tickets)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeOrphanReport(&buf, report, "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"churn.txt", func(fx *selftestFixtures) ([]byte, error) {
+		done, err := parseTouchStatuses(defaultDoneStatuses)
+		if err != nil {
+			return nil, err
+		}
+		start, _ := time.Parse("2006-01-02", fixtureStart)
+		end, _ := time.Parse("2006-01-02", fixtureEnd)
+		report := analyzeChurn(fx.Tickets, fx.TicketHistories, start, end, done, "reopens", defaultChurnTop)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.DoneStatuses = "Resolved, Closed, Done"
+		var buf bytes.Buffer
+		writeChurnReport(&buf, report, "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"calibrate.txt", func(fx *selftestFixtures) ([]byte, error) {
+		var buf bytes.Buffer
+		writeCalibrationSample(&buf, fixtureCalibrationSample(fx), "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"calibrate-task.txt", func(fx *selftestFixtures) ([]byte, error) {
+		return []byte(calibrationTaskDescription(fixtureCalibrationSample(fx), "https://jira.example.com")), nil
+	}},
+	{"labels.txt", func(fx *selftestFixtures) ([]byte, error) {
+		groups, err := parseLabelGroups("area-.*")
+		if err != nil {
+			return nil, err
+		}
+		report := analyzeLabels(fx.Tickets, groups, defaultLabelMatrixSize)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeLabelReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"sizes.txt", func(fx *selftestFixtures) ([]byte, error) {
+		start, end, err := parseRange(fixtureStart, fixtureEnd)
+		if err != nil {
+			return nil, err
+		}
+		sizes := ticketSizes()
+		report := analyzeSizeMix(fx.Tickets, sizes, monthsInRange(start, end), findTicketSize(sizes, defaultLargeSize))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeSizeMixReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"balance.txt", func(fx *selftestFixtures) ([]byte, error) {
+		start, end, err := parseRange(fixtureStart, fixtureEnd)
+		if err != nil {
+			return nil, err
+		}
+		// The fixture tickets created in the range stand in for the
+		// incoming tickets
+		var incoming []Issue
+		for _, issue := range fx.Tickets {
+			if !issue.Created.Before(start) && issue.Created.Before(end) {
+				incoming = append(incoming, issue)
+			}
+		}
+		report := analyzeBalance(incoming, fx.Tickets, monthsInRange(start, end), defaultImbalance, 2)
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.IncomingJQL = "(fixture data)"
+		report.ResolvedJQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeBalanceReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"ticket-gitlab.txt", func(fx *selftestFixtures) ([]byte, error) {
+		opts := ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}
+		opts.Start, _ = time.Parse("2006-01-02", fixtureStart)
+		opts.End, _ = time.Parse("2006-01-02", fixtureEnd)
+		mapping := gitlabMapping(nil)
+		report := analyzeTickets(selectGitLabIssues(fx.GitLabIssues, mapping), opts, 0)
+		report.Project = "acme/shop"
+		report.EndInclusive = true
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = gitlabQuery(report.Project, opts.Start, opts.End, mapping)
+		var buf bytes.Buffer
+		writeTicketReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"incidents.txt", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/incidents.csv")
+		if err != nil {
+			return nil, err
+		}
+		incidents, err := parseIncidentsCSV(b)
+		if err != nil {
+			return nil, err
+		}
+		// The area labels of the fixture tickets stand in for incident
+		// labels, and the tickets linked to an incident's issue link back
+		const prefix = "area-"
+		linked := make(map[string][]Issue)
+		for _, issue := range fx.Tickets {
+			for _, link := range issue.Links {
+				linked[link.Key] = append(linked[link.Key], issue)
+			}
+		}
+		report := correlateIncidents(incidents, fx.Tickets, linked, prefix, 30)
+		report.Project = fixtureProject
+		report.Source = "incidents.csv"
+		report.JQL = "(fixture data)"
+		var buf bytes.Buffer
+		writeIncidentReport(&buf, report, "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"explain.csv", func(fx *selftestFixtures) ([]byte, error) {
+		opts := classifyOptions{
+			BrokenWindows: true,
+			Security:      true,
+			ResearchTypes: map[string]bool{"Spike": true, "Research": true},
+			Research:      researchStory,
+		}
+		return renderExplanations(explainClassification(fx.Tickets, opts), "csv")
+	}},
+	{"ticket-webhook.json", func(fx *selftestFixtures) ([]byte, error) {
+		report := fixtureTicketReport(fx, ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		})
+		return renderWebhookPayload(report, "")
+	}},
+	{"ticket-otlp.json", func(fx *selftestFixtures) ([]byte, error) {
+		report := fixtureTicketReport(fx, ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		})
+		now := time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC)
+		b, err := renderOTLPMetrics(report, 12500*time.Millisecond, now)
+		if err != nil {
+			return nil, err
+		}
+		// Release builds carry their version in the scope
+		var payload otlpMetricsRequest
+		if err := json.Unmarshal(b, &payload); err != nil {
+			return nil, err
+		}
+		payload.ResourceMetrics[0].ScopeMetrics[0].Scope.Version = "dev"
+		b, err = json.MarshalIndent(payload, "", "  ")
+		return append(b, '\n'), err
+	}},
+	{"report-v0-converted.json", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/report-v0.json")
+		if err != nil {
+			return nil, err
+		}
+		doc, err := upgradeReportJSON(b)
+		if err != nil {
+			return nil, err
+		}
+		converted, err := json.MarshalIndent(doc, "", "  ")
+		return append(converted, '\n'), err
+	}},
+	{"ticket-teams.pdf", func(fx *selftestFixtures) ([]byte, error) {
+		text, _ := renderFixtureTicketText(fx, ticketOptions{
+			Classify: classifyOptions{BrokenWindows: true, Security: true},
+			Teams:    true,
+			Stats:    mustParseStatistics(defaultStatistics),
+		}, defaultTableOptions)
+		var buf bytes.Buffer
+		err := writeTextPDF(&buf, "PROJ Mana Analysis", string(text))
+		return buf.Bytes(), err
+	}},
+	{"ticket-msteams.json", func(fx *selftestFixtures) ([]byte, error) {
+		report := fixtureTicketReport(fx, ticketOptions{Stats: mustParseStatistics(defaultStatistics)})
+		return renderTeamsCard(report, "https://reports.example.com/proj/2024-q1.html")
+	}},
+	{"metric.txt", func(fx *selftestFixtures) ([]byte, error) {
+		const name = "bug-mana-by-group"
+		metric := fx.Config.Metrics[name]
+		// The fixture tickets stand in for the metric's query
+		var bugs []Issue
+		for _, issue := range fx.Tickets {
+			if issue.Type == "Bug" {
+				bugs = append(bugs, issue)
+			}
+		}
+		report, err := analyzeMetric(bugs, metricValues(bugs, nil, metric), metric, fx.Config.OrgChart)
+		if err != nil {
+			return nil, err
+		}
+		report.Name = name
+		report.JQL = metric.JQL
+		var buf bytes.Buffer
+		writeMetricReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"archive-index.json", func(fx *selftestFixtures) ([]byte, error) {
+		indexJSON, _, err := renderArchiveIndex(fixtureArchiveIndex())
+		return indexJSON, err
+	}},
+	{"archive-index.html", func(fx *selftestFixtures) ([]byte, error) {
+		_, page, err := renderArchiveIndex(fixtureArchiveIndex())
+		return page, err
+	}},
+	{"epic.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		tmpl, err := parseEpicChildTemplate(defaultEpicChildJQL)
+		if err != nil {
+			return nil, err
+		}
+		if report.ChildJQL, err = epicChildrenJQL(tmpl, childScope{Project: "PROJECT_KEY"}, "EPIC_KEY"); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-value.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		applyEpicValues(report, "Business Value", fx.EpicValues)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-dri.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		applyEpicDRIs(report, "Assignee", fx.EpicDRIs)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-slip.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		applyEpicSlip(report, fx.EpicChildren)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-eta.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		end, _ := time.Parse("2006-01-02", fixtureEnd)
+		applyEpicETA(report, fx.EpicChildren, nil, end.AddDate(0, 0, 1))
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-status.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		applyEpicChildStatus(report, fx.EpicChildren, nil)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-sparkline.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		start, end, err := parseRange(fixtureStart, fixtureEnd)
+		if err != nil {
+			return nil, err
+		}
+		applyEpicActivity(report, fx.EpicChildren, start, end)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-dormancy.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		end, _ := time.Parse("2006-01-02", fixtureEnd)
+		applyEpicDormancy(report, fx.EpicChildren, end)
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-diff.txt", func(fx *selftestFixtures) ([]byte, error) {
+		b, err := selftestFS.ReadFile("selftest/fixtures/epic-diff.json")
+		if err != nil {
+			return nil, err
+		}
+		var pages struct {
+			Epic     json.RawMessage `json:"epic"`
+			Children json.RawMessage `json:"children"`
+		}
+		if err := json.Unmarshal(b, &pages); err != nil {
+			return nil, fmt.Errorf("epic-diff.json: %w", err)
+		}
+		epics, err := decodeSearchPage(pages.Epic)
+		if err != nil {
+			return nil, fmt.Errorf("epic-diff.json: epic: %w", err)
+		}
+		children, err := decodeSearchPage(pages.Children)
+		if err != nil {
+			return nil, fmt.Errorf("epic-diff.json: children: %w", err)
+		}
+		epic := epics.Issues[0]
+		// The fixture children carry their changelogs and current fields
+		// alike, and PROJ-305 is only known from the epic's changelog
+		byKey := make(map[string]jira.Issue)
+		var current []jira.Issue
+		for _, ji := range children.Issues {
+			byKey[ji.Key] = ji
+			if ji.Key != "PROJ-305" {
+				current = append(current, ji)
+			}
+		}
+		at, _ := time.Parse("2006-01-02", "2024-01-31")
+		vs, _ := time.Parse("2006-01-02", fixtureEnd)
+		diff := diffEpicScope(epic.Key, epic.ID, epicChildCandidates(epic, current), byKey, byKey, at, vs)
+		diff.Summary = epic.Fields.Summary
+		var buf bytes.Buffer
+		writeEpicScopeDiff(&buf, diff, "https://jira.example.com", defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic-category-mix.txt", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		report.Start = fixtureStart
+		report.End = fixtureEnd
+		report.JQL = "(fixture data)"
+		report.ChildJQL = "(fixture data)"
+		applyEpicCategoryMix(report, fx.EpicChildren, classifyOptions{BrokenWindows: true, Security: true})
+		var buf bytes.Buffer
+		writeEpicReport(&buf, report, defaultTableOptions)
+		return buf.Bytes(), nil
+	}},
+	{"epic.ics", func(fx *selftestFixtures) ([]byte, error) {
+		report := analyzeEpics(fx.Epics, fx.EpicChildren, mustParseStatistics(defaultStatistics))
+		report.Project = fixtureProject
+		start, _ := time.Parse("2006-01-02", fixtureStart)
+		end, _ := time.Parse("2006-01-02", fixtureEnd)
+		var buf bytes.Buffer
+		events := epicCalendarEvents(report, "https://jira.example.com", start, end, true)
+		err := writeCalendar(&buf, events, start)
+		return buf.Bytes(), err
+	}},
+}
+
+// mustParseStatistics parses a statistics list known to be valid
+func mustParseStatistics(list string) []Statistic {
+	stats, err := parseStatistics(list)
+	if err != nil {
+		panic(err)
+	}
+	return stats
+}
+
+// fixtureTicketReport runs the ticket analysis over the fixture tickets
+func fixtureTicketReport(fx *selftestFixtures, opts ticketOptions) *Report {
+	opts.Start, _ = time.Parse("2006-01-02", fixtureStart)
+	opts.End, _ = time.Parse("2006-01-02", fixtureEnd)
+	report := analyzeTickets(fx.Tickets, opts, 0)
+	report.Project = fixtureProject
+	report.Start = fixtureStart
+	report.End = fixtureEnd
+	report.JQL = "(fixture data)"
+	return report
+}
+
+// renderFixtureTicketText renders the ticket report over the fixtures as text
+// with tables in the layout
+func renderFixtureTicketText(fx *selftestFixtures, opts ticketOptions, layout tableOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	writeTicketReport(&buf, fixtureTicketReport(fx, opts), layout)
+	return buf.Bytes(), nil
+}
+
+// fixtureArchiveIndex is the index of an archive of reports written by the
+// report commands, with their titles
+func fixtureArchiveIndex() *ArchiveIndex {
+	written := time.Date(2024, 4, 2, 9, 30, 0, 0, time.UTC)
+	index := &ArchiveIndex{Title: "Engineering reports", Updated: written.Add(time.Hour)}
+	for _, r := range []struct {
+		file  string
+		title string
+		size  int64
+	}{
+		{"2024/PROJ-2024Q1.pdf", "PROJ-2024Q1 Mana Analysis", 48213},
+		{"2024/PROJ-2024Q1-epics.txt", "PROJ-2024Q1 Epic Analysis", 6120},
+		{"2024/proj-jan.txt", "PROJ Mana Analysis 2024-01-01 to 2024-01-31", 3480},
+		{"2024/ops-churn.md", "OPS Churn Analysis 2024-01-01 to 2024-03-31", 2210},
+		{"metric-feb.txt", "Metric bug-mana-by-group 2024-02", 640},
+		{"notes.txt", "notes", 512},
+	} {
+		e := ArchiveEntry{
+			File:    r.file,
+			Title:   r.title,
+			Format:  archiveFormats[path.Ext(r.file)],
+			Size:    r.size,
+			Written: written,
+		}
+		e.Project, e.Period, e.Start = parseReportTitle(r.title)
+		index.Reports = append(index.Reports, e)
+	}
+	return index
+}
+
+// loadSelftestFixtures decodes the bundled fixture data
+func loadSelftestFixtures() (*selftestFixtures, error) {
+	readPage := func(name string) ([]jira.Issue, error) {
+		b, err := selftestFS.ReadFile(path.Join("selftest/fixtures", name))
+		if err != nil {
+			return nil, err
+		}
+		page, err := decodeSearchPage(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return page.Issues, nil
+	}
+
+	fx := &selftestFixtures{EpicChildren: make(map[string][]Issue)}
+	tickets, err := readPage("tickets.json")
+	if err != nil {
+		return nil, err
+	}
+	fx.Tickets = issuesFromJira(tickets)
+	fx.TicketHistories = make(map[string]jira.Issue, len(tickets))
+	for _, ji := range tickets {
+		fx.TicketHistories[ji.Key] = ji
+	}
+	epics, err := readPage("epics.json")
+	if err != nil {
+		return nil, err
+	}
+	fx.Epics = issuesFromJira(epics)
+	fx.EpicValues = epicValues(epics, fixtureValueField)
+	fx.EpicDRIs = epicDRIs(epics, "assignee")
+
+	b, err := selftestFS.ReadFile("selftest/fixtures/epic-children.json")
+	if err != nil {
+		return nil, err
+	}
+	var childPages map[string]json.RawMessage
+	if err := json.Unmarshal(b, &childPages); err != nil {
+		return nil, fmt.Errorf("epic-children.json: %w", err)
+	}
+	for key, raw := range childPages {
+		page, err := decodeSearchPage(raw)
+		if err != nil {
+			return nil, fmt.Errorf("epic-children.json: %s: %w", key, err)
+		}
+		fx.EpicChildren[key] = issuesFromJira(page.Issues)
+	}
+
+	if b, err = selftestFS.ReadFile("selftest/fixtures/gitlab-issues.json"); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fx.GitLabIssues); err != nil {
+		return nil, fmt.Errorf("gitlab-issues.json: %w", err)
+	}
+
+	if b, err = selftestFS.ReadFile("selftest/fixtures/config.json"); err != nil {
+		return nil, err
+	}
+	if fx.Config, err = parseConfig(b); err != nil {
+		return nil, fmt.Errorf("config.json: %w", err)
+	}
+	return fx, nil
+}
+
+// firstDifference describes the first line at which got differs from want
+func firstDifference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n    want: %q\n    got:  %q", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+func runSelftestCommand() {
+	update := flag.Bool("update", false, "Rewrite the golden files with the current output")
+	goldenDir := flag.String("golden-dir", filepath.Join("selftest", "golden"), "Directory golden files are written to with -update")
+	flag.Parse()
+
+	fx, err := loadSelftestFixtures()
+	if err != nil {
+		log.Fatalf("Error loading fixtures: %v", err)
+	}
+
+	failed := 0
+	for _, c := range selftestCases {
+		got, err := c.Render(fx)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.Golden, err)
+			failed++
+			continue
+		}
+
+		if *update {
+			if err := os.WriteFile(filepath.Join(*goldenDir, c.Golden), got, 0o644); err != nil {
+				log.Fatalf("Error writing golden file: %v", err)
+			}
+			fmt.Printf("UPDATED %s\n", c.Golden)
+			continue
+		}
+
+		want, err := selftestFS.ReadFile(path.Join("selftest/golden", c.Golden))
+		if err != nil {
+			fmt.Printf("FAIL %s: no golden file, run with -update\n", c.Golden)
+			failed++
+			continue
+		}
+		if !bytes.Equal(want, got) {
+			fmt.Printf("FAIL %s: output differs from golden file at %s\n", c.Golden, firstDifference(want, got))
+			failed++
+			continue
+		}
+		fmt.Printf("PASS %s\n", c.Golden)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(selftestCases))
+		os.Exit(1)
+	}
+}
+
+// fixtureCalibrationSample samples the fixture tickets resolved in February
+// with the default seed of the month
+func fixtureCalibrationSample(fx *selftestFixtures) *CalibrationSample {
+	const month = "2024-02"
+	start, end, _ := parseMonth(month)
+	var issues []Issue
+	for _, issue := range fx.Tickets {
+		if !issue.Resolved.Before(start) && issue.Resolved.Before(end.AddDate(0, 0, 1)) {
+			issues = append(issues, issue)
+		}
+	}
+	opts := classifyOptions{BrokenWindows: true, Security: true}
+	sample := selectCalibrationSample(issues, opts, defaultCalibrationSize, calibrationSeed(fixtureProject, month))
+	sample.Project = fixtureProject
+	sample.Month = month
+	sample.JQL = "(fixture data)"
+	return sample
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sizeFields are the fields the size mix analysis reads
+var sizeFields = []string{manaFieldID, "resolutiondate", teamFieldID, "reporter"}
+
+// sizesTeamField is the Team field as the sizes command reads it
+var sizesTeamField = optionalField{ID: teamFieldID, Name: "Team", Features: "Size Mix by Team"}
+
+// defaultLargeSize is the smallest ticket size counted as large
+const defaultLargeSize = "X-Large"
+
+// ticketSize is a Mana Spent option seen as a ticket size, named without its
+// description, e.g. Small for "Small (2 hours or less)"
+type ticketSize struct {
+	Name string
+	Mana float64
+}
+
+// ticketSizes returns the ticket sizes from smallest to largest
+func ticketSizes() []ticketSize {
+	var sizes []ticketSize
+	for option, mana := range manaOptionPoints {
+		name, _, _ := strings.Cut(option, " (")
+		sizes = append(sizes, ticketSize{Name: name, Mana: mana})
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].Mana < sizes[j].Mana })
+	return sizes
+}
+
+// findTicketSize returns the index of the named size, matched
+// case-insensitively, or -1
+func findTicketSize(sizes []ticketSize, name string) int {
+	for i, s := range sizes {
+		if strings.EqualFold(s.Name, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sizeOf returns the index of the largest size whose mana the ticket's mana
+// reaches
+func sizeOf(sizes []ticketSize, mana float64) int {
+	index := 0
+	for i, s := range sizes {
+		if mana >= s.Mana {
+			index = i
+		}
+	}
+	return index
+}
+
+// SizeMix is the number of tickets of every size in one month, team or the
+// whole period
+type SizeMix struct {
+	Name    string
+	Tickets int
+	Mana    float64
+	Counts  []int // Tickets per size, smallest first
+}
+
+// newSizeMix returns an empty size mix over the number of sizes
+func newSizeMix(name string, sizes int) SizeMix {
+	return SizeMix{Name: name, Counts: make([]int, sizes)}
+}
+
+// add counts a ticket of the size
+func (m *SizeMix) add(size int, mana float64) {
+	m.Tickets++
+	m.Mana += mana
+	m.Counts[size]++
+}
+
+// share returns the percentage of tickets of the sizes from..to
+func (m SizeMix) share(from, to int) float64 {
+	if m.Tickets == 0 {
+		return 0
+	}
+	n := 0
+	for i := from; i <= to; i++ {
+		n += m.Counts[i]
+	}
+	return float64(n) / float64(m.Tickets) * 100
+}
+
+// SizeMixTeam is the size mix of one team, overall and per month
+type SizeMixTeam struct {
+	SizeMix
+	Months []SizeMix
+}
+
+// SizeMixReport is the data model of the sizes command
+type SizeMixReport struct {
+	Project      string
+	Start        string
+	End          string
+	EndInclusive bool // Set if tickets resolved at any time on the end date count
+	JQL          string
+	Sizes        []string
+	LargeFrom    int // Index of the smallest size counted as large
+	Total        SizeMix
+	Months       []SizeMix     // Every month of the range
+	Teams        []SizeMixTeam // By team name, No Team for tickets without one
+	// MissingFields lists the fields Jira did not return and the features
+	// left out without them
+	MissingFields []string
+}
+
+// analyzeSizeMix counts the tickets of every size per month of the range,
+// and per team and month
+func analyzeSizeMix(issues []Issue, sizes []ticketSize, months []time.Time, largeFrom int) *SizeMixReport {
+	report := &SizeMixReport{LargeFrom: largeFrom, Total: newSizeMix("TOTAL", len(sizes))}
+	for _, s := range sizes {
+		report.Sizes = append(report.Sizes, s.Name)
+	}
+	monthMixes := func() []SizeMix {
+		mixes := make([]SizeMix, len(months))
+		for i, m := range months {
+			mixes[i] = newSizeMix(m.Format(monthLabelFormat), len(sizes))
+		}
+		return mixes
+	}
+	report.Months = monthMixes()
+	monthOf := monthDimension(months).Group
+
+	teams := make(map[string]*SizeMixTeam)
+	for _, issue := range issues {
+		month := monthOf(issue)
+		if month == "" {
+			continue
+		}
+		size := sizeOf(sizes, issue.Mana)
+		team := teamDimension.Group(issue)
+		if teams[team] == nil {
+			teams[team] = &SizeMixTeam{SizeMix: newSizeMix(team, len(sizes)), Months: monthMixes()}
+		}
+		report.Total.add(size, issue.Mana)
+		teams[team].add(size, issue.Mana)
+		for i := range report.Months {
+			if report.Months[i].Name == month {
+				report.Months[i].add(size, issue.Mana)
+				teams[team].Months[i].add(size, issue.Mana)
+			}
+		}
+	}
+
+	for _, team := range teams {
+		report.Teams = append(report.Teams, *team)
+	}
+	sort.Slice(report.Teams, func(i, j int) bool {
+		return report.Teams[i].Name < report.Teams[j].Name
+	})
+	return report
+}
+
+// largeShare returns the percentage of large tickets of the mix
+func (r *SizeMixReport) largeShare(m SizeMix) float64 {
+	return m.share(r.LargeFrom, len(r.Sizes)-1)
+}
+
+// largeLabel names the large sizes, e.g. "X-Large+"
+func (r *SizeMixReport) largeLabel() string {
+	if r.LargeFrom == len(r.Sizes)-1 {
+		return r.Sizes[r.LargeFrom]
+	}
+	return r.Sizes[r.LargeFrom] + "+"
+}
+
+// largeTrend compares the share of large tickets in the first and last
+// months with tickets, e.g. "12.0% to 25.0% (+13.0)", or "-" with fewer
+// than two such months
+func (r *SizeMixReport) largeTrend(months []SizeMix) string {
+	var first, last *SizeMix
+	for i := range months {
+		if months[i].Tickets == 0 {
+			continue
+		}
+		if first == nil {
+			first = &months[i]
+		}
+		last = &months[i]
+	}
+	if first == nil || first == last {
+		return "-"
+	}
+	from, to := r.largeShare(*first), r.largeShare(*last)
+	return fmt.Sprintf("%.1f%% to %.1f%% (%+.1f)", from, to, to-from)
+}
+
+// sizeMixTable builds a table of size mixes with the share of every size
+func (r *SizeMixReport) sizeMixTable(header string, trend bool) *textTable {
+	columns := []tableColumn{{Header: header, MaxWidth: 30}, {Header: "Tickets", Right: true}}
+	for _, s := range r.Sizes {
+		columns = append(columns, tableColumn{Header: s, Right: true})
+	}
+	columns = append(columns,
+		tableColumn{Header: r.largeLabel(), Right: true},
+		tableColumn{Header: "Avg Mana", Right: true})
+	if trend {
+		columns = append(columns, tableColumn{Header: r.largeLabel() + " Trend"})
+	}
+	return newTextTable(columns...)
+}
+
+// sizeMixCells returns the cells of a size mix row. Shares of a mix without
+// tickets are "-".
+func (r *SizeMixReport) sizeMixCells(m SizeMix, numbers numberFormat) []string {
+	percent := func(share float64) string {
+		if m.Tickets == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", share)
+	}
+	cells := []string{m.Name, numbers.count(m.Tickets)}
+	for i := range r.Sizes {
+		cells = append(cells, percent(m.share(i, i)))
+	}
+	return append(cells,
+		percent(r.largeShare(m)),
+		numbers.mana(safeAverage(m.Mana, m.Tickets)))
+}
+
+// writeSizeMixReport writes the size mix per month and per team
+func writeSizeMixReport(w io.Writer, report *SizeMixReport, layout tableOptions) {
+	writeMissingFields(w, report.MissingFields)
+	fmt.Fprintf(w, "\nSize Mix Analysis Period: %s to %s\n", report.Start, report.End)
+	fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+
+	months := report.sizeMixTable("Month", false)
+	for _, m := range report.Months {
+		months.addRow(report.sizeMixCells(m, layout.Numbers)...)
+	}
+	months.addFooter(report.sizeMixCells(report.Total, layout.Numbers)...)
+	fmt.Fprintf(w, "\nSize Mix by Month (share of tickets):\n")
+	months.write(w, layout.Style)
+	fmt.Fprintf(w, "%s tickets, first to last month with tickets: %s\n", report.largeLabel(), report.largeTrend(report.Months))
+
+	if report.Teams == nil {
+		return
+	}
+	teams := report.sizeMixTable("Team", true)
+	for _, t := range report.Teams {
+		teams.addRow(append(report.sizeMixCells(t.SizeMix, layout.Numbers), report.largeTrend(t.Months))...)
+	}
+	fmt.Fprintf(w, "\nSize Mix by Team (share of tickets):\n")
+	teams.write(w, layout.Style)
+	fmt.Fprintf(w, "Sizes are the Mana Spent options; a growing %s share is an early sign of work not being sliced small enough.\n", report.largeLabel())
+}
+
+func runSizesCommand() {
+	// Command line flags
+	startDate := flag.String("start", "", "Start date (YYYY-MM-DD)")
+	endDate := flag.String("end", "", "End date (YYYY-MM-DD)")
+	projectKey := flag.String("project", "", "JIRA project key (e.g., PROJ)")
+	largeSize := flag.String("large", defaultLargeSize, "Smallest ticket size counted as large, e.g. Large or X-Large")
+	endInclusive := flag.Bool("end-inclusive", false, endInclusiveUsage)
+	format := flag.String("format", "text", "Report format: text or pdf")
+	output := flag.String("output", "", "Write the report to this file instead of stdout")
+	tables := defineTableFlags()
+	configPath := flag.String("config", "", configUsage)
+	flag.Parse()
+
+	// Validate flags
+	if *startDate == "" || *endDate == "" || *projectKey == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	sizes := ticketSizes()
+	largeFrom := findTicketSize(sizes, *largeSize)
+	if largeFrom < 0 {
+		var names []string
+		for _, s := range sizes {
+			names = append(names, s.Name)
+		}
+		log.Fatalf("invalid -large %q, expected one of %s", *largeSize, strings.Join(names, ", "))
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	layout, err := tables.options(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := resolveRange(startDate, endDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create JIRA client from the environment
+	client, _, err := newClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The tickets of the ticket report, on the project's own fields
+	own := config.fieldsOf(*projectKey)
+	jql := projectTicketJQLFilter(*projectKey, resolvedBetween(start, end, *endInclusive), own) + `
+		ORDER BY created DESC`
+
+	// Validate the query before fetching anything
+	if err := validateJQL(client, jql); err != nil {
+		log.Fatal(err)
+	}
+
+	// Without the Team field only the months are broken down
+	missing, err := findMissingFields(client, jql, []optionalField{sizesTeamField}, own)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := own.fetchIssues(client, jql, sizeFields)
+	if err != nil {
+		log.Fatalf("Error fetching tickets: %v", err)
+	}
+	issues = dropAutomationIssues(os.Stdout, issues, config)
+
+	report := analyzeSizeMix(issues, sizes, monthsInRange(start, end), largeFrom)
+	for _, f := range missing {
+		report.Teams = nil
+		report.MissingFields = append(report.MissingFields, describeMissingField(f, own))
+	}
+	writeMissingFields(os.Stderr, report.MissingFields)
+	report.Project = *projectKey
+	report.Start = *startDate
+	report.End = *endDate
+	report.EndInclusive = *endInclusive
+	report.JQL = jql
+
+	title := fmt.Sprintf("%s Size Mix Analysis %s to %s", report.Project, report.Start, report.End)
+	if err := writeFormatted(*format, *output, title, func(w io.Writer) { writeSizeMixReport(w, report, layout) }); err != nil {
+		log.Fatalf("Error writing report: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("\nReport written to %s\n", *output)
+	}
+}
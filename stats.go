@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Statistic is a named metric computed over the mana values of a group of issues
+type Statistic struct {
+	// Key is the name used with -stats and in machine-readable output
+	Key string
+	// Column is the table column header
+	Column string
+	// Compute returns the statistic for the values, which may be empty
+	Compute func(values []float64) float64
+}
+
+// defaultStatistics are the statistic columns shown when -stats is not set
+const defaultStatistics = "mean,median"
+
+var statisticRegistry = make(map[string]Statistic)
+
+var percentileKeyRegex = regexp.MustCompile(`^p(\d{1,2})$`)
+
+// registerStatistic makes a statistic available to every table and output format
+func registerStatistic(s Statistic) {
+	statisticRegistry[s.Key] = s
+}
+
+func init() {
+	registerStatistic(Statistic{Key: "count", Column: "Count", Compute: func(values []float64) float64 {
+		return float64(len(values))
+	}})
+	registerStatistic(Statistic{Key: "sum", Column: "Total Mana", Compute: calculateSum})
+	registerStatistic(Statistic{Key: "mean", Column: "Avg Mana", Compute: calculateMean})
+	registerStatistic(Statistic{Key: "median", Column: "Median Mana", Compute: calculateMedian})
+	registerStatistic(Statistic{Key: "stddev", Column: "Std Dev Mana", Compute: calculateStdDev})
+}
+
+// lookupStatistic returns the registered statistic with the key. Percentiles
+// are available for any pXX key, e.g. p90.
+func lookupStatistic(key string) (Statistic, error) {
+	if s, ok := statisticRegistry[key]; ok {
+		return s, nil
+	}
+	if m := percentileKeyRegex.FindStringSubmatch(key); m != nil {
+		p, _ := strconv.Atoi(m[1])
+		return Statistic{
+			Key:    key,
+			Column: fmt.Sprintf("P%d Mana", p),
+			Compute: func(values []float64) float64 {
+				return calculatePercentile(values, float64(p))
+			},
+		}, nil
+	}
+
+	var known []string
+	for k := range statisticRegistry {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+	return Statistic{}, fmt.Errorf("unknown statistic %q, expected one of %s or pXX", key, strings.Join(known, ", "))
+}
+
+// parseStatistics parses a comma-separated list of statistic keys
+func parseStatistics(list string) ([]Statistic, error) {
+	var stats []Statistic
+	for _, key := range strings.Split(list, ",") {
+		key = strings.TrimSpace(strings.ToLower(key))
+		if key == "" {
+			continue
+		}
+		s, err := lookupStatistic(key)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// computeStatistics computes every statistic over the values, keyed by statistic key
+func computeStatistics(values []float64, stats []Statistic) map[string]float64 {
+	computed := make(map[string]float64, len(stats))
+	for _, s := range stats {
+		computed[s.Key] = s.Compute(values)
+	}
+	return computed
+}
+
+// calculateSum returns the sum of the values
+func calculateSum(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// calculateMean returns the average of the values
+func calculateMean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return calculateSum(values) / float64(len(values))
+}
+
+// calculateMedian returns the median value from a slice of float64
+func calculateMedian(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	// Create a copy to avoid modifying the original slice
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	// If odd number of values
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+
+	// If even number of values
+	mid := len(sorted) / 2
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// calculateStdDev returns the population standard deviation of the values
+func calculateStdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	mean := calculateMean(values)
+	var sumSquares float64
+	for _, v := range values {
+		sumSquares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sumSquares / float64(len(values)))
+}
+
+// calculatePercentile returns the pth percentile of the values, interpolating
+// linearly between the closest ranks
+func calculatePercentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
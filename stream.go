@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// streamRecord is the NDJSON record -stream writes for every issue
+type streamRecord struct {
+	Key      string  `json:"key"`
+	Category string  `json:"category"`
+	Mana     float64 `json:"mana"`
+	Team     string  `json:"team"`  // Empty if the issue has no team
+	Month    string  `json:"month"` // Month of the resolution, YYYY-MM
+}
+
+// issueStream writes a record for every issue as soon as it is fetched,
+// before the report is built. Monthly fetches write from several goroutines.
+// A nil stream writes nothing.
+type issueStream struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	opts  classifyOptions
+	after time.Time // Issues resolved at or before are skipped, as -since-last-run drops them
+	err   error
+}
+
+// newIssueStream creates a stream writing NDJSON records to w, classified
+// with opts
+func newIssueStream(w io.Writer, opts classifyOptions) *issueStream {
+	return &issueStream{enc: json.NewEncoder(w), opts: opts}
+}
+
+// write streams the records of the issues the report will analyze. The
+// first write error is kept for Err and later writes are dropped.
+func (s *issueStream) write(issues []Issue) {
+	if s == nil {
+		return
+	}
+	if !s.after.IsZero() {
+		issues = issuesResolvedAfter(issues, s.after)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, issue := range issues {
+		if s.err != nil {
+			return
+		}
+		if s.opts.excluded(issue) {
+			continue
+		}
+		s.err = s.enc.Encode(streamRecord{
+			Key:      issue.Key,
+			Category: classifyIssue(issue, s.opts),
+			Mana:     issue.Mana,
+			Team:     issue.Team,
+			Month:    issue.Resolved.Format("2006-01"),
+		})
+	}
+}
+
+// page writes a page of search results, as a pageFunc
+func (s *issueStream) page(page []jira.Issue, fetched, total int) {
+	if s != nil {
+		s.write(issuesFromJira(page))
+	}
+}
+
+// Err returns the first error writing the stream
+func (s *issueStream) Err() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Table styles accepted by -table-style
+const (
+	tableStylePlain    = "plain"
+	tableStyleMarkdown = "markdown"
+	tableStyleBox      = "box"
+)
+
+// validateTableStyle checks the -table-style flag value. Box-drawing
+// characters are not in the PDF font, so box tables are text only.
+func validateTableStyle(style, format string) error {
+	switch style {
+	case tableStylePlain, tableStyleMarkdown:
+		return nil
+	case tableStyleBox:
+		if format == "pdf" {
+			return fmt.Errorf("table style box cannot be used with -format pdf")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown table style %q, expected plain, markdown or box", style)
+}
+
+// tableColumn is the header and layout of a table column
+type tableColumn struct {
+	Header   string
+	Right    bool // Right-align, for numbers
+	MaxWidth int  // Longer cells are truncated with an ellipsis, 0 for no limit
+}
+
+// textTable is a table of text cells. Column widths fit the widest cell,
+// measured in terminal columns so wide characters keep the columns aligned.
+type textTable struct {
+	columns []tableColumn
+	rows    [][]string
+	footer  [][]string // Rows below a separator, such as totals
+}
+
+// newTextTable returns an empty table with the columns
+func newTextTable(columns ...tableColumn) *textTable {
+	return &textTable{columns: columns}
+}
+
+// addRow adds a row to the table body
+func (t *textTable) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// addFooter adds a row below the table body
+func (t *textTable) addFooter(cells ...string) {
+	t.footer = append(t.footer, cells)
+}
+
+// write draws the table in the style
+func (t *textTable) write(w io.Writer, style string) {
+	// Truncate and escape the cells, then measure the columns
+	cell := func(row []string, i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		s := row[i]
+		if limit := t.columns[i].MaxWidth; limit > 0 {
+			s = truncateWidth(s, limit)
+		}
+		if style == tableStyleMarkdown {
+			s = strings.ReplaceAll(s, "|", `\|`)
+		}
+		return s
+	}
+	prepare := func(rows [][]string) [][]string {
+		out := make([][]string, len(rows))
+		for r, row := range rows {
+			out[r] = make([]string, len(t.columns))
+			for i := range t.columns {
+				out[r][i] = cell(row, i)
+			}
+		}
+		return out
+	}
+	headers := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		headers[i] = c.Header
+	}
+	rows := prepare(t.rows)
+	footer := prepare(t.footer)
+
+	widths := make([]int, len(t.columns))
+	for _, row := range append(append([][]string{headers}, rows...), footer...) {
+		for i, s := range row {
+			if n := stringWidth(s); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if style == tableStyleMarkdown {
+		// The delimiter row needs room for at least "--:"
+		for i := range widths {
+			if widths[i] < 3 {
+				widths[i] = 3
+			}
+		}
+	}
+
+	line := func(row []string, left, sep, right string) {
+		cells := make([]string, len(row))
+		for i, s := range row {
+			cells[i] = padWidth(s, widths[i], t.columns[i].Right)
+		}
+		fmt.Fprintln(w, strings.TrimRight(left+strings.Join(cells, sep)+right, " "))
+	}
+	rule := func(left, fill, sep, right string) {
+		parts := make([]string, len(widths))
+		for i, n := range widths {
+			parts[i] = strings.Repeat(fill, n+2)
+		}
+		fmt.Fprintln(w, left+strings.Join(parts, sep)+right)
+	}
+
+	switch style {
+	case tableStyleMarkdown:
+		// Markdown tables must not touch the text around them
+		fmt.Fprintln(w)
+		line(headers, "| ", " | ", " |")
+		parts := make([]string, len(widths))
+		for i, n := range widths {
+			if t.columns[i].Right {
+				parts[i] = strings.Repeat("-", n-1) + ":"
+			} else {
+				parts[i] = strings.Repeat("-", n)
+			}
+		}
+		fmt.Fprintln(w, "| "+strings.Join(parts, " | ")+" |")
+		for _, row := range append(rows, footer...) {
+			line(row, "| ", " | ", " |")
+		}
+		fmt.Fprintln(w)
+	case tableStyleBox:
+		rule("┌", "─", "┬", "┐")
+		line(headers, "│ ", " │ ", " │")
+		rule("├", "─", "┼", "┤")
+		for _, row := range rows {
+			line(row, "│ ", " │ ", " │")
+		}
+		if len(footer) > 0 {
+			rule("├", "─", "┼", "┤")
+			for _, row := range footer {
+				line(row, "│ ", " │ ", " │")
+			}
+		}
+		rule("└", "─", "┴", "┘")
+	default:
+		total := 2 * (len(widths) - 1)
+		for _, n := range widths {
+			total += n
+		}
+		line(headers, "", "  ", "")
+		fmt.Fprintln(w, strings.Repeat("-", total))
+		for _, row := range rows {
+			line(row, "", "  ", "")
+		}
+		if len(footer) > 0 {
+			fmt.Fprintln(w, strings.Repeat("-", total))
+			for _, row := range footer {
+				line(row, "", "  ", "")
+			}
+		}
+	}
+}
+
+// runeWidth returns the number of terminal columns a rune takes: 0 for
+// combining and format characters, 2 for East Asian wide characters and
+// emoji, 1 otherwise
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), unicode.Is(unicode.Cf, r):
+		return 0
+	case r >= 0x1100 && r <= 0x115F, // Hangul Jamo
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F, // CJK, Kana, Yi
+		r >= 0xAC00 && r <= 0xD7A3,                // Hangul syllables
+		r >= 0xF900 && r <= 0xFAFF,                // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F,                // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60,                // Fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F, // Emoji
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD: // CJK extensions
+		return 2
+	}
+	return 1
+}
+
+// stringWidth returns the number of terminal columns s takes
+func stringWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// truncateWidth shortens s to at most limit terminal columns, ending it with
+// an ellipsis if anything was cut
+func truncateWidth(s string, limit int) string {
+	if stringWidth(s) <= limit {
+		return s
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if n+rw > limit-1 {
+			break
+		}
+		b.WriteRune(r)
+		n += rw
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+// padWidth pads s with spaces to width terminal columns, on the left if
+// right is set
+func padWidth(s string, width int, right bool) string {
+	pad := strings.Repeat(" ", max(0, width-stringWidth(s)))
+	if right {
+		return pad + s
+	}
+	return s + pad
+}
+
+// numberFormat is how tables print mana, statistics and counts
+type numberFormat struct {
+	Precision int    // Digits after the decimal point
+	TrimWhole bool   // Print numbers that round to whole numbers without decimals
+	Thousands string // Separator between groups of three digits, empty for none
+	ManaUnits string // How mana is printed: points, or time for approximate engineer time
+}
+
+// defaultNumberFormat prints two decimals without separators
+var defaultNumberFormat = numberFormat{Precision: 2}
+
+// decimal formats a mana value or statistic
+func (f numberFormat) decimal(v float64) string {
+	s := strconv.FormatFloat(v, 'f', f.Precision, 64)
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	if hasFrac && f.TrimWhole && strings.Trim(frac, "0") == "" {
+		hasFrac = false
+	}
+	s = groupThousands(intPart, f.Thousands)
+	if hasFrac {
+		s += "." + frac
+	}
+	return s
+}
+
+// Mana units accepted by -mana-units
+const (
+	manaUnitsPoints = "points"
+	manaUnitsTime   = "time"
+)
+
+// Mana in engineer time. The Mana Spent options are sized in hours: Large
+// (~1 day) is 8 and XX-Large (~1 week) is 40.
+const (
+	engineerDayMana  = 8
+	engineerWeekMana = 40
+)
+
+// mana formats a mana value, as points or as approximate engineer time
+func (f numberFormat) mana(v float64) string {
+	if f.ManaUnits != manaUnitsTime {
+		return f.decimal(v)
+	}
+	return engineerTime(v, f.Thousands)
+}
+
+// manaAmount formats a mana value for prose, e.g. "12.00 mana" or
+// "~1.5 engineer-days"
+func (f numberFormat) manaAmount(v float64) string {
+	if f.ManaUnits != manaUnitsTime {
+		return f.decimal(v) + " mana"
+	}
+	return engineerTime(v, f.Thousands)
+}
+
+// statistic formats a value of the statistic: counts as numbers, the others
+// as mana
+func (f numberFormat) statistic(s Statistic, v float64) string {
+	if s.Key == "count" {
+		return f.decimal(v)
+	}
+	return f.mana(v)
+}
+
+// engineerTime formats mana as engineer time in the largest unit it makes
+// at least one of, rounded to half units, e.g. "~3.5 engineer-days". Exact
+// amounts have no tilde.
+func engineerTime(v float64, thousands string) string {
+	unit, size := "hour", 1.0
+	switch abs := math.Abs(v); {
+	case abs >= engineerWeekMana:
+		unit, size = "week", engineerWeekMana
+	case abs >= engineerDayMana:
+		unit, size = "day", engineerDayMana
+	}
+	n := math.Round(math.Abs(v)/size*2) / 2
+	whole, half, _ := strings.Cut(strconv.FormatFloat(n, 'f', -1, 64), ".")
+	s := groupThousands(whole, thousands)
+	if half != "" {
+		s += "." + half
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	s += " engineer-" + unit
+	if n*size != math.Abs(v) {
+		s = "~" + s
+	}
+	if v < 0 && n != 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// count formats a number of issues
+func (f numberFormat) count(n int) string {
+	return groupThousands(strconv.Itoa(n), f.Thousands)
+}
+
+// groupThousands inserts sep between groups of three digits of an integer
+func groupThousands(digits, sep string) string {
+	sign := ""
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if sep == "" || len(digits) <= 3 {
+		return sign + digits
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// Share columns accepted by -shares
+const (
+	sharesMana  = "mana"
+	sharesCount = "count"
+	sharesBoth  = "both"
+)
+
+// tableOptions is the layout of the tables in text reports
+type tableOptions struct {
+	Style   string
+	Numbers numberFormat
+	Shares  string // Whether shares are of mana, of issue counts or both
+}
+
+// defaultTableOptions are plain tables with two decimals and mana shares
+var defaultTableOptions = tableOptions{Style: tableStylePlain, Numbers: defaultNumberFormat, Shares: sharesMana}
+
+// manaShares reports whether tables show shares of mana
+func (o tableOptions) manaShares() bool {
+	return o.Shares != sharesCount
+}
+
+// countShares reports whether tables show shares of issue counts
+func (o tableOptions) countShares() bool {
+	return o.Shares == sharesCount || o.Shares == sharesBoth
+}
+
+// tableFlags are the table layout flags shared by the report commands
+type tableFlags struct {
+	style     *string
+	precision *int
+	trimWhole *bool
+	thousands *string
+	shares    *string
+	manaUnits *string
+}
+
+// defineTableFlags defines the table layout flags on the command line
+func defineTableFlags() *tableFlags {
+	return &tableFlags{
+		style:     flag.String("table-style", tableStylePlain, "Table style: plain, markdown or box"),
+		precision: flag.Int("precision", defaultNumberFormat.Precision, "Digits after the decimal point of mana and statistics"),
+		trimWhole: flag.Bool("trim-whole", false, "Print whole mana values and statistics without decimals"),
+		thousands: flag.String("thousands-sep", "", "Separator between groups of three digits, e.g. \",\""),
+		shares:    flag.String("shares", sharesMana, "Share columns by mana, by issue count or both: mana, count or both"),
+		manaUnits: flag.String("mana-units", manaUnitsPoints, "How mana is printed: points, or time for approximate engineer time like ~3.5 engineer-days"),
+	}
+}
+
+// options checks the parsed flags and returns the table layout for reports
+// in the format
+func (f *tableFlags) options(format string) (tableOptions, error) {
+	if githubActions && !flagPassed("table-style") {
+		// Job summaries render markdown tables
+		*f.style = tableStyleMarkdown
+	}
+	if err := validateTableStyle(*f.style, format); err != nil {
+		return tableOptions{}, err
+	}
+	if *f.precision < 0 || *f.precision > 6 {
+		return tableOptions{}, fmt.Errorf("-precision must be between 0 and 6")
+	}
+	switch *f.shares {
+	case sharesMana, sharesCount, sharesBoth:
+	default:
+		return tableOptions{}, fmt.Errorf("unknown shares %q, expected mana, count or both", *f.shares)
+	}
+	switch *f.manaUnits {
+	case manaUnitsPoints, manaUnitsTime:
+	default:
+		return tableOptions{}, fmt.Errorf("unknown mana units %q, expected points or time", *f.manaUnits)
+	}
+	return tableOptions{
+		Style:  *f.style,
+		Shares: *f.shares,
+		Numbers: numberFormat{
+			Precision: *f.precision,
+			TrimWhole: *f.trimWhole,
+			Thousands: *f.thousands,
+			ManaUnits: *f.manaUnits,
+		},
+	}, nil
+}
+
+// flagPassed reports whether the flag was given on the command line
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// ticketFields are the fields the ticket analysis reads
+var ticketFields = []string{"issuetype", manaFieldID, "resolutiondate", teamFieldID, "labels", "issuelinks", epicLinkFieldID, "parent", "reporter"}
+
+// ticketOptions are the analysis options of the ticket command
+type ticketOptions struct {
+	Classify classifyOptions
+	Teams    bool
+	OrgChart []OrgGroup // Rolls the teams up into groups and orgs
+	Monthly  bool
+	Start    time.Time
+	End      time.Time
+	Stats    []Statistic
+	// SamplePages are the issues fetched from every sampled result page, in
+	// order, when the issues are a page sample
+	SamplePages []int
+}
+
+// analyzeTickets classifies and aggregates the issues into a report. When
+// the issues are a random sample of totalIssues, counts and totals are
+// scaled up to the full population; pass 0 otherwise.
+func analyzeTickets(issues []Issue, opts ticketOptions, totalIssues int) *Report {
+	// Initialize the aggregator with the enabled breakdowns
+	agg := NewAggregator()
+	var dimensions []Dimension
+	if opts.Teams {
+		// Teams are added as we find them
+		dimensions = append(dimensions, teamDimension)
+		if len(opts.OrgChart) > 0 {
+			group, org := orgChartDimensions(opts.OrgChart)
+			dimensions = append(dimensions, group, org)
+		}
+	}
+	if opts.Monthly {
+		// Create a group for each month in the date range
+		months := monthsInRange(opts.Start, opts.End)
+		dimensions = append(dimensions, monthDimension(months))
+		for _, m := range months {
+			agg.AddGroups("month", m.Format(monthLabelFormat))
+		}
+	}
+
+	report := &Report{Stats: opts.Stats, EpicLess: &EpicLessWork{}}
+	if len(opts.Classify.ResearchTypes) > 0 {
+		report.Research = &ResearchInvestment{Mode: opts.Classify.Research}
+	}
+
+	if len(opts.Classify.AutomationReporters) > 0 {
+		report.Automation = &AutomationExclusion{Reporters: make(map[string]int)}
+	}
+
+	// Sampled issues are also counted per result page, the unit of the sample
+	var pages []samplePage
+	var pageOf []int
+	if totalIssues > 0 {
+		pages, pageOf = splitSamplePages(len(issues), opts.SamplePages)
+	}
+
+	for i, issue := range issues {
+		if opts.Classify.isAutomation(issue) {
+			report.Automation.add(issue)
+			continue
+		}
+		if opts.Classify.isResearch(issue) {
+			report.Research.Issues++
+			report.Research.Mana += issue.Mana
+		}
+		if opts.Classify.excluded(issue) {
+			continue
+		}
+		if issue.Parent == "" {
+			report.EpicLess.Issues++
+			report.EpicLess.Mana += issue.Mana
+		}
+		category := classifyIssue(issue, opts.Classify)
+		if pages != nil {
+			pages[pageOf[i]].add(category, issue.Mana)
+		}
+		agg.Add(issue, category, issue.Mana, dimensions...)
+	}
+
+	// Scale sampled results up to the full population
+	if totalIssues > 0 && len(issues) > 0 {
+		factor := float64(totalIssues) / float64(len(issues))
+		totalPages := totalIssues
+		if len(pages) == len(opts.SamplePages) {
+			totalPages = (totalIssues + searchPageSize - 1) / searchPageSize
+		}
+		report.Sample = newSampleSummary(agg.Overall, pages, len(issues), totalIssues, totalPages)
+		agg.Scale(factor)
+		report.EpicLess.Issues = int(math.Round(float64(report.EpicLess.Issues) * factor))
+		report.EpicLess.Mana *= factor
+		if report.Research != nil {
+			report.Research.Issues = int(math.Round(float64(report.Research.Issues) * factor))
+			report.Research.Mana *= factor
+		}
+		if report.Automation != nil {
+			report.Automation.Issues = int(math.Round(float64(report.Automation.Issues) * factor))
+			report.Automation.Mana *= factor
+		}
+	}
+
+	if opts.Teams {
+		report.Breakdown = "team"
+
+		// Sort teams alphabetically
+		teamGroups := agg.Groups("team")
+		sort.Slice(teamGroups, func(i, j int) bool {
+			return teamGroups[i].Name < teamGroups[j].Name
+		})
+		for _, g := range teamGroups {
+			report.Sections = append(report.Sections, newReportSection(g.Name, analysisResults(g.Analysis, opts.Stats), 0))
+		}
+
+		// Roll the teams up along the org chart
+		if len(opts.OrgChart) > 0 {
+			for _, level := range []struct{ dimension, title string }{{"group", "Group"}, {"org", "Org"}} {
+				groups := agg.Groups(level.dimension)
+				sort.Slice(groups, func(i, j int) bool {
+					return groups[i].Name < groups[j].Name
+				})
+				rollup := ReportRollup{Level: level.title}
+				for _, g := range groups {
+					rollup.Sections = append(rollup.Sections, newReportSection(g.Name, analysisResults(g.Analysis, opts.Stats), 0))
+				}
+				report.Rollups = append(report.Rollups, rollup)
+			}
+		}
+	} else if opts.Monthly {
+		report.Breakdown = "month"
+		for _, g := range agg.Groups("month") {
+			report.Sections = append(report.Sections, newReportSection(g.Name, analysisResults(g.Analysis, opts.Stats), g.ZeroManaCount))
+		}
+	}
+
+	// Calculate statistics for overall analysis, sorted by total mana spent
+	report.Summary = newReportSection("Overall", analysisResults(agg.Overall, opts.Stats), agg.ZeroManaCount())
+	return report
+}
+
+// writeTicketReport writes the ticket report as text tables in the layout
+func writeTicketReport(w io.Writer, report *Report, layout tableOptions) {
+	// Print header information, after any warning about changed or missing
+	// fields
+	writeFieldChanges(w, report.FieldChanges)
+	writeMissingFields(w, report.MissingFields)
+	if report.Keys != nil {
+		fmt.Fprintln(w)
+		writeKeySelection(w, report.Keys)
+	} else {
+		fmt.Fprintf(w, "\nAnalysis Period: %s to %s\n", report.startLabel(), report.End)
+		fmt.Fprintln(w, endBoundaryNote(report.End, report.EndInclusive))
+	}
+	fmt.Fprintf(w, "Project: %s\n", report.Project)
+	fmt.Fprintf(w, "\nJQL Query:\n%s\n", report.JQL)
+	if report.Sample != nil {
+		writeSampleEstimates(w, report.Sample, layout)
+	}
+
+	if report.Breakdown != "" {
+		for _, section := range report.Sections {
+			switch report.Breakdown {
+			case "team":
+				printLevelTable(w, section.Results, "Team", section.Title, &report.Summary, report.Stats, layout)
+			case "month":
+				printAnalysisTable(w, section.Results, fmt.Sprintf("Month: %s", section.Title), report.Stats, layout)
+				// Print zero mana tickets for this month
+				fmt.Fprintf(w, "  Zero Mana Tickets: %d\n", section.ZeroManaCount)
+			}
+		}
+
+		// Print the org chart rollups
+		for _, rollup := range report.Rollups {
+			for _, section := range rollup.Sections {
+				printLevelTable(w, section.Results, rollup.Level, section.Title, &report.Summary, report.Stats, layout)
+			}
+		}
+
+		// Print overall summary
+		fmt.Fprintf(w, "\nOVERALL SUMMARY:\n")
+	}
+
+	printAnalysisTable(w, report.Summary.Results, "", report.Stats, layout)
+	fmt.Fprintf(w, "  Zero Mana Tickets: %d\n", report.Summary.ZeroManaCount)
+	if report.EpicLess != nil {
+		writeEpicLessWork(w, report.EpicLess, report.Summary.TotalMana, layout)
+	}
+	if report.Research != nil {
+		writeResearchInvestment(w, report.Research, report.Summary.TotalMana, layout)
+	}
+	if report.Automation != nil {
+		writeAutomationExclusion(w, report.Automation, layout)
+	}
+
+	if report.ExternalWaits != nil {
+		writeExternalWaits(w, report.ExternalWaits, layout)
+	}
+	if report.Attribution != nil {
+		writeTeamAttribution(w, report.Attribution, layout)
+	}
+	if report.CycleTimes != nil {
+		writeCycleTimes(w, report.CycleTimes, layout)
+	}
+}
+
+// writeResearchInvestment writes the mana spent on research issues and its
+// share of all mana, including excluded research
+func writeResearchInvestment(w io.Writer, r *ResearchInvestment, summaryMana float64, layout tableOptions) {
+	total := summaryMana
+	if r.Mode == researchExclude {
+		total += r.Mana
+	}
+	share := 0.0
+	if total > 0 {
+		share = r.Mana / total * 100
+	}
+	note := ""
+	switch r.Mode {
+	case researchStory:
+		note = ", counted as stories"
+	case researchExclude:
+		note = ", excluded from the tables"
+	}
+	fmt.Fprintf(w, "  Research Investment: %s in %s issues (%.1f%% of all mana%s)\n",
+		layout.Numbers.manaAmount(r.Mana), layout.Numbers.count(r.Issues), share, note)
+}
+
+// writeExternalWaits writes the waits on other teams' blockers per team
+func writeExternalWaits(w io.Writer, waits []TeamWait, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Blocked Issues", Right: true},
+		tableColumn{Header: "Wait Days", Right: true},
+		tableColumn{Header: "Wait Mana-Days", Right: true},
+	)
+	var total TeamWait
+	for _, t := range waits {
+		table.addRow(t.Team, layout.Numbers.count(t.BlockedIssues), fmt.Sprintf("%.1f", t.WaitDays), fmt.Sprintf("%.1f", t.WaitManaDays))
+		total.BlockedIssues += t.BlockedIssues
+		total.WaitDays += t.WaitDays
+		total.WaitManaDays += t.WaitManaDays
+	}
+	table.addFooter("TOTAL", layout.Numbers.count(total.BlockedIssues), fmt.Sprintf("%.1f", total.WaitDays), fmt.Sprintf("%.1f", total.WaitManaDays))
+
+	fmt.Fprintf(w, "\nExternal Dependency Wait:\n")
+	table.write(w, layout.Style)
+}
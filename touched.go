@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// teamFieldName is the name of the Team field in changelogs
+const teamFieldName = "Team"
+
+// defaultTouchStatuses are the statuses a team moves an issue into when it
+// works on it
+const defaultTouchStatuses = "In Progress"
+
+// TeamAttribution compares the mana of a team as final owner of issues with
+// the mana attributed to it for the issues it worked on
+type TeamAttribution struct {
+	Team           string
+	OwnedIssues    int
+	OwnedMana      float64
+	TouchedIssues  int // Issues the team moved into a touch status
+	AttributedMana float64
+}
+
+// TouchedAttribution is the touched-by attribution of a report
+type TouchedAttribution struct {
+	Share float64 // Fraction of the mana of shared issues that goes to the touching teams
+	Teams []TeamAttribution
+}
+
+// parseTouchStatuses parses a comma-separated list of status names
+func parseTouchStatuses(list string) (map[string]bool, error) {
+	statuses := make(map[string]bool)
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			statuses[strings.ToLower(s)] = true
+		}
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("no touch statuses given")
+	}
+	return statuses, nil
+}
+
+// touchingTeams returns the teams that moved the issue into one of the touch
+// statuses, in the order they first did, according to its changelog. The
+// team at each transition is replayed from the Team changes, starting from
+// the team before the first change, or the current team if it never changed.
+func touchingTeams(ji jira.Issue, currentTeam string, statuses map[string]bool) []string {
+	if ji.Changelog == nil {
+		return nil
+	}
+	type entry struct {
+		created time.Time
+		items   []jira.ChangelogItems
+	}
+	var entries []entry
+	for _, history := range ji.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{created, history.Items})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].created.Before(entries[j].created)
+	})
+
+	team := currentTeam
+	found := false
+	for _, e := range entries {
+		for _, item := range e.items {
+			if item.Field == teamFieldName && !found {
+				team = item.FromString
+				found = true
+			}
+		}
+	}
+
+	var teams []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		// A team change in the same history applies to its transition
+		for _, item := range e.items {
+			if item.Field == teamFieldName {
+				team = item.ToString
+			}
+		}
+		for _, item := range e.items {
+			if item.Field == "status" && statuses[strings.ToLower(item.ToString)] && team != "" && !seen[team] {
+				seen[team] = true
+				teams = append(teams, team)
+			}
+		}
+	}
+	return teams
+}
+
+// attributeMana splits the mana of an issue between its final owner and the
+// teams that touched it. The owner keeps 1-share of the mana and share is
+// split evenly between the touching teams, the owner included if it touched
+// the issue. If no other team touched the issue the owner keeps all of it.
+func attributeMana(owner string, touched []string, mana, share float64) map[string]float64 {
+	others := false
+	for _, t := range touched {
+		if t != owner {
+			others = true
+		}
+	}
+	if !others {
+		return map[string]float64{owner: mana}
+	}
+
+	split := map[string]float64{owner: mana * (1 - share)}
+	for _, t := range touched {
+		split[t] += mana * share / float64(len(touched))
+	}
+	return split
+}
+
+// fetchTeamAttribution fetches the changelogs of the issues and compares
+// each team's final-owner mana with its touched-by attribution, sorted by
+// attributed mana
+func fetchTeamAttribution(client *jira.Client, changelogs *changelogCache, issues []Issue, statuses map[string]bool, share float64) ([]TeamAttribution, error) {
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	withChangelog, err := fetchChangelogs(client, changelogs, keys)
+	if err != nil {
+		return nil, fmt.Errorf("fetching changelogs: %w", err)
+	}
+	histories := make(map[string]jira.Issue, len(withChangelog))
+	for _, ji := range withChangelog {
+		histories[ji.Key] = ji
+	}
+
+	byTeam := make(map[string]*TeamAttribution)
+	team := func(name string) *TeamAttribution {
+		if name == "" {
+			name = "No Team"
+		}
+		if byTeam[name] == nil {
+			byTeam[name] = &TeamAttribution{Team: name}
+		}
+		return byTeam[name]
+	}
+	for _, issue := range issues {
+		owner := team(issue.Team)
+		owner.OwnedIssues++
+		owner.OwnedMana += issue.Mana
+
+		touched := touchingTeams(histories[issue.Key], issue.Team, statuses)
+		for _, t := range touched {
+			team(t).TouchedIssues++
+		}
+		for name, mana := range attributeMana(issue.Team, touched, issue.Mana, share) {
+			team(name).AttributedMana += mana
+		}
+	}
+
+	attribution := make([]TeamAttribution, 0, len(byTeam))
+	for _, t := range byTeam {
+		attribution = append(attribution, *t)
+	}
+	sort.Slice(attribution, func(i, j int) bool {
+		if attribution[i].AttributedMana != attribution[j].AttributedMana {
+			return attribution[i].AttributedMana > attribution[j].AttributedMana
+		}
+		return attribution[i].Team < attribution[j].Team
+	})
+	return attribution, nil
+}
+
+// writeTeamAttribution writes the final-owner and touched-by mana per team
+func writeTeamAttribution(w io.Writer, attribution *TouchedAttribution, layout tableOptions) {
+	table := newTextTable(
+		tableColumn{Header: "Team", MaxWidth: 30},
+		tableColumn{Header: "Owned Issues", Right: true},
+		tableColumn{Header: "Owned Mana", Right: true},
+		tableColumn{Header: "Touched Issues", Right: true},
+		tableColumn{Header: "Attributed Mana", Right: true},
+		tableColumn{Header: "Diff", Right: true},
+	)
+	var total TeamAttribution
+	for _, t := range attribution.Teams {
+		table.addRow(t.Team,
+			layout.Numbers.count(t.OwnedIssues),
+			layout.Numbers.mana(t.OwnedMana),
+			layout.Numbers.count(t.TouchedIssues),
+			layout.Numbers.mana(t.AttributedMana),
+			layout.Numbers.mana(t.AttributedMana-t.OwnedMana))
+		total.OwnedIssues += t.OwnedIssues
+		total.OwnedMana += t.OwnedMana
+		total.AttributedMana += t.AttributedMana
+	}
+	table.addFooter("TOTAL", layout.Numbers.count(total.OwnedIssues), layout.Numbers.mana(total.OwnedMana), "",
+		layout.Numbers.mana(total.AttributedMana), "")
+
+	fmt.Fprintf(w, "\nTouched-by Attribution:\n")
+	table.write(w, layout.Style)
+	fmt.Fprintf(w, "Owned is the final Team of the issues. Issues other teams worked on give %.0f%% of their mana to the teams that touched them.\n", attribution.Share*100)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// version is the release of the binary, set at build time with
+// -ldflags "-X main.version=v1.4.0"; builds from source are "dev"
+var version = "dev"
+
+// defaultReleasesURL is the GitHub API endpoint of the latest release,
+// overridden by THEIA_RELEASES_URL for mirrors
+const defaultReleasesURL = "https://api.github.com/repos/jwilander/theia/releases/latest"
+
+// checksumsAsset is the release asset listing the SHA-256 of every binary
+const checksumsAsset = "checksums.txt"
+
+// release is a GitHub release as the releases API returns it
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the named asset, empty if the release
+// has none
+func (r *release) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// binaryAsset is the name of the release binary for this platform, e.g.
+// theia_linux_amd64 or theia_windows_amd64.exe
+func binaryAsset() string {
+	name := fmt.Sprintf("theia_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// packageManager returns the package manager that installed the executable
+// and its upgrade command, empty if it was installed by hand. Package
+// managers track the files they install, so those binaries are upgraded
+// through them instead of replaced.
+func packageManager(executable string) (name, upgrade string) {
+	path := filepath.ToSlash(strings.ToLower(executable))
+	switch {
+	case strings.Contains(path, "/cellar/") || strings.Contains(path, "/homebrew/"):
+		return "Homebrew", "brew upgrade theia"
+	case strings.Contains(path, "/scoop/apps/"):
+		return "scoop", "scoop update theia"
+	}
+	return "", ""
+}
+
+// download fetches the URL into memory
+func download(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// latestRelease fetches the latest release from the releases API
+func latestRelease(client *http.Client, url string) (*release, error) {
+	b, err := download(client, url)
+	if err != nil {
+		return nil, fmt.Errorf("looking up the latest release: %w", err)
+	}
+	r := new(release)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("decoding the latest release: %w", err)
+	}
+	if r.Tag == "" {
+		return nil, fmt.Errorf("the latest release has no tag")
+	}
+	return r, nil
+}
+
+// releaseChecksum finds the SHA-256 of the named asset in a checksums file
+// of "<sha256>  <name>" lines
+func releaseChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", checksumsAsset, name)
+}
+
+// replaceExecutable swaps the running executable for the new binary. The new
+// binary is written next to it first, so a failed download or write never
+// leaves a broken executable behind. Windows can't overwrite a running
+// executable, so the old one is moved aside to a .old file instead.
+func replaceExecutable(executable string, binary []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".theia-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), executable)
+}
+
+func runSelfUpdateCommand() {
+	check := flag.Bool("check", false, "Only report whether a newer release is available")
+	force := flag.Bool("force", false, "Install the latest release even if it is the running version")
+	flag.Parse()
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Error locating the executable: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	url := os.Getenv("THEIA_RELEASES_URL")
+	if url == "" {
+		url = defaultReleasesURL
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	latest, err := latestRelease(client, url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Installed: %s\nLatest:    %s\n", version, latest.Tag)
+	if latest.Tag == version && !*force {
+		fmt.Println("theia is up to date.")
+		return
+	}
+	if *check {
+		fmt.Println("A different release is available, run self-update to install it.")
+		return
+	}
+	if manager, upgrade := packageManager(executable); manager != "" {
+		log.Fatalf("theia was installed with %s, run %s instead", manager, upgrade)
+	}
+
+	name := binaryAsset()
+	binaryURL, checksumsURL := latest.assetURL(name), latest.assetURL(checksumsAsset)
+	if binaryURL == "" {
+		log.Fatalf("Release %s has no binary for %s/%s (%s)", latest.Tag, runtime.GOOS, runtime.GOARCH, name)
+	}
+	if checksumsURL == "" {
+		log.Fatalf("Release %s has no %s to verify the binary with", latest.Tag, checksumsAsset)
+	}
+	checksums, err := download(client, checksumsURL)
+	if err != nil {
+		log.Fatalf("Error downloading checksums: %v", err)
+	}
+	want, err := releaseChecksum(checksums, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Downloading %s\n", name)
+	binary, err := download(client, binaryURL)
+	if err != nil {
+		log.Fatalf("Error downloading %s: %v", name, err)
+	}
+	sum := sha256.Sum256(binary)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		log.Fatalf("Checksum mismatch for %s: expected %s, got %s; nothing was installed", name, want, got)
+	}
+
+	if err := replaceExecutable(executable, binary); err != nil {
+		log.Fatalf("Error replacing %s: %v", executable, err)
+	}
+	fmt.Printf("Updated %s to %s\n", executable, latest.Tag)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// warmStartSlack is how much earlier than the previous search a warm start
+// looks for updated issues, covering clock skew and edits made while it ran
+const warmStartSlack = 15 * time.Minute
+
+// warmStartEntry is the issues a -warm-start run analyzed, cached for the
+// next run of the project to start from
+type warmStartEntry struct {
+	Fetched time.Time         `json:"fetched"` // When the issues were searched
+	Start   string            `json:"start"`
+	End     string            `json:"end"`
+	Issues  []json.RawMessage `json:"issues"`
+}
+
+// warmStartKey identifies the cached issues of a project, whatever the
+// range, with the fields requested
+func warmStartKey(projectKey string, fields []string) string {
+	return cacheKey("warm-start "+projectKey, fields)
+}
+
+// loadWarmStart returns the issues cached under the key, if present
+func (c *issueCache) loadWarmStart(key string) (*warmStartEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	b, err := readCacheFile(c.dir, key)
+	if err != nil {
+		return nil, false
+	}
+	entry := new(warmStartEntry)
+	if err := json.Unmarshal(b, entry); err != nil || entry.Fetched.IsZero() {
+		return nil, false
+	}
+	return entry, true
+}
+
+// storeWarmStart saves the issues under the key. Failures are reported but
+// not returned, as the next run then only fetches every issue again.
+func (c *issueCache) storeWarmStart(key string, entry warmStartEntry) {
+	if c == nil {
+		return
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Printf("Warning: could not encode cache entry: %v\n", err)
+		return
+	}
+	writeCacheFile(c.dir, key, b)
+}
+
+// fetchWarmIssues returns the issues of the filter, which selects the
+// project's issues resolved in the range, starting from the issues the
+// previous -warm-start run of the project cached. Only issues updated since
+// that run, or resolved on or after its end day, are fetched; cached issues
+// updated since without matching the filter left the range and are dropped.
+// If the result doesn't add up to the number of issues Jira counts, e.g.
+// because an issue moved to another project, every issue is fetched. Either
+// way the issues are cached for the next run.
+func fetchWarmIssues(client *jira.Client, cache *issueCache, projectKey, filter string, start, end time.Time, inclusive bool, fields []string) ([]jira.Issue, error) {
+	key := warmStartKey(projectKey, fields)
+	searched := time.Now()
+
+	var issues []json.RawMessage
+	warm := false
+	reason := "no issues cached yet"
+	if entry, ok := cache.loadWarmStart(key); ok && entry.Start <= start.Format("2006-01-02") {
+		merged, updated, err := mergeWarmIssues(client, entry, projectKey, filter, start, end, inclusive, fields)
+		if err != nil {
+			return nil, err
+		}
+		total, err := countIssues(client, filter)
+		if err != nil {
+			return nil, err
+		}
+		if total == len(merged) {
+			fmt.Printf("Warm start: %d issues cached by the run of %s, %d of them fetched as updated since\n",
+				len(merged), entry.Fetched.Local().Format("2006-01-02 15:04"), updated)
+			issues, warm = merged, true
+		} else {
+			reason = fmt.Sprintf("the cached issues add up to %d while Jira counts %d", len(merged), total)
+		}
+	} else if ok {
+		reason = fmt.Sprintf("the cached issues start on %s", entry.Start)
+	}
+	if !warm {
+		pages, err := fetchAllPages(client, filter+`
+			ORDER BY created DESC`, fields)
+		if err != nil {
+			return nil, err
+		}
+		if issues, err = rawPageIssues(pages); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Warm start: fetched all %d issues, as %s\n", len(issues), reason)
+	}
+
+	cache.storeWarmStart(key, warmStartEntry{
+		Fetched: searched,
+		Start:   start.Format("2006-01-02"),
+		End:     end.Format("2006-01-02"),
+		Issues:  issues,
+	})
+	return decodeRawIssues(issues)
+}
+
+// mergeWarmIssues returns the cached issues still in the range, with the
+// issues fetched as updated since the entry's run or resolved on or after
+// its end day in place of their cached copies, and how many were fetched
+func mergeWarmIssues(client *jira.Client, entry *warmStartEntry, projectKey, filter string, start, end time.Time, inclusive bool, fields []string) ([]json.RawMessage, int, error) {
+	minutes := math.Ceil((time.Since(entry.Fetched) + warmStartSlack).Minutes())
+	since := fmt.Sprintf(`updated >= "-%.0fm"`, minutes)
+
+	// Every issue of the project updated since, whether it is still in the
+	// range or not, only by key
+	changedPages, err := fetchAllPages(client, fmt.Sprintf(`project = "%s" AND %s`, projectKey, since), []string{"updated"})
+	if err != nil {
+		return nil, 0, err
+	}
+	changed, err := decodePages(changedPages)
+	if err != nil {
+		return nil, 0, err
+	}
+	left := make(map[string]bool, len(changed))
+	for _, issue := range changed {
+		left[issue.Key] = true
+	}
+
+	// The issues of the range updated since or resolved after the cached ones
+	pages, err := fetchAllPages(client, fmt.Sprintf(`%s AND
+			(%s OR resolutiondate >= "%s")
+			ORDER BY created DESC`, filter, since, entry.End), fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	updated, err := rawPageIssues(pages)
+	if err != nil {
+		return nil, 0, err
+	}
+	fresh := make(map[string]json.RawMessage, len(updated))
+	for _, raw := range updated {
+		issue, err := decodeRawIssue(raw)
+		if err != nil {
+			return nil, 0, err
+		}
+		fresh[issue.Key] = raw
+		delete(left, issue.Key)
+	}
+
+	merged := make([]json.RawMessage, 0, len(entry.Issues)+len(updated))
+	for _, raw := range entry.Issues {
+		issue, err := decodeRawIssue(raw)
+		if err != nil {
+			return nil, 0, err
+		}
+		if f, ok := fresh[issue.Key]; ok {
+			merged = append(merged, f)
+			delete(fresh, issue.Key)
+		} else if !left[issue.Key] && issue.Fields != nil && resolvedInRange(time.Time(issue.Fields.Resolutiondate), start, end, inclusive) {
+			merged = append(merged, raw)
+		}
+	}
+	for _, raw := range updated {
+		issue, _ := decodeRawIssue(raw)
+		if _, ok := fresh[issue.Key]; ok {
+			merged = append(merged, raw)
+		}
+	}
+	return merged, len(updated), nil
+}
+
+// resolvedInRange tells whether a resolution falls in the range as
+// resolvedBetween selects it, with days in the resolution's own time zone as
+// Jira returned it
+func resolvedInRange(resolved, start, end time.Time, inclusive bool) bool {
+	loc := resolved.Location()
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	if resolved.Before(from) {
+		return false
+	}
+	if inclusive {
+		return resolved.Before(to.AddDate(0, 0, 1))
+	}
+	return !resolved.After(to)
+}
+
+// rawPageIssues returns the issues of raw search result pages as raw JSON
+func rawPageIssues(pages []json.RawMessage) ([]json.RawMessage, error) {
+	var issues []json.RawMessage
+	for _, raw := range pages {
+		var page struct {
+			Issues []json.RawMessage `json:"issues"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("decoding search results: %w", err)
+		}
+		issues = append(issues, page.Issues...)
+	}
+	return issues, nil
+}
+
+// decodeRawIssue decodes an issue of a search result
+func decodeRawIssue(raw json.RawMessage) (*jira.Issue, error) {
+	issue := new(jira.Issue)
+	if err := json.Unmarshal(raw, issue); err != nil {
+		return nil, fmt.Errorf("decoding cached issue: %w", err)
+	}
+	return issue, nil
+}
+
+// decodeRawIssues decodes the issues of search results
+func decodeRawIssues(raw []json.RawMessage) ([]jira.Issue, error) {
+	issues := make([]jira.Issue, 0, len(raw))
+	for _, r := range raw {
+		issue, err := decodeRawIssue(r)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, *issue)
+	}
+	return issues, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+var webhookFuncs = template.FuncMap{
+	// json renders a value as a JSON literal, so strings are quoted and escaped
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// renderWebhookPayload renders the report through the given template file
+// and checks the result is JSON. Without a template the payload is the JSON
+// report of the current schema version.
+func renderWebhookPayload(report *Report, templatePath string) ([]byte, error) {
+	if templatePath == "" {
+		return renderReportJSON(report)
+	}
+	b, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading webhook template: %w", err)
+	}
+	text := string(b)
+
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not render valid JSON:\n%s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// sendWebhook posts the rendered report payload to the given URL
+func sendWebhook(url string, report *Report, templatePath string) error {
+	payload, err := renderWebhookPayload(report, templatePath)
+	if err != nil {
+		return err
+	}
+	return postJSON(url, payload)
+}
+
+// postJSON posts a JSON payload to a webhook URL and checks it was accepted
+func postJSON(url string, payload []byte) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", redactWebhookError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, string(body))
+	}
+	return nil
+}